@@ -10,7 +10,7 @@ import (
 	"github.com/timshannon/townsourced/fail"
 )
 
-// ErrNotAdmin is when a user fails a login attempt
+// ErrNotAdmin is when a user doesn't have a site role with permission for an admin action
 var ErrNotAdmin = fail.New("You do not have access.")
 
 // AdminStats holds the stats presented on the admin page
@@ -48,8 +48,9 @@ func AdminStatsGet(who *User, since time.Time) (*AdminStats, error) {
 		since = time.Now().AddDate(0, 0, -180)
 	}
 
-	if !who.Admin {
-		return nil, ErrNotAdmin
+	err = who.checkPermission(permViewStats)
+	if err != nil {
+		return nil, err
 	}
 
 	//TODO: gather these on separate goroutines
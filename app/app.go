@@ -4,11 +4,14 @@
 package app
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"time"
 
+	log "git.townsourced.com/townsourced/logrus"
 	"github.com/timshannon/townsourced/app/email"
 	"github.com/timshannon/townsourced/data"
 )
@@ -23,11 +26,14 @@ var (
 // Config are the config values for
 // starting up the application layer
 type Config struct {
-	HTTPClientTimeout string `json:"httpClientTimeout"`
-	DevMode           bool   `json:"-"`
-	TestMode          bool   `json:"-"`
-	TaskQueueSize     uint   `json:"taskQueueSize"`
-	TaskPollTime      string `json:"taskPollTime"`
+	HTTPClientTimeout string    `json:"httpClientTimeout"`
+	DevMode           bool      `json:"-"`
+	TestMode          bool      `json:"-"`
+	TaskQueueSize     uint      `json:"taskQueueSize"`
+	TaskPollTime      string    `json:"taskPollTime"`
+	LogLevel          string    `json:"logLevel"`
+	LogRetention      string    `json:"logRetention"` // how long to keep log entries in the database, 0 keeps them forever
+	Log               LogConfig `json:"log"`
 }
 
 // DefaultConfig returns the default configuration for the app layer
@@ -36,11 +42,65 @@ func DefaultConfig() *Config {
 		HTTPClientTimeout: "30s",
 		TaskQueueSize:     100,
 		TaskPollTime:      "1m",
+		LogLevel:          "info",
+		LogRetention:      "720h",
+		Log:               defaultLogConfig(),
 	}
 }
 
+// running is the config the app layer was initialized with, updated with any reloaded values
+var running *Config
+
+// CheckConfig validates the app config without initializing the application layer
+func CheckConfig(cfg *Config) []error {
+	var errs []error
+
+	_, err := time.ParseDuration(cfg.HTTPClientTimeout)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Error parsing HTTPClientTimeout duration: %s", err))
+	}
+
+	_, err = time.ParseDuration(cfg.TaskPollTime)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Error parsing TaskPollTime duration: %s", err))
+	}
+
+	if cfg.TaskQueueSize == 0 {
+		errs = append(errs, fmt.Errorf("TaskQueueSize must be greater than 0"))
+	}
+
+	_, err = cfg.Level()
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	_, err = time.ParseDuration(cfg.LogRetention)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Error parsing LogRetention duration: %s", err))
+	}
+
+	errs = append(errs, cfg.Log.check()...)
+
+	return errs
+}
+
+// Level returns the log level to use, dev mode always logs at the debug level
+func (c *Config) Level() (log.Level, error) {
+	if c.DevMode {
+		return log.DebugLevel, nil
+	}
+	lvl, err := log.ParseLevel(c.LogLevel)
+	if err != nil {
+		return lvl, fmt.Errorf("Invalid LogLevel %s", c.LogLevel)
+	}
+	return lvl, nil
+}
+
 // Init initializes the application layer
 func Init(cfg *Config, hostname, siteURL, runningDir string) error {
+	current := *cfg
+	running = &current
+
 	devMode = cfg.DevMode
 	timeout, err := time.ParseDuration(cfg.HTTPClientTimeout)
 	if err != nil {
@@ -66,6 +126,17 @@ func Init(cfg *Config, hostname, siteURL, runningDir string) error {
 		return err
 	}
 
+	retention, err := time.ParseDuration(cfg.LogRetention)
+	if err != nil {
+		return fmt.Errorf("Error parsing LogRetention duration: %s", err)
+	}
+	setLogRetention(retention)
+
+	err = logs.start(cfg.Log)
+	if err != nil {
+		return err
+	}
+
 	err = ensureAnnouncementTown()
 	if err != nil {
 		return err
@@ -83,3 +154,68 @@ func Init(cfg *Config, hostname, siteURL, runningDir string) error {
 
 	return nil
 }
+
+// Reload applies the settings that can be safely changed while running: the log level, log retention and task poll
+// time.
+// The names of any other changed settings are returned, and are left as they are until restart
+func Reload(cfg *Config) ([]string, error) {
+	if running == nil {
+		return nil, errors.New("The application layer has not been initialized")
+	}
+
+	lvl, err := cfg.Level()
+	if err != nil {
+		return nil, err
+	}
+
+	taskPoll, err := time.ParseDuration(cfg.TaskPollTime)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing TaskPollTime duration: %s", err)
+	}
+
+	retention, err := time.ParseDuration(cfg.LogRetention)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing LogRetention duration: %s", err)
+	}
+
+	log.SetLevel(lvl)
+	running.LogLevel = cfg.LogLevel
+
+	if runner != nil {
+		runner.setPollInterval(taskPoll)
+	}
+	running.TaskPollTime = cfg.TaskPollTime
+
+	setLogRetention(retention)
+	running.LogRetention = cfg.LogRetention
+
+	var restart []string
+
+	if cfg.HTTPClientTimeout != running.HTTPClientTimeout {
+		restart = append(restart, "httpClientTimeout")
+	}
+	if cfg.TaskQueueSize != running.TaskQueueSize {
+		restart = append(restart, "taskQueueSize")
+	}
+	if cfg.Log != running.Log {
+		restart = append(restart, "log")
+	}
+
+	return restart, nil
+}
+
+// Shutdown stops the application layer as cleanly as it can before the context is done.  Running tasks are finished
+// or thrown back into the queue, queued emails are sent, and pending log entries are written
+func Shutdown(ctx context.Context) {
+	stopTaskRunner(ctx)
+
+	err := email.Wait(ctx)
+	if err != nil {
+		log.Errorf("Not all queued emails were sent before shutdown: %s", err)
+	}
+
+	err = logs.close(ctx)
+	if err != nil {
+		log.Errorf("Not all log entries were written before shutdown: %s", err)
+	}
+}
@@ -5,6 +5,7 @@ package app_test
 
 import (
 	"bytes"
+	"context"
 	"image"
 	"image/jpeg"
 	"io/ioutil"
@@ -79,29 +80,29 @@ func (t *testData) setup(c *C) {
 	c.Assert(err, Equals, nil)
 
 	//posts submitted to both town1 and town2
-	t.postPub, err = app.PostNew("test published post", "test content", "buysell", app.PostFormatStandard, t.user,
-		[]data.Key{t.town1.Key, t.town2.Key}, nil, data.EmptyUUID, true, true, false)
+	t.postPub, err = app.PostNew(context.Background(), "test published post", "test content", "buysell",
+		app.PostFormatStandard, t.user, []data.Key{t.town1.Key, t.town2.Key}, nil, data.EmptyUUID, true, true, false)
 	c.Assert(err, Equals, nil)
 
-	t.postDraft, err = app.PostNew("test draft post", "test content", "buysell", app.PostFormatStandard, t.user,
-		[]data.Key{t.town1.Key, t.town2.Key}, nil, data.EmptyUUID, true, true, true)
+	t.postDraft, err = app.PostNew(context.Background(), "test draft post", "test content", "buysell",
+		app.PostFormatStandard, t.user, []data.Key{t.town1.Key, t.town2.Key}, nil, data.EmptyUUID, true, true, true)
 	c.Assert(err, Equals, nil)
 
-	t.postClosed, err = app.PostNew("test closed post", "test content", "buysell", app.PostFormatStandard, t.user,
-		[]data.Key{t.town1.Key, t.town2.Key}, nil, data.EmptyUUID, true, true, false)
+	t.postClosed, err = app.PostNew(context.Background(), "test closed post", "test content", "buysell",
+		app.PostFormatStandard, t.user, []data.Key{t.town1.Key, t.town2.Key}, nil, data.EmptyUUID, true, true, false)
 
 	c.Assert(err, Equals, nil)
 	c.Assert(t.postClosed.Close(t.user), Equals, nil)
 	c.Assert(t.postClosed.Update(), Equals, nil)
 
-	t.post1, err = app.PostNew("test post 1", "test content", "buysell", app.PostFormatStandard, t.user,
-		[]data.Key{t.town1.Key, t.town2.Key}, nil, data.EmptyUUID, true, true, false)
+	t.post1, err = app.PostNew(context.Background(), "test post 1", "test content", "buysell", app.PostFormatStandard,
+		t.user, []data.Key{t.town1.Key, t.town2.Key}, nil, data.EmptyUUID, true, true, false)
 	c.Assert(err, Equals, nil)
-	t.post2, err = app.PostNew("test post 2", "test content", "buysell", app.PostFormatStandard, t.user,
-		[]data.Key{t.town1.Key, t.town2.Key}, nil, data.EmptyUUID, true, true, false)
+	t.post2, err = app.PostNew(context.Background(), "test post 2", "test content", "buysell", app.PostFormatStandard,
+		t.user, []data.Key{t.town1.Key, t.town2.Key}, nil, data.EmptyUUID, true, true, false)
 	c.Assert(err, Equals, nil)
-	t.post3, err = app.PostNew("test post 3", "test content", "buysell", app.PostFormatStandard, t.user,
-		[]data.Key{t.town1.Key, t.town2.Key}, nil, data.EmptyUUID, true, true, false)
+	t.post3, err = app.PostNew(context.Background(), "test post 3", "test content", "buysell", app.PostFormatStandard,
+		t.user, []data.Key{t.town1.Key, t.town2.Key}, nil, data.EmptyUUID, true, true, false)
 	c.Assert(err, Equals, nil)
 
 }
@@ -0,0 +1,72 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package app
+
+import (
+	"time"
+
+	"github.com/timshannon/townsourced/data"
+)
+
+// Audit actions
+const (
+	AuditUserSuspend   = "user.suspend"
+	AuditUserBan       = "user.ban"
+	AuditUserReinstate = "user.reinstate"
+	AuditRoleGrant     = "role.grant"
+	AuditRoleRevoke    = "role.revoke"
+	AuditDomainBlock   = "domain.block"
+	AuditDomainUnblock = "domain.unblock"
+	AuditRatingRemove  = "rating.remove"
+)
+
+const auditMaxLimit = 500
+
+// AuditEntry records an action taken by an admin, which can't be changed or removed once it's been made
+type AuditEntry struct {
+	Action  string                 `json:"action"`
+	Who     data.Key               `json:"who"`
+	Target  data.Key               `json:"target,omitempty"` // the user the action was taken against
+	Reason  string                 `json:"reason,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
+	When    time.Time              `json:"when"`
+}
+
+func audit(who data.Key, action string, target data.Key, reason string, details map[string]interface{}) error {
+	return data.AuditInsert(&AuditEntry{
+		Action:  action,
+		Who:     who,
+		Target:  target,
+		Reason:  reason,
+		Details: details,
+		When:    time.Now(),
+	})
+}
+
+// AdminAuditGet retrieves the audit trail newest first, optionally only the actions taken against a specific user
+func AdminAuditGet(who *User, target data.Key, from, limit int) ([]*AuditEntry, error) {
+	err := who.checkPermission(permViewAudit)
+	if err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 || limit > auditMaxLimit {
+		limit = auditMaxLimit
+	}
+
+	if from < 0 {
+		from = 0
+	}
+
+	var entries []*AuditEntry
+	err = data.AuditGet(&entries, target, from, limit)
+	if err == data.ErrNotFound {
+		return []*AuditEntry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
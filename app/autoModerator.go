@@ -0,0 +1,367 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"time"
+
+	"github.com/timshannon/townsourced/data"
+	"github.com/timshannon/townsourced/fail"
+)
+
+const autoModDryRunMaxLimit = 500
+
+// AutoModDryRun is the result of running a proposed set of auto moderator rules against a town's recent posts
+type AutoModDryRun struct {
+	Evaluated int                    `json:"evaluated"` // number of posts the rules were run against
+	Blocked   []*AutoModDryRunResult `json:"blocked"`
+}
+
+// AutoModDryRunResult is a post the proposed auto moderator rules would have acted on
+type AutoModDryRunResult struct {
+	Post      *Post  `json:"post"`
+	Action    string `json:"action"` // reject or hold, or the duplicate rule's action
+	Reason    string `json:"reason"`
+	Moderated bool   `json:"moderated"` // whether the town's current rules auto moderated or held the post
+}
+
+// validateAutoModRegexp checks that an auto moderator expression is valid, isn't too complex to run against every
+// post, and can't match every post
+func validateAutoModRegexp(expr string) error {
+	if len(expr) > townModMaxRegexpLength {
+		return fail.New(fmt.Sprintf("The expression is too long.  The max length is %d", townModMaxRegexpLength),
+			expr)
+	}
+
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		if serr, ok := err.(*syntax.Error); ok {
+			return fail.New(fmt.Sprintf("Invalid expression, %s: %s", serr.Code, serr.Expr), expr)
+		}
+		return fail.NewFromErr(err, expr)
+	}
+
+	prog, err := syntax.Compile(re.Simplify())
+	if err != nil {
+		return fail.NewFromErr(err, expr)
+	}
+
+	if len(prog.Inst) > townModMaxRegexpInst {
+		return fail.New("The expression is too complex, try splitting it into several simpler expressions", expr)
+	}
+
+	if regexp.MustCompile(expr).MatchString("") {
+		return fail.New("The expression matches empty text, so it would match every post", expr)
+	}
+
+	return nil
+}
+
+// validate checks a proposed set of auto moderator rules
+func (a *AutoModerator) validate() error {
+	for _, category := range a.Categories {
+		if !isPostCategory(category) {
+			return fail.New("Invalid Auto Moderation post category", category)
+		}
+	}
+
+	if len(a.RegexpReject) > townModMaxRexexpCount {
+		return fail.New(fmt.Sprintf("Too many expressions.  The max number of expressions is %d",
+			townModMaxRexexpCount))
+	}
+
+	for _, rr := range a.RegexpReject {
+		err := validateAutoModRegexp(rr.Regexp)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(a.Quotas) > townModMaxQuotaCount {
+		return fail.New(fmt.Sprintf("Too many quotas.  The max number of quotas is %d", townModMaxQuotaCount))
+	}
+
+	err := validateSpamThreshold(a.SpamThreshold)
+	if err != nil {
+		return err
+	}
+
+	err = validateMinUserRating(a.MinUserRating)
+	if err != nil {
+		return err
+	}
+
+	for _, rule := range a.Hold {
+		if !isAutoModRule(rule) {
+			return fail.New("Invalid auto moderator rule", rule)
+		}
+	}
+
+	for _, quota := range a.Quotas {
+		if _, ok := quotaPeriods[quota.Period]; !ok {
+			return fail.New("Invalid quota period, must be either day or week", quota.Period)
+		}
+		if quota.Category != "" && !isPostCategory(quota.Category) {
+			return fail.New("Invalid Auto Moderation post category", quota.Category)
+		}
+	}
+
+	if a.Duplicates.Threshold != 0 && a.Duplicates.Action != DuplicateActionReject &&
+		a.Duplicates.Action != DuplicateActionHold && a.Duplicates.Action != DuplicateActionFlag {
+		return fail.New("Invalid duplicate action, must be one of reject, hold, or flag", a.Duplicates.Action)
+	}
+
+	return nil
+}
+
+// AutoModDryRun runs a proposed set of auto moderator rules against the town's most recent posts, and reports which
+// posts they would have acted on and why, without changing anything.  If no rules are passed in, the town's current
+// rules are used
+func (t *Town) AutoModDryRun(ctx context.Context, who *User, rules *AutoModerator, limit int) (*AutoModDryRun, error) {
+	if !t.mod(who).active() {
+		return nil, ErrTownNotMod
+	}
+
+	if rules == nil {
+		rules = &t.AutoModerator
+	}
+
+	err := rules.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 || limit > autoModDryRunMaxLimit {
+		limit = autoModDryRunMaxLimit
+	}
+
+	var posts []*Post
+	err = data.PostGetRecentByTown(&posts, t.Key, limit)
+	if err != nil && err != data.ErrNotFound {
+		return nil, err
+	}
+
+	err = setPostCreators(posts)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := newPostHistory(posts)
+	if err != nil {
+		return nil, err
+	}
+
+	trial := &Town{
+		Key:           t.Key,
+		Name:          t.Name,
+		AutoModerator: *rules,
+		history:       history,
+	}
+
+	run := &AutoModDryRun{
+		Evaluated: len(posts),
+		Blocked:   []*AutoModDryRunResult{},
+	}
+
+	for _, p := range posts {
+		if p.approved(t) {
+			// moderators have already reviewed the post, so the auto moderator isn't run against it
+			continue
+		}
+
+		rule, reason, err := trial.autoModerate(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		action := rules.autoModAction(rule)
+
+		if reason == "" {
+			if p.Fingerprint == "" {
+				// posts published before fingerprinting
+				err = p.setFingerprints()
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			duplicates := history.duplicates(p)
+			p.duplicates = &duplicates
+
+			action = rules.Duplicates.Action
+			reason, err = trial.duplicateReason(p)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if reason == "" {
+			continue
+		}
+
+		moderated := false
+		for i := range p.Moderation {
+			if p.Moderation[i].Town == t.Key && p.Moderation[i].Who == data.EmptyKey {
+				moderated = true
+			}
+		}
+		for i := range p.Held {
+			if p.Held[i].Town == t.Key {
+				moderated = true
+			}
+		}
+
+		run.Blocked = append(run.Blocked, &AutoModDryRunResult{
+			Post:      p,
+			Action:    action,
+			Reason:    reason,
+			Moderated: moderated,
+		})
+	}
+
+	return run, nil
+}
+
+// setPostCreators looks up the creators of all of the posts at once, instead of one at a time as each post is checked
+func setPostCreators(posts []*Post) error {
+	var usernames []data.Key
+	found := make(map[data.Key]bool)
+	for _, p := range posts {
+		if !found[p.Creator] {
+			found[p.Creator] = true
+			usernames = append(usernames, p.Creator)
+		}
+	}
+
+	if len(usernames) == 0 {
+		return nil
+	}
+
+	var users []*User
+	err := data.Users(&users, usernames...)
+	if err == data.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	creators := make(map[data.Key]*User, len(users))
+	for i := range users {
+		creators[users[i].Username] = users[i]
+	}
+
+	for _, p := range posts {
+		if u, ok := creators[p.Creator]; ok {
+			p.creatorUser = u
+		}
+	}
+
+	return nil
+}
+
+// postHistory is the posts each creator published around the time of a set of posts, so quotas and duplicates can be
+// counted for many posts without querying the database for each one
+type postHistory map[data.Key][]*Post
+
+// newPostHistory loads the posts the creators of the passed in posts published in the longest quota or duplicate
+// window before any of them
+func newPostHistory(posts []*Post) (postHistory, error) {
+	history := make(postHistory)
+	if len(posts) == 0 {
+		return history, nil
+	}
+
+	window := postDuplicateWindow
+	for _, period := range quotaPeriods {
+		if period > window {
+			window = period
+		}
+	}
+
+	since := posts[0].postedAt()
+	until := since
+	var usernames []data.Key
+	for _, p := range posts {
+		if p.postedAt().Before(since) {
+			since = p.postedAt()
+		}
+		if p.postedAt().After(until) {
+			until = p.postedAt()
+		}
+		if _, ok := history[p.Creator]; !ok {
+			history[p.Creator] = nil
+			usernames = append(usernames, p.Creator)
+		}
+	}
+
+	var recent []*Post
+	// until is exclusive, and only posts published before each post are counted against it
+	err := data.PostGetByCreators(&recent, usernames, since.Add(-window), until.Add(time.Second))
+	if err == data.ErrNotFound {
+		return history, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range recent {
+		history[p.Creator] = append(history[p.Creator], p)
+	}
+
+	return history, nil
+}
+
+// countInTown counts the posts the post's creator published to the town between since and until, optionally only in
+// a single category, matching data.PostCountByUserInTown
+func (h postHistory) countInTown(p *Post, town data.Key, category string, since, until time.Time) int {
+	count := 0
+	for _, other := range h[p.Creator] {
+		if other.Key == p.Key || other.Published.Before(since) || !other.Published.Before(until) {
+			continue
+		}
+		if category != "" && other.Category != category {
+			continue
+		}
+		for i := range other.TownKeys {
+			if other.TownKeys[i] == town {
+				count++
+				break
+			}
+		}
+	}
+	return count
+}
+
+// duplicates counts the creator's posts in the duplicate window before the post which have the same fingerprint or
+// share an image with it, matching data.PostCountDuplicates
+func (h postHistory) duplicates(p *Post) int {
+	until := p.postedAt()
+	since := until.Add(-postDuplicateWindow)
+
+	count := 0
+	for _, other := range h[p.Creator] {
+		if other.Key == p.Key || other.Published.Before(since) || !other.Published.Before(until) {
+			continue
+		}
+		if other.Fingerprint == p.Fingerprint || sharesImage(other.ImageHashes, p.ImageHashes) {
+			count++
+		}
+	}
+	return count
+}
+
+func sharesImage(a, b []string) bool {
+	for i := range a {
+		for j := range b {
+			if a[i] == b[j] {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,406 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package app_test
+
+import (
+	"context"
+
+	. "git.townsourced.com/townsourced/check"
+	"github.com/timshannon/townsourced/app"
+	"github.com/timshannon/townsourced/data"
+)
+
+// Auto Moderator Test Suite
+type AutoModeratorSuite struct {
+	*testData
+	posts []*app.Post
+}
+
+var _ = Suite(&AutoModeratorSuite{testData: &testData{}})
+
+func (s *AutoModeratorSuite) SetUpTest(c *C) {
+	s.testData.setup(c)
+}
+
+func (s *AutoModeratorSuite) TearDownTest(c *C) {
+	for i := range s.posts {
+		s.deletePost(c, s.posts[i])
+	}
+	s.posts = nil
+	s.testData.teardown(c)
+}
+
+func (s *AutoModeratorSuite) newPost(c *C, category string) *app.Post {
+	return s.newPostContent(c, category, "test content")
+}
+
+func (s *AutoModeratorSuite) newPostContent(c *C, category, content string) *app.Post {
+	post, err := app.PostNew(context.Background(), "test auto moderated post", content, category,
+		app.PostFormatStandard, s.user, []data.Key{s.town1.Key}, nil, data.EmptyUUID, true, true, false)
+	c.Assert(err, Equals, nil)
+	s.posts = append(s.posts, post)
+	return post
+}
+
+func moderatedIn(post *app.Post, town *app.Town) bool {
+	for i := range post.Moderation {
+		if post.Moderation[i].Town == town.Key {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *AutoModeratorSuite) TestTrusted(c *C) {
+	c.Assert(s.town1.AddAutoModTrusted(s.other, s.user.Username), Equals, app.ErrTownNotMod)
+
+	c.Assert(s.town1.SetAutoModMinUserDays(s.moderator, 100), Equals, nil)
+	c.Assert(s.town1.Update(), Equals, nil)
+
+	c.Assert(moderatedIn(s.newPost(c, "buysell"), s.town1), Equals, true)
+
+	c.Assert(s.town1.AddAutoModTrusted(s.moderator, s.user.Username), Equals, nil)
+	c.Assert(s.town1.Update(), Equals, nil)
+
+	c.Assert(moderatedIn(s.newPost(c, "buysell"), s.town1), Equals, false)
+
+	// auto moderated users can't also be trusted
+	c.Assert(s.town1.AddAutoModUser(s.moderator, s.other.Username), Equals, nil)
+	c.Assert(s.town1.AddAutoModTrusted(s.moderator, s.other.Username), Not(Equals), nil)
+
+	c.Assert(s.town1.RemoveAutoModTrusted(s.moderator, s.user.Username), Equals, nil)
+	c.Assert(s.town1.Update(), Equals, nil)
+
+	c.Assert(moderatedIn(s.newPost(c, "buysell"), s.town1), Equals, true)
+
+	entries, err := s.town1.Log(s.moderator, app.TownLogAutoModTrusted, 0, 10)
+	c.Assert(err, Equals, nil)
+	c.Assert(len(entries), Equals, 2)
+
+	// auto moderating a trusted user stops trusting them
+	c.Assert(s.town1.SetAutoModMinUserDays(s.moderator, 0), Equals, nil)
+	c.Assert(s.town1.AddAutoModTrusted(s.moderator, s.user.Username), Equals, nil)
+	c.Assert(s.town1.AddAutoModUser(s.moderator, s.user.Username), Equals, nil)
+	c.Assert(s.town1.Update(), Equals, nil)
+	c.Assert(s.town1.AutoModerator.Trusted, HasLen, 0)
+
+	c.Assert(moderatedIn(s.newPost(c, "buysell"), s.town1), Equals, true)
+}
+
+func (s *AutoModeratorSuite) TestQuota(c *C) {
+	c.Assert(s.town1.SetAutoModQuota(s.other, "", app.QuotaPeriodDay, 10), Equals, app.ErrTownNotMod)
+	c.Assert(s.town1.SetAutoModQuota(s.moderator, "", "month", 10), Not(Equals), nil)
+	c.Assert(s.town1.SetAutoModQuota(s.moderator, "badcategory", app.QuotaPeriodDay, 10), Not(Equals), nil)
+	c.Assert(s.town1.SetAutoModQuota(s.moderator, "", app.QuotaPeriodDay, 0), Not(Equals), nil)
+
+	// the test user has already published several posts to the town today
+	c.Assert(s.town1.SetAutoModQuota(s.moderator, "", app.QuotaPeriodDay, 20), Equals, nil)
+	c.Assert(s.town1.SetAutoModQuota(s.moderator, "jobs", app.QuotaPeriodWeek, 1), Equals, nil)
+	c.Assert(s.town1.Update(), Equals, nil)
+
+	c.Assert(moderatedIn(s.newPost(c, "buysell"), s.town1), Equals, false)
+	c.Assert(moderatedIn(s.newPost(c, "jobs"), s.town1), Equals, false)
+	c.Assert(moderatedIn(s.newPost(c, "jobs"), s.town1), Equals, true)
+
+	// setting an existing quota replaces it
+	c.Assert(s.town1.SetAutoModQuota(s.moderator, "", app.QuotaPeriodDay, 1), Equals, nil)
+	c.Assert(len(s.town1.AutoModerator.Quotas), Equals, 2)
+	c.Assert(s.town1.Update(), Equals, nil)
+
+	post := s.newPost(c, "buysell")
+	c.Assert(moderatedIn(post, s.town1), Equals, true)
+	c.Assert(post.Moderation[0].Reason, Matches, ".*at most 1 post\\(s\\) per user per day.*")
+
+	c.Assert(s.town1.RemoveAutoModQuota(s.moderator, "", app.QuotaPeriodDay), Equals, nil)
+	c.Assert(s.town1.RemoveAutoModQuota(s.moderator, "", app.QuotaPeriodDay), Not(Equals), nil)
+	c.Assert(len(s.town1.AutoModerator.Quotas), Equals, 1)
+}
+
+func (s *AutoModeratorSuite) TestDuplicates(c *C) {
+	c.Assert(s.town1.SetAutoModDuplicates(s.other, 1, app.DuplicateActionFlag), Equals, app.ErrTownNotMod)
+	c.Assert(s.town1.SetAutoModDuplicates(s.moderator, 1, "delete"), Not(Equals), nil)
+
+	c.Assert(s.town1.SetAutoModDuplicates(s.moderator, 1, app.DuplicateActionFlag), Equals, nil)
+	c.Assert(s.town1.Update(), Equals, nil)
+
+	c.Assert(len(s.newPost(c, "buysell").Flagged), Equals, 0)
+
+	flagged := s.newPost(c, "buysell")
+	c.Assert(moderatedIn(flagged, s.town1), Equals, false)
+	c.Assert(len(flagged.Flagged), Equals, 1)
+
+	entries, err := s.town1.ModQueue(s.moderator, 0, 10)
+	c.Assert(err, Equals, nil)
+	c.Assert(len(entries), Equals, 1)
+	c.Assert(entries[0].Post.Key, Equals, flagged.Key)
+	c.Assert(len(entries[0].Flagged), Equals, 1)
+
+	c.Assert(s.town1.SetAutoModDuplicates(s.moderator, 1, app.DuplicateActionHold), Equals, nil)
+	c.Assert(s.town1.Update(), Equals, nil)
+
+	// changes to case and punctuation are still duplicates
+	post, err := app.PostNew(context.Background(), "Test auto-moderated post!", "Test content.", "buysell",
+		app.PostFormatStandard, s.user, []data.Key{s.town1.Key}, nil, data.EmptyUUID, true, true, false)
+	c.Assert(err, Equals, nil)
+	s.posts = append(s.posts, post)
+	c.Assert(moderatedIn(post, s.town1), Equals, false)
+	c.Assert(post.Status, Equals, app.PostStatusPending)
+	c.Assert(len(post.Held), Equals, 1)
+
+	c.Assert(s.town1.SetAutoModDuplicates(s.moderator, 1, app.DuplicateActionReject), Equals, nil)
+	c.Assert(s.town1.Update(), Equals, nil)
+
+	_, err = app.PostNew(context.Background(), "test auto moderated post", "test content", "buysell",
+		app.PostFormatStandard, s.user, []data.Key{s.town1.Key}, nil, data.EmptyUUID, true, true, false)
+	c.Assert(err, Not(Equals), nil)
+
+	// trusted users can repost
+	c.Assert(s.town1.AddAutoModTrusted(s.moderator, s.user.Username), Equals, nil)
+	c.Assert(s.town1.Update(), Equals, nil)
+	c.Assert(moderatedIn(s.newPost(c, "buysell"), s.town1), Equals, false)
+}
+
+func (s *AutoModeratorSuite) TestHold(c *C) {
+	c.Assert(s.town1.SetAutoModAction(s.other, app.AutoModRuleCategories, app.AutoModActionHold), Equals,
+		app.ErrTownNotMod)
+	c.Assert(s.town1.SetAutoModAction(s.moderator, "colors", app.AutoModActionHold), Not(Equals), nil)
+	c.Assert(s.town1.SetAutoModAction(s.moderator, app.AutoModRuleCategories, "delete"), Not(Equals), nil)
+
+	c.Assert(s.town1.AddAutoModCategory(s.moderator, "jobs"), Equals, nil)
+	c.Assert(s.town1.SetAutoModAction(s.moderator, app.AutoModRuleCategories, app.AutoModActionHold), Equals, nil)
+	c.Assert(s.town1.Update(), Equals, nil)
+
+	post := s.newPost(c, "jobs")
+	c.Assert(moderatedIn(post, s.town1), Equals, false)
+	c.Assert(post.Status, Equals, app.PostStatusPending)
+	c.Assert(len(post.Held), Equals, 1)
+	c.Assert(post.Held[0].Town, Equals, s.town1.Key)
+
+	// pending posts are only visible to their creator and the town's moderators
+	for _, u := range []*app.User{nil, s.other} {
+		visible, err := post.Visible(u)
+		c.Assert(err, Equals, nil)
+		c.Assert(visible, Equals, false)
+	}
+	for _, u := range []*app.User{s.user, s.moderator} {
+		visible, err := post.Visible(u)
+		c.Assert(err, Equals, nil)
+		c.Assert(visible, Equals, true)
+	}
+
+	entries, err := s.town1.ModQueue(s.moderator, 0, 10)
+	c.Assert(err, Equals, nil)
+	c.Assert(len(entries), Equals, 1)
+	c.Assert(entries[0].Post.Key, Equals, post.Key)
+	c.Assert(len(entries[0].Held), Equals, 1)
+
+	c.Assert(post.Approve(s.town1, s.moderator), Equals, nil)
+	c.Assert(post.Update(), Equals, nil)
+	c.Assert(post.Status, Equals, app.PostStatusPublished)
+	c.Assert(len(post.Held), Equals, 0)
+
+	entries, err = s.town1.ModQueue(s.moderator, 0, 10)
+	c.Assert(err, Equals, nil)
+	c.Assert(len(entries), Equals, 0)
+
+	// rejecting a held post moderates it
+	rejected := s.newPost(c, "jobs")
+	c.Assert(rejected.Moderate(s.town1, s.moderator, "no jobs"), Equals, nil)
+	c.Assert(rejected.Update(), Equals, nil)
+	c.Assert(rejected.Status, Equals, app.PostStatusPublished)
+	c.Assert(moderatedIn(rejected, s.town1), Equals, true)
+
+	c.Assert(s.town1.SetAutoModAction(s.moderator, app.AutoModRuleCategories, app.AutoModActionReject), Equals, nil)
+	c.Assert(s.town1.Update(), Equals, nil)
+	c.Assert(len(s.town1.AutoModerator.Hold), Equals, 0)
+	c.Assert(moderatedIn(s.newPost(c, "jobs"), s.town1), Equals, true)
+
+	logEntries, err := s.town1.Log(s.moderator, app.TownLogAutoModHold, 0, 10)
+	c.Assert(err, Equals, nil)
+	c.Assert(len(logEntries), Equals, 2)
+}
+
+func (s *AutoModeratorSuite) TestSpamThreshold(c *C) {
+	c.Assert(s.town1.SetAutoModSpamThreshold(s.other, 0.9), Equals, app.ErrTownNotMod)
+	c.Assert(s.town1.SetAutoModSpamThreshold(s.moderator, 0.3), Not(Equals), nil)
+	c.Assert(s.town1.SetAutoModSpamThreshold(s.moderator, 1), Not(Equals), nil)
+
+	c.Assert(s.town1.SetAutoModSpamThreshold(s.moderator, 0.9), Equals, nil)
+	c.Assert(s.town1.Update(), Equals, nil)
+	c.Assert(s.town1.AutoModerator.SpamThreshold, Equals, 0.9)
+
+	// without enough moderated posts to train a model, the classifier doesn't moderate anything
+	c.Assert(moderatedIn(s.newPostContent(c, "buysell", "cheap pills, buy now"), s.town1), Equals, false)
+
+	c.Assert(s.town1.SetAutoModSpamThreshold(s.moderator, 0), Equals, nil)
+	c.Assert(s.town1.Update(), Equals, nil)
+
+	logEntries, err := s.town1.Log(s.moderator, app.TownLogAutoModSpamThreshold, 0, 10)
+	c.Assert(err, Equals, nil)
+	c.Assert(len(logEntries), Equals, 2)
+}
+
+func (s *AutoModeratorSuite) TestRegexpValidation(c *C) {
+	c.Assert(s.town1.AddAutoModRegexp(s.moderator, "(spam", "spam"), ErrorMatches, ".*missing closing \\).*")
+	c.Assert(s.town1.AddAutoModRegexp(s.moderator, "a*", "spam"), ErrorMatches, ".*matches empty text.*")
+	c.Assert(s.town1.AddAutoModRegexp(s.moderator, "(foo|bar){1000}", "spam"), Not(Equals), nil)
+	c.Assert(len(s.town1.AutoModerator.RegexpReject), Equals, 0)
+
+	c.Assert(s.town1.AddAutoModRegexp(s.moderator, "(?i)spam", "spam"), Equals, nil)
+	c.Assert(len(s.town1.AutoModerator.RegexpReject), Equals, 1)
+}
+
+func (s *AutoModeratorSuite) TestDryRun(c *C) {
+	_, err := s.town1.AutoModDryRun(context.Background(), s.other, nil, 10)
+	c.Assert(err, Equals, app.ErrTownNotMod)
+
+	rules := s.town1.AutoModerator
+	rules.RegexpReject = []app.RegexpReason{{Regexp: "(published", Reason: "no published posts"}}
+	_, err = s.town1.AutoModDryRun(context.Background(), s.moderator, &rules, 10)
+	c.Assert(err, Not(Equals), nil)
+
+	rules.RegexpReject = []app.RegexpReason{{Regexp: "published", Reason: "no published posts"}}
+	run, err := s.town1.AutoModDryRun(context.Background(), s.moderator, &rules, 10)
+	c.Assert(err, Equals, nil)
+	c.Assert(run.Evaluated > 0, Equals, true)
+	c.Assert(len(run.Blocked), Equals, 1)
+	c.Assert(run.Blocked[0].Post.Key, Equals, s.postPub.Key)
+	c.Assert(run.Blocked[0].Reason, Equals, "no published posts")
+	c.Assert(run.Blocked[0].Moderated, Equals, false)
+
+	// the town's rules aren't changed
+	c.Assert(len(s.town1.AutoModerator.RegexpReject), Equals, 0)
+
+	run, err = s.town1.AutoModDryRun(context.Background(), s.moderator, nil, 10)
+	c.Assert(err, Equals, nil)
+	c.Assert(len(run.Blocked), Equals, 0)
+
+	// quotas are counted from each post's publish time, the test user has published several posts today
+	rules = s.town1.AutoModerator
+	rules.Quotas = []app.PostQuota{{Period: app.QuotaPeriodDay, Max: 1}}
+	run, err = s.town1.AutoModDryRun(context.Background(), s.moderator, &rules, 10)
+	c.Assert(err, Equals, nil)
+	c.Assert(len(run.Blocked) > 0, Equals, true)
+	c.Assert(run.Blocked[0].Reason, Equals, "This town allows at most 1 post(s) per user per day.  Please try again "+
+		"later.")
+}
+
+func (s *AutoModeratorSuite) TestLinkDomains(c *C) {
+	c.Assert(s.town1.AddAutoModDomain(s.other, "scam.com", false), Equals, app.ErrTownNotMod)
+	c.Assert(s.town1.AddAutoModDomain(s.moderator, "not a domain", false), Not(Equals), nil)
+
+	c.Assert(s.town1.AddAutoModDomain(s.moderator, "https://www.scam.com/deals", false), Equals, nil)
+	c.Assert(s.town1.AutoModerator.BlockedDomains, DeepEquals, []string{"scam.com"})
+	c.Assert(s.town1.AddAutoModDomain(s.moderator, "scam.com", true), Not(Equals), nil)
+	c.Assert(s.town1.Update(), Equals, nil)
+
+	post := s.newPostContent(c, "buysell", "Great deals at [our shop](http://shop.SCAM.com/deal)")
+	c.Assert(moderatedIn(post, s.town1), Equals, true)
+	c.Assert(post.Moderation[0].Reason, Equals, "This town does not allow links to scam.com")
+
+	c.Assert(moderatedIn(s.newPostContent(c, "buysell", "Details at www.example.org"), s.town1), Equals, false)
+
+	c.Assert(s.town1.RemoveAutoModDomain(s.moderator, "scam.com", false), Equals, nil)
+	c.Assert(s.town1.AddAutoModDomain(s.moderator, "district.k12.ca.us", true), Equals, nil)
+	c.Assert(s.town1.Update(), Equals, nil)
+
+	post = s.newPostContent(c, "buysell", "Details at www.example.org")
+	c.Assert(moderatedIn(post, s.town1), Equals, true)
+	c.Assert(post.Moderation[0].Reason, Matches, ".*example\\.org.*")
+	c.Assert(moderatedIn(s.newPostContent(c, "buysell", "See https://www.district.k12.ca.us/calendar"), s.town1),
+		Equals, false)
+	c.Assert(moderatedIn(s.newPostContent(c, "buysell", "No links here"), s.town1), Equals, false)
+}
+
+func (s *AutoModeratorSuite) TestBlockedDomains(c *C) {
+	c.Assert(app.AdminBlockDomain(s.other, "evil.com", "phishing"), Equals, app.ErrNotAdmin)
+
+	// copies, so the shared test user keeps its roles
+	reviewer := *s.other
+	reviewer.Roles = []string{app.RoleReviewer}
+	admin := *s.other
+	admin.Roles = []string{app.RoleAdmin}
+
+	// the site wide list is only maintained by admins
+	c.Assert(app.AdminBlockDomain(&reviewer, "evil.com", "phishing"), Equals, app.ErrNotAdmin)
+	_, err := app.AdminBlockedDomainsGet(&reviewer)
+	c.Assert(err, Equals, app.ErrNotAdmin)
+
+	c.Assert(app.AdminBlockDomain(&admin, "evil.com", ""), Not(Equals), nil)
+	c.Assert(app.AdminBlockDomain(&admin, "http://login.evil.com", "phishing"), Equals, nil)
+	defer func() {
+		c.Assert(app.AdminUnblockDomain(&admin, "evil.com", "test cleanup"), Equals, nil)
+	}()
+
+	blocked, err := app.AdminBlockedDomainsGet(&admin)
+	c.Assert(err, Equals, nil)
+	found := false
+	for i := range blocked {
+		if blocked[i].Domain == "evil.com" {
+			found = true
+		}
+	}
+	c.Assert(found, Equals, true)
+
+	// trusted users can't link to domains blocked site wide
+	c.Assert(s.town1.AddAutoModTrusted(s.moderator, s.user.Username), Equals, nil)
+	c.Assert(s.town1.Update(), Equals, nil)
+
+	post := s.newPostContent(c, "buysell", "Log in at https://login.evil.com")
+	c.Assert(moderatedIn(post, s.town1), Equals, true)
+	c.Assert(post.Moderation[0].Reason, Equals, "Links to evil.com are not allowed on townsourced")
+
+	_, err = app.CommentNew(context.Background(), s.user, s.postPub, "Log in at https://login.evil.com")
+	c.Assert(err, ErrorMatches, ".*evil\\.com.*")
+}
+
+func (s *AutoModeratorSuite) TestCommentRules(c *C) {
+	c.Assert(s.town1.AddCommentModUser(s.other, s.other.Username), Equals, app.ErrTownNotMod)
+	c.Assert(s.town1.AddCommentModRegexp(s.moderator, "a*", "spam"), Not(Equals), nil)
+
+	c.Assert(s.town1.AddCommentModUser(s.moderator, s.other.Username), Equals, nil)
+	c.Assert(s.town1.AddCommentModRegexp(s.moderator, "(?i)spam", "no spam"), Equals, nil)
+	// post rules don't apply to comments
+	c.Assert(s.town1.AddAutoModRegexp(s.moderator, "(?i)eggs", "no eggs"), Equals, nil)
+	c.Assert(s.town1.Update(), Equals, nil)
+
+	post, err := app.PostGet(s.postPub.Key)
+	c.Assert(err, Equals, nil)
+
+	_, err = app.CommentNew(context.Background(), s.other, post, "Test comment")
+	c.Assert(err, Not(Equals), nil)
+
+	_, err = app.CommentNew(context.Background(), s.user, post, "Buy SPAM")
+	c.Assert(err, ErrorMatches, "no spam")
+
+	comment, err := app.CommentNew(context.Background(), s.user, post, "Green eggs")
+	c.Assert(err, Equals, nil)
+
+	_, err = comment.Reply(context.Background(), s.user, "and spam")
+	c.Assert(err, ErrorMatches, "no spam")
+
+	c.Assert(s.town1.AddAutoModTrusted(s.moderator, s.user.Username), Equals, nil)
+	c.Assert(s.town1.Update(), Equals, nil)
+
+	post, err = app.PostGet(s.postPub.Key)
+	c.Assert(err, Equals, nil)
+	_, err = app.CommentNew(context.Background(), s.user, post, "Buy SPAM")
+	c.Assert(err, Equals, nil)
+
+	// trusted users can still be blocked from commenting
+	c.Assert(s.town1.AddCommentModUser(s.moderator, s.user.Username), Equals, nil)
+	c.Assert(s.town1.Update(), Equals, nil)
+	post, err = app.PostGet(s.postPub.Key)
+	c.Assert(err, Equals, nil)
+	_, err = app.CommentNew(context.Background(), s.user, post, "Test comment")
+	c.Assert(err, Not(Equals), nil)
+	c.Assert(s.town1.RemoveCommentModUser(s.moderator, s.user.Username), Equals, nil)
+
+	c.Assert(s.town1.RemoveCommentModRegexp(s.moderator, "(?i)spam"), Equals, nil)
+	c.Assert(s.town1.RemoveCommentModRegexp(s.moderator, "(?i)spam"), Not(Equals), nil)
+
+	entries, err := s.town1.Log(s.moderator, app.TownLogCommentModRegexpReject, 0, 10)
+	c.Assert(err, Equals, nil)
+	c.Assert(len(entries), Equals, 1)
+}
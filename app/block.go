@@ -0,0 +1,84 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package app
+
+import (
+	"fmt"
+
+	"github.com/timshannon/townsourced/data"
+	"github.com/timshannon/townsourced/fail"
+)
+
+const userMaxBlocked = 1000
+
+var (
+	// ErrUserBlockSelf is when a user tries to block themselves
+	ErrUserBlockSelf = fail.New("You can't block yourself")
+	// ErrCommentBlocked is when a user tries to comment on the post of a user who has blocked them
+	ErrCommentBlocked = fail.New("You can't comment on this post")
+)
+
+// Block adds a user to the user's block list.  Blocked users' messages, mentions and replies aren't delivered to the
+// user, their comments are hidden from the user, and they can't comment on the user's posts
+func (u *User) Block(username data.Key) error {
+	if username == u.Username {
+		return ErrUserBlockSelf
+	}
+
+	if u.blocks(username) {
+		return nil
+	}
+
+	if len(u.Blocked) >= userMaxBlocked {
+		return fail.New(fmt.Sprintf("You can block at most %d users, you'll need to unblock some before you can "+
+			"block more", userMaxBlocked))
+	}
+
+	_, err := UserGet(username)
+	if err != nil {
+		return err
+	}
+
+	u.Blocked = append(u.Blocked, username)
+	return nil
+}
+
+// Unblock removes a user from the user's block list
+func (u *User) Unblock(username data.Key) {
+	for i := range u.Blocked {
+		if u.Blocked[i] == username {
+			u.Blocked = append(u.Blocked[:i], u.Blocked[i+1:]...)
+			return
+		}
+	}
+}
+
+// blocks is whether or not the user has blocked the passed in user
+func (u *User) blocks(username data.Key) bool {
+	if u == nil {
+		return false
+	}
+	for i := range u.Blocked {
+		if u.Blocked[i] == username {
+			return true
+		}
+	}
+	return false
+}
+
+// hideBlocked hides the text of comments from users the passed in user has blocked.  The comments are kept so their
+// replies stay in place
+func (c *Comment) hideBlocked(who *User) {
+	if who.blocks(c.Username) {
+		c.Blocked = true
+		c.Comment = ""
+	}
+}
+
+func (t *CommentTree) hideBlocked(who *User) {
+	t.Comment.hideBlocked(who)
+	for i := range t.Children {
+		t.Children[i].hideBlocked(who)
+	}
+}
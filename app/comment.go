@@ -4,8 +4,10 @@
 package app
 
 import (
+	"context"
 	"math"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/timshannon/townsourced/data"
@@ -20,11 +22,19 @@ type Comment struct {
 	Parent   data.UUID `json:"parent,omitempty" gorethink:",omitempty"`
 	Username data.Key  `json:"username,omitempty" gorethink:",omitempty"`
 	Comment  string    `json:"comment,omitempty" gorethink:",omitempty"`
+	Hidden   bool      `json:"hidden,omitempty"`  // hidden when its user is banned
+	Deleted  bool      `json:"deleted,omitempty"` // deleted by its user, but kept so its replies stay in place
+
+	Moderation *Moderated          `json:"moderation,omitempty"` // hidden by a town moderator
+	Reported   map[data.Key]string `json:"-" gorethink:",omitempty"`
+	Queued     bool                `json:"-"`                               // waiting for review in the moderation queue
+	Blocked    bool                `json:"blocked,omitempty" gorethink:"-"` // its user is blocked by who's viewing it
 	data.Version
 
 	post   *Post
 	parent *Comment
 	user   *User
+	modLog townLog
 }
 
 // CommentTree is a tree of comments including their children and a "HasChildren" tag if children exist that
@@ -67,6 +77,24 @@ func (t *CommentTree) setMoreChildren(limit int) {
 	}
 }
 
+// clearHidden clears the text of hidden, moderated and deleted comments, so it isn't shown.  Deleted comments
+// don't show who posted them either
+func (c *Comment) clearHidden() {
+	if c.Hidden || c.Deleted || c.Moderation != nil {
+		c.Comment = ""
+	}
+	if c.Deleted {
+		c.Username = data.EmptyKey
+	}
+}
+
+func (t *CommentTree) clearHidden() {
+	t.Comment.clearHidden()
+	for i := range t.Children {
+		t.Children[i].clearHidden()
+	}
+}
+
 // CommentGet retrieves  a single comment
 func CommentGet(key data.UUID) (*Comment, error) {
 	c := &Comment{}
@@ -74,12 +102,24 @@ func CommentGet(key data.UUID) (*Comment, error) {
 	if err != nil {
 		return nil, err
 	}
+	c.clearHidden()
 
 	return c, nil
 }
 
+// Update updates the comment.  The text of a comment is never cleared by an update, so comments retrieved with
+// their text cleared for display can be safely updated
+func (c *Comment) Update() error {
+	err := data.CommentUpdate(c, c.Key)
+	if err != nil {
+		return err
+	}
+
+	return c.modLog.write()
+}
+
 // CommentGetTree retrieves  a single comment and it's child comments
-func CommentGetTree(key data.UUID, limit int, sort string) (*CommentTree, error) {
+func CommentGetTree(who *User, key data.UUID, limit int, sort string) (*CommentTree, error) {
 	c := &CommentTree{}
 	limit = int(math.Min(math.Max(float64(1), float64(limit)), float64(commentMaxRetrieve)))
 
@@ -88,12 +128,15 @@ func CommentGetTree(key data.UUID, limit int, sort string) (*CommentTree, error)
 		return nil, err
 	}
 	c.setMoreChildren(limit)
+	c.clearHidden()
+	c.hideBlocked(who)
 
 	return c, nil
 }
 
 // CommentsGet retrieves comments from a given post or parent comment
-func CommentsGet(post *Post, parent *Comment, from, limit int, sort string) (comments []CommentTree, more bool, err error) {
+func CommentsGet(who *User, post *Post, parent *Comment, from, limit int, sort string) (comments []CommentTree,
+	more bool, err error) {
 
 	limit = int(math.Min(math.Max(float64(1), float64(limit)), float64(commentMaxRetrieve)))
 	from = int(math.Max(0, float64(from)))
@@ -112,6 +155,8 @@ func CommentsGet(post *Post, parent *Comment, from, limit int, sort string) (com
 	}
 	for i := range comments {
 		comments[i].setMoreChildren(limit)
+		comments[i].clearHidden()
+		comments[i].hideBlocked(who)
 	}
 
 	more = len(comments) > limit
@@ -123,11 +168,16 @@ func CommentsGet(post *Post, parent *Comment, from, limit int, sort string) (com
 }
 
 // CommentNew adds a new root comment on a post
-func CommentNew(who *User, post *Post, comment string) (*Comment, error) {
+func CommentNew(ctx context.Context, who *User, post *Post, comment string) (*Comment, error) {
 	if who == nil {
 		return nil, ErrCommentNoUser
 	}
 
+	err := who.checkSuspended()
+	if err != nil {
+		return nil, err
+	}
+
 	c := &Comment{
 		PostKey:  post.Key,
 		Parent:   post.Key,
@@ -137,7 +187,7 @@ func CommentNew(who *User, post *Post, comment string) (*Comment, error) {
 		user:     who,
 	}
 
-	err := c.validate()
+	err = c.validate(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -150,11 +200,16 @@ func CommentNew(who *User, post *Post, comment string) (*Comment, error) {
 }
 
 // Reply relies to a specific comment
-func (c *Comment) Reply(who *User, comment string) (*Comment, error) {
+func (c *Comment) Reply(ctx context.Context, who *User, comment string) (*Comment, error) {
 	if who == nil {
 		return nil, ErrCommentNoUser
 	}
 
+	err := who.checkSuspended()
+	if err != nil {
+		return nil, err
+	}
+
 	reply := &Comment{
 		PostKey:  c.PostKey,
 		Parent:   c.Key,
@@ -163,7 +218,7 @@ func (c *Comment) Reply(who *User, comment string) (*Comment, error) {
 		user:     who,
 	}
 
-	err := reply.validate()
+	err = reply.validate(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -208,6 +263,10 @@ func (c *Comment) notifyReply() error {
 		return err
 	}
 
+	if u.blocks(c.Username) {
+		return nil
+	}
+
 	msgData := struct {
 		To      *User
 		Post    *Post
@@ -239,7 +298,7 @@ func (c *Comment) notifyReply() error {
 
 }
 
-func (c *Comment) validate() error {
+func (c *Comment) validate(ctx context.Context) error {
 	if c.Comment == "" {
 		return ErrCommentEmpty
 	}
@@ -270,6 +329,70 @@ func (c *Comment) validate() error {
 		return ErrCommentPostNotPublished
 	}
 
+	creator, err := c.post.creator()
+	if err != nil {
+		return err
+	}
+
+	if creator.blocks(c.Username) {
+		return ErrCommentBlocked
+	}
+
+	err = c.checkLinks()
+	if err != nil {
+		return err
+	}
+
+	return c.checkTowns(ctx)
+}
+
+// checkTowns returns an error with the reason if the comment breaks the comment rules of any of the towns its post
+// is in
+func (c *Comment) checkTowns(ctx context.Context) error {
+	towns, err := c.post.Towns()
+	if err != nil {
+		return err
+	}
+
+	for _, t := range towns {
+		reason, err := t.commentReason(ctx, c)
+		if err != nil {
+			return err
+		}
+		if reason != "" {
+			return fail.New(reason)
+		}
+	}
+
+	return nil
+}
+
+// checkLinks returns an error naming the domain if the comment links to a domain which is blocked site wide, or by
+// any of the towns its post is in
+func (c *Comment) checkLinks() error {
+	towns, err := c.post.Towns()
+	if err != nil {
+		return err
+	}
+
+	if len(towns) == 0 {
+		towns = []*Town{nil}
+	}
+
+	for _, t := range towns {
+		if t != nil && t.trusted(c.Username) {
+			t = nil
+		}
+
+		reason, err := linkReason(t, c.Comment)
+		if err != nil {
+			return err
+		}
+		if reason != "" {
+			return fail.New(reason)
+		}
+	}
+
 	return nil
 }
 
@@ -405,3 +528,151 @@ func (c *Comment) User() (*User, error) {
 
 	return UserGet(c.Username)
 }
+
+// Delete deletes the user's own comment.  The comment is kept as a placeholder without its text or user, so any
+// replies to it stay in place
+func (c *Comment) Delete(who *User) error {
+	if who == nil || c.Deleted || who.Username != c.Username {
+		return fail.New("You can only delete your own comments")
+	}
+
+	err := data.CommentDelete(c.Key)
+	if err != nil {
+		return err
+	}
+
+	c.Deleted = true
+	c.Queued = false
+	c.clearHidden()
+	return nil
+}
+
+// Report reports the comment into the moderation queue of the towns its post is in
+func (c *Comment) Report(who *User, reason string) error {
+	if who == nil {
+		return fail.New("Only logged in users can report a comment, please log in and try again")
+	}
+
+	if reason == "" {
+		return fail.New("You must provide a reason why you think this comment should be moderated")
+	}
+
+	if c.Deleted || c.Moderation != nil {
+		return fail.New("This comment has already been removed")
+	}
+
+	if _, ok := c.Reported[who.Username]; ok {
+		return fail.New("You have already reported this comment.")
+	}
+
+	if c.Reported == nil {
+		c.Reported = make(map[data.Key]string)
+	}
+
+	c.Reported[who.Username] = reason
+	c.Queued = true
+	return nil
+}
+
+// reviewTown returns the first of the comment's post's towns the user can review, or ErrTownNotMod if there are
+// none
+func (c *Comment) reviewTown(who *User) (*Town, error) {
+	post, err := c.Post()
+	if err != nil {
+		return nil, err
+	}
+
+	towns, err := post.Towns()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range towns {
+		if towns[i].canReview(who) {
+			return towns[i], nil
+		}
+	}
+
+	return nil, ErrTownNotMod
+}
+
+// Moderate hides the comment's text with a reason, for a moderator of one of the towns its post is in
+func (c *Comment) Moderate(who *User, reason string) error {
+	town, err := c.reviewTown(who)
+	if err != nil {
+		return err
+	}
+
+	if len(strings.TrimSpace(reason)) == 0 {
+		return fail.New("A reason is required when moderating a comment")
+	}
+
+	if c.Deleted {
+		return fail.New("This comment has already been deleted")
+	}
+
+	before := c.Moderation
+	c.Moderation = &Moderated{
+		Town:   town.Key,
+		Who:    who.Username,
+		Reason: reason,
+	}
+	c.Queued = false
+	c.modLog.add(town.Key, who, TownLogCommentModerate, string(c.Key), before, c.Moderation, reason)
+
+	sub, msg, err := messages.use("msgCommentModerated").Execute(struct {
+		Comment *Comment
+		Town    *Town
+		Reason  string
+	}{
+		Comment: c,
+		Town:    town,
+		Reason:  reason,
+	})
+	if err != nil {
+		return err
+	}
+
+	author, err := c.User()
+	if err != nil {
+		return err
+	}
+
+	return who.SendMessage(author, sub, msg)
+}
+
+// RemoveModeration shows a moderated comment's text again
+func (c *Comment) RemoveModeration(who *User) error {
+	if c.Moderation == nil {
+		return nil
+	}
+
+	town, err := TownGet(c.Moderation.Town)
+	if err != nil {
+		return err
+	}
+
+	if !town.canReview(who) {
+		return ErrTownNotMod
+	}
+
+	c.modLog.add(town.Key, who, TownLogCommentRemoveModeration, string(c.Key), c.Moderation, nil, "")
+	c.Moderation = nil
+	return nil
+}
+
+// Approve takes a reported comment out of the moderation queue, until it's reported again
+func (c *Comment) Approve(who *User) error {
+	town, err := c.reviewTown(who)
+	if err != nil {
+		return err
+	}
+
+	if !c.Queued {
+		return nil
+	}
+
+	c.Queued = false
+	c.modLog.add(town.Key, who, TownLogCommentApprove, string(c.Key), nil, nil, "")
+	return nil
+}
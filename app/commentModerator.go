@@ -0,0 +1,199 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/timshannon/townsourced/data"
+	"github.com/timshannon/townsourced/fail"
+)
+
+// CommentModerator is a town's rules for which comments on its posts are rejected.  They're set separately from the
+// town's post rules, and like them are blacklists.  Links to blocked domains are checked against the town's post
+// rules, and users trusted there are trusted with their comments too
+type CommentModerator struct {
+	MinUserDays  uint           `json:"minUserDays,omitempty"`  //reject if posted by user younger than x days
+	MaxNumLinks  uint           `json:"maxNumLinks,omitempty"`  //reject if has more than x links, 0 allows any number
+	Users        []data.Key     `json:"users,omitempty"`        //reject comments from these users
+	RegexpReject []RegexpReason `json:"regexpReject,omitempty"` //reject if regexp matches with given reason
+}
+
+// commentReason returns why the town's comment rules don't allow the comment, or an empty string if they do
+func (t *Town) commentReason(ctx context.Context, c *Comment) (string, error) {
+	rules := t.CommentModerator
+
+	// checked before trusted users, so trusting a user for their posts doesn't let a blocked user comment
+	for _, u := range rules.Users {
+		if c.Username == u {
+			return fmt.Sprintf("You are not allowed to comment on posts in the town %s.  Contact the town "+
+				"moderator(s) for more information.", t.Name), nil
+		}
+	}
+
+	if t.trusted(c.Username) {
+		return "", nil
+	}
+
+	if rules.MinUserDays > 0 {
+		author, err := c.User()
+		if err != nil {
+			return "", err
+		}
+
+		if author.Created.After(time.Now().AddDate(0, 0, int(rules.MinUserDays)*-1)) {
+			return fmt.Sprintf("The town %s does not allow comments by users whose accounts are younger than %d "+
+				"day(s)", t.Name, rules.MinUserDays), nil
+		}
+	}
+
+	if rules.MaxNumLinks > 0 &&
+		len(rxMDLink.FindAll([]byte(c.Comment), int(rules.MaxNumLinks+1))) > int(rules.MaxNumLinks) {
+		return fmt.Sprintf("The town %s does not allow comments with more than %d links", t.Name,
+			rules.MaxNumLinks), nil
+	}
+
+	for _, rr := range rules.RegexpReject {
+		rxFind, err := regexp.Compile(rr.Regexp)
+		if err != nil {
+			requestLog(ctx).WithField("town", t.Key).WithField("regexp", rr.Regexp).
+				Warn("Skipping invalid comment moderator regexp")
+			continue
+		}
+
+		if rxFind.MatchString(c.Comment) {
+			return rr.Reason, nil
+		}
+	}
+
+	return "", nil
+}
+
+// SetCommentModMinUserDays sets the minimum days old a user must be to comment on posts in this town
+func (t *Town) SetCommentModMinUserDays(who *User, minUserDays uint) error {
+	if !t.mod(who).active() {
+		return ErrTownNotMod
+	}
+	t.logSetting(who, TownLogCommentModMinUserDays, t.CommentModerator.MinUserDays, minUserDays)
+	t.CommentModerator.MinUserDays = minUserDays
+	return nil
+}
+
+// SetCommentModMaxNumLinks sets the maximum number of links allowed in a comment on posts in this town, 0 allows any
+// number
+func (t *Town) SetCommentModMaxNumLinks(who *User, maxNumLinks uint) error {
+	if !t.mod(who).active() {
+		return ErrTownNotMod
+	}
+	t.logSetting(who, TownLogCommentModMaxNumLinks, t.CommentModerator.MaxNumLinks, maxNumLinks)
+	t.CommentModerator.MaxNumLinks = maxNumLinks
+	return nil
+}
+
+// AddCommentModUser adds a user who isn't allowed to comment on posts in this town
+func (t *Town) AddCommentModUser(who *User, username data.Key) error {
+	if !t.mod(who).active() {
+		return ErrTownNotMod
+	}
+
+	_, err := UserGet(username)
+	if err != nil {
+		return err
+	}
+
+	before := append([]data.Key(nil), t.CommentModerator.Users...)
+
+	//remove if exists already
+	t.removeCommentModUser(username)
+
+	t.CommentModerator.Users = append(t.CommentModerator.Users, username)
+	t.modLog.add(t.Key, who, TownLogCommentModUsers, string(username), before,
+		append([]data.Key(nil), t.CommentModerator.Users...), "")
+
+	return nil
+}
+
+// RemoveCommentModUser allows a user to comment on posts in this town again
+func (t *Town) RemoveCommentModUser(who *User, username data.Key) error {
+	if !t.mod(who).active() {
+		return ErrTownNotMod
+	}
+
+	before := append([]data.Key(nil), t.CommentModerator.Users...)
+	if t.removeCommentModUser(username) {
+		t.modLog.add(t.Key, who, TownLogCommentModUsers, string(username), before,
+			append([]data.Key(nil), t.CommentModerator.Users...), "")
+	}
+
+	return nil
+}
+
+func (t *Town) removeCommentModUser(username data.Key) bool {
+	for i := range t.CommentModerator.Users {
+		if t.CommentModerator.Users[i] == username {
+			t.CommentModerator.Users = append(t.CommentModerator.Users[:i], t.CommentModerator.Users[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// AddCommentModRegexp adds a regular expression which rejects the comments it matches with the given reason
+func (t *Town) AddCommentModRegexp(who *User, expr, reason string) error {
+	if !t.mod(who).active() {
+		return ErrTownNotMod
+	}
+
+	if len(t.CommentModerator.RegexpReject) >= townModMaxRexexpCount {
+		return fail.New(fmt.Sprintf("This town has too many comment expressions added, you'll need to remove some "+
+			"before you can add more.  The max number of expressions is %d", townModMaxRexexpCount))
+	}
+
+	err := validateAutoModRegexp(expr)
+	if err != nil {
+		return err
+	}
+
+	for _, rr := range t.CommentModerator.RegexpReject {
+		if rr.Regexp == expr {
+			return fail.New("A comment moderator reason already exists for this expression.", expr)
+		}
+	}
+
+	before := append([]RegexpReason(nil), t.CommentModerator.RegexpReject...)
+
+	t.CommentModerator.RegexpReject = append(t.CommentModerator.RegexpReject, RegexpReason{
+		Regexp: expr,
+		Reason: reason,
+	})
+	t.modLog.add(t.Key, who, TownLogCommentModRegexpReject, expr, before,
+		append([]RegexpReason(nil), t.CommentModerator.RegexpReject...), "")
+
+	return nil
+}
+
+// RemoveCommentModRegexp removes a regular expression from the comment moderator
+func (t *Town) RemoveCommentModRegexp(who *User, expr string) error {
+	if !t.mod(who).active() {
+		return ErrTownNotMod
+	}
+
+	before := append([]RegexpReason(nil), t.CommentModerator.RegexpReject...)
+
+	for i := range t.CommentModerator.RegexpReject {
+		if t.CommentModerator.RegexpReject[i].Regexp == expr {
+			t.CommentModerator.RegexpReject = append(t.CommentModerator.RegexpReject[:i],
+				t.CommentModerator.RegexpReject[i+1:]...)
+			t.modLog.add(t.Key, who, TownLogCommentModRegexpReject, expr, before,
+				append([]RegexpReason(nil), t.CommentModerator.RegexpReject...), "")
+			return nil
+		}
+	}
+
+	return fail.New("No comment moderator reason exists for this expression.", expr)
+}
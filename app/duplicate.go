@@ -0,0 +1,174 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package app
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/timshannon/townsourced/data"
+	"github.com/timshannon/townsourced/fail"
+)
+
+// Actions the auto moderator can take when a post duplicates its creator's recent posts
+const (
+	DuplicateActionReject = "reject" // the post can't be published
+	DuplicateActionHold   = "hold"   // the post is pending until a moderator approves it
+	DuplicateActionFlag   = "flag"   // the post is visible, but shows up in the moderation queue
+)
+
+// how far back a creator's posts are compared when looking for duplicates
+const postDuplicateWindow = 7 * 24 * time.Hour
+
+// DuplicateRule is when and how a town's auto moderator acts on posts which duplicate their creator's recent posts
+// in any town
+type DuplicateRule struct {
+	Threshold uint   `json:"threshold,omitempty"` // number of recent duplicates allowed before acting, 0 is off
+	Action    string `json:"action,omitempty"`
+}
+
+// rxFingerprintStrip matches everything which shouldn't change a post's fingerprint, such as punctuation, markdown
+// and whitespace
+var rxFingerprintStrip = regexp.MustCompile(`[^\pL\pN]+`)
+
+// fingerprint returns a hash of the post's title and content, normalized so reposts with trivial changes to case,
+// spacing or punctuation still match
+func (p *Post) fingerprint() string {
+	normalize := func(s string) string {
+		return strings.TrimSpace(rxFingerprintStrip.ReplaceAllString(strings.ToLower(s), " "))
+	}
+
+	hash := sha1.Sum([]byte(normalize(p.Title) + "\n" + normalize(p.Content)))
+	return hex.EncodeToString(hash[:])
+}
+
+// setFingerprints updates the fingerprint and image hashes the post is compared against other posts with
+func (p *Post) setFingerprints() error {
+	p.Fingerprint = p.fingerprint()
+	p.ImageHashes = nil
+
+	if len(p.Images) == 0 {
+		return nil
+	}
+
+	err := data.ImageGetHashes(&p.ImageHashes, p.Images)
+	if err == data.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// duplicateCount returns the number of the creator's recent posts in any town which have the same fingerprint or
+// share an image with the post
+func (p *Post) duplicateCount() (int, error) {
+	if p.duplicates != nil {
+		return *p.duplicates, nil
+	}
+
+	until := p.postedAt()
+
+	count, err := data.PostCountDuplicates(p.Creator, p.Fingerprint, p.ImageHashes, until.Add(-postDuplicateWindow),
+		until, p.Key)
+	if err != nil {
+		return 0, err
+	}
+
+	p.duplicates = &count
+	return count, nil
+}
+
+// duplicateReason returns why the town's duplicate rule applies to the post, or an empty string if it doesn't
+func (t *Town) duplicateReason(p *Post) (string, error) {
+	rule := t.AutoModerator.Duplicates
+	if rule.Threshold == 0 || p.Status == PostStatusDraft || t.trusted(p.Creator) {
+		return "", nil
+	}
+
+	count, err := p.duplicateCount()
+	if err != nil {
+		return "", err
+	}
+
+	if uint(count) < rule.Threshold {
+		return "", nil
+	}
+
+	return fmt.Sprintf("This post duplicates %d of your recent posts", count), nil
+}
+
+// checkDuplicates runs the town's duplicate rule against the post.  Rejected posts return an error, held posts
+// return the reason to hold them with, and flagged posts are flagged in the town
+func (p *Post) checkDuplicates(t *Town) (string, error) {
+	reason, err := t.duplicateReason(p)
+	if err != nil {
+		return "", err
+	}
+
+	if reason == "" {
+		p.removeFlag(t)
+		return "", nil
+	}
+
+	rule := t.AutoModerator.Duplicates
+
+	switch rule.Action {
+	case DuplicateActionReject:
+		return "", fail.New(fmt.Sprintf("The town %s (%s) does not allow posting the same thing more than %d "+
+			"time(s) a week", t.Name, t.Key, rule.Threshold))
+	case DuplicateActionFlag:
+		p.flag(t, reason)
+		return "", nil
+	default:
+		return reason, nil
+	}
+}
+
+// flag marks the post for review by the town's moderators, while leaving it visible
+func (p *Post) flag(t *Town, reason string) {
+	for i := range p.Flagged {
+		if p.Flagged[i].Town == t.Key {
+			p.Flagged[i].Reason = reason
+			return
+		}
+	}
+
+	p.Flagged = append(p.Flagged, Moderated{
+		Town:   t.Key,
+		Reason: reason,
+	})
+}
+
+func (p *Post) removeFlag(t *Town) {
+	for i := range p.Flagged {
+		if p.Flagged[i].Town == t.Key {
+			p.Flagged = append(p.Flagged[:i], p.Flagged[i+1:]...)
+			return
+		}
+	}
+}
+
+// SetAutoModDuplicates sets how many duplicates of a creator's recent posts are allowed before the auto moderator
+// acts on a post, and what it does.  A threshold of 0 turns duplicate detection off
+func (t *Town) SetAutoModDuplicates(who *User, threshold uint, action string) error {
+	if !t.mod(who).active() {
+		return ErrTownNotMod
+	}
+
+	if action != DuplicateActionReject && action != DuplicateActionHold && action != DuplicateActionFlag {
+		return fail.New("Invalid duplicate action, must be one of reject, hold, or flag", action)
+	}
+
+	rule := DuplicateRule{
+		Threshold: threshold,
+		Action:    action,
+	}
+
+	t.logSetting(who, TownLogAutoModDuplicates, t.AutoModerator.Duplicates, rule)
+	t.AutoModerator.Duplicates = rule
+	return nil
+}
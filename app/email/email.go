@@ -10,7 +10,9 @@ API keys will be stored in the private package with the rest of our keys
 package email
 
 import (
+	"context"
 	"net/mail"
+	"sync"
 
 	log "git.townsourced.com/townsourced/logrus"
 	sg "git.townsourced.com/townsourced/sendgrid-go"
@@ -25,6 +27,8 @@ var DefaultFrom = &mail.Address{
 
 var client *sg.SGClient
 
+var sending sync.WaitGroup
+
 // Init initialized the mail package, creates any need clients
 func Init(testMode bool) error {
 	//create email api client
@@ -47,7 +51,9 @@ func Send(from, to *mail.Address, subject, body string) error {
 
 	// don't wait for email and dont' return email errors to end users
 	if client != nil {
+		sending.Add(1)
 		go func() {
+			defer sending.Done()
 			err := client.Send(mail)
 
 			if err != nil {
@@ -61,3 +67,19 @@ func Send(from, to *mail.Address, subject, body string) error {
 
 	return nil
 }
+
+// Wait waits for all emails currently being sent to finish sending, or until the context is done
+func Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		sending.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -3,12 +3,22 @@
 
 package app
 
-import log "git.townsourced.com/townsourced/logrus"
+import (
+	"context"
+	"time"
+
+	log "git.townsourced.com/townsourced/logrus"
+)
+
+// haltTimeout is how long Halt will wait for the application layer to cleanup before exiting
+const haltTimeout = 10 * time.Second
 
 // Halt cleanups the townsourced app, and shuts it down as clean as
 // possible logging the passed in message, and printing it to the stderr
 func Halt(msg string, a ...interface{}) {
-	//TODO: shutdown cleanup, logging, etc
-	stopTaskRunner()
+	ctx, cancel := context.WithTimeout(context.Background(), haltTimeout)
+	defer cancel()
+
+	Shutdown(ctx)
 	log.Fatalf(msg+"\n", a...)
 }
@@ -0,0 +1,186 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package app
+
+import (
+	"github.com/timshannon/townsourced/data"
+	"github.com/timshannon/townsourced/fail"
+)
+
+// Auto moderator rule types, which can each be set to reject or hold the posts they match
+const (
+	AutoModRuleCategories  = "categories"
+	AutoModRuleUsers       = "users"
+	AutoModRuleMinUserDays = "minUserDays"
+	AutoModRuleMaxNumLinks = "maxNumLinks"
+	AutoModRuleDomains     = "domains"
+	AutoModRuleRegexp      = "regexpReject"
+	AutoModRuleQuotas      = "quotas"
+	AutoModRuleSpam        = "spam"
+
+	AutoModRuleMinUserRating = "minUserRating"
+)
+
+var autoModRules = []string{
+	AutoModRuleCategories,
+	AutoModRuleUsers,
+	AutoModRuleMinUserDays,
+	AutoModRuleMinUserRating,
+	AutoModRuleMaxNumLinks,
+	AutoModRuleDomains,
+	AutoModRuleRegexp,
+	AutoModRuleQuotas,
+	AutoModRuleSpam,
+}
+
+// Actions the auto moderator can take on posts matching one of its rules
+const (
+	AutoModActionReject = "reject" // the post is auto moderated in the town
+	AutoModActionHold   = "hold"   // the post is pending, and only published once the town's moderators approve it
+)
+
+func isAutoModRule(rule string) bool {
+	for i := range autoModRules {
+		if autoModRules[i] == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// autoModAction returns what the auto moderator does with posts matching the passed in rule type
+func (a *AutoModerator) autoModAction(rule string) string {
+	for i := range a.Hold {
+		if a.Hold[i] == rule {
+			return AutoModActionHold
+		}
+	}
+	return AutoModActionReject
+}
+
+// SetAutoModAction sets whether posts matching a type of auto moderator rule are rejected, or held for review
+func (t *Town) SetAutoModAction(who *User, rule, action string) error {
+	if !t.mod(who).active() {
+		return ErrTownNotMod
+	}
+
+	if !isAutoModRule(rule) {
+		return fail.New("Invalid auto moderator rule", rule)
+	}
+
+	if action != AutoModActionReject && action != AutoModActionHold {
+		return fail.New("Invalid auto moderator action, must be either reject or hold", action)
+	}
+
+	if t.AutoModerator.autoModAction(rule) == action {
+		return nil
+	}
+
+	before := append([]string(nil), t.AutoModerator.Hold...)
+	if action == AutoModActionHold {
+		t.AutoModerator.Hold = append(t.AutoModerator.Hold, rule)
+	} else {
+		hold := t.AutoModerator.Hold[:0]
+		for i := range t.AutoModerator.Hold {
+			if t.AutoModerator.Hold[i] != rule {
+				hold = append(hold, t.AutoModerator.Hold[i])
+			}
+		}
+		t.AutoModerator.Hold = hold
+	}
+
+	t.modLog.add(t.Key, who, TownLogAutoModHold, rule, before, append([]string(nil), t.AutoModerator.Hold...), "")
+	return nil
+}
+
+// hold keeps the post from being published until the town's moderators review it
+func (p *Post) hold(t *Town, reason string) {
+	for i := range p.Held {
+		if p.Held[i].Town == t.Key {
+			p.Held[i].Reason = reason
+			return
+		}
+	}
+
+	p.Held = append(p.Held, Moderated{
+		Town:   t.Key,
+		Reason: reason,
+	})
+}
+
+// removeHold removes the town's hold on the post, and returns the removed hold if there was one
+func (p *Post) removeHold(t *Town) *Moderated {
+	for i := range p.Held {
+		if p.Held[i].Town == t.Key {
+			held := p.Held[i]
+			p.Held = append(p.Held[:i], p.Held[i+1:]...)
+			return &held
+		}
+	}
+	return nil
+}
+
+// release publishes a pending post once none of its towns are holding it any longer
+func (p *Post) release() error {
+	if p.Status != PostStatusPending || len(p.Held) != 0 {
+		return nil
+	}
+
+	return p.publish()
+}
+
+// notifyHeld lets the active moderators of each town holding the post know it's waiting for their review, sending
+// only one notification per moderator
+func (p *Post) notifyHeld() error {
+	towns, err := p.Towns()
+	if err != nil {
+		return err
+	}
+
+	mods := make(map[data.Key]struct{})
+
+	for _, t := range towns {
+		var held *Moderated
+		for i := range p.Held {
+			if p.Held[i].Town == t.Key {
+				held = &p.Held[i]
+			}
+		}
+		if held == nil {
+			continue
+		}
+
+		for m := range t.Moderators {
+			if !t.Moderators[m].active() {
+				// towns without active mods are reviewed from the site wide moderation queue
+				continue
+			}
+			username := t.Moderators[m].Username
+			if _, ok := mods[username]; ok {
+				continue
+			}
+
+			sub, msg, err := messages.use("msgPostHeld").Execute(struct {
+				Post   *Post
+				Town   *Town
+				Reason string
+			}{
+				Post:   p,
+				Town:   t,
+				Reason: held.Reason,
+			})
+			if err != nil {
+				return err
+			}
+
+			err = notificationNew(data.EmptyKey, username, sub, msg)
+			if err != nil {
+				return err
+			}
+			mods[username] = struct{}{}
+		}
+	}
+
+	return nil
+}
@@ -5,7 +5,10 @@ package app
 
 import (
 	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"image"
 	"image/gif"
 	"image/jpeg"
@@ -17,7 +20,6 @@ import (
 
 	"git.townsourced.com/townsourced/goexif/exif"
 	"git.townsourced.com/townsourced/imaging"
-	log "git.townsourced.com/townsourced/logrus"
 	"github.com/timshannon/townsourced/data"
 	"github.com/timshannon/townsourced/fail"
 )
@@ -65,7 +67,8 @@ type Image struct {
 
 	// whether or not the image is used by a post or elsewhere
 	// images not in use will be cleaned up by a task
-	InUse bool `json:"-"`
+	InUse bool   `json:"-"`
+	Hash  string `json:"-" gorethink:",omitempty"` // hash of the uploaded image, for finding reposted images
 	data.Version
 
 	Data            []byte `json:"-" gorethink:",omitempty"` // full image
@@ -106,6 +109,9 @@ func imageNew(owner *User, contentType string, imgData []byte, inUse bool) (*Ima
 		InUse:       inUse,
 	}
 
+	hash := sha1.Sum(imgData)
+	i.Hash = hex.EncodeToString(hash[:])
+
 	err := i.validate()
 	if err != nil {
 		return nil, err
@@ -290,8 +296,8 @@ func (i *Image) decode() error {
 	}
 
 	if err != nil {
-		log.WithField("imagekey", i.Key).Error(err)
-		return err
+		// returned to the request that's handling the image, which logs it along with the request's ID
+		return fmt.Errorf("Error decoding image %s: %s", i.Key, err)
 	}
 
 	i.Data = nil
@@ -464,10 +470,13 @@ func (i *Image) ReadSeeker() io.ReadSeeker {
 
 type taskerUnusedImages struct{}
 
+var scheduleUnusedImages = mustParseSchedule("@every 15m jitter 1m")
+
 func (d *taskerUnusedImages) Type() string       { return "DeleteUnusedImages" }
 func (d *taskerUnusedImages) Priority() uint     { return priorityLow }
-func (d *taskerUnusedImages) NextRun() time.Time { return time.Now().Add(15 * time.Minute) }
+func (d *taskerUnusedImages) Schedule() Schedule { return scheduleUnusedImages }
 func (d *taskerUnusedImages) Retry() int         { return -1 }
+func (d *taskerUnusedImages) Concurrency() int   { return 1 }
 func (d *taskerUnusedImages) Do(variables ...interface{}) error {
 	// delete all images that aren't in use an hour after they were last updated
 	err := data.ImageDeleteOrphans(time.Now().Add(-1 * time.Hour))
@@ -0,0 +1,274 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package app
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/timshannon/townsourced/data"
+	"github.com/timshannon/townsourced/fail"
+	"golang.org/x/net/publicsuffix"
+)
+
+var (
+	// rxLink matches absolute urls, and urls starting with www. which markdown renders as links
+	rxLink = regexp.MustCompile(`(?i)\b(?:[a-z][a-z0-9+.\-]*://|www\.)[^\s<>()\[\]"'` + "`" + `]+`)
+	// rxMDSchemeRelative matches markdown link targets without a scheme, such as [text](//example.com)
+	rxMDSchemeRelative = regexp.MustCompile(`\]\(\s*<?(//[^\s<>()]+)`)
+)
+
+// BlockedDomain is a domain which can't be linked to anywhere on the site
+type BlockedDomain struct {
+	Domain string    `json:"domain"`
+	Who    data.Key  `json:"who"`
+	Reason string    `json:"reason,omitempty"`
+	When   time.Time `json:"when"`
+}
+
+// linkDomain returns the registrable domain of a link, i.e. the domain one level below its public suffix, so links
+// to www.example.com and shop.example.com both resolve to example.com.  An empty string is returned for anything
+// which isn't a link to another host
+func linkDomain(link string) string {
+	if strings.HasPrefix(strings.ToLower(link), "www.") {
+		link = "http://" + link
+	}
+
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+
+	host := u.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.ToLower(strings.Trim(host, "[]")), ".")
+
+	if host == "" {
+		return ""
+	}
+
+	if net.ParseIP(host) != nil {
+		return host
+	}
+
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		// hosts like localhost, or bare public suffixes
+		return host
+	}
+	return domain
+}
+
+// normalizeDomain turns a domain or link entered by a moderator or admin into the registrable domain links are
+// checked against
+func normalizeDomain(domain string) (string, error) {
+	domain = strings.TrimSpace(domain)
+	if !strings.Contains(domain, "//") {
+		domain = "//" + domain
+	}
+
+	normalized := linkDomain(domain)
+	if normalized == "" || !strings.Contains(normalized, ".") {
+		return "", fail.New("Invalid domain", domain)
+	}
+	return normalized, nil
+}
+
+// linkDomains returns the unique registrable domains of every link in the text, excluding links to the site itself
+func linkDomains(text string) []string {
+	site := linkDomain(baseURL)
+
+	var domains []string
+	add := func(link string) {
+		domain := linkDomain(link)
+		if domain == "" || domain == site || inStrings(domains, domain) {
+			return
+		}
+		domains = append(domains, domain)
+	}
+
+	for _, link := range rxLink.FindAllString(text, -1) {
+		add(strings.TrimRight(link, ".,;:!?"))
+	}
+
+	for _, match := range rxMDSchemeRelative.FindAllStringSubmatch(text, -1) {
+		add(match[1])
+	}
+
+	return domains
+}
+
+// linkReason returns why the links in the text aren't allowed, naming the offending domain, or an empty string if
+// they are.  Links are checked against the site wide block list, and then the town's lists if a town is passed in
+func linkReason(t *Town, text string) (string, error) {
+	domains := linkDomains(text)
+	if len(domains) == 0 {
+		return "", nil
+	}
+
+	var blocked []*BlockedDomain
+	err := data.BlockedDomainGet(&blocked, domains)
+	if err != nil && err != data.ErrNotFound {
+		return "", err
+	}
+
+	if len(blocked) != 0 {
+		return fmt.Sprintf("Links to %s are not allowed on townsourced", blocked[0].Domain), nil
+	}
+
+	if t == nil {
+		return "", nil
+	}
+
+	for _, domain := range domains {
+		if inStrings(t.AutoModerator.BlockedDomains, domain) {
+			return fmt.Sprintf("This town does not allow links to %s", domain), nil
+		}
+
+		if len(t.AutoModerator.AllowedDomains) != 0 && !inStrings(t.AutoModerator.AllowedDomains, domain) {
+			return fmt.Sprintf("This town does not allow links to %s, only links to %s", domain,
+				strings.Join(t.AutoModerator.AllowedDomains, ", ")), nil
+		}
+	}
+
+	return "", nil
+}
+
+// AddAutoModDomain adds a domain to the town's blocked domains, or if allow is true to its allowed domains.  If a
+// town has any allowed domains, links to every other domain are auto moderated
+func (t *Town) AddAutoModDomain(who *User, domain string, allow bool) error {
+	if !t.mod(who).active() {
+		return ErrTownNotMod
+	}
+
+	domain, err := normalizeDomain(domain)
+	if err != nil {
+		return err
+	}
+
+	list, other, action := t.domainLists(allow)
+	if inStrings(*other, domain) {
+		return fail.New(fmt.Sprintf("%s is already in the town's other domain list, remove it from there first",
+			domain), domain)
+	}
+
+	if inStrings(*list, domain) {
+		return nil
+	}
+
+	before := append([]string(nil), *list...)
+	*list = append(*list, domain)
+	t.modLog.add(t.Key, who, action, domain, before, append([]string(nil), *list...), "")
+
+	return nil
+}
+
+// RemoveAutoModDomain removes a domain from the town's blocked domains, or if allow is true from its allowed
+// domains
+func (t *Town) RemoveAutoModDomain(who *User, domain string, allow bool) error {
+	if !t.mod(who).active() {
+		return ErrTownNotMod
+	}
+
+	domain, err := normalizeDomain(domain)
+	if err != nil {
+		return err
+	}
+
+	list, _, action := t.domainLists(allow)
+
+	for i := range *list {
+		if (*list)[i] == domain {
+			before := append([]string(nil), *list...)
+			*list = append((*list)[:i], (*list)[i+1:]...)
+			t.modLog.add(t.Key, who, action, domain, before, append([]string(nil), *list...), "")
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// domainLists returns the town's domain list to change, the other list, and the log action for the change
+func (t *Town) domainLists(allow bool) (list, other *[]string, action string) {
+	if allow {
+		return &t.AutoModerator.AllowedDomains, &t.AutoModerator.BlockedDomains, TownLogAutoModAllowedDomains
+	}
+	return &t.AutoModerator.BlockedDomains, &t.AutoModerator.AllowedDomains, TownLogAutoModBlockedDomains
+}
+
+// AdminBlockDomain blocks links to the domain everywhere on the site
+func AdminBlockDomain(who *User, domain, reason string) error {
+	err := who.checkPermission(permBlockDomains)
+	if err != nil {
+		return err
+	}
+
+	domain, err = normalizeDomain(domain)
+	if err != nil {
+		return err
+	}
+
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return fail.New("A reason is required")
+	}
+
+	err = data.BlockedDomainInsert(&BlockedDomain{
+		Domain: domain,
+		Who:    who.Username,
+		Reason: reason,
+		When:   time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	return audit(who.Username, AuditDomainBlock, data.EmptyKey, reason, map[string]interface{}{"domain": domain})
+}
+
+// AdminUnblockDomain allows links to a blocked domain again
+func AdminUnblockDomain(who *User, domain, reason string) error {
+	err := who.checkPermission(permBlockDomains)
+	if err != nil {
+		return err
+	}
+
+	domain, err = normalizeDomain(domain)
+	if err != nil {
+		return err
+	}
+
+	err = data.BlockedDomainDelete(domain)
+	if err != nil {
+		return err
+	}
+
+	return audit(who.Username, AuditDomainUnblock, data.EmptyKey, reason, map[string]interface{}{"domain": domain})
+}
+
+// AdminBlockedDomainsGet retrieves every domain blocked site wide
+func AdminBlockedDomainsGet(who *User) ([]*BlockedDomain, error) {
+	err := who.checkPermission(permBlockDomains)
+	if err != nil {
+		return nil, err
+	}
+
+	var blocked []*BlockedDomain
+	err = data.BlockedDomainGetAll(&blocked)
+	if err == data.ErrNotFound {
+		return []*BlockedDomain{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return blocked, nil
+}
@@ -4,76 +4,224 @@
 package app
 
 import (
-	"log"
-	"log/syslog"
+	"crypto/sha1"
+	"encoding/hex"
+	"regexp"
+	"sync/atomic"
 	"time"
 
 	"git.townsourced.com/townsourced/logrus"
 	"github.com/timshannon/townsourced/data"
+	"github.com/timshannon/townsourced/fail"
 )
 
 // LogEntry is an entry in the townsourced log
 type LogEntry struct {
-	Time    time.Time
-	Fields  map[string]interface{}
-	Level   string
-	Message string
+	Time        time.Time              `json:"time"`
+	Fields      map[string]interface{} `json:"fields,omitempty"`
+	Level       string                 `json:"level"`
+	Message     string                 `json:"message"`
+	Fingerprint string                 `json:"fingerprint,omitempty"`
 }
 
-// LogHook is a hook for logging townsourced errors in the database
+// LogGroup is a group of recurring log entries which share the same fingerprint
+type LogGroup struct {
+	Fingerprint string    `json:"fingerprint"`
+	Level       string    `json:"level"`
+	Message     string    `json:"message"` // message of the most recent entry in the group
+	Count       int       `json:"count"`
+	First       time.Time `json:"first"`
+	Last        time.Time `json:"last"`
+}
+
+// LogFilter filters which log entries an admin sees, empty values aren't filtered on
+type LogFilter struct {
+	Levels      []string
+	Since       time.Time
+	Until       time.Time
+	Message     string            // text the message must contain
+	Fields      map[string]string // text each field's value must contain
+	Fingerprint string
+}
+
+const (
+	logMaxLimit    = 1000
+	logGroupLimit  = 100
+	logExportLimit = 10000
+)
+
+// logRetention is how long log entries are kept before they are trimmed, 0 keeps them forever
+// accessed atomically as it can be reloaded while running
+var logRetention int64
+
+// logVariable matches the parts of a message that change between occurrences of the same error, such as keys,
+// ids, counts, and addresses, so they can be left out of the fingerprint
+var logVariable = regexp.MustCompile(`[0-9a-fA-F]*[0-9][0-9a-fA-F.:_-]*`)
+
+// fingerprint groups recurring log entries by their level and message, ignoring any variable values
+func fingerprint(level, message string) string {
+	hash := sha1.Sum([]byte(level + " " + logVariable.ReplaceAllString(message, "#")))
+	return hex.EncodeToString(hash[:8])
+}
+
+// LogHook is a hook for writing townsourced log entries to the log pipeline, which batches them out to the
+// configured sinks, such as the database
 type LogHook struct {
 }
 
 // Levels implements the logrus.Hook interface for LogHook
 func (l *LogHook) Levels() []logrus.Level {
-	return []logrus.Level{
-		logrus.PanicLevel,
-		logrus.FatalLevel,
-		logrus.ErrorLevel,
-		logrus.WarnLevel,
-		logrus.InfoLevel,
-	}
+	return logrus.AllLevels
 }
 
-// Fire implements the logrus.Hook interface for LogHook
+// Fire implements the logrus.Hook interface for LogHook.  It never blocks on a sink, if the buffer is full the
+// entry is dropped and counted
 func (l *LogHook) Fire(entry *logrus.Entry) error {
-	go func(e *logrus.Entry) {
-		lEntry := &LogEntry{
-			Time:    e.Time,
-			Fields:  map[string]interface{}(e.Data),
-			Level:   e.Level.String(),
-			Message: e.Message,
+	logs.add(newLogEntry(entry))
+
+	if entry.Level <= logrus.FatalLevel {
+		// the process is about to exit or panic, so write everything out now
+		logs.flush()
+	}
+	return nil
+}
+
+func newLogEntry(e *logrus.Entry) *LogEntry {
+	// the entry's fields can be reused by the caller after Fire returns, so they are copied, and errors are
+	// written as their message, rather than as an empty object
+	fields := make(map[string]interface{}, len(e.Data))
+	for k, v := range e.Data {
+		if err, ok := v.(error); ok {
+			v = err.Error()
 		}
-		err := data.Log(lEntry)
+		fields[k] = v
+	}
+
+	return &LogEntry{
+		Time:        e.Time,
+		Fields:      fields,
+		Level:       e.Level.String(),
+		Message:     e.Message,
+		Fingerprint: fingerprint(e.Level.String(), e.Message),
+	}
+}
+
+func (f *LogFilter) data() (*data.LogFilter, error) {
+	for i := range f.Levels {
+		_, err := logrus.ParseLevel(f.Levels[i])
 		if err != nil {
-			//error writing log to database, write to syslog instead
-			slog, serr := syslog.NewLogger(lEntry.syslogPriority(), log.LstdFlags)
-			if serr != nil {
-				return
-			}
-			slog.Printf("Error logging entry in database: %s, Original Entry: %s", err, lEntry.Message)
+			return nil, fail.New("Invalid log level "+f.Levels[i], f.Levels)
 		}
+	}
 
-	}(entry)
+	if !f.Since.IsZero() && !f.Until.IsZero() && f.Until.Before(f.Since) {
+		return nil, fail.New("The until time must be after the since time", f)
+	}
 
-	return nil
+	return &data.LogFilter{
+		Levels:      f.Levels,
+		Since:       f.Since,
+		Until:       f.Until,
+		Message:     f.Message,
+		Fields:      f.Fields,
+		Fingerprint: f.Fingerprint,
+	}, nil
+}
+
+// AdminLogGet retrieves the log entries that match the filter, newest first
+func AdminLogGet(who *User, filter *LogFilter, from, limit int) ([]*LogEntry, error) {
+	err := who.checkPermission(permViewLog)
+	if err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 || limit > logMaxLimit {
+		limit = logMaxLimit
+	}
+
+	if from < 0 {
+		from = 0
+	}
+
+	return adminLogGet(filter, from, limit)
+}
+
+// AdminLogExport retrieves up to the export limit of log entries that match the filter, newest first
+func AdminLogExport(who *User, filter *LogFilter) ([]*LogEntry, error) {
+	err := who.checkPermission(permViewLog)
+	if err != nil {
+		return nil, err
+	}
+
+	return adminLogGet(filter, 0, logExportLimit)
 }
 
-func (le *LogEntry) syslogPriority() syslog.Priority {
-	switch le.Level {
-	case logrus.PanicLevel.String():
-		return syslog.LOG_EMERG
-	case logrus.FatalLevel.String():
-		return syslog.LOG_CRIT
-	case logrus.ErrorLevel.String():
-		return syslog.LOG_ERR
-	case logrus.WarnLevel.String():
-		return syslog.LOG_WARNING
-	case logrus.InfoLevel.String():
-		return syslog.LOG_INFO
-	case logrus.DebugLevel.String():
-		return syslog.LOG_DEBUG
-	default:
-		return syslog.LOG_INFO
+func adminLogGet(filter *LogFilter, from, limit int) ([]*LogEntry, error) {
+	dFilter, err := filter.data()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*LogEntry
+	err = data.LogGet(&entries, dFilter, from, limit)
+	if err == data.ErrNotFound {
+		return []*LogEntry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// AdminLogGroups groups the log entries that match the filter by their fingerprint, so recurring errors can be
+// seen at a glance.  The most frequent groups are returned first
+func AdminLogGroups(who *User, filter *LogFilter) ([]*LogGroup, error) {
+	err := who.checkPermission(permViewLog)
+	if err != nil {
+		return nil, err
+	}
+
+	dFilter, err := filter.data()
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []*LogGroup
+	err = data.LogGroups(&groups, dFilter, logGroupLimit)
+	if err == data.ErrNotFound {
+		return []*LogGroup{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return groups, nil
+}
+
+func setLogRetention(retention time.Duration) {
+	atomic.StoreInt64(&logRetention, int64(retention))
+}
+
+// logRetentionTasker trims log entries older than the log retention, so the log table doesn't grow without limit
+type logRetentionTasker struct{}
+
+var scheduleLogRetention = mustParseSchedule("@every 1h jitter 5m")
+
+func (l *logRetentionTasker) Type() string       { return "TrimLog" }
+func (l *logRetentionTasker) Priority() uint     { return priorityLow }
+func (l *logRetentionTasker) Schedule() Schedule { return scheduleLogRetention }
+func (l *logRetentionTasker) Retry() int         { return -1 }
+func (l *logRetentionTasker) Concurrency() int   { return 1 }
+func (l *logRetentionTasker) Do(variables ...interface{}) error {
+	retention := time.Duration(atomic.LoadInt64(&logRetention))
+	if retention <= 0 {
+		return nil
+	}
+
+	err := data.LogDeleteBefore(time.Now().Add(-1 * retention))
+	if err == data.ErrNotFound {
+		return nil
 	}
+	return err
 }
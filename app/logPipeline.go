@@ -0,0 +1,294 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"git.townsourced.com/townsourced/logrus"
+	"github.com/timshannon/townsourced/metrics"
+)
+
+/*
+	Log entries are written out by a single pipeline, rather than a goroutine per entry, so an error storm can't
+	flood the database or spawn unbounded goroutines.
+	1. The LogHook adds each entry to a bounded buffer, and never blocks.  If the buffer is full the entry is
+		dropped and counted
+	2. The pipeline writes the buffer out every flush interval, or as soon as a full batch is waiting
+	3. Each batch is written to every enabled sink, filtered by that sink's minimum level
+	4. A sink which fails to write a batch has the failure counted and reported on stderr.  It's never logged back
+		into the pipeline
+*/
+
+var (
+	logDropped = metrics.NewCounter("townsourced_log_entries_dropped_total",
+		"Number of log entries dropped because the log buffer was full, by level.", "level")
+	logSinkEntries = metrics.NewCounter("townsourced_log_sink_entries_total",
+		"Number of log entries written, by sink.", "sink")
+	logSinkErrors = metrics.NewCounter("townsourced_log_sink_errors_total",
+		"Number of batches of log entries which failed to write, by sink.", "sink")
+)
+
+func init() {
+	metrics.NewGauge("townsourced_log_buffer_depth", "Number of log entries waiting to be written.",
+		func() float64 {
+			return float64(logs.len())
+		})
+}
+
+// LogConfig configures the log pipeline and its sinks.  Each sink is enabled by setting the minimum level of the
+// entries written to it, an empty level disables the sink
+type LogConfig struct {
+	BufferSize     int    `json:"bufferSize"`    // entries waiting to be written before new entries are dropped
+	BatchSize      int    `json:"batchSize"`     // most entries written to a sink at once
+	FlushInterval  string `json:"flushInterval"` // longest an entry waits before it's written
+	Database       string `json:"database"`      // the log table browsed from the admin page
+	Stdout         string `json:"stdout"`        // one JSON object per line
+	Syslog         string `json:"syslog"`
+	File           string `json:"file"` // one JSON object per line, rotated by size
+	FilePath       string `json:"filePath"`
+	FileMaxSizeMB  int    `json:"fileMaxSizeMB"`
+	FileMaxBackups int    `json:"fileMaxBackups"`
+}
+
+func defaultLogConfig() LogConfig {
+	return LogConfig{
+		BufferSize:     10000,
+		BatchSize:      100,
+		FlushInterval:  "1s",
+		Database:       logrus.InfoLevel.String(),
+		FilePath:       "townsourced.log",
+		FileMaxSizeMB:  100,
+		FileMaxBackups: 5,
+	}
+}
+
+func (c *LogConfig) check() []error {
+	var errs []error
+
+	if c.BufferSize <= 0 {
+		errs = append(errs, fmt.Errorf("Log BufferSize must be greater than 0"))
+	}
+
+	if c.BatchSize <= 0 {
+		errs = append(errs, fmt.Errorf("Log BatchSize must be greater than 0"))
+	}
+
+	interval, err := time.ParseDuration(c.FlushInterval)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Error parsing Log FlushInterval duration: %s", err))
+	} else if interval <= 0 {
+		errs = append(errs, fmt.Errorf("Log FlushInterval must be greater than 0"))
+	}
+
+	_, err = c.sinks()
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	return errs
+}
+
+// sinks returns the enabled sinks, but doesn't open any files or connections
+func (c *LogConfig) sinks() ([]*levelSink, error) {
+	var sinks []*levelSink
+
+	add := func(name, level string, sink logSink) error {
+		if level == "" {
+			return nil
+		}
+		lvl, err := logrus.ParseLevel(level)
+		if err != nil {
+			return fmt.Errorf("Invalid log level %s for the %s log sink", level, name)
+		}
+		sinks = append(sinks, &levelSink{logSink: sink, name: name, level: lvl})
+		return nil
+	}
+
+	err := add("database", c.Database, &databaseSink{})
+	if err != nil {
+		return nil, err
+	}
+
+	err = add("stdout", c.Stdout, newStdoutSink())
+	if err != nil {
+		return nil, err
+	}
+
+	err = add("syslog", c.Syslog, &syslogSink{})
+	if err != nil {
+		return nil, err
+	}
+
+	if c.File != "" {
+		if c.FilePath == "" {
+			return nil, fmt.Errorf("Log FilePath must be set to write to the file log sink")
+		}
+		if c.FileMaxSizeMB <= 0 {
+			return nil, fmt.Errorf("Log FileMaxSizeMB must be greater than 0")
+		}
+		if c.FileMaxBackups < 0 {
+			return nil, fmt.Errorf("Log FileMaxBackups can't be negative")
+		}
+	}
+
+	err = add("file", c.File, &fileSink{
+		path:       c.FilePath,
+		maxSize:    int64(c.FileMaxSizeMB) * 1024 * 1024,
+		maxBackups: c.FileMaxBackups,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sinks, nil
+}
+
+// logs is created before the application layer is initialized, so entries logged while starting up are buffered
+// until the sinks are ready
+var logs = &logPipeline{
+	size:      defaultLogConfig().BufferSize,
+	batchSize: defaultLogConfig().BatchSize,
+	notify:    make(chan struct{}, 1),
+}
+
+type logPipeline struct {
+	sync.Mutex
+	buffer    []*LogEntry
+	size      int
+	batchSize int
+	sinks     []*levelSink
+	stop      chan struct{}
+	done      chan struct{}
+
+	notify chan struct{}
+	write  sync.Mutex // only one flush writes to the sinks at a time, so batches stay in order
+}
+
+// add buffers the entry to be written, or drops it if the buffer is full
+func (p *logPipeline) add(entry *LogEntry) {
+	p.Lock()
+	if len(p.buffer) >= p.size {
+		p.Unlock()
+		logDropped.Inc(entry.Level)
+		return
+	}
+	p.buffer = append(p.buffer, entry)
+	full := len(p.buffer) >= p.batchSize
+	p.Unlock()
+
+	if full {
+		select {
+		case p.notify <- struct{}{}:
+		default:
+			// a flush is already pending
+		}
+	}
+}
+
+func (p *logPipeline) len() int {
+	p.Lock()
+	defer p.Unlock()
+	return len(p.buffer)
+}
+
+// start begins writing buffered entries out to the configured sinks
+func (p *logPipeline) start(cfg LogConfig) error {
+	sinks, err := cfg.sinks()
+	if err != nil {
+		return err
+	}
+
+	interval, err := time.ParseDuration(cfg.FlushInterval)
+	if err != nil {
+		return fmt.Errorf("Error parsing Log FlushInterval duration: %s", err)
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	if p.stop != nil {
+		return fmt.Errorf("The log pipeline has already been started")
+	}
+
+	p.size = cfg.BufferSize
+	p.batchSize = cfg.BatchSize
+	p.sinks = sinks
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+
+	go p.run(interval, p.stop, p.done)
+	return nil
+}
+
+func (p *logPipeline) run(interval time.Duration, stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.notify:
+		case <-ticker.C:
+		case <-stop:
+			p.flush()
+			return
+		}
+		p.flush()
+	}
+}
+
+// flush writes every buffered entry out to the sinks.  Entries are left buffered until the pipeline has been
+// started
+func (p *logPipeline) flush() {
+	p.write.Lock()
+	defer p.write.Unlock()
+
+	p.Lock()
+	if p.sinks == nil {
+		p.Unlock()
+		return
+	}
+	entries := p.buffer
+	p.buffer = nil
+	sinks := p.sinks
+	batchSize := p.batchSize
+	p.Unlock()
+
+	for len(entries) > 0 {
+		n := batchSize
+		if n > len(entries) {
+			n = len(entries)
+		}
+		for i := range sinks {
+			sinks[i].write(entries[:n])
+		}
+		entries = entries[n:]
+	}
+}
+
+// close stops the pipeline and waits for the remaining entries to be written.  Any entries logged after it's
+// closed are only written if they are fatal
+func (p *logPipeline) close(ctx context.Context) error {
+	p.Lock()
+	stop, done := p.stop, p.done
+	p.stop = nil
+	p.Unlock()
+
+	if stop == nil {
+		return nil
+	}
+	close(stop)
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,240 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"log/syslog"
+	"os"
+
+	"git.townsourced.com/townsourced/logrus"
+	"github.com/timshannon/townsourced/data"
+)
+
+// logSink is somewhere log entries are written to
+type logSink interface {
+	write(entries []*LogEntry) error
+}
+
+// levelSink only writes entries at or above its level to its sink
+type levelSink struct {
+	logSink
+	name  string
+	level logrus.Level
+}
+
+func (l *levelSink) write(entries []*LogEntry) {
+	filtered := make([]*LogEntry, 0, len(entries))
+	for i := range entries {
+		lvl, err := logrus.ParseLevel(entries[i].Level)
+		if err != nil || lvl <= l.level {
+			filtered = append(filtered, entries[i])
+		}
+	}
+
+	if len(filtered) == 0 {
+		return
+	}
+
+	err := l.logSink.write(filtered)
+	if err != nil {
+		// writing to the standard logger would feed the failure back into the pipeline, so it's written straight
+		// to stderr instead
+		logSinkErrors.Inc(l.name)
+		log.Printf("Error writing %d log entries to the %s log sink: %s, First entry: %s", len(filtered), l.name,
+			err, filtered[0].Message)
+		return
+	}
+
+	logSinkEntries.Add(float64(len(filtered)), l.name)
+}
+
+// databaseSink writes entries to the log table, where they can be browsed from the admin page
+type databaseSink struct{}
+
+func (d *databaseSink) write(entries []*LogEntry) error {
+	return data.Log(entries)
+}
+
+// stdoutSink writes each entry as a line of JSON
+type stdoutSink struct {
+	out io.Writer
+}
+
+func newStdoutSink() *stdoutSink {
+	return &stdoutSink{out: os.Stdout}
+}
+
+func (s *stdoutSink) write(entries []*LogEntry) error {
+	buf, err := jsonLines(entries)
+	if err != nil {
+		return err
+	}
+	_, err = s.out.Write(buf)
+	return err
+}
+
+func jsonLines(entries []*LogEntry) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	for i := range entries {
+		err := enc.Encode(entries[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// syslogSink writes entries to the local syslog, reusing one connection until it fails
+type syslogSink struct {
+	writer *syslog.Writer
+}
+
+func (s *syslogSink) write(entries []*LogEntry) error {
+	if s.writer == nil {
+		w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_USER, "townsourced")
+		if err != nil {
+			return err
+		}
+		s.writer = w
+	}
+
+	for i := range entries {
+		err := s.writeEntry(entries[i])
+		if err != nil {
+			// reconnect on the next batch
+			_ = s.writer.Close()
+			s.writer = nil
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *syslogSink) writeEntry(entry *LogEntry) error {
+	msg := entry.Message
+	if len(entry.Fields) != 0 {
+		fields, err := json.Marshal(entry.Fields)
+		if err == nil {
+			msg += " " + string(fields)
+		}
+	}
+
+	switch entry.syslogPriority() {
+	case syslog.LOG_EMERG:
+		return s.writer.Emerg(msg)
+	case syslog.LOG_CRIT:
+		return s.writer.Crit(msg)
+	case syslog.LOG_ERR:
+		return s.writer.Err(msg)
+	case syslog.LOG_WARNING:
+		return s.writer.Warning(msg)
+	case syslog.LOG_DEBUG:
+		return s.writer.Debug(msg)
+	default:
+		return s.writer.Info(msg)
+	}
+}
+
+func (le *LogEntry) syslogPriority() syslog.Priority {
+	switch le.Level {
+	case logrus.PanicLevel.String():
+		return syslog.LOG_EMERG
+	case logrus.FatalLevel.String():
+		return syslog.LOG_CRIT
+	case logrus.ErrorLevel.String():
+		return syslog.LOG_ERR
+	case logrus.WarnLevel.String():
+		return syslog.LOG_WARNING
+	case logrus.InfoLevel.String():
+		return syslog.LOG_INFO
+	case logrus.DebugLevel.String():
+		return syslog.LOG_DEBUG
+	default:
+		return syslog.LOG_INFO
+	}
+}
+
+// fileSink writes each entry as a line of JSON to a file.  When the file would grow past its max size, it's
+// rotated to path.1, path.1 to path.2 and so on, keeping at most maxBackups old files
+type fileSink struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	file *os.File
+	size int64
+}
+
+func (f *fileSink) write(entries []*LogEntry) error {
+	buf, err := jsonLines(entries)
+	if err != nil {
+		return err
+	}
+
+	if f.file == nil {
+		err = f.open()
+		if err != nil {
+			return err
+		}
+	}
+
+	if f.size > 0 && f.size+int64(len(buf)) > f.maxSize {
+		err = f.rotate()
+		if err != nil {
+			return err
+		}
+	}
+
+	n, err := f.file.Write(buf)
+	f.size += int64(n)
+	return err
+}
+
+func (f *fileSink) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+func (f *fileSink) rotate() error {
+	err := f.file.Close()
+	f.file = nil
+	if err != nil {
+		return err
+	}
+
+	if f.maxBackups == 0 {
+		err = os.Remove(f.path)
+	} else {
+		for i := f.maxBackups - 1; i > 0; i-- {
+			err = os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		err = os.Rename(f.path, f.path+".1")
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return f.open()
+}
@@ -0,0 +1,66 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package app_test
+
+import (
+	"time"
+
+	. "git.townsourced.com/townsourced/check"
+	"github.com/timshannon/townsourced/app"
+	"github.com/timshannon/townsourced/fail"
+)
+
+// Log Test Suite
+type LogSuite struct{}
+
+var _ = Suite(&LogSuite{})
+
+func (s *LogSuite) TestAdminOnly(c *C) {
+	u := &app.User{}
+
+	_, err := app.AdminLogGet(u, &app.LogFilter{}, 0, 10)
+	c.Assert(err, Equals, app.ErrNotAdmin)
+
+	_, err = app.AdminLogGroups(u, &app.LogFilter{})
+	c.Assert(err, Equals, app.ErrNotAdmin)
+
+	_, err = app.AdminLogExport(u, &app.LogFilter{})
+	c.Assert(err, Equals, app.ErrNotAdmin)
+}
+
+func (s *LogSuite) TestInvalidFilter(c *C) {
+	u := &app.User{Admin: true}
+
+	_, err := app.AdminLogGet(u, &app.LogFilter{Levels: []string{"error", "bad level"}}, 0, 10)
+	c.Assert(fail.IsFail(err), Equals, true)
+
+	now := time.Now()
+	_, err = app.AdminLogGroups(u, &app.LogFilter{Since: now, Until: now.Add(-1 * time.Hour)})
+	c.Assert(fail.IsFail(err), Equals, true)
+}
+
+func (s *LogSuite) TestLogConfig(c *C) {
+	cfg := app.DefaultConfig()
+	c.Assert(app.CheckConfig(cfg), HasLen, 0)
+
+	cfg.Log.Stdout = "debug"
+	cfg.Log.Syslog = "error"
+	cfg.Log.File = "warning"
+	c.Assert(app.CheckConfig(cfg), HasLen, 0)
+
+	cfg = app.DefaultConfig()
+	cfg.Log.Database = "loud"
+	c.Assert(app.CheckConfig(cfg), HasLen, 1)
+
+	cfg = app.DefaultConfig()
+	cfg.Log.File = "info"
+	cfg.Log.FilePath = ""
+	c.Assert(app.CheckConfig(cfg), HasLen, 1)
+
+	cfg = app.DefaultConfig()
+	cfg.Log.BufferSize = 0
+	cfg.Log.BatchSize = -1
+	cfg.Log.FlushInterval = "soon"
+	c.Assert(app.CheckConfig(cfg), HasLen, 3)
+}
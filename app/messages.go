@@ -68,6 +68,28 @@ I have moderated your post {{.Post.Title}} in the town {{.Town.Name}} for the fo
 > {{.Reason}}
 
 You can view the post and comments [here](/post/{{FromUUID .Post.Key}}).
+`})
+
+	addMessageType("msgPostHeld", message{
+		subject: `The post "{{.Post.Title}}" is waiting for review in {{.Town.Name}}`,
+		body: `
+The post **{{.Post.Title}}** has been held by the auto moderator of {{.Town.Name}} for the following reason:
+
+> {{.Reason}}
+
+It won't be published until it's approved.  You can view the post [here](/post/{{FromUUID .Post.Key}}), and approve or moderate it from the town's moderation queue.
+
+*This message was sent to you because you are a moderator of {{.Town.Name}}.*
+`})
+
+	addMessageType("msgCommentModerated", message{
+		subject: `Your comment has been moderated in {{.Town.Name}}`,
+		body: `
+I have moderated your comment in the town {{.Town.Name}} for the following reason:
+
+> {{.Reason}}
+
+You can view the comment [here](/post/{{FromUUID .Comment.PostKey}}/comment/{{FromUUID .Comment.Key}}).
 `})
 
 	addMessageType("msgPostReport", message{
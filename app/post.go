@@ -5,6 +5,7 @@ package app
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"math"
 	"regexp"
@@ -31,6 +32,8 @@ const (
 	PostStatusPublished = data.PostStatusPublished
 	//PostStatusClosed not searchable but link is still valid
 	PostStatusClosed = data.PostStatusClosed
+	// PostStatusPending held by a town's auto moderator, only visible to its creator and moderators until approved
+	PostStatusPending = data.PostStatusPending
 )
 
 //PostFormat is the types of formats available for posts
@@ -111,6 +114,11 @@ type Post struct {
 	HashTags        []data.Key          `json:"hashTags,omitempty"`
 	Prices          []float64           `json:"prices,omitempty"`
 	Reported        map[data.Key]string `json:"reported,omitempty" gorethink:",omitempty"`
+	Approved        []data.Key          `json:"approved,omitempty"` // towns where moderators reviewed and allowed the post
+	Flagged         []Moderated         `json:"-"`                  // towns where the auto moderator wants a review
+	Held            []Moderated         `json:"held,omitempty"`     // towns which must approve the post before it's published
+	Fingerprint     string              `json:"-" gorethink:",omitempty"`
+	ImageHashes     []string            `json:"-" gorethink:",omitempty"`
 	AllowComments   bool                `json:"allowComments,omitempty"`
 	NotifyOnComment bool                `json:"notifyOnComment,omitempty"`
 	Published       time.Time           `json:"published,omitempty" gorethink:",omitempty"`
@@ -119,7 +127,9 @@ type Post struct {
 	data.Version
 	creatorUser *User
 
-	towns []*Town
+	towns      []*Town
+	modLog     townLog
+	duplicates *int // cached count of the creator's recent duplicate posts, nil if not counted yet
 }
 
 // Moderated contains which moderators have moderated this post and their reason
@@ -291,8 +301,13 @@ func PostSearch(who *User, searchText string, tags []string, towns []Town, categ
 }
 
 // PostNew creates a new Post
-func PostNew(title, content, category, format string, creator *User, towns []data.Key, images []data.UUID, featuredImage data.UUID,
-	allowComments, notifyOnComment, draft bool) (*Post, error) {
+func PostNew(ctx context.Context, title, content, category, format string, creator *User, towns []data.Key,
+	images []data.UUID, featuredImage data.UUID, allowComments, notifyOnComment, draft bool) (*Post, error) {
+	err := creator.checkSuspended()
+	if err != nil {
+		return nil, err
+	}
+
 	post := &Post{
 		Title:           title,
 		Content:         content,
@@ -317,7 +332,7 @@ func PostNew(title, content, category, format string, creator *User, towns []dat
 		post.Status = PostStatusPublished
 	}
 
-	err := post.validate()
+	err = post.validate(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -355,7 +370,7 @@ func PostNew(title, content, category, format string, creator *User, towns []dat
 }
 
 // validate a post
-func (p *Post) validate() error {
+func (p *Post) validate(ctx context.Context) error {
 	if strings.TrimSpace(p.Title) == "" {
 		return ErrPostNoTitle
 	}
@@ -387,7 +402,7 @@ func (p *Post) validate() error {
 		return err
 	}
 
-	err = p.checkTowns()
+	err = p.checkTowns(ctx)
 	if err != nil {
 		return err
 	}
@@ -400,7 +415,8 @@ func (p *Post) validate() error {
 }
 
 func postStatusCheck(status string) error {
-	if status != PostStatusClosed && status != PostStatusDraft && status != PostStatusPublished {
+	if status != PostStatusClosed && status != PostStatusDraft && status != PostStatusPublished &&
+		status != PostStatusPending {
 		return ErrPostBadStatus
 	}
 	return nil
@@ -446,7 +462,7 @@ func (p *Post) checkImages() error {
 	return nil
 }
 
-func (p *Post) checkTowns() error {
+func (p *Post) checkTowns(ctx context.Context) error {
 	if len(p.TownKeys) == 0 {
 		if p.Status == PostStatusDraft {
 			// towns aren't required until publishing
@@ -457,6 +473,12 @@ func (p *Post) checkTowns() error {
 	}
 
 	p.towns = nil // reset cached town list so it can be checked
+	p.duplicates = nil
+
+	err := p.setFingerprints()
+	if err != nil {
+		return err
+	}
 
 	towns, err := p.Towns()
 	if err == data.ErrNotFound {
@@ -468,16 +490,40 @@ func (p *Post) checkTowns() error {
 	}
 
 	for i := range towns {
-		reason, err := towns[i].autoModerate(p)
-		if err != nil {
-			return err
+		reason := ""
+		action := AutoModActionReject
+		if !p.approved(towns[i]) {
+			var rule string
+			rule, reason, err = towns[i].autoModerate(ctx, p)
+			if err != nil {
+				return err
+			}
+			action = towns[i].AutoModerator.autoModAction(rule)
+			if reason == "" {
+				// duplicates which aren't rejected or flagged are held
+				action = AutoModActionHold
+				reason, err = p.checkDuplicates(towns[i])
+				if err != nil {
+					return err
+				}
+			}
 		}
-		if reason != "" {
+
+		switch {
+		case reason != "" && action == AutoModActionHold:
+			err = p.removeModeration(towns[i])
+			if err != nil {
+				return err
+			}
+			p.hold(towns[i], reason)
+		case reason != "":
+			p.removeHold(towns[i])
 			err = p.addModeration(towns[i], nil, reason)
 			if err != nil {
 				return err
 			}
-		} else {
+		default:
+			p.removeHold(towns[i])
 			err = p.removeModeration(towns[i])
 			if err != nil {
 				return err
@@ -489,7 +535,7 @@ func (p *Post) checkTowns() error {
 			return err
 		}
 
-		if towns[i].Key == AnnouncementTown && !creator.Admin {
+		if towns[i].Key == AnnouncementTown && !creator.HasRole(RoleAdmin) {
 			return fail.New("You cannot post to the Announcements town")
 		}
 
@@ -599,6 +645,11 @@ func (p *Post) Update() error {
 		return err
 	}
 
+	err = p.modLog.write()
+	if err != nil {
+		return err
+	}
+
 	if p.Status == PostStatusPublished {
 		err := data.PostIndex(p, p.Key)
 		if err != nil {
@@ -609,6 +660,14 @@ func (p *Post) Update() error {
 	return nil
 }
 
+// postedAt is when the post was published, or now if it hasn't been yet
+func (p *Post) postedAt() time.Time {
+	if p.Published.IsZero() {
+		return time.Now()
+	}
+	return p.Published
+}
+
 func (p *Post) creator() (*User, error) {
 	if p.creatorUser != nil {
 		return p.creatorUser, nil
@@ -620,15 +679,41 @@ func (p *Post) creator() (*User, error) {
 // Moderate puts a post into moderation for a specific town, and makes it not publically
 // visible for the given town
 func (p *Post) Moderate(town *Town, who *User, reason string) error {
-	if !town.IsMod(who) {
+	if !town.canReview(who) {
 		return ErrTownNotMod
 	}
 
+	changed := true
+	var before interface{}
+	for i := range p.Moderation {
+		if p.Moderation[i].Town == town.Key {
+			// a moderator's decision replaces the auto moderator's
+			changed = p.Moderation[i].Who == data.EmptyKey
+			before = p.Moderation[i]
+		}
+	}
+
+	if held := p.removeHold(town); held != nil {
+		before = *held
+	}
+
+	if changed {
+		err := p.removeModeration(town)
+		if err != nil {
+			return err
+		}
+	}
+
 	err := p.addModeration(town, who, reason)
 	if err != nil {
 		return err
 	}
 
+	if changed {
+		p.modLog.add(town.Key, who, TownLogPostModerate, string(p.Key), before, p.Moderation[len(p.Moderation)-1],
+			reason)
+	}
+
 	sub, msg, err := messages.use("msgPostModerated").Execute(struct {
 		Post   *Post
 		Town   *Town
@@ -651,7 +736,9 @@ func (p *Post) Moderate(town *Town, who *User, reason string) error {
 	if err != nil {
 		return err
 	}
-	return nil
+
+	// a rejected post is still published to the towns it wasn't held in
+	return p.release()
 }
 
 func (p *Post) addModeration(town *Town, who *User, reason string) error {
@@ -688,10 +775,16 @@ func (p *Post) addModeration(town *Town, who *User, reason string) error {
 // RemoveModeration removes moderation for a specific town, i.e. makes it publically
 // visible again
 func (p *Post) RemoveModeration(town *Town, who *User) error {
-	if !town.IsMod(who) {
+	if !town.canReview(who) {
 		return ErrTownNotMod
 	}
 
+	for i := range p.Moderation {
+		if p.Moderation[i].Town == town.Key {
+			p.modLog.add(town.Key, who, TownLogPostRemoveModeration, string(p.Key), p.Moderation[i], nil, "")
+		}
+	}
+
 	return p.removeModeration(town)
 }
 
@@ -711,6 +804,46 @@ func (p *Post) removeModeration(town *Town) error {
 	return nil
 }
 
+// Approve marks a reported or auto moderated post as reviewed and allowed in the town, which removes it from the
+// town's moderation queue.  The town's auto moderator isn't run against the post again unless its content changes
+func (p *Post) Approve(town *Town, who *User) error {
+	if !town.canReview(who) {
+		return ErrTownNotMod
+	}
+
+	var before interface{}
+	for i := range p.Moderation {
+		if p.Moderation[i].Town == town.Key {
+			if p.Moderation[i].Who != data.EmptyKey {
+				return fail.New("This post has been moderated by a moderator, remove the moderation instead")
+			}
+			before = p.Moderation[i]
+			p.Moderation = append(p.Moderation[:i], p.Moderation[i+1:]...)
+			break
+		}
+	}
+
+	if held := p.removeHold(town); held != nil {
+		before = *held
+	}
+
+	if !p.approved(town) {
+		p.Approved = append(p.Approved, town.Key)
+		p.modLog.add(town.Key, who, TownLogPostApprove, string(p.Key), before, nil, "")
+	}
+
+	return p.release()
+}
+
+func (p *Post) approved(t *Town) bool {
+	for i := range p.Approved {
+		if p.Approved[i] == t.Key {
+			return true
+		}
+	}
+	return false
+}
+
 // Publish publishes the post to make it visible publically
 func (p *Post) Publish(who *User) error {
 	err := p.CanEdit(who)
@@ -723,9 +856,16 @@ func (p *Post) Publish(who *User) error {
 
 // Note: this function is called by PostNew as well as after a post updated
 func (p *Post) publish() error {
-	p.Status = PostStatusPublished
 	p.Published = time.Now()
 
+	if len(p.Held) != 0 {
+		// published once every town holding it approves or moderates it
+		p.Status = PostStatusPending
+		return p.notifyHeld()
+	}
+
+	p.Status = PostStatusPublished
+
 	p.parseHashTags()
 	p.parsePrices()
 
@@ -740,6 +880,12 @@ func (p *Post) Unpublish(who *User) error {
 		return nil
 	}
 
+	if p.Status == PostStatusPending {
+		// pending posts were never indexed, and can always be taken back
+		p.Status = PostStatusDraft
+		return nil
+	}
+
 	if p.Status != PostStatusPublished {
 		return ErrPostNotPublished
 	}
@@ -792,6 +938,9 @@ func (p *Post) ReOpen(who *User) error {
 	}
 
 	p.Status = PostStatusPublished
+	if len(p.Held) != 0 {
+		p.Status = PostStatusPending
+	}
 	return nil
 }
 
@@ -846,7 +995,7 @@ func (p *Post) SetTitle(who *User, title string) error {
 }
 
 // SetContent sets the content on a draft version of a post
-func (p *Post) SetContent(who *User, content string) error {
+func (p *Post) SetContent(ctx context.Context, who *User, content string) error {
 	err := p.CanEdit(who)
 	if err != nil {
 		return err
@@ -855,9 +1004,13 @@ func (p *Post) SetContent(who *User, content string) error {
 	if strings.TrimSpace(p.Content) == "" {
 		return ErrPostNoContent
 	}
+	if content != p.Content {
+		// changed content needs to be reviewed again
+		p.Approved = nil
+	}
 	p.Content = content
 	// re-run town moderation if content changed
-	return p.checkTowns()
+	return p.checkTowns(ctx)
 }
 
 // SetCategory sets the category on a draft version of a post
@@ -910,7 +1063,7 @@ func (p *Post) SetNotifyOnComment(who *User, notifyOnComment bool) error {
 }
 
 // SetTowns sets the towns a draft post is associated to
-func (p *Post) SetTowns(who *User, towns []data.Key) error {
+func (p *Post) SetTowns(ctx context.Context, who *User, towns []data.Key) error {
 	err := p.CanEdit(who)
 	if err != nil {
 		return err
@@ -925,10 +1078,11 @@ func (p *Post) SetTowns(who *User, towns []data.Key) error {
 	}
 
 	p.Moderation = nonAuto
+	p.Held = nil
 
 	p.TownKeys = towns
 
-	return p.checkTowns()
+	return p.checkTowns(ctx)
 }
 
 // SetImages updates a post's set of images
@@ -978,6 +1132,34 @@ func (p *Post) Visible(u *User) (bool, error) {
 		return true, nil
 	}
 
+	if p.Status == PostStatusPending {
+		// pending posts can only be seen by the moderators who need to review them
+		if u == nil {
+			return false, nil
+		}
+		towns, err := p.Towns()
+		if err != nil {
+			return false, err
+		}
+		for i := range towns {
+			if towns[i].canReview(u) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	if p.Status == PostStatusClosed {
+		// closed posts are still visible from a direct link, unless they were hidden by banning their creator
+		creator, err := p.creator()
+		if err != nil {
+			return false, err
+		}
+		if creator.contentHidden() {
+			return false, nil
+		}
+	}
+
 	moddedInAllTowns := true
 	for _, tk := range p.TownKeys {
 		found := false
@@ -1054,6 +1236,8 @@ func (p *Post) Report(who *User, reason string) error {
 	}
 
 	p.Reported[who.Username] = reason
+	// a new report puts the post back into the moderation queue of every town
+	p.Approved = nil
 
 	mods := make(map[data.Key]struct{})
 
@@ -1088,9 +1272,8 @@ func (p *Post) Report(who *User, reason string) error {
 
 				}
 			}
-			//if town had no active mods, then the admins can still moderate, but we'll have to
-			// build reports to find these i.e posts that have been reported more than x times
-			// in towns with no active moderators and hasn't been moderated yet
+			// if the town has no active mods, the post shows up in the site wide moderation queue on the
+			// admin page instead
 		}
 	}
 	return nil
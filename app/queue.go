@@ -0,0 +1,229 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package app
+
+import (
+	"sort"
+
+	"github.com/timshannon/townsourced/data"
+	"github.com/timshannon/townsourced/fail"
+)
+
+// modQueueMaxLimit is the most posts that can be retrieved from a moderation queue at once
+const modQueueMaxLimit = 100
+
+// ModQueueEntry is a post waiting to be reviewed, because it was reported, held or auto moderated
+type ModQueueEntry struct {
+	Post          *Post       `json:"post"`
+	Towns         []data.Key  `json:"towns"` // the towns the post is waiting for review in
+	ReportCount   int         `json:"reportCount"`
+	Reasons       []string    `json:"reasons,omitempty"`       // why the post was reported
+	AutoModerated []Moderated `json:"autoModerated,omitempty"` // why the post was auto moderated, by town
+	Flagged       []Moderated `json:"flagged,omitempty"`       // why the auto moderator flagged the post, by town
+	Held          []Moderated `json:"held,omitempty"`          // why the auto moderator held the post, by town
+}
+
+// CommentQueueEntry is a reported comment waiting to be reviewed
+type CommentQueueEntry struct {
+	Comment     *Comment `json:"comment"`
+	ReportCount int      `json:"reportCount"`
+	Reasons     []string `json:"reasons,omitempty"` // why the comment was reported
+}
+
+// ModQueue retrieves the posts waiting to be reviewed by the town's moderators, newest first
+func (t *Town) ModQueue(who *User, from, limit int) ([]*ModQueueEntry, error) {
+	if !t.canReview(who) {
+		return nil, ErrTownNotMod
+	}
+
+	return modQueue([]*Town{t}, from, limit)
+}
+
+// CommentQueue retrieves the reported comments on posts in the town waiting to be reviewed by the town's
+// moderators, newest first
+func (t *Town) CommentQueue(who *User, from, limit int) ([]*CommentQueueEntry, error) {
+	if !t.canReview(who) {
+		return nil, ErrTownNotMod
+	}
+
+	return commentQueue([]*Town{t}, from, limit)
+}
+
+// AdminModQueueGet retrieves the posts waiting to be reviewed in towns which have no active moderators, newest first
+func AdminModQueueGet(who *User, from, limit int) ([]*ModQueueEntry, error) {
+	towns, err := adminQueueTowns(who)
+	if err != nil {
+		return nil, err
+	}
+
+	return modQueue(towns, from, limit)
+}
+
+// AdminCommentQueueGet retrieves the reported comments waiting to be reviewed in towns which have no active
+// moderators, newest first
+func AdminCommentQueueGet(who *User, from, limit int) ([]*CommentQueueEntry, error) {
+	towns, err := adminQueueTowns(who)
+	if err != nil {
+		return nil, err
+	}
+
+	return commentQueue(towns, from, limit)
+}
+
+// adminQueueTowns returns the towns with no active moderators
+func adminQueueTowns(who *User) ([]*Town, error) {
+	err := who.checkPermission(permReviewContent)
+	if err != nil {
+		return nil, err
+	}
+
+	var towns []*Town
+	err = data.TownGetUnmoderated(&towns)
+	if err == data.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return towns, nil
+}
+
+func queueKeys(towns []*Town, from, limit int) ([]data.Key, error) {
+	if limit <= 0 || limit > modQueueMaxLimit {
+		return nil, fail.New("Invalid limit, must be between 1 and 100", limit)
+	}
+	if from < 0 {
+		return nil, fail.New("Invalid from, must not be negative", from)
+	}
+
+	keys := make([]data.Key, len(towns))
+	for i := range towns {
+		keys[i] = towns[i].Key
+	}
+	return keys, nil
+}
+
+func commentQueue(towns []*Town, from, limit int) ([]*CommentQueueEntry, error) {
+	keys, err := queueKeys(towns, from, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(keys) == 0 {
+		return []*CommentQueueEntry{}, nil
+	}
+
+	var comments []*Comment
+	err = data.CommentGetModQueue(&comments, keys, from, limit)
+	if err == data.ErrNotFound {
+		return []*CommentQueueEntry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*CommentQueueEntry, len(comments))
+	for i := range comments {
+		entries[i] = &CommentQueueEntry{
+			Comment:     comments[i],
+			ReportCount: len(comments[i].Reported),
+			Reasons:     reportReasons(comments[i].Reported),
+		}
+	}
+
+	return entries, nil
+}
+
+func reportReasons(reported map[data.Key]string) []string {
+	var reasons []string
+	for _, reason := range reported {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	return reasons
+}
+
+func modQueue(towns []*Town, from, limit int) ([]*ModQueueEntry, error) {
+	keys, err := queueKeys(towns, from, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(keys) == 0 {
+		return []*ModQueueEntry{}, nil
+	}
+
+	var posts []*Post
+	err = data.PostGetModQueue(&posts, keys, from, limit)
+	if err == data.ErrNotFound {
+		return []*ModQueueEntry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*ModQueueEntry, len(posts))
+	for i := range posts {
+		entries[i] = newModQueueEntry(posts[i], towns)
+	}
+
+	return entries, nil
+}
+
+// newModQueueEntry builds the queue entry for the post, for the towns it's waiting for review in.  The checks
+// match data.PostGetModQueue
+func newModQueueEntry(p *Post, towns []*Town) *ModQueueEntry {
+	entry := &ModQueueEntry{
+		Post:        p,
+		Towns:       []data.Key{},
+		ReportCount: len(p.Reported),
+		Reasons:     reportReasons(p.Reported),
+	}
+
+	for _, t := range towns {
+		if !p.inTown(t) {
+			continue
+		}
+
+		autoModerated := false
+		for i := range p.Moderation {
+			if p.Moderation[i].Town == t.Key && p.Moderation[i].Who == data.EmptyKey {
+				entry.AutoModerated = append(entry.AutoModerated, p.Moderation[i])
+				autoModerated = true
+			}
+		}
+
+		flagged := false
+		for i := range p.Flagged {
+			if p.Flagged[i].Town == t.Key {
+				entry.Flagged = append(entry.Flagged, p.Flagged[i])
+				flagged = true
+			}
+		}
+
+		held := false
+		for i := range p.Held {
+			if p.Held[i].Town == t.Key {
+				entry.Held = append(entry.Held, p.Held[i])
+				held = true
+			}
+		}
+
+		if held || autoModerated || ((len(p.Reported) != 0 || flagged) && !p.isModerated(t) && !p.approved(t)) {
+			entry.Towns = append(entry.Towns, t.Key)
+		}
+	}
+
+	return entry
+}
+
+func (p *Post) inTown(t *Town) bool {
+	for i := range p.TownKeys {
+		if p.TownKeys[i] == t.Key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package app_test
+
+import (
+	"context"
+
+	. "git.townsourced.com/townsourced/check"
+	"github.com/timshannon/townsourced/app"
+	"github.com/timshannon/townsourced/data"
+)
+
+// Moderation Queue Test Suite
+type QueueSuite struct {
+	*testData
+}
+
+var _ = Suite(&QueueSuite{testData: &testData{}})
+
+func (s *QueueSuite) SetUpTest(c *C) {
+	s.testData.setup(c)
+}
+
+func (s *QueueSuite) TearDownTest(c *C) {
+	s.testData.teardown(c)
+}
+
+func (s *QueueSuite) TestModQueue(c *C) {
+	_, err := s.town1.ModQueue(s.other, 0, 10)
+	c.Assert(err, Equals, app.ErrTownNotMod)
+
+	entries, err := s.town1.ModQueue(s.moderator, 0, 10)
+	c.Assert(err, Equals, nil)
+	c.Assert(len(entries), Equals, 0)
+
+	c.Assert(s.postPub.Report(s.other, "spam"), Equals, nil)
+	c.Assert(s.postPub.Update(), Equals, nil)
+
+	entries, err = s.town1.ModQueue(s.moderator, 0, 10)
+	c.Assert(err, Equals, nil)
+	c.Assert(len(entries), Equals, 1)
+	c.Assert(entries[0].Post.Key, Equals, s.postPub.Key)
+	c.Assert(entries[0].ReportCount, Equals, 1)
+	c.Assert(entries[0].Reasons, DeepEquals, []string{"spam"})
+	c.Assert(len(entries[0].Towns), Equals, 1)
+	c.Assert(entries[0].Towns[0], Equals, s.town1.Key)
+
+	// approving in one town leaves it in the queue of the other
+	c.Assert(s.postPub.Approve(s.town1, s.other), Equals, app.ErrTownNotMod)
+	c.Assert(s.postPub.Approve(s.town1, s.moderator), Equals, nil)
+	c.Assert(s.postPub.Update(), Equals, nil)
+
+	entries, err = s.town1.ModQueue(s.moderator, 0, 10)
+	c.Assert(err, Equals, nil)
+	c.Assert(len(entries), Equals, 0)
+
+	entries, err = s.town2.ModQueue(s.moderator, 0, 10)
+	c.Assert(err, Equals, nil)
+	c.Assert(len(entries), Equals, 1)
+
+	c.Assert(s.postPub.Moderate(s.town2, s.moderator, "spam"), Equals, nil)
+	c.Assert(s.postPub.Update(), Equals, nil)
+
+	entries, err = s.town2.ModQueue(s.moderator, 0, 10)
+	c.Assert(err, Equals, nil)
+	c.Assert(len(entries), Equals, 0)
+}
+
+func (s *QueueSuite) TestAdminModQueue(c *C) {
+	_, err := app.AdminModQueueGet(s.other, 0, 10)
+	c.Assert(err, Equals, app.ErrNotAdmin)
+
+	// a copy, so the shared test user keeps its roles
+	reviewer := *s.other
+	reviewer.Roles = []string{app.RoleReviewer}
+
+	// the test towns all have an active moderator
+	c.Assert(s.postPub.Report(s.user, "spam"), Equals, nil)
+	c.Assert(s.postPub.Update(), Equals, nil)
+
+	entries, err := app.AdminModQueueGet(&reviewer, 0, 10)
+	c.Assert(err, Equals, nil)
+	for i := range entries {
+		c.Assert(entries[i].Post.Key, Not(Equals), s.postPub.Key)
+	}
+
+	// reviewers can only act on towns without active moderators
+	c.Assert(s.postPub.Approve(s.town1, &reviewer), Equals, app.ErrTownNotMod)
+}
+
+func (s *QueueSuite) TestCommentModeration(c *C) {
+	comment, err := app.CommentNew(context.Background(), s.user, s.postPub, "Test comment")
+	c.Assert(err, Equals, nil)
+
+	c.Assert(comment.Report(s.other, ""), Not(Equals), nil)
+	c.Assert(comment.Report(s.other, "rude"), Equals, nil)
+	c.Assert(comment.Report(s.other, "rude"), Not(Equals), nil)
+	c.Assert(comment.Update(), Equals, nil)
+
+	_, err = s.town1.CommentQueue(s.other, 0, 10)
+	c.Assert(err, Equals, app.ErrTownNotMod)
+
+	entries, err := s.town1.CommentQueue(s.moderator, 0, 10)
+	c.Assert(err, Equals, nil)
+	c.Assert(len(entries), Equals, 1)
+	c.Assert(entries[0].Comment.Key, Equals, comment.Key)
+	c.Assert(entries[0].Reasons, DeepEquals, []string{"rude"})
+
+	c.Assert(comment.Moderate(s.other, "rude"), Equals, app.ErrTownNotMod)
+	c.Assert(comment.Moderate(s.moderator, "rude"), Equals, nil)
+	c.Assert(comment.Update(), Equals, nil)
+
+	entries, err = s.town1.CommentQueue(s.moderator, 0, 10)
+	c.Assert(err, Equals, nil)
+	c.Assert(len(entries), Equals, 0)
+
+	moderated, err := app.CommentGet(comment.Key)
+	c.Assert(err, Equals, nil)
+	c.Assert(moderated.Comment, Equals, "")
+	c.Assert(moderated.Moderation, NotNil)
+
+	c.Assert(moderated.RemoveModeration(s.moderator), Equals, nil)
+	c.Assert(moderated.Update(), Equals, nil)
+
+	restored, err := app.CommentGet(comment.Key)
+	c.Assert(err, Equals, nil)
+	c.Assert(restored.Comment, Equals, "Test comment")
+}
+
+func (s *QueueSuite) TestCommentDelete(c *C) {
+	comment, err := app.CommentNew(context.Background(), s.user, s.postPub, "Test comment")
+	c.Assert(err, Equals, nil)
+
+	reply, err := comment.Reply(context.Background(), s.other, "Test reply")
+	c.Assert(err, Equals, nil)
+
+	c.Assert(comment.Delete(s.other), Not(Equals), nil)
+	c.Assert(comment.Delete(s.user), Equals, nil)
+
+	tree, err := app.CommentGetTree(nil, comment.Key, 10, "")
+	c.Assert(err, Equals, nil)
+	c.Assert(tree.Deleted, Equals, true)
+	c.Assert(tree.Comment.Comment, Equals, "")
+	c.Assert(tree.Username, Equals, data.EmptyKey)
+	c.Assert(len(tree.Children), Equals, 1)
+	c.Assert(tree.Children[0].Key, Equals, reply.Key)
+}
@@ -4,11 +4,12 @@
 package app
 
 import (
+	"context"
 	"time"
 
-	log "git.townsourced.com/townsourced/logrus"
 	"github.com/timshannon/townsourced/data"
 	"github.com/timshannon/townsourced/fail"
+	"github.com/timshannon/townsourced/metrics"
 )
 
 //TODO: Simplify Rate limiting, and provide headers https://developer.github.com/v3/#rate-limiting
@@ -50,6 +51,13 @@ var ErrRequestMax = &fail.Fail{
 	HTTPStatus: 429,
 }
 
+var (
+	rateLimitDelays = metrics.NewCounter("townsourced_ratelimit_delays_total",
+		"Number of requests delayed for exceeding their free attempts, by request type.", "type")
+	rateLimitRejections = metrics.NewCounter("townsourced_ratelimit_rejections_total",
+		"Number of requests rejected for exceeding their max wait, by request type.", "type")
+)
+
 func (t *RequestType) setDefault() {
 	if t.Type == "" {
 		t.Type = attemptType
@@ -70,7 +78,7 @@ func (t *RequestType) setDefault() {
 
 // AttemptRequest logs a new attempt for the given ip address / or session ID of the given type, and waits
 // appropriately if they are exceeding the rate limit for the type
-func AttemptRequest(id string, reqType RequestType) error {
+func AttemptRequest(ctx context.Context, id string, reqType RequestType) error {
 	var attempts []RequestAttempt
 	reqType.setDefault()
 
@@ -104,12 +112,14 @@ func AttemptRequest(id string, reqType RequestType) error {
 	if len(attempts) > reqType.FreeAttempts {
 		wait := time.Duration(int64(len(attempts)-reqType.FreeAttempts) * int64(reqType.Scale))
 		if wait > reqType.MaxWait {
+			rateLimitRejections.Inc(reqType.Type)
 			return ErrRequestMax
 		}
+		rateLimitDelays.Inc(reqType.Type)
 
-		log.WithField("ID", id).Debugf("Start Ratelimit waiting for %s", reqType.Type)
+		requestLog(ctx).WithField("ID", id).Debugf("Start Ratelimit waiting for %s", reqType.Type)
 		time.Sleep(wait)
-		log.WithField("ID", id).Debugf("Finish Ratelimit waiting for %s", reqType.Type)
+		requestLog(ctx).WithField("ID", id).Debugf("Finish Ratelimit waiting for %s", reqType.Type)
 	}
 
 	return nil
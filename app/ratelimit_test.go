@@ -4,6 +4,7 @@
 package app_test
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -22,7 +23,7 @@ func (s *AppSuite) TestRateLimit(c *C) {
 
 	// free attempts
 	for i := 0; i < reqType.FreeAttempts; i++ {
-		err := app.AttemptRequest("testID", reqType)
+		err := app.AttemptRequest(context.Background(), "testID", reqType)
 		c.Assert(err, Equals, nil)
 	}
 
@@ -32,16 +33,16 @@ func (s *AppSuite) TestRateLimit(c *C) {
 
 	// delayed attempts
 	for i := 0; i < int(reqType.MaxWait/reqType.Scale); i++ {
-		err := app.AttemptRequest("testID", reqType)
+		err := app.AttemptRequest(context.Background(), "testID", reqType)
 		c.Assert(err, Equals, nil)
 	}
 
 	// errored attempt
-	err := app.AttemptRequest("testID", reqType)
+	err := app.AttemptRequest(context.Background(), "testID", reqType)
 	c.Assert(err, ErrorMatches, app.ErrRequestMax.Error())
 
 	// attempt limit should be freed after range expires
 	time.Sleep(reqType.Range)
-	err = app.AttemptRequest("testID", reqType)
+	err = app.AttemptRequest(context.Background(), "testID", reqType)
 	c.Assert(err, Equals, nil)
 }
@@ -0,0 +1,319 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package app
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/timshannon/townsourced/data"
+	"github.com/timshannon/townsourced/fail"
+)
+
+const (
+	ratingMinScore      = 1
+	ratingMaxScore      = 5
+	ratingMaxComment    = 500
+	ratingWindow        = 90 * 24 * time.Hour // how long after a post is published its users can rate each other
+	ratingMinUserDays   = 7
+	ratingMaxPerDay     = 20
+	ratingMaxRetrieve   = 100
+	ratingPriorCount    = 3 // reputation scores are pulled towards the middle until a user has a few ratings
+	ratingPriorScore    = 3.0
+	townModMaxMinRating = ratingMaxScore
+)
+
+// posts in these categories are transactions between users, which they can rate each other on
+var ratingCategories = []string{"buysell", "housing", "jobs"}
+
+var (
+	// ErrRatingNotFound is when a rating can't be found
+	ErrRatingNotFound = fail.New("Rating not found")
+	// ErrRatingSelf is when a user tries to rate themselves
+	ErrRatingSelf = fail.New("You can't rate yourself")
+	// ErrRatingNoTransaction is when a user tries to rate someone they haven't dealt with on the post
+	ErrRatingNoTransaction = fail.New("You can only rate the creator of a post who has replied to your comments or " +
+		"messages, or the users you've replied to on your own post")
+)
+
+// Rating is a score one user gives another after a transaction on a post.  Each user can rate the other user once
+// per post, rating them again replaces their previous rating
+type Rating struct {
+	Key      string    `json:"key,omitempty"`
+	Post     data.UUID `json:"post,omitempty"`
+	From     data.Key  `json:"from,omitempty"`
+	Username data.Key  `json:"username,omitempty"` // the user being rated
+	Score    int       `json:"score,omitempty"`
+	Comment  string    `json:"comment,omitempty"`
+	When     time.Time `json:"when,omitempty"`
+	Updated  time.Time `json:"updated,omitempty"`
+}
+
+// UserReputation is the summary of the ratings a user has received
+type UserReputation struct {
+	Count   int     `json:"count"`
+	Average float64 `json:"average"`
+	Score   float64 `json:"score"` // average weighted towards the middle score for users with few ratings
+}
+
+func ratingKey(post data.UUID, from, username data.Key) string {
+	return string(post) + "_" + string(from) + "_" + string(username)
+}
+
+// RatingGet retrieves a rating
+func RatingGet(post data.UUID, from, username data.Key) (*Rating, error) {
+	rating := &Rating{}
+	err := data.RatingGet(rating, ratingKey(post, from, username))
+	if err == data.ErrNotFound {
+		return nil, ErrRatingNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rating, nil
+}
+
+// Rate rates another user for a transaction on the post.  One of the two users must be the post's creator, and the
+// other must have commented on the post or messaged the creator since it was published
+func (u *User) Rate(username data.Key, post *Post, score int, comment string) (*Rating, error) {
+	if username == u.Username {
+		return nil, ErrRatingSelf
+	}
+
+	err := u.checkSuspended()
+	if err != nil {
+		return nil, err
+	}
+
+	if score < ratingMinScore || score > ratingMaxScore {
+		return nil, fail.New(fmt.Sprintf("A rating must be from %d to %d", ratingMinScore, ratingMaxScore), score)
+	}
+
+	comment = strings.TrimSpace(comment)
+	if len(comment) > ratingMaxComment {
+		return nil, fail.New(fmt.Sprintf("The rating's comment is too long.  The max is %d characters",
+			ratingMaxComment))
+	}
+
+	if u.Created.After(time.Now().AddDate(0, 0, -ratingMinUserDays)) {
+		return nil, fail.New(fmt.Sprintf("Your account must be at least %d days old to rate other users",
+			ratingMinUserDays))
+	}
+
+	if post.Status != PostStatusPublished && post.Status != PostStatusClosed {
+		return nil, fail.New("You can only rate users on published posts")
+	}
+
+	if !inStrings(ratingCategories, post.Category) {
+		return nil, fail.New("You can only rate users on posts in the " + strings.Join(ratingCategories, ", ") +
+			" categories")
+	}
+
+	if time.Since(post.Published) > ratingWindow {
+		return nil, fail.New(fmt.Sprintf("Users can only be rated within %d days of the post being published",
+			int(ratingWindow.Hours()/24)))
+	}
+
+	err = post.checkTransaction(u.Username, username)
+	if err != nil {
+		return nil, err
+	}
+
+	rated, err := UserGet(username)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	rating, err := RatingGet(post.Key, u.Username, username)
+	if err == ErrRatingNotFound {
+		count, err := data.RatingCountFrom(u.Username, now.Add(-24*time.Hour))
+		if err != nil {
+			return nil, err
+		}
+		if count >= ratingMaxPerDay {
+			return nil, fail.New(fmt.Sprintf("You can only rate %d users per day", ratingMaxPerDay))
+		}
+
+		rating = &Rating{
+			Key:      ratingKey(post.Key, u.Username, username),
+			Post:     post.Key,
+			From:     u.Username,
+			Username: username,
+			When:     now,
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	rating.Score = score
+	rating.Comment = comment
+	rating.Updated = now
+
+	err = data.RatingUpsert(rating)
+	if err != nil {
+		return nil, err
+	}
+
+	err = rated.updateReputation()
+	if err != nil {
+		return nil, err
+	}
+
+	return rating, nil
+}
+
+// checkTransaction returns an error unless the two users dealt with each other on the post, meaning one of them is
+// the post's creator, the other has commented on the post or messaged the creator since the post was published, and
+// the creator has replied to them.  A single comment isn't enough, so accounts can't cheaply rate each other
+func (p *Post) checkTransaction(user, other data.Key) error {
+	var buyer data.Key
+	switch p.Creator {
+	case user:
+		buyer = other
+	case other:
+		buyer = user
+	default:
+		return ErrRatingNoTransaction
+	}
+
+	comments, err := data.CommentCountByUserOnPost(buyer, p.Key)
+	if err != nil {
+		return err
+	}
+	// only private messages are sent from another user
+	sent, err := data.NotificationCountSent(buyer, p.Creator, p.Published)
+	if err != nil {
+		return err
+	}
+	if comments+sent == 0 {
+		return ErrRatingNoTransaction
+	}
+
+	replies, err := data.CommentCountRepliesOnPost(p.Creator, buyer, p.Key)
+	if err != nil {
+		return err
+	}
+	if replies > 0 {
+		return nil
+	}
+
+	received, err := data.NotificationCountSent(p.Creator, buyer, p.Published)
+	if err != nil {
+		return err
+	}
+	if received == 0 {
+		return ErrRatingNoTransaction
+	}
+	return nil
+}
+
+// Remove removes a rating, which can be done by the user who gave it, or by staff who review content
+func (r *Rating) Remove(who *User, reason string) error {
+	if who == nil {
+		return ErrNotAdmin
+	}
+
+	if who.Username != r.From {
+		err := who.checkPermission(permReviewContent)
+		if err != nil {
+			return err
+		}
+
+		reason = strings.TrimSpace(reason)
+		if reason == "" {
+			return fail.New("A reason is required")
+		}
+	}
+
+	err := data.RatingDelete(r.Key)
+	if err != nil {
+		return err
+	}
+
+	rated, err := UserGet(r.Username)
+	if err != nil {
+		return err
+	}
+
+	err = rated.updateReputation()
+	if err != nil {
+		return err
+	}
+
+	if who.Username == r.From {
+		return nil
+	}
+
+	return audit(who.Username, AuditRatingRemove, r.Username, reason, map[string]interface{}{
+		"post":  r.Post,
+		"from":  r.From,
+		"score": r.Score,
+	})
+}
+
+// Ratings retrieves the ratings the user has received, newest first
+func (u *User) Ratings(since time.Time, limit int) ([]Rating, error) {
+	var ratings []Rating
+
+	limit = int(math.Min(math.Max(float64(1), float64(limit)), float64(ratingMaxRetrieve)))
+
+	err := data.RatingsGetByUser(&ratings, u.Username, since, limit)
+	if err == data.ErrNotFound {
+		return []Rating{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return ratings, nil
+}
+
+// updateReputation recalculates the user's reputation from the ratings they've received
+func (u *User) updateReputation() error {
+	count, total, err := data.RatingSummary(u.Username)
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		u.Reputation = nil
+	} else {
+		u.Reputation = &UserReputation{
+			Count:   count,
+			Average: float64(total) / float64(count),
+			Score: (float64(total) + ratingPriorCount*ratingPriorScore) /
+				float64(count+ratingPriorCount),
+		}
+	}
+
+	return u.Update()
+}
+
+// SetAutoModMinUserRating sets the minimum reputation score a user must have to post in this town.  Users who
+// haven't been rated yet aren't affected, 0 turns it off
+func (t *Town) SetAutoModMinUserRating(who *User, minUserRating float64) error {
+	if !t.mod(who).active() {
+		return ErrTownNotMod
+	}
+
+	err := validateMinUserRating(minUserRating)
+	if err != nil {
+		return err
+	}
+
+	t.logSetting(who, TownLogAutoModMinUserRating, t.AutoModerator.MinUserRating, minUserRating)
+	t.AutoModerator.MinUserRating = minUserRating
+	return nil
+}
+
+func validateMinUserRating(minUserRating float64) error {
+	if minUserRating != 0 && (minUserRating < ratingMinScore || minUserRating > townModMaxMinRating) {
+		return fail.New(fmt.Sprintf("The minimum user rating must be from %d to %d, or 0 to turn it off",
+			ratingMinScore, townModMaxMinRating), minUserRating)
+	}
+	return nil
+}
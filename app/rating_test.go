@@ -0,0 +1,156 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package app_test
+
+import (
+	"context"
+	"time"
+
+	. "git.townsourced.com/townsourced/check"
+	rt "git.townsourced.com/townsourced/gorethink"
+	"github.com/timshannon/townsourced/app"
+	"github.com/timshannon/townsourced/data"
+)
+
+// Rating Test Suite
+type RatingSuite struct {
+	*testData
+	comments []*app.Comment
+	posts    []*app.Post
+}
+
+var _ = Suite(&RatingSuite{testData: &testData{}})
+
+func (s *RatingSuite) SetUpTest(c *C) {
+	s.testData.setup(c)
+
+	// new accounts can't rate other users
+	s.user = s.backdate(c, s.user)
+	s.other = s.backdate(c, s.other)
+}
+
+func (s *RatingSuite) TearDownTest(c *C) {
+	_, err := rt.DB(data.DefaultConfig().DB.Database).Table("rating").
+		Filter(rt.Row.Field("Post").Eq(s.postPub.Key)).Delete().RunWrite(s.client.database())
+	c.Assert(err, Equals, nil)
+
+	for i := range s.comments {
+		s.deleteComment(c, s.comments[i])
+	}
+	s.comments = nil
+	for i := range s.posts {
+		s.deletePost(c, s.posts[i])
+	}
+	s.posts = nil
+	s.testData.teardown(c)
+}
+
+func (s *RatingSuite) backdate(c *C, u *app.User) *app.User {
+	_, err := rt.DB(data.DefaultConfig().DB.Database).Table("user").Get(u.Username).
+		Update(map[string]interface{}{"Created": time.Now().AddDate(0, 0, -30)}).RunWrite(s.client.database())
+	c.Assert(err, Equals, nil)
+
+	u, err = app.UserGet(u.Username)
+	c.Assert(err, Equals, nil)
+	return u
+}
+
+func (s *RatingSuite) TestRate(c *C) {
+	_, err := s.other.Rate(s.user.Username, s.postPub, 5, "")
+	c.Assert(err, Equals, app.ErrRatingNoTransaction)
+
+	_, err = s.moderator.Rate(s.user.Username, s.postPub, 5, "")
+	c.Assert(err, Not(Equals), nil)
+
+	comment, err := app.CommentNew(context.Background(), s.other, s.postPub, "Is this still available?")
+	c.Assert(err, Equals, nil)
+	s.comments = append(s.comments, comment)
+
+	// a comment alone isn't a transaction until the post's creator replies
+	_, err = s.other.Rate(s.user.Username, s.postPub, 5, "")
+	c.Assert(err, Equals, app.ErrRatingNoTransaction)
+	_, err = s.user.Rate(s.other.Username, s.postPub, 5, "")
+	c.Assert(err, Equals, app.ErrRatingNoTransaction)
+
+	reply, err := comment.Reply(context.Background(), s.user, "Yes it is")
+	c.Assert(err, Equals, nil)
+	s.comments = append(s.comments, reply)
+
+	_, err = s.user.Rate(s.user.Username, s.postPub, 5, "")
+	c.Assert(err, Equals, app.ErrRatingSelf)
+	_, err = s.other.Rate(s.user.Username, s.postPub, 6, "")
+	c.Assert(err, Not(Equals), nil)
+	_, err = s.other.Rate(s.user.Username, s.postDraft, 5, "")
+	c.Assert(err, Not(Equals), nil)
+
+	_, err = s.other.Rate(s.user.Username, s.postPub, 5, "Great seller")
+	c.Assert(err, Equals, nil)
+
+	// rating again replaces the previous rating
+	rating, err := s.other.Rate(s.user.Username, s.postPub, 1, "Never showed up")
+	c.Assert(err, Equals, nil)
+	c.Assert(rating.Score, Equals, 1)
+
+	rated, err := app.UserGet(s.user.Username)
+	c.Assert(err, Equals, nil)
+	c.Assert(rated.Reputation, Not(Equals), (*app.UserReputation)(nil))
+	c.Assert(rated.Reputation.Count, Equals, 1)
+	c.Assert(rated.Reputation.Average, Equals, 1.0)
+	c.Assert(rated.Reputation.Score, Equals, 2.5)
+
+	ratings, err := rated.Ratings(time.Time{}, 10)
+	c.Assert(err, Equals, nil)
+	c.Assert(len(ratings), Equals, 1)
+	c.Assert(ratings[0].Comment, Equals, "Never showed up")
+
+	// the post's creator can rate the user who commented
+	_, err = s.user.Rate(s.other.Username, s.postPub, 4, "")
+	c.Assert(err, Equals, nil)
+
+	// only the rater or staff can remove a rating
+	c.Assert(rating.Remove(s.moderator, "abuse"), Equals, app.ErrNotAdmin)
+	c.Assert(rating.Remove(s.other, ""), Equals, nil)
+
+	rated, err = app.UserGet(s.user.Username)
+	c.Assert(err, Equals, nil)
+	c.Assert(rated.Reputation, Equals, (*app.UserReputation)(nil))
+}
+
+func (s *RatingSuite) TestMinUserRating(c *C) {
+	c.Assert(s.town1.SetAutoModMinUserRating(s.other, 3), Equals, app.ErrTownNotMod)
+	c.Assert(s.town1.SetAutoModMinUserRating(s.moderator, 0.5), Not(Equals), nil)
+	c.Assert(s.town1.SetAutoModMinUserRating(s.moderator, 6), Not(Equals), nil)
+	c.Assert(s.town1.SetAutoModMinUserRating(s.moderator, 3), Equals, nil)
+	c.Assert(s.town1.Update(), Equals, nil)
+
+	// users who haven't been rated can post
+	post, err := app.PostNew(context.Background(), "unrated post", "test content", "buysell", app.PostFormatStandard,
+		s.user, []data.Key{s.town1.Key}, nil, data.EmptyUUID, true, true, false)
+	c.Assert(err, Equals, nil)
+	s.posts = append(s.posts, post)
+	c.Assert(moderatedIn(post, s.town1), Equals, false)
+
+	comment, err := app.CommentNew(context.Background(), s.other, s.postPub, "Is this still available?")
+	c.Assert(err, Equals, nil)
+	s.comments = append(s.comments, comment)
+	reply, err := comment.Reply(context.Background(), s.user, "Yes it is")
+	c.Assert(err, Equals, nil)
+	s.comments = append(s.comments, reply)
+
+	_, err = s.other.Rate(s.user.Username, s.postPub, 1, "")
+	c.Assert(err, Equals, nil)
+
+	user, err := app.UserGet(s.user.Username)
+	c.Assert(err, Equals, nil)
+
+	post, err = app.PostNew(context.Background(), "low rated post", "test content", "buysell", app.PostFormatStandard,
+		user, []data.Key{s.town1.Key}, nil, data.EmptyUUID, true, true, false)
+	c.Assert(err, Equals, nil)
+	s.posts = append(s.posts, post)
+	c.Assert(moderatedIn(post, s.town1), Equals, true)
+
+	entries, err := s.town1.Log(s.moderator, app.TownLogAutoModMinUserRating, 0, 10)
+	c.Assert(err, Equals, nil)
+	c.Assert(len(entries), Equals, 1)
+}
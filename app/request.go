@@ -0,0 +1,62 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package app
+
+import (
+	"context"
+
+	log "git.townsourced.com/townsourced/logrus"
+)
+
+// RequestIDField is the log field the current request ID is recorded in
+const RequestIDField = "requestID"
+
+// maxRequestIDLength is the longest request ID accepted from a client or load balancer
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// NewRequestID returns the passed in request ID if it's valid, otherwise it returns a new random one
+func NewRequestID(id string) string {
+	if validRequestID(id) {
+		return id
+	}
+	return Random(128)
+}
+
+// validRequestID only allows IDs which are safe to echo back in headers and write to logs
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// WithRequestID returns a copy of the context carrying the request ID, so that the app layer can tie what it logs
+// while handling a request back to it
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID returns the request ID carried by the context, if any
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// requestLog returns a log entry with the ID of the request the context belongs to
+func requestLog(ctx context.Context) *log.Entry {
+	entry := log.NewEntry(log.StandardLogger())
+	if id := RequestID(ctx); id != "" {
+		return entry.WithField(RequestIDField, id)
+	}
+	return entry
+}
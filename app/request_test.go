@@ -0,0 +1,46 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package app_test
+
+import (
+	"context"
+	"strings"
+
+	. "git.townsourced.com/townsourced/check"
+	"github.com/timshannon/townsourced/app"
+)
+
+// Request Test Suite
+type RequestSuite struct{}
+
+var _ = Suite(&RequestSuite{})
+
+func (s *RequestSuite) TestNewRequestID(c *C) {
+	c.Assert(app.NewRequestID("lb-1234_abc.5"), Equals, "lb-1234_abc.5")
+
+	for _, id := range []string{
+		"",
+		"has spaces",
+		"new\nline",
+		"quote\"",
+		strings.Repeat("a", 129),
+	} {
+		newID := app.NewRequestID(id)
+		c.Assert(newID, Not(Equals), id)
+		c.Assert(app.NewRequestID(newID), Equals, newID)
+	}
+
+	c.Assert(app.NewRequestID(""), Not(Equals), app.NewRequestID(""))
+}
+
+func (s *RequestSuite) TestRequestIDContext(c *C) {
+	ctx := context.Background()
+	c.Assert(app.RequestID(ctx), Equals, "")
+
+	reqCtx := app.WithRequestID(ctx, "test-request")
+	c.Assert(app.RequestID(reqCtx), Equals, "test-request")
+
+	// the parent context isn't part of the request
+	c.Assert(app.RequestID(ctx), Equals, "")
+}
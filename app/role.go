@@ -0,0 +1,227 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package app
+
+import (
+	"strings"
+
+	"github.com/timshannon/townsourced/data"
+	"github.com/timshannon/townsourced/fail"
+)
+
+// Site roles, which give users access to parts of the admin page
+const (
+	RoleAdmin    = "admin"    // everything, including managing roles
+	RoleSupport  = "support"  // handles problem users and investigates errors
+	RoleReviewer = "reviewer" // reviews reported and flagged content
+)
+
+type permission string
+
+// permissions granted by site roles
+const (
+	permViewStats     permission = "viewStats"
+	permViewLog       permission = "viewLog"
+	permViewAudit     permission = "viewAudit"
+	permSuspendUsers  permission = "suspendUsers"
+	permReviewContent permission = "reviewContent"
+	permBlockDomains  permission = "blockDomains"
+	permManageRoles   permission = "manageRoles"
+)
+
+var rolePermissions = map[string][]permission{
+	RoleAdmin: []permission{permViewStats, permViewLog, permViewAudit, permSuspendUsers, permReviewContent,
+		permBlockDomains, permManageRoles},
+	RoleSupport:  []permission{permViewStats, permViewLog, permViewAudit, permSuspendUsers},
+	RoleReviewer: []permission{permViewStats, permReviewContent},
+}
+
+// auditConsole is recorded as who made a change from the command line, rather than through the site.  It can't
+// collide with a real username, as usernames can only contain letters, numbers and dashes
+const auditConsole = data.Key("(console)")
+
+// ErrRoleInvalid is when granting or revoking a role that doesn't exist
+var ErrRoleInvalid = fail.New("Invalid role, must be one of admin, support or reviewer")
+
+// HasRole is whether or not the user has been granted the site role.  Users with Admin set directly in the
+// database have the admin role
+func (u *User) HasRole(role string) bool {
+	if role == RoleAdmin && u.Admin {
+		return true
+	}
+	for i := range u.Roles {
+		if u.Roles[i] == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Staff is whether or not the user has any site role
+func (u *User) Staff() bool {
+	return u.Admin || len(u.Roles) != 0
+}
+
+// Permissions returns the names of every permission granted by the user's roles
+func (u *User) Permissions() []string {
+	var perms []string
+	for role, rolePerms := range rolePermissions {
+		if !u.HasRole(role) {
+			continue
+		}
+		for i := range rolePerms {
+			if !inStrings(perms, string(rolePerms[i])) {
+				perms = append(perms, string(rolePerms[i]))
+			}
+		}
+	}
+	return perms
+}
+
+func (u *User) can(perm permission) bool {
+	for role, rolePerms := range rolePermissions {
+		if !u.HasRole(role) {
+			continue
+		}
+		for i := range rolePerms {
+			if rolePerms[i] == perm {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkPermission returns ErrNotAdmin if the user doesn't have a role with the passed in permission
+func (u *User) checkPermission(perm permission) error {
+	if u == nil || !u.can(perm) {
+		return ErrNotAdmin
+	}
+	return nil
+}
+
+func validRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// GrantRole grants the user a site role
+func (u *User) GrantRole(who *User, role, reason string) error {
+	err := who.checkPermission(permManageRoles)
+	if err != nil {
+		return err
+	}
+
+	return u.grantRole(who.Username, role, reason)
+}
+
+// RevokeRole revokes a site role from the user
+func (u *User) RevokeRole(who *User, role, reason string) error {
+	err := who.checkPermission(permManageRoles)
+	if err != nil {
+		return err
+	}
+
+	if who.Username == u.Username && role == RoleAdmin {
+		return fail.New("You can't revoke your own admin role")
+	}
+
+	return u.revokeRole(who.Username, role, reason)
+}
+
+// ConsoleGrantRole grants a user a site role from the command line, such as when setting up the first admin
+func ConsoleGrantRole(username data.Key, role string) error {
+	u, err := UserGet(username)
+	if err != nil {
+		return err
+	}
+
+	return u.grantRole(auditConsole, role, "Granted from the command line")
+}
+
+// ConsoleRevokeRole revokes a site role from a user from the command line
+func ConsoleRevokeRole(username data.Key, role string) error {
+	u, err := UserGet(username)
+	if err != nil {
+		return err
+	}
+
+	return u.revokeRole(auditConsole, role, "Revoked from the command line")
+}
+
+func (u *User) grantRole(who data.Key, role, reason string) error {
+	role = strings.ToLower(strings.TrimSpace(role))
+	if !validRole(role) {
+		return fail.NewFromErr(ErrRoleInvalid, role)
+	}
+
+	if u.Suspended() {
+		return fail.New("Suspended or banned users can't be granted a role")
+	}
+
+	if inStrings(u.Roles, role) {
+		return nil
+	}
+
+	u.Roles = append(u.Roles, role)
+	if role == RoleAdmin {
+		// kept in sync for anything still checking the admin flag, such as the front end
+		u.Admin = true
+	}
+
+	err := u.Update()
+	if err != nil {
+		return err
+	}
+
+	return audit(who, AuditRoleGrant, u.Username, reason, map[string]interface{}{"role": role})
+}
+
+func (u *User) revokeRole(who data.Key, role, reason string) error {
+	role = strings.ToLower(strings.TrimSpace(role))
+	if !validRole(role) {
+		return fail.NewFromErr(ErrRoleInvalid, role)
+	}
+
+	if !u.HasRole(role) {
+		return nil
+	}
+
+	roles := make([]string, 0, len(u.Roles))
+	for i := range u.Roles {
+		if u.Roles[i] != role {
+			roles = append(roles, u.Roles[i])
+		}
+	}
+	u.Roles = roles
+	if role == RoleAdmin {
+		u.Admin = false
+	}
+
+	err := u.Update()
+	if err != nil {
+		return err
+	}
+
+	return audit(who, AuditRoleRevoke, u.Username, reason, map[string]interface{}{"role": role})
+}
+
+// AdminStaffGet retrieves every user with a site role
+func AdminStaffGet(who *User) ([]*User, error) {
+	err := who.checkPermission(permManageRoles)
+	if err != nil {
+		return nil, err
+	}
+
+	var users []*User
+	err = data.UserGetStaff(&users)
+	if err == data.ErrNotFound {
+		return []*User{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
@@ -0,0 +1,89 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package app_test
+
+import (
+	"time"
+
+	. "git.townsourced.com/townsourced/check"
+	"github.com/timshannon/townsourced/app"
+)
+
+// Role Test Suite
+type RoleSuite struct {
+	*testData
+	admin *app.User
+}
+
+var _ = Suite(&RoleSuite{testData: &testData{}})
+
+func (s *RoleSuite) SetUpTest(c *C) {
+	s.testData.setup(c)
+	s.admin = s.moderator
+	s.admin.Admin = true
+}
+
+func (s *RoleSuite) TearDownTest(c *C) {
+	s.testData.teardown(c)
+}
+
+func (s *RoleSuite) TestHasRole(c *C) {
+	u := &app.User{}
+	c.Assert(u.Staff(), Equals, false)
+	c.Assert(u.HasRole(app.RoleAdmin), Equals, false)
+	c.Assert(len(u.Permissions()), Equals, 0)
+
+	u.Admin = true
+	c.Assert(u.Staff(), Equals, true)
+	c.Assert(u.HasRole(app.RoleAdmin), Equals, true)
+	c.Assert(u.HasRole(app.RoleSupport), Equals, false)
+
+	u = &app.User{Roles: []string{app.RoleReviewer}}
+	c.Assert(u.Staff(), Equals, true)
+	c.Assert(u.HasRole(app.RoleReviewer), Equals, true)
+	c.Assert(u.HasRole(app.RoleAdmin), Equals, false)
+	c.Assert(len(u.Permissions()), Equals, 2)
+}
+
+func (s *RoleSuite) TestGrantRole(c *C) {
+	c.Assert(s.user.GrantRole(s.other, app.RoleAdmin, "promotion"), Equals, app.ErrNotAdmin)
+	c.Assert(s.user.GrantRole(s.admin, "superuser", "promotion"), Not(Equals), nil)
+
+	c.Assert(s.user.GrantRole(s.admin, app.RoleSupport, "promotion"), Equals, nil)
+
+	u, err := app.UserGet(s.user.Username)
+	c.Assert(err, Equals, nil)
+	c.Assert(u.HasRole(app.RoleSupport), Equals, true)
+	c.Assert(u.Admin, Equals, false)
+
+	// support can't manage roles
+	c.Assert(s.other.GrantRole(u, app.RoleSupport, "promotion"), Equals, app.ErrNotAdmin)
+
+	// support can view the audit trail, which records the grant
+	entries, err := app.AdminAuditGet(u, u.Username, 0, 10)
+	c.Assert(err, Equals, nil)
+	c.Assert(len(entries), Equals, 1)
+	c.Assert(entries[0].Action, Equals, app.AuditRoleGrant)
+	c.Assert(entries[0].Who, Equals, s.admin.Username)
+
+	// staff can't be suspended
+	c.Assert(u.Suspend(s.admin, time.Now().Add(time.Hour), "spam"), Not(Equals), nil)
+}
+
+func (s *RoleSuite) TestRevokeRole(c *C) {
+	c.Assert(app.ConsoleGrantRole(s.user.Username, app.RoleAdmin), Equals, nil)
+
+	u, err := app.UserGet(s.user.Username)
+	c.Assert(err, Equals, nil)
+	c.Assert(u.Admin, Equals, true)
+
+	c.Assert(u.RevokeRole(u, app.RoleAdmin, "demotion"), Not(Equals), nil)
+	c.Assert(u.RevokeRole(s.other, app.RoleAdmin, "demotion"), Equals, app.ErrNotAdmin)
+	c.Assert(u.RevokeRole(s.admin, app.RoleAdmin, "demotion"), Equals, nil)
+
+	u, err = app.UserGet(s.user.Username)
+	c.Assert(err, Equals, nil)
+	c.Assert(u.Admin, Equals, false)
+	c.Assert(u.Staff(), Equals, false)
+}
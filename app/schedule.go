@@ -0,0 +1,337 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package app
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedules determine when a task next runs.  They can be parsed from the following specs:
+//
+// Cron expressions with 5 fields:  minute hour day-of-month month day-of-week
+// 	"0 3 * * *"		every night at 3am
+// 	"*/15 * * * 1-5"	every 15 minutes on weekdays
+// Month and day-of-week fields accept names (JAN-DEC, SUN-SAT), and the following descriptors are accepted in
+// place of the fields: @yearly, @annually, @monthly, @weekly, @daily, @midnight, @hourly
+//
+// Cron expressions can be prefixed with a timezone, otherwise they run in the server's local time
+// 	"TZ=America/Chicago 0 3 * * *"
+//
+// Intervals which are aligned to the clock, with an optional random jitter added to each run, so
+// that several servers don't all run the same thing at the same time
+// 	"@every 15m"
+// 	"@every 1h jitter 5m"
+
+// Schedule determines when a task should next run
+type Schedule interface {
+	Next(from time.Time) time.Time // Next returns the next run time after from, a zero time means never run again
+	String() string                // String returns the spec of the schedule, used to detect schedule changes
+}
+
+// scheduleOnce is for tasks that only run once
+var scheduleOnce Schedule = onceSchedule{}
+
+type onceSchedule struct{}
+
+func (o onceSchedule) Next(from time.Time) time.Time { return time.Time{} }
+func (o onceSchedule) String() string                { return "@once" }
+
+// ParseSchedule parses a cron expression or interval spec into a Schedule
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, fmt.Errorf("Empty schedule spec")
+	}
+
+	if spec == scheduleOnce.String() {
+		return scheduleOnce, nil
+	}
+
+	if strings.HasPrefix(spec, "@every") {
+		return parseInterval(spec)
+	}
+
+	return parseCron(spec)
+}
+
+// mustParseSchedule is for schedules defined in code, and panics if the spec is invalid
+func mustParseSchedule(spec string) Schedule {
+	s, err := ParseSchedule(spec)
+	if err != nil {
+		panic(fmt.Sprintf("Invalid schedule %s: %s", spec, err))
+	}
+	return s
+}
+
+type intervalSchedule struct {
+	interval time.Duration
+	jitter   time.Duration
+}
+
+func parseInterval(spec string) (Schedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 2 && len(fields) != 4 {
+		return nil, fmt.Errorf("Invalid interval schedule %s, expected @every <duration> [jitter <duration>]", spec)
+	}
+
+	s := &intervalSchedule{}
+	var err error
+
+	s.interval, err = time.ParseDuration(fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("Invalid interval in schedule %s: %s", spec, err)
+	}
+	if s.interval < time.Second {
+		return nil, fmt.Errorf("Invalid interval in schedule %s: interval must be at least 1s", spec)
+	}
+
+	if len(fields) == 4 {
+		if fields[2] != "jitter" {
+			return nil, fmt.Errorf("Invalid interval schedule %s, expected @every <duration> [jitter <duration>]", spec)
+		}
+		s.jitter, err = time.ParseDuration(fields[3])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid jitter in schedule %s: %s", spec, err)
+		}
+		if s.jitter < 0 || s.jitter >= s.interval {
+			return nil, fmt.Errorf("Invalid jitter in schedule %s: jitter must be positive and less than the interval",
+				spec)
+		}
+	}
+
+	return s, nil
+}
+
+// Next returns the next interval boundary after from, plus a random jitter
+// Intervals are aligned to the clock so that run times don't drift from how long the task took to run
+func (s *intervalSchedule) Next(from time.Time) time.Time {
+	next := from.Truncate(s.interval).Add(s.interval)
+	if s.jitter > 0 {
+		next = next.Add(time.Duration(rand.Int63n(int64(s.jitter))))
+	}
+	return next
+}
+
+func (s *intervalSchedule) String() string {
+	if s.jitter > 0 {
+		return fmt.Sprintf("@every %s jitter %s", s.interval, s.jitter)
+	}
+	return fmt.Sprintf("@every %s", s.interval)
+}
+
+type cronSchedule struct {
+	spec     string
+	location *time.Location
+
+	minute, hour, dom, month, dow uint64
+}
+
+type cronField struct {
+	min, max uint
+	names    map[string]uint
+}
+
+var (
+	cronMinute = cronField{min: 0, max: 59}
+	cronHour   = cronField{min: 0, max: 23}
+	cronDom    = cronField{min: 1, max: 31}
+	cronMonth  = cronField{min: 1, max: 12, names: map[string]uint{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	cronDow = cronField{min: 0, max: 6, names: map[string]uint{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronStar is set on a field's bits when the field was a *, and is used to determine how day of month and day of
+// week are matched
+const cronStar = 1 << 63
+
+func parseCron(spec string) (Schedule, error) {
+	s := &cronSchedule{
+		spec:     strings.Join(strings.Fields(spec), " "),
+		location: time.Local,
+	}
+
+	expr := s.spec
+	if strings.HasPrefix(expr, "TZ=") {
+		i := strings.Index(expr, " ")
+		if i == -1 {
+			return nil, fmt.Errorf("Invalid cron schedule %s, missing expression after timezone", spec)
+		}
+		loc, err := time.LoadLocation(expr[len("TZ="):i])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid timezone in cron schedule %s: %s", spec, err)
+		}
+		s.location = loc
+		expr = expr[i+1:]
+	}
+
+	if strings.HasPrefix(expr, "@") {
+		desc, ok := cronDescriptors[expr]
+		if !ok {
+			return nil, fmt.Errorf("Invalid cron schedule %s, unknown descriptor %s", spec, expr)
+		}
+		expr = desc
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("Invalid cron schedule %s, expected 5 fields but found %d", spec, len(fields))
+	}
+
+	var err error
+	for i, f := range []struct {
+		bits  *uint64
+		field cronField
+		name  string
+	}{
+		{&s.minute, cronMinute, "minute"},
+		{&s.hour, cronHour, "hour"},
+		{&s.dom, cronDom, "day of month"},
+		{&s.month, cronMonth, "month"},
+		{&s.dow, cronDow, "day of week"},
+	} {
+		*f.bits, err = f.field.parse(fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid %s in cron schedule %s: %s", f.name, spec, err)
+		}
+	}
+
+	return s, nil
+}
+
+// parse parses a single cron field made up of comma separated lists of values, ranges and steps
+// e.g. 1,5,10-20/2,*/15
+func (c cronField) parse(field string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := uint(1)
+		rng := part
+		if i := strings.Index(part, "/"); i != -1 {
+			s, err := strconv.ParseUint(part[i+1:], 10, 8)
+			if err != nil || s == 0 {
+				return 0, fmt.Errorf("invalid step %s", part[i+1:])
+			}
+			step = uint(s)
+			rng = part[:i]
+		}
+
+		var start, end uint
+		switch {
+		case rng == "*":
+			start, end = c.min, c.max
+			if step == 1 {
+				bits |= cronStar
+			}
+		case strings.Contains(rng, "-"):
+			i := strings.Index(rng, "-")
+			var err error
+			start, err = c.value(rng[:i])
+			if err != nil {
+				return 0, err
+			}
+			end, err = c.value(rng[i+1:])
+			if err != nil {
+				return 0, err
+			}
+			if end < start {
+				return 0, fmt.Errorf("invalid range %s", rng)
+			}
+		default:
+			v, err := c.value(rng)
+			if err != nil {
+				return 0, err
+			}
+			start, end = v, v
+			if step != 1 {
+				end = c.max
+			}
+		}
+
+		for i := start; i <= end; i += step {
+			bits |= 1 << i
+		}
+	}
+
+	return bits, nil
+}
+
+func (c cronField) value(v string) (uint, error) {
+	if n, ok := c.names[strings.ToLower(v)]; ok {
+		return n, nil
+	}
+
+	n, err := strconv.ParseUint(v, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %s", v)
+	}
+	if uint(n) < c.min || uint(n) > c.max {
+		return 0, fmt.Errorf("value %d is outside of the range %d-%d", n, c.min, c.max)
+	}
+	return uint(n), nil
+}
+
+// cronSearchYears is how far ahead Next will look for a matching time before giving up
+const cronSearchYears = 5
+
+// Next returns the next time after from that matches the cron expression
+// if no time matches in the next few years (i.e. Feb 30th), then a zero time is returned
+func (s *cronSchedule) Next(from time.Time) time.Time {
+	t := from.In(s.location).Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(cronSearchYears, 0, 0)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.location)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.location)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, s.location).Add(time.Hour)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
+
+// dayMatches follows the standard cron rules, if either day of month or day of week are restricted (not *), then a
+// day matches if either field matches
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+
+	if s.dom&cronStar != 0 || s.dow&cronStar != 0 {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+func (s *cronSchedule) String() string {
+	return s.spec
+}
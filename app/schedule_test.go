@@ -0,0 +1,90 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package app_test
+
+import (
+	"time"
+
+	. "git.townsourced.com/townsourced/check"
+	"github.com/timshannon/townsourced/app"
+)
+
+// Schedule Test Suite
+type ScheduleSuite struct{}
+
+var _ = Suite(&ScheduleSuite{})
+
+func (s *ScheduleSuite) TestScheduleCron(c *C) {
+	loc, err := time.LoadLocation("America/Chicago")
+	c.Assert(err, Equals, nil)
+
+	sched, err := app.ParseSchedule("TZ=America/Chicago 0 3 * * *")
+	c.Assert(err, Equals, nil)
+
+	from := time.Date(2016, time.March, 1, 2, 30, 0, 0, loc)
+	c.Assert(sched.Next(from).Equal(time.Date(2016, time.March, 1, 3, 0, 0, 0, loc)), Equals, true)
+
+	from = time.Date(2016, time.March, 1, 3, 0, 0, 0, loc)
+	c.Assert(sched.Next(from).Equal(time.Date(2016, time.March, 2, 3, 0, 0, 0, loc)), Equals, true)
+
+	// steps, ranges and names
+	sched, err = app.ParseSchedule("*/15 9-17 * * mon-fri")
+	c.Assert(err, Equals, nil)
+
+	from = time.Date(2016, time.March, 4, 17, 50, 0, 0, time.Local) // friday
+	c.Assert(sched.Next(from).Equal(time.Date(2016, time.March, 7, 9, 0, 0, 0, time.Local)), Equals, true)
+
+	// day of month or day of week
+	sched, err = app.ParseSchedule("0 0 1 * sun")
+	c.Assert(err, Equals, nil)
+
+	from = time.Date(2016, time.March, 1, 12, 0, 0, 0, time.Local)
+	c.Assert(sched.Next(from).Equal(time.Date(2016, time.March, 6, 0, 0, 0, 0, time.Local)), Equals, true)
+
+	// descriptors
+	sched, err = app.ParseSchedule("@monthly")
+	c.Assert(err, Equals, nil)
+	c.Assert(sched.Next(from).Equal(time.Date(2016, time.April, 1, 0, 0, 0, 0, time.Local)), Equals, true)
+
+	// never matches
+	sched, err = app.ParseSchedule("0 0 30 feb *")
+	c.Assert(err, Equals, nil)
+	c.Assert(sched.Next(from).IsZero(), Equals, true)
+}
+
+func (s *ScheduleSuite) TestScheduleInterval(c *C) {
+	sched, err := app.ParseSchedule("@every 15m")
+	c.Assert(err, Equals, nil)
+	c.Assert(sched.String(), Equals, "@every 15m0s")
+
+	from := time.Date(2016, time.March, 1, 2, 31, 12, 0, time.UTC)
+	c.Assert(sched.Next(from).Equal(time.Date(2016, time.March, 1, 2, 45, 0, 0, time.UTC)), Equals, true)
+
+	sched, err = app.ParseSchedule("@every 1h jitter 5m")
+	c.Assert(err, Equals, nil)
+
+	for i := 0; i < 100; i++ {
+		next := sched.Next(from)
+		c.Assert(next.Before(time.Date(2016, time.March, 1, 3, 0, 0, 0, time.UTC)), Equals, false)
+		c.Assert(next.Before(time.Date(2016, time.March, 1, 3, 5, 0, 0, time.UTC)), Equals, true)
+	}
+}
+
+func (s *ScheduleSuite) TestScheduleInvalid(c *C) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* * * * 7-1",
+		"*/0 * * * *",
+		"@fortnightly",
+		"TZ=Not/AZone 0 3 * * *",
+		"@every",
+		"@every 15m jitter 15m",
+		"@every 15m wobble 1m",
+	} {
+		_, err := app.ParseSchedule(spec)
+		c.Assert(err, Not(Equals), nil, Commentf("spec: %s", spec))
+	}
+}
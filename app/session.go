@@ -47,6 +47,11 @@ func SessionGet(sessionKey string) (*Session, error) {
 
 // SessionNew generates a new session for the passed in user
 func SessionNew(user *User, expires time.Time, ipAddress, userAgent string) (*Session, error) {
+	err := user.checkSuspended()
+	if err != nil {
+		return nil, err
+	}
+
 	if expires.IsZero() {
 		expires = time.Now().AddDate(0, 0, 3)
 	}
@@ -63,7 +68,7 @@ func SessionNew(user *User, expires time.Time, ipAddress, userAgent string) (*Se
 	}
 	s.Key = string(s.UserKey) + "_" + s.SessionID
 	//insert
-	err := data.SessionInsert(s, s.Key, s.Expires)
+	err = data.SessionInsert(s, s.Key, s.Expires)
 	if err != nil {
 		return nil, err
 	}
@@ -5,6 +5,7 @@ package app
 
 import (
 	"bytes"
+	"context"
 	"net/http"
 	"net/url"
 	"strings"
@@ -26,6 +27,7 @@ var (
 type shareDoc struct {
 	*goquery.Document
 	useragent string
+	logger    *log.Entry
 }
 
 // Share processes a share call to add a temporary post from a url or from the passed in params
@@ -33,7 +35,8 @@ type shareDoc struct {
 // Note this function does not save the post in the database, it only returns a temporary post object
 // from the passed in variables
 // if full then we'll attempt to convert the entire page to markdown in the post
-func Share(who *User, strURL, title, content, useragent string, town data.Key, images []string, imageKeys []data.UUID,
+// ctx is the context of the share request, which anything logged along the way is tied to
+func Share(ctx context.Context, who *User, strURL, title, content, useragent string, town data.Key, images []string, imageKeys []data.UUID,
 	selector string) (*Post, error) {
 	//if title, content or images are specified, use those
 
@@ -44,6 +47,8 @@ func Share(who *User, strURL, title, content, useragent string, town data.Key, i
 	// if title, content or images can't be found via one of the above, then
 	// use page title, and guessed page content
 
+	logger := requestLog(ctx)
+
 	uri, err := url.Parse(strURL)
 	if err != nil {
 		return nil, ErrShareURL
@@ -51,7 +56,7 @@ func Share(who *User, strURL, title, content, useragent string, town data.Key, i
 
 	post := &Post{
 		Title:           strings.TrimSpace(title),
-		Content:         htmlStringToMarkdown(content, uri),
+		Content:         htmlStringToMarkdown(logger, content, uri),
 		Format:          PostFormatStandard,
 		Images:          imageKeys,
 		AllowComments:   true,
@@ -67,7 +72,7 @@ func Share(who *User, strURL, title, content, useragent string, town data.Key, i
 
 	if post.Title == "" || post.Content == "" && strings.TrimSpace(strURL) != "" {
 
-		doc, err := loadShareURL(strURL, useragent)
+		doc, err := loadShareURL(logger, strURL, useragent)
 		if err != nil {
 			return nil, err
 		}
@@ -129,7 +134,7 @@ func Share(who *User, strURL, title, content, useragent string, town data.Key, i
 
 		lnk, err := url.Parse(images[i])
 		if err != nil {
-			log.Infof("Error building url for image request %s Error: %s", images[i], err)
+			logger.Infof("Error building url for image request %s Error: %s", images[i], err)
 			continue
 		}
 
@@ -137,19 +142,19 @@ func Share(who *User, strURL, title, content, useragent string, town data.Key, i
 
 		req, err := http.NewRequest("GET", lnk.String(), nil)
 		if err != nil {
-			log.Infof("Error building request for image request %s Error: %s", lnk, err)
+			logger.Infof("Error building request for image request %s Error: %s", lnk, err)
 			continue
 		}
 		req.Header.Set("User-Agent", useragent)
 		resp, err := httpClient.Do(req)
 		if err != nil {
-			log.Infof("Error retrieving image from URL %s Error: %s", lnk, err)
+			logger.Infof("Error retrieving image from URL %s Error: %s", lnk, err)
 			continue
 		}
 
 		img, err := ImageNew(who, resp.Header.Get("Content-Type"), resp.Body)
 		if err != nil {
-			log.Infof("Error inserting image from URL %s Error: %s", lnk, err)
+			logger.Infof("Error inserting image from URL %s Error: %s", lnk, err)
 			continue
 		}
 
@@ -163,9 +168,10 @@ func Share(who *User, strURL, title, content, useragent string, town data.Key, i
 	return post, nil
 }
 
-func loadShareURL(uri, useragent string) (*shareDoc, error) {
+func loadShareURL(logger *log.Entry, uri, useragent string) (*shareDoc, error) {
 	doc := &shareDoc{
 		useragent: useragent,
+		logger:    logger,
 	}
 
 	gDoc, err := doc.loadURL(uri)
@@ -181,20 +187,20 @@ func loadShareURL(uri, useragent string) (*shareDoc, error) {
 func (d *shareDoc) loadURL(uri string) (*goquery.Document, error) {
 	req, err := http.NewRequest("GET", uri, nil)
 	if err != nil {
-		log.Infof("Error building request for share URL %s Error: %s", uri, err)
+		d.logger.Infof("Error building request for share URL %s Error: %s", uri, err)
 		return nil, ErrShareURL
 	}
 	req.Header.Set("User-Agent", d.useragent)
 
 	resp, err := httpClient.Do(req)
 	if err != nil {
-		log.Infof("Error retrieving share URL %s Error: %s", uri, err)
+		d.logger.Infof("Error retrieving share URL %s Error: %s", uri, err)
 		return nil, ErrShareURL
 	}
 
 	doc, err := goquery.NewDocumentFromResponse(resp)
 	if err != nil {
-		log.Infof("Error loading response from share URL into goquery %s Error: %s", uri, err)
+		d.logger.Infof("Error loading response from share URL into goquery %s Error: %s", uri, err)
 		return nil, ErrShareURL
 	}
 
@@ -214,11 +220,11 @@ func (d *shareDoc) allImages() []string {
 	return images
 }
 
-func htmlStringToMarkdown(content string, uri *url.URL) string {
+func htmlStringToMarkdown(logger *log.Entry, content string, uri *url.URL) string {
 	doc, err := goquery.NewDocumentFromReader(bytes.NewBufferString(content))
 
 	if err != nil {
-		log.Infof("Error loading html string into goquery for markdown parsing: %s", err)
+		logger.Infof("Error loading html string into goquery for markdown parsing: %s", err)
 		return ""
 	}
 
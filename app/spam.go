@@ -0,0 +1,359 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package app
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	log "git.townsourced.com/townsourced/logrus"
+	"github.com/timshannon/townsourced/data"
+	"github.com/timshannon/townsourced/fail"
+)
+
+const (
+	spamModelGlobal        = data.Key("~global") // trained on the posts of every town
+	spamTrainWindow        = 180 * 24 * time.Hour
+	spamTrainMaxPosts      = 20000
+	spamTrainPageSize      = 500
+	spamModelMinExamples   = 10    // of both spam and non-spam posts before a model is used
+	spamModelMaxTokens     = 20000 // tokens kept per model, most frequent first
+	spamModelCacheDuration = 10 * time.Minute
+	spamTokenMinLength     = 2
+	spamTokenMaxLength     = 30
+	spamDomainToken        = "domain:"
+
+	townModMinSpamThreshold = 0.5
+)
+
+// spamModel is a naive Bayes classifier trained on which of a town's posts were spam, counting the number of spam
+// and non-spam posts each token appeared in
+type spamModel struct {
+	Town       data.Key
+	Spam       int
+	Ham        int
+	SpamTokens map[string]int
+	HamTokens  map[string]int
+	Trained    time.Time
+}
+
+func newSpamModel(town data.Key) *spamModel {
+	return &spamModel{
+		Town:       town,
+		SpamTokens: make(map[string]int),
+		HamTokens:  make(map[string]int),
+	}
+}
+
+// spamTokens returns the unique lower case words in the text, and the domains it links to
+func spamTokens(text string) []string {
+	unique := make(map[string]struct{})
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for _, word := range words {
+		if len(word) >= spamTokenMinLength && len(word) <= spamTokenMaxLength {
+			unique[word] = struct{}{}
+		}
+	}
+
+	for _, domain := range linkDomains(text) {
+		unique[spamDomainToken+domain] = struct{}{}
+	}
+
+	tokens := make([]string, 0, len(unique))
+	for token := range unique {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+	return tokens
+}
+
+func (m *spamModel) train(tokens []string, spam bool) {
+	counts := m.HamTokens
+	if spam {
+		counts = m.SpamTokens
+		m.Spam++
+	} else {
+		m.Ham++
+	}
+
+	for _, token := range tokens {
+		counts[token]++
+	}
+}
+
+// trained is whether the model has seen enough of both kinds of posts to be used
+func (m *spamModel) trained() bool {
+	return m.Spam >= spamModelMinExamples && m.Ham >= spamModelMinExamples
+}
+
+// prune drops tokens only seen once, and keeps only the most frequent tokens, so models stay a reasonable size
+func (m *spamModel) prune() {
+	type tokenCount struct {
+		token string
+		count int
+	}
+
+	var counts []tokenCount
+	for token, count := range m.SpamTokens {
+		counts = append(counts, tokenCount{token, count + m.HamTokens[token]})
+	}
+	for token, count := range m.HamTokens {
+		if _, ok := m.SpamTokens[token]; !ok {
+			counts = append(counts, tokenCount{token, count})
+		}
+	}
+
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].count == counts[j].count {
+			return counts[i].token < counts[j].token
+		}
+		return counts[i].count > counts[j].count
+	})
+
+	for i := range counts {
+		if counts[i].count < 2 || i >= spamModelMaxTokens {
+			delete(m.SpamTokens, counts[i].token)
+			delete(m.HamTokens, counts[i].token)
+		}
+	}
+}
+
+// probability returns the probability the tokens are from a spam post, only counting the tokens the model has seen
+func (m *spamModel) probability(tokens []string) float64 {
+	score := math.Log(float64(m.Spam)) - math.Log(float64(m.Ham))
+
+	for _, token := range tokens {
+		spam, ham := m.SpamTokens[token], m.HamTokens[token]
+		if spam == 0 && ham == 0 {
+			continue
+		}
+		// laplace smoothed chance of a spam and non-spam post containing the token
+		score += math.Log(float64(spam+1)/float64(m.Spam+2)) - math.Log(float64(ham+1)/float64(m.Ham+2))
+	}
+
+	return 1 / (1 + math.Exp(-score))
+}
+
+// spamLabel returns whether the post was spam in the town, and false for ok if it can't be used for training.  Only
+// posts a moderator removed are spam, so reports alone can't teach a model to block a user's posts.  Posts moderated
+// by the auto moderator aren't used, so the models don't learn from their own mistakes, and neither are reported
+// posts a moderator hasn't reviewed yet
+func spamLabel(p *Post, town data.Key) (spam, ok bool) {
+	for i := range p.Approved {
+		if p.Approved[i] == town {
+			return false, true
+		}
+	}
+
+	for i := range p.Moderation {
+		if p.Moderation[i].Town == town {
+			if p.Moderation[i].Who == data.EmptyKey {
+				return false, false
+			}
+			return true, true
+		}
+	}
+
+	if len(p.Reported) != 0 {
+		return false, false
+	}
+
+	return false, true
+}
+
+// trainSpamModels retrains every town's spam model, and the global model, from recent posts
+func trainSpamModels() error {
+	models := make(map[data.Key]*spamModel)
+	global := newSpamModel(spamModelGlobal)
+	since := time.Now().Add(-spamTrainWindow)
+
+	for from := 0; from < spamTrainMaxPosts; from += spamTrainPageSize {
+		var posts []*Post
+		err := data.PostGetTraining(&posts, since, from, spamTrainPageSize)
+		if err == data.ErrNotFound {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, p := range posts {
+			tokens := spamTokens(p.Title + "\n" + p.Content)
+			labeled, spam := false, false
+
+			for _, town := range p.TownKeys {
+				townSpam, ok := spamLabel(p, town)
+				if !ok {
+					continue
+				}
+				if _, ok := models[town]; !ok {
+					models[town] = newSpamModel(town)
+				}
+				models[town].train(tokens, townSpam)
+				labeled = true
+				spam = spam || townSpam
+			}
+
+			if labeled {
+				global.train(tokens, spam)
+			}
+		}
+
+		if len(posts) < spamTrainPageSize {
+			break
+		}
+	}
+
+	models[spamModelGlobal] = global
+	// whole seconds, so the stored time matches exactly when looking for older models
+	now := time.Now().Truncate(time.Second)
+
+	for _, model := range models {
+		if !model.trained() {
+			continue
+		}
+
+		model.prune()
+		model.Trained = now
+		err := data.SpamModelUpsert(model)
+		if err != nil {
+			return err
+		}
+	}
+
+	// towns which no longer have enough reviewed posts, or no recent posts at all, fall back to the global model
+	err := data.SpamModelDeleteTrainedBefore(now)
+	if err != nil && err != data.ErrNotFound {
+		return err
+	}
+
+	log.WithField("models", len(models)).WithField("spam", global.Spam).WithField("ham", global.Ham).
+		Info("Trained spam models")
+	spamModels.reset()
+	return nil
+}
+
+// spamModelCache keeps recently loaded spam models in memory, so they aren't retrieved for every post
+type spamModelCache struct {
+	sync.RWMutex
+	models map[data.Key]cachedSpamModel
+}
+
+type cachedSpamModel struct {
+	model  *spamModel // nil if the town has no trained model
+	loaded time.Time
+}
+
+var spamModels = &spamModelCache{
+	models: make(map[data.Key]cachedSpamModel),
+}
+
+func (c *spamModelCache) get(town data.Key) (*spamModel, error) {
+	c.RLock()
+	cached, ok := c.models[town]
+	c.RUnlock()
+
+	if ok && time.Since(cached.loaded) < spamModelCacheDuration {
+		return cached.model, nil
+	}
+
+	model := &spamModel{}
+	err := data.SpamModelGet(model, town)
+	if err == data.ErrNotFound {
+		model = nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	c.Lock()
+	c.models[town] = cachedSpamModel{
+		model:  model,
+		loaded: time.Now(),
+	}
+	c.Unlock()
+
+	return model, nil
+}
+
+func (c *spamModelCache) reset() {
+	c.Lock()
+	c.models = make(map[data.Key]cachedSpamModel)
+	c.Unlock()
+}
+
+// spamReason returns a reason if the town's spam model, or the global model if the town doesn't have enough
+// moderated posts to train its own, is at least as confident as the town's threshold that the post is spam
+func (t *Town) spamReason(p *Post) (string, error) {
+	threshold := t.AutoModerator.SpamThreshold
+	if threshold == 0 {
+		return "", nil
+	}
+
+	model, err := spamModels.get(t.Key)
+	if err != nil {
+		return "", err
+	}
+	if model == nil {
+		model, err = spamModels.get(spamModelGlobal)
+		if err != nil {
+			return "", err
+		}
+	}
+	if model == nil {
+		return "", nil
+	}
+
+	if model.probability(spamTokens(p.Title+"\n"+p.Content)) < threshold {
+		return "", nil
+	}
+
+	return "This post looks like spam.  Contact the town moderator(s) if you think this is a mistake.", nil
+}
+
+func validateSpamThreshold(threshold float64) error {
+	if threshold != 0 && (threshold < townModMinSpamThreshold || threshold >= 1) {
+		return fail.New(fmt.Sprintf("The spam threshold must be at least %.1f and less than 1, or 0 to turn it off",
+			townModMinSpamThreshold), threshold)
+	}
+	return nil
+}
+
+// SetAutoModSpamThreshold sets how confident the spam classifier must be that a post is spam before it's auto
+// moderated, 0 turns it off
+func (t *Town) SetAutoModSpamThreshold(who *User, threshold float64) error {
+	if !t.mod(who).active() {
+		return ErrTownNotMod
+	}
+
+	err := validateSpamThreshold(threshold)
+	if err != nil {
+		return err
+	}
+
+	t.logSetting(who, TownLogAutoModSpamThreshold, t.AutoModerator.SpamThreshold, threshold)
+	t.AutoModerator.SpamThreshold = threshold
+	return nil
+}
+
+// spamTrainTasker periodically retrains the spam models from the posts moderators have reviewed since
+type spamTrainTasker struct{}
+
+var scheduleSpamTrain = mustParseSchedule("@every 6h jitter 30m")
+
+func (s *spamTrainTasker) Type() string       { return "TrainSpamModels" }
+func (s *spamTrainTasker) Priority() uint     { return priorityLow }
+func (s *spamTrainTasker) Schedule() Schedule { return scheduleSpamTrain }
+func (s *spamTrainTasker) Retry() int         { return -1 }
+func (s *spamTrainTasker) Concurrency() int   { return 1 }
+func (s *spamTrainTasker) Do(variables ...interface{}) error {
+	return trainSpamModels()
+}
@@ -0,0 +1,99 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package app
+
+import (
+	"fmt"
+	"time"
+
+	. "git.townsourced.com/townsourced/check"
+	"github.com/timshannon/townsourced/data"
+)
+
+// Spam Test Suite
+type SpamSuite struct{}
+
+var _ = Suite(&SpamSuite{})
+
+const testSpamTown = data.Key("testspamtown")
+
+func testSpamModel() *spamModel {
+	model := newSpamModel(testSpamTown)
+	for i := 0; i < spamModelMinExamples; i++ {
+		model.train(spamTokens(fmt.Sprintf("Cheap pills, buy now at http://pills%d.example.com casino bonus", i)), true)
+		model.train(spamTokens(fmt.Sprintf("Garage sale on Saturday %d, kids bikes and furniture", i)), false)
+	}
+	return model
+}
+
+func (s *SpamSuite) TestTrain(c *C) {
+	model := newSpamModel(testSpamTown)
+	for i := 0; i < spamModelMinExamples-1; i++ {
+		model.train(spamTokens("Cheap pills, buy now"), true)
+		model.train(spamTokens("Garage sale on Saturday"), false)
+	}
+	c.Assert(model.trained(), Equals, false)
+
+	model = testSpamModel()
+	c.Assert(model.trained(), Equals, true)
+	c.Assert(model.Spam, Equals, spamModelMinExamples)
+	c.Assert(model.Ham, Equals, spamModelMinExamples)
+	c.Assert(model.SpamTokens["pills"], Equals, spamModelMinExamples)
+	c.Assert(model.HamTokens["garage"], Equals, spamModelMinExamples)
+	c.Assert(model.SpamTokens["garage"], Equals, 0)
+
+	model.prune()
+	c.Assert(model.SpamTokens["pills"], Equals, spamModelMinExamples)
+	_, ok := model.SpamTokens[spamDomainToken+"pills0.example.com"]
+	c.Assert(ok, Equals, false) // only seen once
+}
+
+func (s *SpamSuite) TestProbability(c *C) {
+	model := testSpamModel()
+
+	c.Assert(model.probability(spamTokens("Buy cheap pills now")) > 0.9, Equals, true)
+	c.Assert(model.probability(spamTokens("Bikes and furniture at our garage sale")) < 0.1, Equals, true)
+	c.Assert(model.probability(spamTokens("Unrelated words entirely")), Equals, 0.5)
+}
+
+func (s *SpamSuite) TestLabel(c *C) {
+	other := data.Key("othertown")
+
+	spam, ok := spamLabel(&Post{}, testSpamTown)
+	c.Assert(ok, Equals, true)
+	c.Assert(spam, Equals, false)
+
+	// reports alone aren't enough to mark a post as spam
+	reported := &Post{Reported: map[data.Key]string{"reporter": "spam"}}
+	_, ok = spamLabel(reported, testSpamTown)
+	c.Assert(ok, Equals, false)
+
+	reported.Approved = []data.Key{testSpamTown}
+	spam, ok = spamLabel(reported, testSpamTown)
+	c.Assert(ok, Equals, true)
+	c.Assert(spam, Equals, false)
+
+	removed := &Post{Moderation: []Moderated{{Town: testSpamTown, Who: "moderator", Reason: "spam"}}}
+	spam, ok = spamLabel(removed, testSpamTown)
+	c.Assert(ok, Equals, true)
+	c.Assert(spam, Equals, true)
+
+	spam, ok = spamLabel(removed, other)
+	c.Assert(ok, Equals, true)
+	c.Assert(spam, Equals, false)
+
+	autoModerated := &Post{Moderation: []Moderated{{Town: testSpamTown, Reason: "spam"}}}
+	_, ok = spamLabel(autoModerated, testSpamTown)
+	c.Assert(ok, Equals, false)
+}
+
+func (s *SpamSuite) TestStaleModels(c *C) {
+	stale := testSpamModel()
+	stale.Trained = time.Now().AddDate(0, 0, -30)
+	c.Assert(data.SpamModelUpsert(stale), Equals, nil)
+
+	c.Assert(trainSpamModels(), Equals, nil)
+
+	c.Assert(data.SpamModelGet(newSpamModel(testSpamTown), testSpamTown), Equals, data.ErrNotFound)
+}
@@ -0,0 +1,234 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package app
+
+import (
+	"strings"
+	"time"
+
+	"github.com/timshannon/townsourced/data"
+	"github.com/timshannon/townsourced/fail"
+)
+
+// UserSuspension is a site wide suspension of a user by an admin.  A suspension which never ends is a ban
+type UserSuspension struct {
+	Until       time.Time   `json:"until,omitempty"` // zero for a ban
+	Reason      string      `json:"reason,omitempty"`
+	By          data.Key    `json:"by,omitempty"`
+	When        time.Time   `json:"when,omitempty"`
+	HideContent bool        `json:"hideContent,omitempty"`
+	HiddenPosts []data.UUID `json:"-"` // posts closed by the ban, which are reopened if the user is reinstated
+}
+
+var (
+	// ErrUserSuspended is when a suspended user tries to log in, post or comment
+	ErrUserSuspended = fail.New("Your account has been suspended")
+	// ErrUserBanned is when a banned user tries to log in, post or comment
+	ErrUserBanned = fail.New("Your account has been banned")
+	// ErrUserNotSuspended is when reinstating a user who isn't suspended or banned
+	ErrUserNotSuspended = fail.New("This user is not suspended or banned")
+)
+
+// Suspended is whether or not the user is currently suspended or banned
+func (u *User) Suspended() bool {
+	if u.Suspension == nil {
+		return false
+	}
+	return u.Suspension.Until.IsZero() || u.Suspension.Until.After(time.Now())
+}
+
+// Banned is whether or not the user has been permanently banned
+func (u *User) Banned() bool {
+	return u.Suspension != nil && u.Suspension.Until.IsZero()
+}
+
+// checkSuspended returns an error if the user is currently suspended or banned
+func (u *User) checkSuspended() error {
+	if !u.Suspended() {
+		return nil
+	}
+	if u.Banned() {
+		return ErrUserBanned
+	}
+	return fail.NewFromErr(ErrUserSuspended, u.Suspension.Until)
+}
+
+// Suspend suspends the user site wide until the passed in time.  A suspended user can't log in, post or comment,
+// and all of their current sessions are logged out
+func (u *User) Suspend(who *User, until time.Time, reason string) error {
+	if !until.After(time.Now()) {
+		return fail.New("A suspension must end in the future", until)
+	}
+
+	return u.suspend(who, AuditUserSuspend, &UserSuspension{
+		Until:  until,
+		Reason: reason,
+	})
+}
+
+// Ban permanently bans the user site wide, and optionally hides all of their posts and comments
+func (u *User) Ban(who *User, reason string, hideContent bool) error {
+	return u.suspend(who, AuditUserBan, &UserSuspension{
+		Reason:      reason,
+		HideContent: hideContent,
+	})
+}
+
+func (u *User) suspend(who *User, action string, suspension *UserSuspension) error {
+	err := who.checkPermission(permSuspendUsers)
+	if err != nil {
+		return err
+	}
+
+	if u.Staff() {
+		return fail.New("Users with a site role can't be suspended or banned, revoke their roles first")
+	}
+
+	suspension.Reason = strings.TrimSpace(suspension.Reason)
+	if suspension.Reason == "" {
+		return fail.New("A reason is required")
+	}
+
+	suspension.By = who.Username
+	suspension.When = time.Now()
+
+	prev := u.Suspension
+	if prev != nil && prev.HideContent {
+		if suspension.HideContent {
+			suspension.HiddenPosts = prev.HiddenPosts
+		} else {
+			err := u.showContent(prev.HiddenPosts)
+			if err != nil {
+				return err
+			}
+		}
+	} else if suspension.HideContent {
+		hidden, err := u.hideContent()
+		if err != nil {
+			return err
+		}
+		suspension.HiddenPosts = hidden
+	}
+
+	u.Suspension = suspension
+	err = u.Update()
+	if err != nil {
+		return err
+	}
+
+	err = data.SessionInvalidateUser(u.Username)
+	if err != nil {
+		return err
+	}
+
+	details := map[string]interface{}{
+		"hideContent": suspension.HideContent,
+	}
+	if !suspension.Until.IsZero() {
+		details["until"] = suspension.Until
+	}
+
+	return audit(who.Username, action, u.Username, suspension.Reason, details)
+}
+
+// Reinstate lifts the user's suspension or ban, and shows any content that was hidden by it
+func (u *User) Reinstate(who *User, reason string) error {
+	err := who.checkPermission(permSuspendUsers)
+	if err != nil {
+		return err
+	}
+
+	if u.Suspension == nil {
+		return ErrUserNotSuspended
+	}
+
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return fail.New("A reason is required")
+	}
+
+	if u.Suspension.HideContent {
+		err = u.showContent(u.Suspension.HiddenPosts)
+		if err != nil {
+			return err
+		}
+	}
+
+	u.Suspension = nil
+	err = u.Update()
+	if err != nil {
+		return err
+	}
+
+	return audit(who.Username, AuditUserReinstate, u.Username, reason, nil)
+}
+
+// hideContent closes all of the user's published posts, and hides all of their comments.  The keys of the closed
+// posts are returned so only they are reopened if the content is shown again
+func (u *User) hideContent() ([]data.UUID, error) {
+	var keys []data.UUID
+	err := data.PostKeysByUser(&keys, u.Username, PostStatusPublished)
+	if err != nil && err != data.ErrNotFound {
+		return nil, err
+	}
+
+	hidden := make([]data.UUID, 0, len(keys))
+	for i := range keys {
+		post, err := PostGet(keys[i])
+		if err == ErrPostNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if post.Status != PostStatusPublished {
+			continue
+		}
+
+		err = data.PostRemoveIndex(post.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		post.Status = PostStatusClosed
+		err = post.Update()
+		if err != nil {
+			return nil, err
+		}
+		hidden = append(hidden, post.Key)
+	}
+
+	return hidden, data.CommentsSetHidden(u.Username, true)
+}
+
+// showContent reopens the passed in posts if they are still closed, and shows all of the user's comments
+func (u *User) showContent(posts []data.UUID) error {
+	for i := range posts {
+		post, err := PostGet(posts[i])
+		if err == ErrPostNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if post.Status != PostStatusClosed {
+			continue
+		}
+
+		post.Status = PostStatusPublished
+		err = post.Update()
+		if err != nil {
+			return err
+		}
+	}
+
+	return data.CommentsSetHidden(u.Username, false)
+}
+
+// contentHidden is whether or not the user's posts and comments have been hidden by a ban
+func (u *User) contentHidden() bool {
+	return u.Suspension != nil && u.Suspension.HideContent
+}
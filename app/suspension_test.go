@@ -0,0 +1,132 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package app_test
+
+import (
+	"context"
+	"time"
+
+	. "git.townsourced.com/townsourced/check"
+	"github.com/timshannon/townsourced/app"
+)
+
+// Suspension Test Suite
+type SuspensionSuite struct {
+	*testData
+	admin *app.User
+}
+
+var _ = Suite(&SuspensionSuite{testData: &testData{}})
+
+func (s *SuspensionSuite) SetUpTest(c *C) {
+	s.testData.setup(c)
+	s.admin = s.moderator
+	s.admin.Admin = true
+}
+
+func (s *SuspensionSuite) TearDownTest(c *C) {
+	s.testData.teardown(c)
+}
+
+func (s *SuspensionSuite) TestSuspended(c *C) {
+	u := &app.User{}
+	c.Assert(u.Suspended(), Equals, false)
+	c.Assert(u.Banned(), Equals, false)
+
+	u.Suspension = &app.UserSuspension{Until: time.Now().Add(-1 * time.Minute)}
+	c.Assert(u.Suspended(), Equals, false)
+	c.Assert(u.Banned(), Equals, false)
+
+	u.Suspension = &app.UserSuspension{Until: time.Now().Add(time.Hour)}
+	c.Assert(u.Suspended(), Equals, true)
+	c.Assert(u.Banned(), Equals, false)
+
+	u.Suspension = &app.UserSuspension{}
+	c.Assert(u.Suspended(), Equals, true)
+	c.Assert(u.Banned(), Equals, true)
+}
+
+func (s *SuspensionSuite) TestSuspendPermissions(c *C) {
+	c.Assert(s.user.Suspend(s.other, time.Now().Add(time.Hour), "spam"), Equals, app.ErrNotAdmin)
+	c.Assert(s.user.Ban(s.other, "spam", false), Equals, app.ErrNotAdmin)
+
+	// admins can't be suspended
+	c.Assert(s.admin.Ban(s.admin, "spam", false), Not(Equals), nil)
+
+	// reason is required
+	c.Assert(s.user.Ban(s.admin, " ", false), Not(Equals), nil)
+
+	// must end in the future
+	c.Assert(s.user.Suspend(s.admin, time.Now().Add(-1*time.Hour), "spam"), Not(Equals), nil)
+
+	c.Assert(s.user.Reinstate(s.admin, "mistake"), Equals, app.ErrUserNotSuspended)
+}
+
+func (s *SuspensionSuite) TestSuspend(c *C) {
+	err := s.user.Suspend(s.admin, time.Now().Add(time.Hour), "spam")
+	c.Assert(err, Equals, nil)
+
+	_, err = app.UserLogin(string(s.user.Username), s.userPassword)
+	c.Assert(err, ErrorMatches, app.ErrUserSuspended.Error())
+
+	u, err := app.UserGet(s.user.Username)
+	c.Assert(err, Equals, nil)
+	c.Assert(u.Suspended(), Equals, true)
+
+	_, err = app.SessionNew(u, time.Time{}, "127.0.0.1", "test")
+	c.Assert(err, ErrorMatches, app.ErrUserSuspended.Error())
+
+	_, err = app.PostNew(context.Background(), "suspended post", "test content", "buysell", app.PostFormatStandard, u,
+		s.postPub.TownKeys, nil, s.postPub.FeaturedImage, true, true, false)
+	c.Assert(err, ErrorMatches, app.ErrUserSuspended.Error())
+
+	_, err = app.CommentNew(context.Background(), u, s.postPub, "suspended comment")
+	c.Assert(err, ErrorMatches, app.ErrUserSuspended.Error())
+
+	c.Assert(u.Reinstate(s.admin, "served their time"), Equals, nil)
+
+	_, err = app.UserLogin(string(s.user.Username), s.userPassword)
+	c.Assert(err, Equals, nil)
+
+	entries, err := app.AdminAuditGet(s.admin, s.user.Username, 0, 10)
+	c.Assert(err, Equals, nil)
+	c.Assert(entries, HasLen, 2)
+	c.Assert(entries[0].Action, Equals, app.AuditUserReinstate)
+	c.Assert(entries[1].Action, Equals, app.AuditUserSuspend)
+}
+
+func (s *SuspensionSuite) TestBanHidesContent(c *C) {
+	comment, err := app.CommentNew(context.Background(), s.user, s.postPub, "soon to be hidden")
+	c.Assert(err, Equals, nil)
+
+	c.Assert(s.user.Ban(s.admin, "spam", true), Equals, nil)
+
+	_, err = app.UserLogin(string(s.user.Username), s.userPassword)
+	c.Assert(err, ErrorMatches, app.ErrUserBanned.Error())
+
+	post, err := app.PostGet(s.postPub.Key)
+	c.Assert(err, Equals, nil)
+	c.Assert(post.Status, Equals, app.PostStatusClosed)
+
+	visible, err := post.Visible(s.other)
+	c.Assert(err, Equals, nil)
+	c.Assert(visible, Equals, false)
+
+	hidden, err := app.CommentGet(comment.Key)
+	c.Assert(err, Equals, nil)
+	c.Assert(hidden.Hidden, Equals, true)
+	c.Assert(hidden.Comment, Equals, "")
+
+	u, err := app.UserGet(s.user.Username)
+	c.Assert(err, Equals, nil)
+	c.Assert(u.Reinstate(s.admin, "appealed"), Equals, nil)
+
+	post, err = app.PostGet(s.postPub.Key)
+	c.Assert(err, Equals, nil)
+	c.Assert(post.Status, Equals, app.PostStatusPublished)
+
+	shown, err := app.CommentGet(comment.Key)
+	c.Assert(err, Equals, nil)
+	c.Assert(shown.Comment, Equals, "soon to be hidden")
+}
@@ -11,6 +11,7 @@ import (
 
 	log "git.townsourced.com/townsourced/logrus"
 	"github.com/timshannon/townsourced/data"
+	"github.com/timshannon/townsourced/metrics"
 )
 
 const (
@@ -23,6 +24,14 @@ const (
 
 var tasks *taskMap
 
+var (
+	taskRuns = metrics.NewCounter("townsourced_task_runs_total",
+		"Number of task runs, by task type and outcome: success, retry or failed.", "type", "outcome")
+	taskDuration = metrics.NewHistogram("townsourced_task_duration_seconds",
+		"How long tasks took to run in seconds, by task type.",
+		[]float64{.01, .1, .5, 1, 5, 10, 30, 60, 300, 900}, "type")
+)
+
 type taskMap struct {
 	sync.RWMutex
 	t map[string]Tasker
@@ -36,11 +45,13 @@ func initTasks(owner data.Key, queueSize uint, pollInterval time.Duration) {
 	recurringTasks := []Tasker{
 		&deleteClosedTasker{},
 		&taskerUnusedImages{},
+		&logRetentionTasker{},
+		&spamTrainTasker{},
 	}
 
 	registerRecurringTask(recurringTasks)
 
-	go startTaskRunner(owner, queueSize, pollInterval)
+	startTaskRunner(owner, queueSize, pollInterval)
 }
 
 func registerTaskType(t Tasker) {
@@ -51,7 +62,8 @@ func registerTaskType(t Tasker) {
 }
 
 // registerRecurringTask is for tasks that regularly recur and aren't one-off tasks
-// if the task isn't already in the task DB, then it inserts it
+// if the task isn't already in the task DB, then it inserts it.  If it is already in the DB, but its schedule or
+// priority has changed in code, then the existing task is updated to match
 func registerRecurringTask(recurringTasks []Tasker) {
 
 	for i := range recurringTasks {
@@ -62,6 +74,10 @@ func registerRecurringTask(recurringTasks []Tasker) {
 		registerTaskType(t)
 		if err == nil && len(tasks) >= 1 {
 			//task already exists in the DB
+			err = tasks[0].reconcile(t)
+			if err != nil {
+				panic(fmt.Sprintf("Unable to update recurring task %s: %s", t.Type(), err))
+			}
 			continue
 		}
 
@@ -77,6 +93,50 @@ func registerRecurringTask(recurringTasks []Tasker) {
 	}
 }
 
+// reconcile updates an existing task's schedule and priority to match its tasker, if they've changed
+func (t *Task) reconcile(tskr Tasker) error {
+	schedule := tskr.Schedule()
+	if t.Schedule == schedule.String() && t.Priority == tskr.Priority() {
+		return nil
+	}
+
+	log.WithFields(log.Fields{
+		"taskKey":     t.Key,
+		"type":        t.Type,
+		"oldSchedule": t.Schedule,
+		"newSchedule": schedule.String(),
+	}).Infof("Updating recurring task schedule")
+
+	update := map[string]interface{}{
+		"Schedule": schedule.String(),
+		"Priority": tskr.Priority(),
+	}
+
+	if t.Schedule != schedule.String() {
+		next := schedule.Next(time.Now())
+		if next.IsZero() {
+			next = time.Now()
+		}
+		update["NextRun"] = next
+	}
+
+	return data.TaskUpdate(update, t.Key)
+}
+
+// limits returns the concurrency limit of every registered task type that has one
+func (t *taskMap) limits() map[string]int {
+	t.RLock()
+	defer t.RUnlock()
+
+	limits := make(map[string]int)
+	for tType, tskr := range t.t {
+		if limit := tskr.Concurrency(); limit > 0 {
+			limits[tType] = limit
+		}
+	}
+	return limits
+}
+
 func (t *taskMap) get(Type string) Tasker {
 	t.RLock()
 	defer t.RUnlock()
@@ -93,9 +153,10 @@ func (t *taskMap) get(Type string) Tasker {
 type Tasker interface {
 	Type() string                      // Type of the task
 	Priority() uint                    // Task Priority so high priority tasks (1) will be run before low priority tasks (5)
-	NextRun() time.Time                // detrmines when to next run this task, a zero time means the task is complete, and is not run again
+	Schedule() Schedule                // detrmines when to next run this task, a zero next time means the task is complete, and is not run again
 	Do(variables ...interface{}) error // The task to be run
 	Retry() int                        // Number of times to retry this task before marking it as failed, return -1 will retry forever
+	Concurrency() int                  // Max number of this type of task a task runner will run at once, 0 is no limit
 }
 
 // Task is a unit of work stored in the database, corresponds to a pre-registered tasker interface
@@ -105,6 +166,7 @@ type Task struct {
 	Owner     data.Key
 	Priority  uint          `gorethink:",omitempty"`
 	NextRun   time.Time     `gorethink:",omitempty"`
+	Schedule  string        `gorethink:",omitempty"`
 	Variables []interface{} `gorethink:",omitempty"`
 	Created   time.Time     `gorethink:",omitempty"`
 	Failed    time.Time     `gorethink:",omitempty"`
@@ -123,7 +185,8 @@ func taskAdd(t Tasker, variables ...interface{}) error {
 		return errors.New("Invalid task priority")
 	}
 
-	next := t.NextRun()
+	schedule := t.Schedule()
+	next := schedule.Next(time.Now())
 	// all tasks must run at least once
 	if next.IsZero() {
 		next = time.Now()
@@ -133,12 +196,19 @@ func taskAdd(t Tasker, variables ...interface{}) error {
 		Type:      t.Type(),
 		Priority:  t.Priority(),
 		NextRun:   next,
+		Schedule:  schedule.String(),
 		Variables: variables,
 		Created:   time.Now(),
 		Retry:     0,
 	}
 
-	return data.TaskInsert(task)
+	err := data.TaskInsert(task)
+	if err != nil {
+		return err
+	}
+
+	taskWake()
+	return nil
 }
 
 // Run runs the given task
@@ -146,11 +216,21 @@ func taskAdd(t Tasker, variables ...interface{}) error {
 func (t *Task) Run() {
 	t.tasker = tasks.get(t.Type)
 
-	if t.errHandled(t.tasker.Do(t.Variables...)) {
+	start := time.Now()
+	err := t.tasker.Do(t.Variables...)
+	taskDuration.Since(start, t.Type)
+
+	if t.errHandled(err) {
+		if t.Closed {
+			taskRuns.Inc(t.Type, "failed")
+		} else {
+			taskRuns.Inc(t.Type, "retry")
+		}
 		return
 	}
+	taskRuns.Inc(t.Type, "success")
 
-	t.NextRun = t.tasker.NextRun()
+	t.NextRun = t.tasker.Schedule().Next(time.Now())
 	t.Retry = 0             //reset any retries
 	t.Owner = data.EmptyKey // throw it back into the queue
 
@@ -159,7 +239,7 @@ func (t *Task) Run() {
 		t.Completed = time.Now()
 	}
 
-	err := data.TaskUpdate(t, t.Key)
+	err = data.TaskUpdate(t, t.Key)
 	if err != nil {
 		log.WithFields(log.Fields{
 			"taskKey": t.Key,
@@ -208,10 +288,13 @@ func (t *Task) fail() {
 
 type deleteClosedTasker struct{}
 
+var scheduleDeleteClosed = mustParseSchedule("@every 15m jitter 1m")
+
 func (d *deleteClosedTasker) Type() string       { return "DeleteClosedTasks" }
 func (d *deleteClosedTasker) Priority() uint     { return priorityLow }
-func (d *deleteClosedTasker) NextRun() time.Time { return time.Now().Add(15 * time.Minute) }
+func (d *deleteClosedTasker) Schedule() Schedule { return scheduleDeleteClosed }
 func (d *deleteClosedTasker) Retry() int         { return -1 }
+func (d *deleteClosedTasker) Concurrency() int   { return 1 }
 func (d *deleteClosedTasker) Do(variables ...interface{}) error {
 	err := data.TaskDeleteClosed()
 	if err == data.ErrNotFound {
@@ -4,11 +4,14 @@
 package app
 
 import (
+	"context"
+	"math"
 	"sync"
 	"time"
 
 	log "git.townsourced.com/townsourced/logrus"
 	"github.com/timshannon/townsourced/data"
+	"github.com/timshannon/townsourced/metrics"
 )
 
 /*
@@ -21,46 +24,315 @@ import (
 
 	Some tasks may be recurring and continually put themselves back into the queue for processing at a later time
 	Some tasks may be one off and close once they have run once
+
+	A task runner runs at most queueSize tasks at once, and no more than a tasker's Concurrency() of any given type.
+	As soon as a running task finishes, or a new task is added, the task runner claims more work, otherwise it
+	checks for tasks that have become ready every poll interval.
 */
 
-var taskRun = make(chan bool)
+var runner *taskRunner
+
+func init() {
+	metrics.NewGauge("townsourced_task_running", "Number of tasks currently running on this server.",
+		func() float64 {
+			if runner == nil {
+				return 0
+			}
+			return float64(len(runner.runningKeys()))
+		})
+	metrics.NewGauge("townsourced_task_waiting",
+		"Number of tasks claimed by this server, waiting for a worker or their type's concurrency limit.",
+		func() float64 {
+			if runner == nil {
+				return 0
+			}
+			runner.Lock()
+			defer runner.Unlock()
+			return float64(runner.waitingCount())
+		})
+	metrics.NewGauge("townsourced_task_queue_depth",
+		"Number of ready tasks waiting to be claimed by any server, as of the last poll interval.",
+		func() float64 {
+			if runner == nil {
+				return 0
+			}
+			runner.Lock()
+			defer runner.Unlock()
+			return runner.queueDepth
+		})
+}
+
+type taskRunner struct {
+	owner        data.Key
+	queueSize    uint
+	pollInterval time.Duration
+
+	sync.Mutex
+	running  map[data.UUID]bool // keys of the tasks currently running
+	finished map[data.UUID]bool // keys of the tasks that finished since the last claim started
+	types    map[string]int     // number of currently running tasks by type
+	waiting  map[string]int     // number of owned tasks waiting for a worker or their type to free up, by type
+
+	queueDepth float64 // number of ready tasks in the queue, refreshed every poll interval by measure
+
+	wake     chan struct{}
+	stop     chan struct{}
+	stopped  chan struct{} // closed once the runner has stopped claiming and starting tasks
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
 
 func startTaskRunner(owner data.Key, queueSize uint, pollInterval time.Duration) {
-	var wg sync.WaitGroup
+	runner = newTaskRunner(owner, queueSize, pollInterval)
 
-	tasks := make([]*Task, 0, queueSize)
-	go func() {
-		taskRun <- true
-	}()
+	go runner.watch()
+	go runner.run()
+	go runner.measure()
+}
+
+func newTaskRunner(owner data.Key, queueSize uint, pollInterval time.Duration) *taskRunner {
+	return &taskRunner{
+		owner:        owner,
+		queueSize:    queueSize,
+		pollInterval: pollInterval,
+		running:      make(map[data.UUID]bool),
+		finished:     make(map[data.UUID]bool),
+		types:        make(map[string]int),
+		waiting:      make(map[string]int),
+		wake:         make(chan struct{}, 1),
+		stop:         make(chan struct{}),
+		stopped:      make(chan struct{}),
+	}
+}
+
+// stopTaskRunner stops claiming new tasks, throws any owned tasks that haven't started back into the queue, and
+// waits for any running tasks to finish.  If the context is done before the running tasks finish, they stay claimed,
+// so no other task runner starts them while they may still be running, and this server picks them back up the next
+// time it starts
+func stopTaskRunner(ctx context.Context) {
+	if runner == nil {
+		return
+	}
+	runner.stopOnce.Do(func() {
+		close(runner.stop)
+	})
+	<-runner.stopped
+
+	err := data.TaskRelease(runner.owner, runner.runningKeys())
+	if err != nil && err != data.ErrNotFound {
+		log.Errorf("Error releasing unstarted tasks: %s", err)
+	}
+
+	if waitContext(ctx, &runner.wg) == nil {
+		return
+	}
 
-	for <-taskRun {
-		err := data.TaskClaim(owner, queueSize)
+	log.WithField("running", runner.runningKeys()).
+		Warnf("Task runner shutdown timed out before all running tasks finished, leaving them claimed")
+}
+
+// taskWake wakes up the task runner to immediately check for new tasks
+func taskWake() {
+	if runner == nil {
+		return
+	}
+	runner.wakeUp()
+}
+
+func (r *taskRunner) interval() time.Duration {
+	r.Lock()
+	defer r.Unlock()
+	return r.pollInterval
+}
+
+// setPollInterval changes how often the runner checks for ready tasks, and wakes it up to use the new interval
+func (r *taskRunner) setPollInterval(pollInterval time.Duration) {
+	r.Lock()
+	r.pollInterval = pollInterval
+	r.Unlock()
+	r.wakeUp()
+}
+
+func (r *taskRunner) wakeUp() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+		// a wake up is already pending
+	}
+}
+
+func (r *taskRunner) run() {
+	defer close(r.stopped)
+	for {
+		r.claim()
+
+		select {
+		case <-r.stop:
+			return
+		case <-r.wake:
+		case <-time.After(r.interval()):
+		}
+	}
+}
+
+// measure counts the ready tasks in the queue every poll interval, so the queue depth metric doesn't have to query
+// the database every time it's collected
+func (r *taskRunner) measure() {
+	for {
+		depth := math.NaN()
+		count, err := data.TaskCountReady()
 		if err != nil {
-			log.Errorf("Error claiming open tasks from DB: %s", err)
-			continue
+			log.Errorf("Error counting ready tasks: %s", err)
+		} else {
+			depth = float64(count)
+		}
+
+		r.Lock()
+		r.queueDepth = depth
+		r.Unlock()
+
+		select {
+		case <-r.stop:
+			return
+		case <-time.After(r.interval()):
+		}
+	}
+}
+
+// watch wakes up the task runner whenever a new task is added by any server
+func (r *taskRunner) watch() {
+	for {
+		err := data.TaskWatchNew(r.stop, r.wakeUp)
+
+		select {
+		case <-r.stop:
+			return
+		default:
 		}
 
-		err = data.TaskGetMine(&tasks, owner)
 		if err != nil {
-			log.Errorf("Error getting open owned tasks from DB: %s", err)
-			continue
+			log.Warnf("Error watching for new tasks: %s  RETRYING...", err)
 		}
 
-		for i := range tasks {
-			wg.Add(1)
-			go func(t *Task) {
-				t.Run()
-				wg.Done()
-			}(tasks[i])
+		select {
+		case <-r.stop:
+			return
+		case <-time.After(r.interval()):
 		}
-		wg.Wait()
-		time.AfterFunc(pollInterval, func() {
-			taskRun <- true
+	}
+}
 
-		})
+// claim claims as many ready tasks as there are free workers, and starts any owned tasks that can be run
+func (r *taskRunner) claim() {
+	free, typeLimits := r.capacity()
+
+	if free > 0 {
+		err := data.TaskClaim(r.owner, free, typeLimits)
+		if err != nil && err != data.ErrNotFound {
+			log.Errorf("Error claiming open tasks from DB: %s", err)
+			return
+		}
+	}
+
+	var owned []*Task
+	err := data.TaskGetMine(&owned, r.owner)
+	if err == data.ErrNotFound {
+		return
+	}
+	if err != nil {
+		log.Errorf("Error getting open owned tasks from DB: %s", err)
+		return
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	r.waiting = make(map[string]int)
+	for i := range owned {
+		// tasks that finished after they were read from the DB are still marked as owned in the results
+		if r.running[owned[i].Key] || r.finished[owned[i].Key] {
+			continue
+		}
+		if !r.start(owned[i]) {
+			r.waiting[owned[i].Type]++
+		}
+	}
+}
+
+// capacity returns the number of new tasks that can be claimed, and how many more tasks can be claimed of each
+// type with a concurrency limit, counting the tasks of that type already running or claimed and waiting.  It also
+// starts tracking which tasks finish during this claim
+func (r *taskRunner) capacity() (uint, map[string]int) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.finished = make(map[data.UUID]bool)
+
+	typeLimits := tasks.limits()
+	for tType := range typeLimits {
+		typeLimits[tType] -= r.types[tType] + r.waiting[tType]
+	}
+
+	used := uint(len(r.running)) + r.waitingCount()
+	if used >= r.queueSize {
+		return 0, typeLimits
+	}
+	return r.queueSize - used, typeLimits
+}
+
+// waitingCount is the number of owned tasks waiting to run, runner must be locked
+func (r *taskRunner) waitingCount() uint {
+	count := 0
+	for _, waiting := range r.waiting {
+		count += waiting
 	}
+	return uint(count)
 }
 
-func stopTaskRunner() {
-	taskRun <- false
+// start runs the task on a new worker if there is room for it, runner must be locked
+func (r *taskRunner) start(t *Task) bool {
+	if uint(len(r.running)) >= r.queueSize {
+		return false
+	}
+
+	if limit := tasks.get(t.Type).Concurrency(); limit > 0 && r.types[t.Type] >= limit {
+		return false
+	}
+
+	r.running[t.Key] = true
+	r.types[t.Type]++
+	r.wg.Add(1)
+
+	go func() {
+		defer r.done(t)
+		t.Run()
+	}()
+
+	return true
+}
+
+func (r *taskRunner) runningKeys() []data.UUID {
+	r.Lock()
+	defer r.Unlock()
+
+	keys := make([]data.UUID, 0, len(r.running))
+	for key := range r.running {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// done frees up the worker for the completed task, and wakes the runner to claim more work
+func (r *taskRunner) done(t *Task) {
+	r.Lock()
+	delete(r.running, t.Key)
+	r.finished[t.Key] = true
+	r.types[t.Type]--
+	if r.types[t.Type] <= 0 {
+		delete(r.types, t.Type)
+	}
+	r.Unlock()
+
+	r.wg.Done()
+	r.wakeUp()
 }
@@ -0,0 +1,168 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package app
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "git.townsourced.com/townsourced/check"
+	rt "git.townsourced.com/townsourced/gorethink"
+	"github.com/timshannon/townsourced/data"
+)
+
+// Task Runner Test Suite
+// The shared task runner is stopped for these tests, so it doesn't claim the test tasks
+type TaskRunnerSuite struct {
+	shared *taskRunner
+
+	limited   *testTasker
+	unlimited *testTasker
+	failing   *testTasker
+}
+
+var _ = Suite(&TaskRunnerSuite{})
+
+const testTaskRunnerOwner = data.Key("testTaskRunner")
+
+type testTasker struct {
+	tType       string
+	concurrency int
+	retry       int
+	err         error
+	release     chan struct{} // Do blocks until release is closed
+}
+
+func (t *testTasker) Type() string       { return t.tType }
+func (t *testTasker) Priority() uint     { return priorityMedium }
+func (t *testTasker) Schedule() Schedule { return scheduleOnce }
+func (t *testTasker) Retry() int         { return t.retry }
+func (t *testTasker) Concurrency() int   { return t.concurrency }
+func (t *testTasker) Do(variables ...interface{}) error {
+	<-t.release
+	return t.err
+}
+
+func (s *TaskRunnerSuite) SetUpSuite(c *C) {
+	s.shared = runner
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	stopTaskRunner(ctx)
+
+	s.limited = &testTasker{tType: "testLimited", concurrency: 1}
+	s.unlimited = &testTasker{tType: "testUnlimited"}
+	s.failing = &testTasker{tType: "testFailing", retry: 1, err: errors.New("test task failure")}
+
+	registerTaskType(s.limited)
+	registerTaskType(s.unlimited)
+	registerTaskType(s.failing)
+}
+
+func (s *TaskRunnerSuite) TearDownSuite(c *C) {
+	startTaskRunner(s.shared.owner, s.shared.queueSize, s.shared.pollInterval)
+}
+
+func (s *TaskRunnerSuite) SetUpTest(c *C) {
+	s.limited.release = make(chan struct{})
+	s.unlimited.release = make(chan struct{})
+	s.failing.release = make(chan struct{})
+}
+
+func (s *TaskRunnerSuite) TearDownTest(c *C) {
+	_, err := rt.DB("task").Table("task").Filter(func(row rt.Term) interface{} {
+		return rt.Expr([]string{s.limited.tType, s.unlimited.tType, s.failing.tType}).Contains(row.Field("Type"))
+	}).Delete().RunWrite(data.DatabaseSession())
+	c.Assert(err, Equals, nil)
+}
+
+func ownedTypes(c *C, owner data.Key) map[string]int {
+	var owned []*Task
+	err := data.TaskGetMine(&owned, owner)
+	if err == data.ErrNotFound {
+		return map[string]int{}
+	}
+	c.Assert(err, Equals, nil)
+
+	types := make(map[string]int)
+	for i := range owned {
+		types[owned[i].Type]++
+	}
+	return types
+}
+
+func (s *TaskRunnerSuite) TestTaskRunnerClaim(c *C) {
+	for i := 0; i < 3; i++ {
+		c.Assert(taskAdd(s.limited), Equals, nil)
+		c.Assert(taskAdd(s.unlimited), Equals, nil)
+	}
+
+	r := newTaskRunner(testTaskRunnerOwner, 10, time.Minute)
+	r.claim()
+
+	// only one task of a type with a concurrency of 1 is claimed
+	owned := ownedTypes(c, r.owner)
+	c.Assert(owned[s.limited.tType], Equals, 1)
+	c.Assert(owned[s.unlimited.tType], Equals, 3)
+	c.Assert(r.runningKeys(), HasLen, 4)
+
+	// no more are claimed while it's running
+	r.claim()
+	c.Assert(ownedTypes(c, r.owner)[s.limited.tType], Equals, 1)
+
+	close(s.limited.release)
+	close(s.unlimited.release)
+	r.wg.Wait()
+
+	for i := 0; i < 2; i++ {
+		r.claim()
+		r.wg.Wait()
+	}
+
+	var open []*Task
+	c.Assert(data.TaskGetOpenType(&open, s.limited.tType, 10), Equals, data.ErrNotFound)
+	c.Assert(data.TaskGetOpenType(&open, s.unlimited.tType, 10), Equals, data.ErrNotFound)
+}
+
+func (s *TaskRunnerSuite) TestTaskRunnerQueueSize(c *C) {
+	for i := 0; i < 3; i++ {
+		c.Assert(taskAdd(s.unlimited), Equals, nil)
+	}
+
+	r := newTaskRunner(testTaskRunnerOwner, 2, time.Minute)
+	r.claim()
+	c.Assert(ownedTypes(c, r.owner)[s.unlimited.tType], Equals, 2)
+
+	r.claim()
+	c.Assert(ownedTypes(c, r.owner)[s.unlimited.tType], Equals, 2)
+
+	close(s.unlimited.release)
+	r.wg.Wait()
+	r.claim()
+	r.wg.Wait()
+
+	var open []*Task
+	c.Assert(data.TaskGetOpenType(&open, s.unlimited.tType, 10), Equals, data.ErrNotFound)
+}
+
+func (s *TaskRunnerSuite) TestTaskRunnerRetry(c *C) {
+	c.Assert(taskAdd(s.failing), Equals, nil)
+	close(s.failing.release)
+
+	r := newTaskRunner(testTaskRunnerOwner, 10, time.Minute)
+	r.claim()
+	r.wg.Wait()
+
+	// failed tasks are thrown back into the queue until they run out of retries
+	var open []*Task
+	c.Assert(data.TaskGetOpenType(&open, s.failing.tType, 10), Equals, nil)
+	c.Assert(open, HasLen, 1)
+	c.Assert(open[0].Retry, Equals, 1)
+	c.Assert(open[0].Owner, Equals, data.EmptyKey)
+
+	r.claim()
+	r.wg.Wait()
+
+	c.Assert(data.TaskGetOpenType(&open, s.failing.tType, 10), Equals, data.ErrNotFound)
+}
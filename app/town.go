@@ -4,6 +4,7 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"net/mail"
@@ -34,21 +35,54 @@ type Town struct {
 
 	//Private towns are where only invitees can post or join the town, and only members can view
 	// Note that you may be a member, and not an invitee, as in the announcements town
-	Private        bool            `json:"private,omitempty"`
-	Invites        []data.Key      `json:"invites,omitempty"`
-	InviteRequests []InviteRequest `json:"inviteRequests,omitempty"`
-	AutoModerator  struct {        //blacklists - what isn't allowed instead of what is
-		Categories   []string       `json:"categories,omitempty"`   //automod posts in these categories
-		MinUserDays  uint           `json:"minUserDays,omitempty"`  //automod if posted by user younger than x days MaxNumLinks  uint           `json:"maxNumLinks,omitempty"`  //automod if has more than x links in post
-		MaxNumLinks  uint           `json:"maxNumLinks,omitempty"`  //automod if has more than x links in post
-		Users        []data.Key     `json:"users,omitempty"`        //automod if post is submitted by one of these users
-		RegexpReject []RegexpReason `json:"regexpReject,omitempty"` //automod if regexp matches with given reason
-		//TODO: minUserRating
-	} `json:"autoModerator,omitempty"`
+	Private          bool             `json:"private,omitempty"`
+	Invites          []data.Key       `json:"invites,omitempty"`
+	InviteRequests   []InviteRequest  `json:"inviteRequests,omitempty"`
+	AutoModerator    AutoModerator    `json:"autoModerator,omitempty"`
+	CommentModerator CommentModerator `json:"commentModerator,omitempty"`
 
 	Population int `json:"population,omitempty" gorethink:",omitempty"` //Calculated field - not pulled from document
 
 	data.Version
+	modLog  townLog
+	history postHistory // recent posts quotas are counted from instead of the database, only set for dry runs
+}
+
+// AutoModerator is a town's rules for which posts are automatically moderated.  They're blacklists - what isn't
+// allowed instead of what is
+type AutoModerator struct {
+	Categories     []string       `json:"categories,omitempty"`     //automod posts in these categories
+	MinUserDays    uint           `json:"minUserDays,omitempty"`    //automod if posted by user younger than x days
+	MaxNumLinks    uint           `json:"maxNumLinks,omitempty"`    //automod if has more than x links in post
+	Users          []data.Key     `json:"users,omitempty"`          //automod if post is submitted by one of these users
+	RegexpReject   []RegexpReason `json:"regexpReject,omitempty"`   //automod if regexp matches with given reason
+	Trusted        []data.Key     `json:"trusted,omitempty"`        //never automod posts submitted by these users
+	Quotas         []PostQuota    `json:"quotas,omitempty"`         //automod if user has posted more than x per period
+	Duplicates     DuplicateRule  `json:"duplicates,omitempty"`     //act on posts duplicating the user's recent posts
+	BlockedDomains []string       `json:"blockedDomains,omitempty"` //automod posts linking to these domains
+	AllowedDomains []string       `json:"allowedDomains,omitempty"` //if set, automod posts linking to any other domain
+	Hold           []string       `json:"hold,omitempty"`           //rule types which hold posts for review instead
+	SpamThreshold  float64        `json:"spamThreshold,omitempty"`  //automod if the spam classifier is this confident
+	MinUserRating  float64        `json:"minUserRating,omitempty"`  //automod if posted by a rated user scoring under x
+}
+
+// PostQuota is the max number of posts a user can publish to a town in a period, optionally only counting posts in a
+// single category
+type PostQuota struct {
+	Category string `json:"category,omitempty"`
+	Period   string `json:"period,omitempty"`
+	Max      uint   `json:"max"`
+}
+
+// Post quota periods
+const (
+	QuotaPeriodDay  = "day"
+	QuotaPeriodWeek = "week"
+)
+
+var quotaPeriods = map[string]time.Duration{
+	QuotaPeriodDay:  24 * time.Hour,
+	QuotaPeriodWeek: 7 * 24 * time.Hour,
 }
 
 // RegexpReason is a reason for moderation tied to a regular expression
@@ -100,6 +134,8 @@ const (
 	townMaxLinkDefault      = 5
 	townModMaxRegexpLength  = 500
 	townModMaxRexexpCount   = 100
+	townModMaxRegexpInst    = 5000
+	townModMaxQuotaCount    = 20
 	townSearchMaxRetrieve   = 1000
 	townSearchMinDistance   = 0.5
 	townSearchMaxDistance   = 1000.0
@@ -365,9 +401,22 @@ func (t *Town) Update() error {
 	if err != nil {
 		return err
 	}
+	err = t.modLog.write()
+	if err != nil {
+		return err
+	}
 	return t.index()
 }
 
+// logSetting records a change to one of the town's settings in its moderation log, before and after must be
+// comparable
+func (t *Town) logSetting(who *User, action string, before, after interface{}) {
+	if before == after {
+		return
+	}
+	t.modLog.add(t.Key, who, action, "", before, after, "")
+}
+
 // SetDescription sets the town's description
 func (t *Town) SetDescription(who *User, newDescription string) error {
 	if !t.mod(who).active() {
@@ -377,6 +426,7 @@ func (t *Town) SetDescription(who *User, newDescription string) error {
 		return ErrTownDescriptionMax
 	}
 
+	t.logSetting(who, TownLogSettingDescription, t.Description, newDescription)
 	t.Description = newDescription
 	return nil
 
@@ -398,6 +448,7 @@ func (t *Town) SetInformation(who *User, newInformation string) error {
 		return ErrTownNotMod
 	}
 
+	t.logSetting(who, TownLogSettingInformation, t.Information, newInformation)
 	t.Information = newInformation
 	return nil
 }
@@ -414,6 +465,7 @@ func (t *Town) SetName(who *User, newName string) error {
 		return ErrTownNameMax
 	}
 
+	t.logSetting(who, TownLogSettingName, t.Name, newName)
 	t.Name = newName
 	return nil
 }
@@ -440,6 +492,7 @@ func (t *Town) SetColor(who *User, newColor string) error {
 		return fail.New("Invalid color.  Color must be in hexidecimal format with a leading #")
 	}
 
+	t.logSetting(who, TownLogSettingColor, t.Color, newColor)
 	t.Color = newColor
 	return nil
 }
@@ -460,6 +513,7 @@ func (t *Town) InviteModerator(who *User, newMod *User) error {
 		InviteSent: time.Now(),
 		Username:   newMod.Username,
 	})
+	t.modLog.add(t.Key, who, TownLogModeratorInvite, string(newMod.Username), nil, nil, "")
 
 	sub, msg, err := messages.use("msgTownModInvite").Execute(t)
 	if err != nil {
@@ -490,6 +544,7 @@ func (t *Town) AcceptModeratorInvite(who *User) error {
 		mod := t.Moderators[i]
 		if mod.Username == who.Username {
 			t.Moderators[i].Start = time.Now()
+			t.modLog.add(t.Key, who, TownLogModeratorAccept, string(who.Username), nil, nil, "")
 			return nil
 		}
 	}
@@ -523,6 +578,7 @@ func (t *Town) RemoveModerator(who *User, removeMod *User) error {
 		mod := t.Moderators[i]
 		if mod.Username == removeMod.Username {
 			t.Moderators[i].End = time.Now()
+			t.modLog.add(t.Key, who, TownLogModeratorRemove, string(removeMod.Username), nil, nil, "")
 			return nil
 		}
 	}
@@ -535,6 +591,25 @@ func (t *Town) IsMod(user *User) bool {
 	return t.mod(user).active()
 }
 
+// moderated is whether or not the town has any active moderators
+func (t *Town) moderated() bool {
+	for i := range t.Moderators {
+		if t.Moderators[i].active() {
+			return true
+		}
+	}
+	return false
+}
+
+// canReview is whether or not the user can review the town's moderation queue, either as one of its moderators,
+// or as a site reviewer when the town has no active moderators
+func (t *Town) canReview(user *User) bool {
+	if t.IsMod(user) {
+		return true
+	}
+	return user != nil && user.can(permReviewContent) && !t.moderated()
+}
+
 // returns  a mod entry for the passed in user
 // if the user isn't a mod, then an empty mod is returned
 // active mods will be returned instead of old inactive entries
@@ -545,7 +620,7 @@ func (t *Town) mod(user *User) *Moderator {
 		return &Moderator{}
 	}
 
-	if user.Admin {
+	if user.HasRole(RoleAdmin) {
 		return &Moderator{
 			Start:    user.Created,
 			Username: user.Username,
@@ -657,6 +732,7 @@ func (t *Town) SetHeaderImage(who *User, image *Image, x0, y0, x1, y1 float64) e
 		return err
 	}
 
+	t.logSetting(who, TownLogSettingHeaderImage, t.HeaderImage, image.Key)
 	t.HeaderImage = image.Key
 
 	err = image.encode()
@@ -679,57 +755,139 @@ func (t *Town) RemoveHeaderImage(who *User) error {
 		return err
 	}
 
+	t.logSetting(who, TownLogSettingHeaderImage, t.HeaderImage, data.EmptyUUID)
 	t.HeaderImage = data.EmptyUUID
 
 	return nil
 }
 
-func (t *Town) autoModerate(p *Post) (string, error) {
-	// categories
-	for _, category := range t.AutoModerator.Categories {
-		if p.Category == category {
-			return "This town does not allow posts from the " + category + " category.", nil
+// autoModerate returns the type of the first rule the post breaks in the town, and the reason shown for it
+func (t *Town) autoModerate(ctx context.Context, p *Post) (rule, reason string, err error) {
+	//Users
+	// checked before trusted users, so a user who is both is never let through
+	for _, u := range t.AutoModerator.Users {
+		if p.Creator == u {
+			return AutoModRuleUsers, "You are not allowed to post to this town.  Contact the town moderator(s) for " +
+				"more information.", nil
 		}
 	}
 
-	//Users
+	if t.trusted(p.Creator) {
+		// trusting a user in a town doesn't let them link to domains blocked site wide
+		reason, err = linkReason(nil, p.Title+"\n"+p.Content)
+		return AutoModRuleDomains, reason, err
+	}
 
-	for _, u := range t.AutoModerator.Users {
-		if p.Creator == u {
-			return "You are not allowed to post to this town.  Contact the town moderator(s) for more information.", nil
+	// categories
+	for _, category := range t.AutoModerator.Categories {
+		if p.Category == category {
+			return AutoModRuleCategories, "This town does not allow posts from the " + category + " category.", nil
 		}
 	}
 
 	postUser, err := p.creator()
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
-	if postUser.Created.After(time.Now().AddDate(0, 0, int(t.AutoModerator.MinUserDays)*-1)) {
-		return fmt.Sprintf("This town does not allow posts by users whose accounts are younger than %d day(s)", t.AutoModerator.MinUserDays), nil
+	// account age is checked as of when the post was published, so older posts are judged as they were posted
+	if postUser.Created.After(p.postedAt().AddDate(0, 0, int(t.AutoModerator.MinUserDays)*-1)) {
+		return AutoModRuleMinUserDays, fmt.Sprintf("This town does not allow posts by users whose accounts are "+
+			"younger than %d day(s)", t.AutoModerator.MinUserDays), nil
+	}
+
+	if t.AutoModerator.MinUserRating > 0 && postUser.Reputation != nil &&
+		postUser.Reputation.Score < t.AutoModerator.MinUserRating {
+		return AutoModRuleMinUserRating, fmt.Sprintf("This town does not allow posts by users with a rating lower "+
+			"than %.1f", t.AutoModerator.MinUserRating), nil
 	}
 
 	//links
 	// TODO: I'm betting there are ways around my rx with commonmark, and it may end up being safer and faster
 	// to simply use the commonmark implementation to count links.  We'll start with this for now though.
 	if len(rxMDLink.FindAll([]byte(p.Content), int(t.AutoModerator.MaxNumLinks+1))) > int(t.AutoModerator.MaxNumLinks) {
-		return fmt.Sprintf("This town does not allow posts with more than %d links", t.AutoModerator.MaxNumLinks), nil
+		return AutoModRuleMaxNumLinks, fmt.Sprintf("This town does not allow posts with more than %d links",
+			t.AutoModerator.MaxNumLinks), nil
+	}
+
+	//link domains
+	reason, err = linkReason(t, p.Title+"\n"+p.Content)
+	if err != nil {
+		return "", "", err
+	}
+	if reason != "" {
+		return AutoModRuleDomains, reason, nil
 	}
 
 	//regexp
 	for _, rr := range t.AutoModerator.RegexpReject {
 		rxFind, err := regexp.Compile(rr.Regexp)
 		if err != nil {
-			//skip the bad regexp
+			// expressions are validated when they're added, so this is only possible for ones saved before
+			// validation was tightened
+			requestLog(ctx).WithField("town", t.Key).WithField("regexp", rr.Regexp).
+				Warn("Skipping invalid auto moderator regexp")
 			continue
 		}
 
 		if rxFind.Find([]byte(p.Title)) != nil || rxFind.Find([]byte(p.Content)) != nil {
-			return rr.Reason, nil
+			return AutoModRuleRegexp, rr.Reason, nil
+		}
+	}
+
+	//quotas
+	for _, quota := range t.AutoModerator.Quotas {
+		reason, err := t.checkQuota(p, quota)
+		if err != nil {
+			return "", "", err
+		}
+		if reason != "" {
+			return AutoModRuleQuotas, reason, nil
+		}
+	}
+
+	//spam classifier
+	reason, err = t.spamReason(p)
+	if err != nil {
+		return "", "", err
+	}
+	if reason != "" {
+		return AutoModRuleSpam, reason, nil
+	}
+
+	return "", "", nil
+}
+
+// checkQuota returns a reason if the post's creator had already published the quota's max number of posts to the
+// town in the period before the post was published
+func (t *Town) checkQuota(p *Post, quota PostQuota) (string, error) {
+	if quota.Category != "" && quota.Category != p.Category {
+		return "", nil
+	}
+
+	until := p.postedAt()
+	since := until.Add(-quotaPeriods[quota.Period])
+
+	var count int
+	if t.history != nil {
+		count = t.history.countInTown(p, t.Key, quota.Category, since, until)
+	} else {
+		var err error
+		count, err = data.PostCountByUserInTown(p.Creator, t.Key, quota.Category, since, until, p.Key)
+		if err != nil {
+			return "", err
 		}
 	}
 
-	return "", nil
+	if uint(count) < quota.Max {
+		return "", nil
+	}
+
+	reason := fmt.Sprintf("This town allows at most %d post(s) per user per %s", quota.Max, quota.Period)
+	if quota.Category != "" {
+		reason += " in the " + quota.Category + " category"
+	}
+	return reason + ".  Please try again later.", nil
 }
 
 // ensureAnnoucementTown checks if the announcement town has been created yet
@@ -766,6 +924,7 @@ func (t *Town) SetPrivate(who *User, private bool) error {
 		return ErrTownNotMod
 	}
 
+	t.logSetting(who, TownLogSettingPrivate, t.Private, private)
 	t.Private = private
 	return nil
 }
@@ -792,6 +951,7 @@ func (t *Town) AddInvite(who *User, invitee *User) error {
 	}
 
 	t.Invites = append(t.Invites, invitee.Username)
+	t.modLog.add(t.Key, who, TownLogInviteAdd, string(invitee.Username), nil, nil, "")
 
 	sub, msg, err := messages.use("msgTownPrivateInvite").Execute(t)
 	if err != nil {
@@ -915,6 +1075,7 @@ func (t *Town) RemoveInvite(who *User, toRemove *User) error {
 		if t.Invites[i] == toRemove.Username {
 			//remove user from invites
 			t.Invites = append(t.Invites[:i], t.Invites[i+1:]...)
+			t.modLog.add(t.Key, who, TownLogInviteRemove, string(toRemove.Username), nil, nil, "")
 			return nil
 		}
 	}
@@ -1010,6 +1171,7 @@ func (t *Town) AcceptInviteRequest(who *User, invitee *User) error {
 	}
 
 	t.Invites = append(t.Invites, invitee.Username)
+	t.modLog.add(t.Key, who, TownLogInviteRequestAccept, string(invitee.Username), nil, nil, "")
 	err := invitee.JoinTown(t)
 	if err != nil {
 		return err
@@ -1062,6 +1224,7 @@ func (t *Town) RejectInviteRequest(who *User, invitee *User) error {
 		return nil
 	}
 
+	t.modLog.add(t.Key, who, TownLogInviteRequestReject, string(invitee.Username), nil, nil, "")
 	err := t.Update()
 	if err != nil {
 		return err
@@ -1118,13 +1281,14 @@ func (t *Town) AddAutoModCategory(who *User, category string) error {
 		return fail.New("Invalid Auto Moderation post category")
 	}
 
+	before := append([]string(nil), t.AutoModerator.Categories...)
+
 	// remove if already added
-	err := t.RemoveAutoModCategory(who, category)
-	if err != nil {
-		return err
-	}
+	t.removeAutoModCategory(category)
 
 	t.AutoModerator.Categories = append(t.AutoModerator.Categories, category)
+	t.modLog.add(t.Key, who, TownLogAutoModCategories, category, before,
+		append([]string(nil), t.AutoModerator.Categories...), "")
 
 	return nil
 }
@@ -1135,14 +1299,24 @@ func (t *Town) RemoveAutoModCategory(who *User, category string) error {
 		return ErrTownNotMod
 	}
 
+	before := append([]string(nil), t.AutoModerator.Categories...)
+	if t.removeAutoModCategory(category) {
+		t.modLog.add(t.Key, who, TownLogAutoModCategories, category, before,
+			append([]string(nil), t.AutoModerator.Categories...), "")
+	}
+
+	return nil
+}
+
+func (t *Town) removeAutoModCategory(category string) bool {
 	for i := range t.AutoModerator.Categories {
 		if t.AutoModerator.Categories[i] == category {
 			t.AutoModerator.Categories = append(t.AutoModerator.Categories[:i], t.AutoModerator.Categories[i+1:]...)
-			return nil
+			return true
 		}
 	}
 
-	return nil
+	return false
 }
 
 // SetAutoModMinUserDays sets the minimum days old a user must be to post to this town
@@ -1150,6 +1324,7 @@ func (t *Town) SetAutoModMinUserDays(who *User, minUserDays uint) error {
 	if !t.mod(who).active() {
 		return ErrTownNotMod
 	}
+	t.logSetting(who, TownLogAutoModMinUserDays, t.AutoModerator.MinUserDays, minUserDays)
 	t.AutoModerator.MinUserDays = minUserDays
 	return nil
 }
@@ -1159,6 +1334,7 @@ func (t *Town) SetAutoModMaxNumLinks(who *User, maxNumLinks uint) error {
 	if !t.mod(who).active() {
 		return ErrTownNotMod
 	}
+	t.logSetting(who, TownLogAutoModMaxNumLinks, t.AutoModerator.MaxNumLinks, maxNumLinks)
 	t.AutoModerator.MaxNumLinks = maxNumLinks
 	return nil
 }
@@ -1174,13 +1350,21 @@ func (t *Town) AddAutoModUser(who *User, username data.Key) error {
 		return err
 	}
 
+	before := append([]data.Key(nil), t.AutoModerator.Users...)
+
 	//remove if exists already
-	err = t.RemoveAutoModUser(who, username)
-	if err != nil {
-		return err
-	}
+	t.removeAutoModUser(username)
 
 	t.AutoModerator.Users = append(t.AutoModerator.Users, username)
+	t.modLog.add(t.Key, who, TownLogAutoModUsers, string(username), before,
+		append([]data.Key(nil), t.AutoModerator.Users...), "")
+
+	// auto moderated users can't also be trusted
+	beforeTrusted := append([]data.Key(nil), t.AutoModerator.Trusted...)
+	if t.removeAutoModTrusted(username) {
+		t.modLog.add(t.Key, who, TownLogAutoModTrusted, string(username), beforeTrusted,
+			append([]data.Key(nil), t.AutoModerator.Trusted...), "")
+	}
 
 	return nil
 }
@@ -1191,14 +1375,24 @@ func (t *Town) RemoveAutoModUser(who *User, username data.Key) error {
 		return ErrTownNotMod
 	}
 
+	before := append([]data.Key(nil), t.AutoModerator.Users...)
+	if t.removeAutoModUser(username) {
+		t.modLog.add(t.Key, who, TownLogAutoModUsers, string(username), before,
+			append([]data.Key(nil), t.AutoModerator.Users...), "")
+	}
+
+	return nil
+}
+
+func (t *Town) removeAutoModUser(username data.Key) bool {
 	for i := range t.AutoModerator.Users {
 		if t.AutoModerator.Users[i] == username {
 			t.AutoModerator.Users = append(t.AutoModerator.Users[:i], t.AutoModerator.Users[i+1:]...)
-			return nil
+			return true
 		}
 	}
 
-	return nil
+	return false
 }
 
 // AddAutoModRegexp adds a new regular expression to auto moderator
@@ -1207,18 +1401,14 @@ func (t *Town) AddAutoModRegexp(who *User, expr, reason string) error {
 		return ErrTownNotMod
 	}
 
-	if len(expr) > townModMaxRegexpLength {
-		return fail.New(fmt.Sprintf("The expression is too long.  The max length is %d", townModMaxRegexpLength))
-	}
-
 	if len(t.AutoModerator.RegexpReject) >= townModMaxRexexpCount {
 		return fail.New(fmt.Sprintf("This town has too many expressions added, you'll need to remove some before "+
 			"you can add more.  The max number of expressions is %d", townModMaxRexexpCount))
 	}
 
-	_, err := regexp.Compile(expr)
+	err := validateAutoModRegexp(expr)
 	if err != nil {
-		return fail.NewFromErr(err)
+		return err
 	}
 
 	for _, rr := range t.AutoModerator.RegexpReject {
@@ -1227,10 +1417,14 @@ func (t *Town) AddAutoModRegexp(who *User, expr, reason string) error {
 		}
 	}
 
+	before := append([]RegexpReason(nil), t.AutoModerator.RegexpReject...)
+
 	t.AutoModerator.RegexpReject = append(t.AutoModerator.RegexpReject, RegexpReason{
 		Regexp: expr,
 		Reason: reason,
 	})
+	t.modLog.add(t.Key, who, TownLogAutoModRegexpReject, expr, before,
+		append([]RegexpReason(nil), t.AutoModerator.RegexpReject...), "")
 
 	return nil
 }
@@ -1241,9 +1435,13 @@ func (t *Town) RemoveAutoModRegexp(who *User, expr string) error {
 		return ErrTownNotMod
 	}
 
+	before := append([]RegexpReason(nil), t.AutoModerator.RegexpReject...)
+
 	for i := range t.AutoModerator.RegexpReject {
 		if t.AutoModerator.RegexpReject[i].Regexp == expr {
 			t.AutoModerator.RegexpReject = append(t.AutoModerator.RegexpReject[:i], t.AutoModerator.RegexpReject[i+1:]...)
+			t.modLog.add(t.Key, who, TownLogAutoModRegexpReject, expr, before,
+				append([]RegexpReason(nil), t.AutoModerator.RegexpReject...), "")
 			return nil
 		}
 	}
@@ -1251,6 +1449,136 @@ func (t *Town) RemoveAutoModRegexp(who *User, expr string) error {
 	return fail.New("No auto moderator reason exists for this expression.", expr)
 }
 
+// AddAutoModTrusted adds a user whose posts are never auto moderated
+func (t *Town) AddAutoModTrusted(who *User, username data.Key) error {
+	if !t.mod(who).active() {
+		return ErrTownNotMod
+	}
+
+	_, err := UserGet(username)
+	if err != nil {
+		return err
+	}
+
+	for _, u := range t.AutoModerator.Users {
+		if u == username {
+			return fail.New("This user is auto moderated, remove them from the auto moderated users before trusting them",
+				username)
+		}
+	}
+
+	before := append([]data.Key(nil), t.AutoModerator.Trusted...)
+
+	//remove if exists already
+	t.removeAutoModTrusted(username)
+
+	t.AutoModerator.Trusted = append(t.AutoModerator.Trusted, username)
+	t.modLog.add(t.Key, who, TownLogAutoModTrusted, string(username), before,
+		append([]data.Key(nil), t.AutoModerator.Trusted...), "")
+
+	return nil
+}
+
+// RemoveAutoModTrusted removes a user from the auto moderator's trusted users
+func (t *Town) RemoveAutoModTrusted(who *User, username data.Key) error {
+	if !t.mod(who).active() {
+		return ErrTownNotMod
+	}
+
+	before := append([]data.Key(nil), t.AutoModerator.Trusted...)
+	if t.removeAutoModTrusted(username) {
+		t.modLog.add(t.Key, who, TownLogAutoModTrusted, string(username), before,
+			append([]data.Key(nil), t.AutoModerator.Trusted...), "")
+	}
+	return nil
+}
+
+// trusted is whether or not the user's posts are never auto moderated in this town
+func (t *Town) trusted(username data.Key) bool {
+	for _, u := range t.AutoModerator.Trusted {
+		if u == username {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Town) removeAutoModTrusted(username data.Key) bool {
+	for i := range t.AutoModerator.Trusted {
+		if t.AutoModerator.Trusted[i] == username {
+			t.AutoModerator.Trusted = append(t.AutoModerator.Trusted[:i], t.AutoModerator.Trusted[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetAutoModQuota sets the max number of posts a user can publish to this town per period, optionally only in a
+// single category.  Setting an existing category and period replaces its max.
+func (t *Town) SetAutoModQuota(who *User, category, period string, max uint) error {
+	if !t.mod(who).active() {
+		return ErrTownNotMod
+	}
+
+	if _, ok := quotaPeriods[period]; !ok {
+		return fail.New("Invalid quota period, must be either day or week", period)
+	}
+
+	if category != "" && !isPostCategory(category) {
+		return fail.New("Invalid Auto Moderation post category")
+	}
+
+	if max == 0 {
+		return fail.New("A quota's max must be at least 1", max)
+	}
+
+	before := append([]PostQuota(nil), t.AutoModerator.Quotas...)
+
+	//replace if exists already
+	if !t.removeAutoModQuota(category, period) && len(t.AutoModerator.Quotas) >= townModMaxQuotaCount {
+		return fail.New(fmt.Sprintf("This town has too many quotas, you'll need to remove some before "+
+			"you can add more.  The max number of quotas is %d", townModMaxQuotaCount))
+	}
+
+	t.AutoModerator.Quotas = append(t.AutoModerator.Quotas, PostQuota{
+		Category: category,
+		Period:   period,
+		Max:      max,
+	})
+	t.modLog.add(t.Key, who, TownLogAutoModQuotas, category, before,
+		append([]PostQuota(nil), t.AutoModerator.Quotas...), "")
+
+	return nil
+}
+
+// RemoveAutoModQuota removes the quota for a category and period from the auto moderator
+func (t *Town) RemoveAutoModQuota(who *User, category, period string) error {
+	if !t.mod(who).active() {
+		return ErrTownNotMod
+	}
+
+	before := append([]PostQuota(nil), t.AutoModerator.Quotas...)
+	if t.removeAutoModQuota(category, period) {
+		t.modLog.add(t.Key, who, TownLogAutoModQuotas, category, before,
+			append([]PostQuota(nil), t.AutoModerator.Quotas...), "")
+		return nil
+	}
+
+	return fail.New("No auto moderator quota exists for this category and period.", category, period)
+}
+
+func (t *Town) removeAutoModQuota(category, period string) bool {
+	for i := range t.AutoModerator.Quotas {
+		if t.AutoModerator.Quotas[i].Category == category && t.AutoModerator.Quotas[i].Period == period {
+			t.AutoModerator.Quotas = append(t.AutoModerator.Quotas[:i], t.AutoModerator.Quotas[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
 // CanSearch is whether or not the passed in user can run searches against this town
 // e.g. town isn't private or they are a member
 func (t *Town) CanSearch(who *User) bool {
@@ -0,0 +1,148 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package app
+
+import (
+	"time"
+
+	"github.com/timshannon/townsourced/data"
+	"github.com/timshannon/townsourced/fail"
+)
+
+// Town moderation log actions
+const (
+	TownLogPostModerate            = "post.moderate"
+	TownLogPostRemoveModeration    = "post.removeModeration"
+	TownLogPostApprove             = "post.approve"
+	TownLogCommentModerate         = "comment.moderate"
+	TownLogCommentRemoveModeration = "comment.removeModeration"
+	TownLogCommentApprove          = "comment.approve"
+	TownLogModeratorInvite         = "moderator.invite"
+	TownLogModeratorAccept         = "moderator.accept"
+	TownLogModeratorRemove         = "moderator.remove"
+	TownLogInviteAdd               = "invite.add"
+	TownLogInviteRemove            = "invite.remove"
+	TownLogInviteRequestAccept     = "inviteRequest.accept"
+	TownLogInviteRequestReject     = "inviteRequest.reject"
+	TownLogAutoModCategories       = "autoModerator.categories"
+	TownLogAutoModMinUserDays      = "autoModerator.minUserDays"
+	TownLogAutoModMaxNumLinks      = "autoModerator.maxNumLinks"
+	TownLogAutoModUsers            = "autoModerator.users"
+	TownLogAutoModRegexpReject     = "autoModerator.regexpReject"
+	TownLogAutoModTrusted          = "autoModerator.trusted"
+	TownLogAutoModQuotas           = "autoModerator.quotas"
+	TownLogAutoModDuplicates       = "autoModerator.duplicates"
+	TownLogAutoModBlockedDomains   = "autoModerator.blockedDomains"
+	TownLogAutoModAllowedDomains   = "autoModerator.allowedDomains"
+	TownLogAutoModHold             = "autoModerator.hold"
+	TownLogAutoModSpamThreshold    = "autoModerator.spamThreshold"
+	TownLogAutoModMinUserRating    = "autoModerator.minUserRating"
+	TownLogCommentModMinUserDays   = "commentModerator.minUserDays"
+	TownLogCommentModMaxNumLinks   = "commentModerator.maxNumLinks"
+	TownLogCommentModUsers         = "commentModerator.users"
+	TownLogCommentModRegexpReject  = "commentModerator.regexpReject"
+	TownLogSettingName             = "settings.name"
+	TownLogSettingDescription      = "settings.description"
+	TownLogSettingInformation      = "settings.information"
+	TownLogSettingColor            = "settings.color"
+	TownLogSettingPrivate          = "settings.private"
+	TownLogSettingHeaderImage      = "settings.headerImage"
+)
+
+const (
+	townLogMaxLimit        = 500
+	townLogExportLimit     = 10000
+	townLogActionMaxLength = 64
+)
+
+// TownLogEntry records an action taken by one of a town's moderators.  Entries are only ever appended to a town's
+// moderation log, never changed or removed
+type TownLogEntry struct {
+	Town   data.Key    `json:"town"`
+	Action string      `json:"action"`
+	Who    data.Key    `json:"who"`
+	Target string      `json:"target,omitempty"` // the post or user the action was taken against
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+	Reason string      `json:"reason,omitempty"`
+	When   time.Time   `json:"when"`
+}
+
+// townLog holds moderation log entries until the town or post they were made against is updated, so actions which
+// fail to save are never logged
+type townLog []*TownLogEntry
+
+func (l *townLog) add(town data.Key, who *User, action, target string, before, after interface{}, reason string) {
+	username := data.EmptyKey
+	if who != nil {
+		username = who.Username
+	}
+
+	*l = append(*l, &TownLogEntry{
+		Town:   town,
+		Action: action,
+		Who:    username,
+		Target: target,
+		Before: before,
+		After:  after,
+		Reason: reason,
+		When:   time.Now(),
+	})
+}
+
+func (l *townLog) write() error {
+	if len(*l) == 0 {
+		return nil
+	}
+
+	err := data.TownLogInsert(*l)
+	if err != nil {
+		return err
+	}
+	*l = nil
+	return nil
+}
+
+// Log retrieves the town's moderation log newest first, optionally only the entries for a specific action
+func (t *Town) Log(who *User, action string, from, limit int) ([]*TownLogEntry, error) {
+	if !t.canReview(who) {
+		return nil, ErrTownNotMod
+	}
+
+	if limit <= 0 || limit > townLogMaxLimit {
+		limit = townLogMaxLimit
+	}
+
+	if from < 0 {
+		from = 0
+	}
+
+	return t.log(action, from, limit)
+}
+
+// LogExport retrieves up to the export limit of the town's moderation log entries, newest first
+func (t *Town) LogExport(who *User, action string) ([]*TownLogEntry, error) {
+	if !t.canReview(who) {
+		return nil, ErrTownNotMod
+	}
+
+	return t.log(action, 0, townLogExportLimit)
+}
+
+func (t *Town) log(action string, from, limit int) ([]*TownLogEntry, error) {
+	if len(action) > townLogActionMaxLength {
+		return nil, fail.New("Invalid moderation log action", action)
+	}
+
+	var entries []*TownLogEntry
+	err := data.TownLogGet(&entries, t.Key, action, from, limit)
+	if err == data.ErrNotFound {
+		return []*TownLogEntry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
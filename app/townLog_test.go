@@ -0,0 +1,79 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package app_test
+
+import (
+	. "git.townsourced.com/townsourced/check"
+	"github.com/timshannon/townsourced/app"
+)
+
+// Town Moderation Log Test Suite
+type TownLogSuite struct {
+	*testData
+}
+
+var _ = Suite(&TownLogSuite{testData: &testData{}})
+
+func (s *TownLogSuite) SetUpTest(c *C) {
+	s.testData.setup(c)
+}
+
+func (s *TownLogSuite) TearDownTest(c *C) {
+	s.testData.teardown(c)
+}
+
+func (s *TownLogSuite) TestTownLog(c *C) {
+	_, err := s.town1.Log(s.other, "", 0, 10)
+	c.Assert(err, Equals, app.ErrTownNotMod)
+
+	entries, err := s.town1.Log(s.moderator, "", 0, 10)
+	c.Assert(err, Equals, nil)
+	c.Assert(len(entries), Equals, 0)
+
+	c.Assert(s.town1.SetName(s.moderator, "New Test Town 1"), Equals, nil)
+	c.Assert(s.town1.AddAutoModCategory(s.moderator, "jobs"), Equals, nil)
+	// unchanged settings aren't logged
+	c.Assert(s.town1.SetAutoModMinUserDays(s.moderator, s.town1.AutoModerator.MinUserDays), Equals, nil)
+
+	// nothing is logged until the town is updated
+	entries, err = s.town1.Log(s.moderator, "", 0, 10)
+	c.Assert(err, Equals, nil)
+	c.Assert(len(entries), Equals, 0)
+
+	c.Assert(s.town1.Update(), Equals, nil)
+
+	entries, err = s.town1.Log(s.moderator, "", 0, 10)
+	c.Assert(err, Equals, nil)
+	c.Assert(len(entries), Equals, 2)
+
+	entries, err = s.town1.Log(s.moderator, app.TownLogSettingName, 0, 10)
+	c.Assert(err, Equals, nil)
+	c.Assert(len(entries), Equals, 1)
+	c.Assert(entries[0].Who, Equals, s.moderator.Username)
+	c.Assert(entries[0].Before, Equals, "Test Town 1")
+	c.Assert(entries[0].After, Equals, "New Test Town 1")
+
+	c.Assert(s.postPub.Moderate(s.town1, s.moderator, "spam"), Equals, nil)
+	c.Assert(s.postPub.RemoveModeration(s.town1, s.moderator), Equals, nil)
+	c.Assert(s.postPub.Update(), Equals, nil)
+
+	entries, err = s.town1.Log(s.moderator, "", 0, 10)
+	c.Assert(err, Equals, nil)
+	c.Assert(len(entries), Equals, 4)
+
+	entries, err = s.town1.Log(s.moderator, app.TownLogPostModerate, 0, 10)
+	c.Assert(err, Equals, nil)
+	c.Assert(len(entries), Equals, 1)
+	c.Assert(entries[0].Reason, Equals, "spam")
+	c.Assert(entries[0].Target, Equals, string(s.postPub.Key))
+
+	entries, err = s.town1.Log(s.moderator, app.TownLogPostRemoveModeration, 0, 10)
+	c.Assert(err, Equals, nil)
+	c.Assert(len(entries), Equals, 1)
+
+	// other towns have their own log
+	entries, err = s.town2.Log(s.moderator, "", 0, 10)
+	c.Assert(err, Equals, nil)
+	c.Assert(len(entries), Equals, 0)
+}
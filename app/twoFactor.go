@@ -0,0 +1,353 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package app
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/skip2/go-qrcode"
+	"github.com/timshannon/townsourced/data"
+	"github.com/timshannon/townsourced/fail"
+)
+
+// Two-factor authentication uses time based one time passwords (TOTP, RFC 6238) from an authenticator app, with
+// single-use recovery codes for when the user doesn't have access to their app
+const (
+	twoFactorIssuer         = "Townsourced"
+	twoFactorSecretBytes    = 20
+	twoFactorDigits         = 6
+	twoFactorModulo         = 1000000 // 10^twoFactorDigits
+	twoFactorPeriod         = 30      // seconds
+	twoFactorSkew           = 1       // number of periods before and after the current one a code is accepted for
+	twoFactorQRSize         = 256
+	twoFactorRecoveryCodes  = 10
+	twoFactorRecoveryBytes  = 5
+	twoFactorLoginExpire    = 5 * time.Minute
+	twoFactorLoginAttempts  = 5 // wrong codes allowed before the login has to be started over
+	twoFactorRecoveryLength = 8 // characters in a recovery code, excluding the separator
+)
+
+var twoFactorEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+var (
+	// ErrTwoFactorInvalid is when a two-factor authentication or recovery code doesn't match
+	ErrTwoFactorInvalid = fail.New("Invalid two-factor authentication code")
+	// ErrTwoFactorEnabled is when a user tries to setup two-factor authentication when it's already enabled
+	ErrTwoFactorEnabled = fail.New("Two-factor authentication is already enabled")
+	// ErrTwoFactorNotEnabled is when a user tries to change two-factor authentication when it isn't enabled
+	ErrTwoFactorNotEnabled = fail.New("Two-factor authentication isn't enabled")
+	// ErrTwoFactorLoginExpired is when the second step of a login happens after its token has expired
+	ErrTwoFactorLoginExpired = fail.New("Your login has expired, please log in again")
+)
+
+// TwoFactorSetup is what a user needs to add their account to an authenticator app
+type TwoFactorSetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+	QRCode []byte `json:"qrCode"` // png of the uri
+}
+
+// twoFactorLogin is the state kept between the password and the two-factor steps of a login
+type twoFactorLogin struct {
+	Username   data.Key
+	RememberMe bool
+	Expires    time.Time
+}
+
+// TwoFactorBegin starts setting up two-factor authentication for the user.  It isn't enabled until a code from the
+// new secret is verified with TwoFactorEnable
+func (u *User) TwoFactorBegin() (*TwoFactorSetup, error) {
+	if u.TwoFactor {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	secret := make([]byte, twoFactorSecretBytes)
+	_, err := io.ReadFull(rand.Reader, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	u.TOTPPending = twoFactorEncoding.EncodeToString(secret)
+
+	uri := (&url.URL{
+		Scheme: "otpauth",
+		Host:   "totp",
+		Path:   "/" + twoFactorIssuer + ":" + string(u.Username),
+		RawQuery: url.Values{
+			"secret": []string{u.TOTPPending},
+			"issuer": []string{twoFactorIssuer},
+			"digits": []string{fmt.Sprintf("%d", twoFactorDigits)},
+			"period": []string{fmt.Sprintf("%d", twoFactorPeriod)},
+		}.Encode(),
+	}).String()
+
+	png, err := qrcode.Encode(uri, qrcode.Medium, twoFactorQRSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TwoFactorSetup{
+		Secret: u.TOTPPending,
+		URI:    uri,
+		QRCode: png,
+	}, nil
+}
+
+// TwoFactorEnable enables two-factor authentication once the user has proven their app is setup with a valid code.
+// The returned recovery codes are only ever shown this once
+func (u *User) TwoFactorEnable(code string) ([]string, error) {
+	if u.TwoFactor {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	if u.TOTPPending == "" {
+		return nil, fail.New("Two-factor authentication setup hasn't been started")
+	}
+
+	counter, ok := totpValidate(u.TOTPPending, twoFactorNormalize(code), 0)
+	if !ok {
+		return nil, ErrTwoFactorInvalid
+	}
+
+	u.TwoFactor = true
+	u.TOTPSecret = u.TOTPPending
+	u.TOTPPending = ""
+	u.TOTPCounter = counter
+
+	return u.newRecoveryCodes(), nil
+}
+
+// TwoFactorDisable turns off two-factor authentication.  The user must re-authenticate with their password, if they
+// have one, and a current code or recovery code
+func (u *User) TwoFactorDisable(password, code string) error {
+	if !u.TwoFactor {
+		return ErrTwoFactorNotEnabled
+	}
+
+	err := u.login(password)
+	if err != nil {
+		return err
+	}
+
+	err = u.checkTwoFactor(code)
+	if err != nil {
+		return err
+	}
+
+	u.TwoFactor = false
+	u.TOTPSecret = ""
+	u.TOTPPending = ""
+	u.TOTPCounter = 0
+	u.RecoveryCodes = nil
+	return nil
+}
+
+// TwoFactorRecoveryCodes replaces the user's recovery codes with new ones, for when they've used or lost them
+func (u *User) TwoFactorRecoveryCodes(code string) ([]string, error) {
+	if !u.TwoFactor {
+		return nil, ErrTwoFactorNotEnabled
+	}
+
+	err := u.checkTwoFactor(code)
+	if err != nil {
+		return nil, err
+	}
+
+	return u.newRecoveryCodes(), nil
+}
+
+// TwoFactorLoginNew starts the second step of logging in a user who has two-factor authentication enabled.  The
+// returned token is exchanged along with a code for the user with UserLoginTwoFactor
+func (u *User) TwoFactorLoginNew(rememberMe bool) (string, error) {
+	token := Random(256)
+
+	err := data.TempTokenSet(&twoFactorLogin{
+		Username:   u.Username,
+		RememberMe: rememberMe,
+		Expires:    time.Now().Add(twoFactorLoginExpire),
+	}, token, twoFactorLoginExpire)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// UserLoginTwoFactor finishes logging in a user with the token from the first step of their login and a
+// two-factor authentication or recovery code.  Returns the user and whether or not they asked to be remembered
+func UserLoginTwoFactor(token, code string) (*User, bool, error) {
+	login := &twoFactorLogin{}
+	err := data.TempTokenGet(login, token)
+	if err == data.ErrNotFound {
+		return nil, false, ErrTwoFactorLoginExpired
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	if login.Username == data.EmptyKey || time.Now().After(login.Expires) {
+		return nil, false, ErrTwoFactorLoginExpired
+	}
+
+	// attempts are counted before the code is checked, so parallel requests can't check more codes than allowed
+	attempts, err := data.TempTokenAttempt(token, login.Expires.Sub(time.Now()))
+	if err != nil {
+		return nil, false, err
+	}
+	if attempts > twoFactorLoginAttempts {
+		return nil, false, ErrTwoFactorLoginExpired
+	}
+
+	u, err := UserGet(login.Username)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// suspended users are turned away before their code is used up
+	err = u.checkSuspended()
+	if err != nil {
+		return nil, false, err
+	}
+
+	err = u.checkTwoFactor(code)
+	if err == ErrTwoFactorInvalid {
+		if attempts >= twoFactorLoginAttempts {
+			err = data.TempTokenExpire(token)
+			if err != nil {
+				return nil, false, err
+			}
+		}
+		return nil, false, ErrTwoFactorInvalid
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	// the login token and the code can only be used once
+	err = data.TempTokenExpire(token)
+	if err != nil {
+		return nil, false, err
+	}
+
+	err = u.Update()
+	if err != nil {
+		return nil, false, err
+	}
+
+	return u, login.RememberMe, nil
+}
+
+// checkTwoFactor checks a code from the user's authenticator app, or one of their recovery codes, which is used up
+func (u *User) checkTwoFactor(code string) error {
+	if !u.TwoFactor {
+		return nil
+	}
+
+	code = twoFactorNormalize(code)
+
+	if len(code) == twoFactorDigits {
+		counter, ok := totpValidate(u.TOTPSecret, code, u.TOTPCounter)
+		if !ok {
+			return ErrTwoFactorInvalid
+		}
+		// a code can't be used again
+		u.TOTPCounter = counter
+		return nil
+	}
+
+	if len(code) != twoFactorRecoveryLength {
+		return ErrTwoFactorInvalid
+	}
+
+	hash := recoveryCodeHash(code)
+	for i := range u.RecoveryCodes {
+		if hmac.Equal([]byte(u.RecoveryCodes[i]), []byte(hash)) {
+			u.RecoveryCodes = append(u.RecoveryCodes[:i], u.RecoveryCodes[i+1:]...)
+			return nil
+		}
+	}
+
+	return ErrTwoFactorInvalid
+}
+
+// newRecoveryCodes generates a new set of recovery codes for the user, only their hashes are stored
+func (u *User) newRecoveryCodes() []string {
+	codes := make([]string, twoFactorRecoveryCodes)
+	u.RecoveryCodes = make([]string, twoFactorRecoveryCodes)
+
+	for i := range codes {
+		b := make([]byte, twoFactorRecoveryBytes)
+		_, err := io.ReadFull(rand.Reader, b)
+		if err != nil {
+			panic(fmt.Sprintf("Error generating random values: %v", err))
+		}
+		code := strings.ToLower(twoFactorEncoding.EncodeToString(b))
+		u.RecoveryCodes[i] = recoveryCodeHash(code)
+		codes[i] = code[:twoFactorRecoveryLength/2] + "-" + code[twoFactorRecoveryLength/2:]
+	}
+
+	return codes
+}
+
+func recoveryCodeHash(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// twoFactorNormalize strips the spacing and separators users may type or paste along with a code
+func twoFactorNormalize(code string) string {
+	return strings.ToLower(strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, code))
+}
+
+// totpValidate checks a code against the secret for the current time period, and the periods just before and after
+// it to allow for clock drift.  Codes for periods at or before lastCounter have already been used and aren't valid.
+// Returns the period the code matched
+func totpValidate(secret, code string, lastCounter int64) (int64, bool) {
+	key, err := twoFactorEncoding.DecodeString(secret)
+	if err != nil || len(code) != twoFactorDigits {
+		return 0, false
+	}
+
+	current := time.Now().Unix() / twoFactorPeriod
+	for counter := current - twoFactorSkew; counter <= current+twoFactorSkew; counter++ {
+		if counter <= lastCounter {
+			continue
+		}
+		if hmac.Equal([]byte(totpCode(key, counter)), []byte(code)) {
+			return counter, true
+		}
+	}
+	return 0, false
+}
+
+// totpCode generates the code for the key and time period
+func totpCode(key []byte, counter int64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(counter))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", twoFactorDigits, value%twoFactorModulo)
+}
@@ -30,13 +30,22 @@ type User struct {
 	Name           string          `json:"name,omitempty"`
 	Password       []byte          `json:"-" gorethink:",omitempty"`
 	HasPassword    bool            `json:"hasPassword,omitempty"`
+	TwoFactor      bool            `json:"twoFactor,omitempty"` // a code from an authenticator app is required to log in
+	TOTPSecret     string          `json:"-"`
+	TOTPPending    string          `json:"-"` // secret being setup, until a code from it is verified
+	TOTPCounter    int64           `json:"-"` // time period of the last code used, codes can't be reused
+	RecoveryCodes  []string        `json:"-"` // hashes of the unused single-use recovery codes
 	TownKeys       []data.KeyWhen  `json:"townKeys,omitempty"`
 	Badges         []int           `json:"badges,omitempty" gorethink:",omitempty"` // Awards given by townsourced (i.e. 5 year badge)
 	Stamps         []data.Key      `json:"stamps,omitempty" gorethink:",omitempty"`
 	ProfileImage   data.UUID       `json:"profileImage,omitempty" gorethink:",omitempty"`
 	ProfileIcon    data.UUID       `json:"profileIcon,omitempty" gorethink:",omitempty"`
-	Admin          bool            `json:"admin,omitempty"` // Only set directly in DB currently
+	Admin          bool            `json:"admin,omitempty"` // kept in sync with the admin role
+	Roles          []string        `json:"roles,omitempty"` // site roles, see role.go
 	SavedPosts     []data.UUIDWhen `json:"savedPosts,omitempty"`
+	Blocked        []data.Key      `json:"blocked,omitempty"`    // users whose messages and comments are hidden
+	Suspension     *UserSuspension `json:"suspension,omitempty"` // set when suspended or banned by an admin
+	Reputation     *UserReputation `json:"reputation,omitempty"` // summary of the ratings the user has received
 
 	NotifyPost    bool `json:"notifyPost,omitempty"`
 	NotifyComment bool `json:"notifyComment,omitempty"`
@@ -107,7 +116,6 @@ var (
 var emailTest = regexp.MustCompile(".+@.+\\..+")
 
 //TODO: Financials - payments and payouts
-//TODO: Ratings, separate table?
 
 // UserNew creates a new user
 func UserNew(username data.Key, email, password string) (*User, error) {
@@ -249,6 +257,11 @@ func (u *User) ClearPrivate() {
 	u.TwitterID = ""
 	u.Password = nil
 	u.HasPassword = false
+	u.TwoFactor = false
+	u.TOTPSecret = ""
+	u.TOTPPending = ""
+	u.TOTPCounter = 0
+	u.RecoveryCodes = nil
 	u.NotifyPost = false
 	u.NotifyComment = false
 	u.EmailPrivateMsg = false
@@ -257,8 +270,10 @@ func (u *User) ClearPrivate() {
 	u.EmailCommentReply = false
 	u.EmailPostComment = false
 	u.EmailValidated = false
+	u.Suspension = nil
 
 	u.SavedPosts = nil
+	u.Blocked = nil
 	u.privateCleared = true
 }
 
@@ -308,6 +323,11 @@ func UserLogin(usernameOrEmail, password string) (*User, error) {
 	if err != nil {
 		return nil, err
 	}
+
+	err = u.checkSuspended()
+	if err != nil {
+		return nil, err
+	}
 	return u, nil
 
 }
@@ -791,9 +811,19 @@ func (u *User) LinkTwitter(stateToken, verificationCode string) error {
 	return nil
 }
 
-// SendMessage sends a private message to another user
+// SendDirectMessage sends a private message a user wrote to another user, which isn't delivered if the recipient has
+// blocked them
+func (u *User) SendDirectMessage(to *User, subject, message string) error {
+	if to.blocks(u.Username) {
+		// the sender isn't told they've been blocked
+		return nil
+	}
+	return u.SendMessage(to, subject, message)
+}
+
+// SendMessage sends a private message to another user.  Moderation notices and invites are sent even if the
+// recipient has blocked the sender
 func (u *User) SendMessage(to *User, subject, message string) error {
-	//TODO: Blocking users?
 	err := notificationNew(u.Username, to.Username, subject, message)
 	if err != nil {
 		return err
@@ -833,7 +863,7 @@ func (u *User) sendEmail(subject, body string) error {
 }
 
 func (u *User) mentionPost(p *Post) error {
-	if !u.NotifyPost {
+	if !u.NotifyPost || u.blocks(p.Creator) {
 		return nil
 	}
 
@@ -878,7 +908,7 @@ func (u *User) mentionPost(p *Post) error {
 }
 
 func (u *User) mentionComment(c *Comment) error {
-	if !u.NotifyComment {
+	if !u.NotifyComment || u.blocks(c.Username) {
 		return nil
 	}
 
@@ -1029,6 +1059,10 @@ func (u *User) Comments(who *User, since time.Time, limit int) ([]Comment, error
 		return nil, err
 	}
 
+	for i := range comments {
+		comments[i].clearHidden()
+	}
+
 	return comments, nil
 }
 
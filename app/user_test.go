@@ -4,6 +4,12 @@
 package app_test
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
 	"strings"
 	"time"
 
@@ -459,6 +465,157 @@ func (s *UserSuite) TestUserSendMessage(c *C) {
 	c.Assert(found, Equals, true)
 }
 
+func (s *UserSuite) TestUserBlock(c *C) {
+	var subject = "blocked subject"
+
+	c.Assert(s.user.Block(s.user.Username), Equals, app.ErrUserBlockSelf)
+	c.Assert(s.user.Block(data.NewKey("notarealunittestuser")), Equals, app.ErrUserNotFound)
+
+	comment, err := app.CommentNew(context.Background(), s.other, s.postPub, "comment before blocking")
+	c.Assert(err, Equals, nil)
+	defer s.deleteComment(c, comment)
+
+	c.Assert(s.user.Block(s.other.Username), Equals, nil)
+	c.Assert(s.user.Update(), Equals, nil)
+
+	// blocked users' messages aren't delivered, and they aren't told
+	c.Assert(s.other.SendDirectMessage(s.user, subject, "test message"), Equals, nil)
+	notifications, err := s.user.UnreadNotifications(time.Time{}, 10)
+	c.Assert(err, Equals, nil)
+	for i := range notifications {
+		c.Assert(notifications[i].Subject, Not(Equals), subject)
+	}
+
+	// moderation notices and invites from a blocked user are still delivered
+	var noticeSubject = "blocked notice subject"
+	c.Assert(s.other.SendMessage(s.user, noticeSubject, "test notice"), Equals, nil)
+	notifications, err = s.user.UnreadNotifications(time.Time{}, 10)
+	c.Assert(err, Equals, nil)
+	found := false
+	for i := range notifications {
+		if notifications[i].Subject == noticeSubject {
+			found = true
+			break
+		}
+	}
+	c.Assert(found, Equals, true)
+
+	post, err := app.PostGet(s.postPub.Key)
+	c.Assert(err, Equals, nil)
+	_, err = app.CommentNew(context.Background(), s.other, post, "comment after blocking")
+	c.Assert(err, Equals, app.ErrCommentBlocked)
+
+	comments, _, err := app.CommentsGet(s.user, post, nil, 0, 10, "")
+	c.Assert(err, Equals, nil)
+	c.Assert(comments, HasLen, 1)
+	c.Assert(comments[0].Blocked, Equals, true)
+	c.Assert(comments[0].Comment.Comment, Equals, "")
+
+	comments, _, err = app.CommentsGet(nil, post, nil, 0, 10, "")
+	c.Assert(err, Equals, nil)
+	c.Assert(comments, HasLen, 1)
+	c.Assert(comments[0].Blocked, Equals, false)
+	c.Assert(comments[0].Comment.Comment, Equals, "comment before blocking")
+
+	s.user.Unblock(s.other.Username)
+	c.Assert(s.user.Update(), Equals, nil)
+
+	post, err = app.PostGet(s.postPub.Key)
+	c.Assert(err, Equals, nil)
+	comment, err = app.CommentNew(context.Background(), s.other, post, "comment after unblocking")
+	c.Assert(err, Equals, nil)
+	defer s.deleteComment(c, comment)
+}
+
+// twoFactorCode generates the current code for the secret, like an authenticator app would
+func twoFactorCode(c *C, secret string) string {
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	c.Assert(err, Equals, nil)
+
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(time.Now().Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[offset:offset+4])&0x7fffffff)%1000000)
+}
+
+func (s *UserSuite) TestUserTwoFactor(c *C) {
+	_, err := s.user.TwoFactorEnable("123456")
+	c.Assert(err, Not(Equals), nil)
+
+	setup, err := s.user.TwoFactorBegin()
+	c.Assert(err, Equals, nil)
+	c.Assert(strings.HasPrefix(setup.URI, "otpauth://totp/"), Equals, true)
+	c.Assert(len(setup.QRCode) > 0, Equals, true)
+
+	_, err = s.user.TwoFactorEnable("not a code")
+	c.Assert(err, Equals, app.ErrTwoFactorInvalid)
+
+	code := twoFactorCode(c, setup.Secret)
+	codes, err := s.user.TwoFactorEnable(code)
+	c.Assert(err, Equals, nil)
+	c.Assert(codes, HasLen, 10)
+	c.Assert(s.user.TwoFactor, Equals, true)
+	c.Assert(s.user.Update(), Equals, nil)
+
+	_, err = s.user.TwoFactorBegin()
+	c.Assert(err, Equals, app.ErrTwoFactorEnabled)
+
+	// the password is only the first step of logging in
+	u, err := app.UserLogin(string(s.user.Username), s.userPassword)
+	c.Assert(err, Equals, nil)
+	c.Assert(u.TwoFactor, Equals, true)
+
+	token, err := u.TwoFactorLoginNew(true)
+	c.Assert(err, Equals, nil)
+
+	// codes can't be reused
+	_, _, err = app.UserLoginTwoFactor(token, code)
+	c.Assert(err, Equals, app.ErrTwoFactorInvalid)
+
+	_, _, err = app.UserLoginTwoFactor("badtoken", codes[0])
+	c.Assert(err, Equals, app.ErrTwoFactorLoginExpired)
+
+	u, rememberMe, err := app.UserLoginTwoFactor(token, codes[0])
+	c.Assert(err, Equals, nil)
+	c.Assert(rememberMe, Equals, true)
+	c.Assert(u.Username, Equals, s.user.Username)
+
+	// login tokens can only be used once
+	_, _, err = app.UserLoginTwoFactor(token, codes[1])
+	c.Assert(err, Equals, app.ErrTwoFactorLoginExpired)
+
+	// and so can recovery codes
+	token, err = u.TwoFactorLoginNew(false)
+	c.Assert(err, Equals, nil)
+	_, _, err = app.UserLoginTwoFactor(token, codes[0])
+	c.Assert(err, Equals, app.ErrTwoFactorInvalid)
+
+	// too many wrong codes, and the login has to be started over
+	token, err = u.TwoFactorLoginNew(false)
+	c.Assert(err, Equals, nil)
+	for i := 0; i < 5; i++ {
+		_, _, err = app.UserLoginTwoFactor(token, codes[0])
+		c.Assert(err, Equals, app.ErrTwoFactorInvalid)
+	}
+	_, _, err = app.UserLoginTwoFactor(token, codes[1])
+	c.Assert(err, Equals, app.ErrTwoFactorLoginExpired)
+
+	// disabling requires re-authenticating
+	c.Assert(u.TwoFactorDisable("badpassword", codes[1]), Equals, app.ErrUserLogonFailure)
+	c.Assert(u.TwoFactorDisable(s.userPassword, codes[0]), Equals, app.ErrTwoFactorInvalid)
+	c.Assert(u.TwoFactorDisable(s.userPassword, strings.ToUpper(codes[1])), Equals, nil)
+	c.Assert(u.Update(), Equals, nil)
+
+	u, err = app.UserGet(s.user.Username)
+	c.Assert(err, Equals, nil)
+	c.Assert(u.TwoFactor, Equals, false)
+	c.Assert(u.RecoveryCodes, HasLen, 0)
+}
+
 func (s *UserSuite) TestUserPosts(c *C) {
 	//test not owner, published only
 	posts, err := s.user.Posts(s.other, "", time.Time{}, 10)
@@ -543,8 +700,8 @@ func (s *UserSuite) TestUserGetSavedPosts(c *C) {
 }
 
 func (s *UserSuite) TestUserSavePost(c *C) {
-	otherPost, err := app.PostNew("test title", "test content", "buysell", app.PostFormatStandard, s.other,
-		[]data.Key{s.town1.Key}, nil, data.EmptyUUID, false, false, false)
+	otherPost, err := app.PostNew(context.Background(), "test title", "test content", "buysell", app.PostFormatStandard,
+		s.other, []data.Key{s.town1.Key}, nil, data.EmptyUUID, false, false, false)
 	defer s.testData.deletePost(c, otherPost)
 	c.Assert(err, Equals, nil)
 
@@ -611,11 +768,11 @@ func (s *UserSuite) TestUserRemoveSavedPost(c *C) {
 
 func (s *UserSuite) TestUserComments(c *C) {
 	// add 2 comments
-	comment1, err := app.CommentNew(s.user, s.post1, "test comment 1")
+	comment1, err := app.CommentNew(context.Background(), s.user, s.post1, "test comment 1")
 	defer s.deleteComment(c, comment1)
 	c.Assert(err, Equals, nil)
 
-	comment2, err := app.CommentNew(s.user, s.post1, "test comment 2")
+	comment2, err := app.CommentNew(context.Background(), s.user, s.post1, "test comment 2")
 	defer s.deleteComment(c, comment2)
 	c.Assert(err, Equals, nil)
 
@@ -4,12 +4,14 @@
 package app
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/base64"
 	"fmt"
 	"io"
 	"math"
 	"strings"
+	"sync"
 	"unicode"
 )
 
@@ -59,3 +61,28 @@ func (u urlify) make() string {
 func round(n float64) int {
 	return int(n + math.Copysign(0.5, n))
 }
+
+// waitContext waits for the wait group to finish, or until the context is done
+func waitContext(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func inStrings(slice []string, value string) bool {
+	for i := range slice {
+		if slice[i] == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package data
+
+import rt "git.townsourced.com/townsourced/gorethink"
+
+func init() {
+	tables = append(tables, tblAudit)
+}
+
+var tblAudit = &table{
+	name: "audit",
+	indexes: []index{
+		index{name: "When"},
+		index{
+			name: "Target_When",
+			indexFunc: func(row rt.Term) interface{} {
+				return []interface{}{row.Field("Target"), row.Field("When")}
+			},
+		},
+	},
+}
+
+// AuditInsert records a new entry in the audit trail
+func AuditInsert(entry interface{}) error {
+	return wErr(runWrite("AuditInsert", tblAudit.Insert(entry)))
+}
+
+// AuditGet retrieves entries from the audit trail newest first, optionally only those against a specific target
+func AuditGet(result interface{}, target Key, from, limit int) (err error) {
+	var trm rt.Term
+	if target == EmptyKey {
+		trm = tblAudit.OrderBy(rt.OrderByOpts{
+			Index: rt.Desc("When"),
+		})
+	} else {
+		trm = tblAudit.Between([]interface{}{target, rt.MinVal}, []interface{}{target, rt.MaxVal},
+			rt.BetweenOpts{
+				Index: "Target_When",
+			}).OrderBy(rt.OrderByOpts{
+			Index: rt.Desc("Target_When"),
+		})
+	}
+
+	c, err := run("AuditGet", trm.Skip(from).Limit(limit))
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	if c.IsNil() {
+		return ErrNotFound
+	}
+
+	return c.All(result)
+}
@@ -17,6 +17,7 @@ import (
 	"git.townsourced.com/townsourced/consistent"
 	"git.townsourced.com/townsourced/gomemcache/memcache"
 	log "git.townsourced.com/townsourced/logrus"
+	"github.com/timshannon/townsourced/metrics"
 )
 
 //TODO: Log statistics on query usage to determine
@@ -36,17 +37,40 @@ type cacher interface {
 }
 
 var cacheClient *memcache.Client
+var cacheSelector *consistentSelector
+
+var (
+	cacheRequests = metrics.NewCounter("townsourced_cache_requests_total",
+		"Number of memcached lookups, by whether the key was found.", "result")
+	cacheDuration = metrics.NewHistogram("townsourced_cache_operation_duration_seconds",
+		"Latency of memcached operations in seconds.", nil, "operation")
+	cacheErrors = metrics.NewCounter("townsourced_cache_errors_total",
+		"Number of memcached operations that failed.", "operation")
+)
+
+// observeCache records the latency of a memcached operation, and the error if it failed.  Misses, and adding keys
+// which already exist, aren't errors
+func observeCache(operation string, start time.Time, err error) {
+	cacheDuration.Since(start, operation)
+	if err != nil && err != memcache.ErrCacheMiss && err != memcache.ErrNotStored {
+		cacheErrors.Inc(operation)
+	}
+}
 
 // cacheGet tries to retrieve data from cache for the passed in definition
 // if not found in cache, it'll retrieve the data from the database and update
 // the cache with the retrieved value
 func cacheGet(c cacher, result interface{}) error {
+	start := time.Now()
 	item, err := cacheClient.Get(c.key())
+	observeCache("get", start, err)
 	//get cache based on c.key()
 	if err == nil {
 		//key found
+		cacheRequests.Inc("hit")
 		return cacheDecode(item.Value, result)
 	}
+	cacheRequests.Inc("miss")
 
 	log.WithField("key", c.key()).Debugf("Cache Miss: %s", err)
 	// if not found, get data from c.source()
@@ -64,7 +88,10 @@ func cacheGet(c cacher, result interface{}) error {
 // cacheSet will update the cache value for the passed in cacher definition
 func cacheSet(c cacher, value interface{}) error {
 	if value == nil {
-		return cacheClient.Delete(c.key())
+		start := time.Now()
+		err := cacheClient.Delete(c.key())
+		observeCache("delete", start, err)
+		return err
 	}
 
 	cacheValue, err := cacheEncode(value)
@@ -72,11 +99,13 @@ func cacheSet(c cacher, value interface{}) error {
 		return err
 	}
 
+	start := time.Now()
 	err = cacheClient.Set(&memcache.Item{
 		Key:        c.key(),
 		Value:      cacheValue,
 		Expiration: int32(c.expiration().Seconds()),
 	})
+	observeCache("set", start, err)
 	if err != nil {
 		return err
 	}
@@ -103,12 +132,13 @@ func cacheRefresh(c cacher, emptyType interface{}) error {
 }
 
 func initCache(cfg *CacheConfig) error {
-	selector, err := newConsistentSelector(cfg.Addresses...)
+	var err error
+	cacheSelector, err = newConsistentSelector(cfg.Addresses...)
 	if err != nil {
 		return err
 	}
 
-	cacheClient = memcache.NewFromSelector(selector)
+	cacheClient = memcache.NewFromSelector(cacheSelector)
 	return nil
 }
 
@@ -118,8 +148,7 @@ type consistentSelector struct {
 	addrs map[string]net.Addr
 }
 
-//TODO: Add / remove cache servers on the fly
-// automatically drop out failing cache servers
+//TODO: automatically drop out failing cache servers
 // Track healthy cache servers in the DB, poll for new ones every 15 minutes
 
 func newConsistentSelector(servers ...string) (*consistentSelector, error) {
@@ -152,6 +181,20 @@ func newConsistentSelector(servers ...string) (*consistentSelector, error) {
 	return cs, nil
 }
 
+// setServers replaces the set of cache servers, keys will be redistributed across the new servers
+func (cs *consistentSelector) setServers(servers ...string) error {
+	updated, err := newConsistentSelector(servers...)
+	if err != nil {
+		return err
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.con = updated.con
+	cs.addrs = updated.addrs
+	return nil
+}
+
 // Each iterates over each server calling the given function
 func (cs *consistentSelector) Each(f func(net.Addr) error) error {
 	cs.mu.RLock()
@@ -0,0 +1,81 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package data
+
+import (
+	"fmt"
+	"time"
+
+	"git.townsourced.com/townsourced/elastic"
+	"git.townsourced.com/townsourced/gomemcache/memcache"
+	rt "git.townsourced.com/townsourced/gorethink"
+)
+
+// checkTimeout is how long to wait on each server when checking the config
+const checkTimeout = 10 * time.Second
+
+// CheckConfig validates the data layer config, and tests the connection to each of the configured database, cache
+// and search servers without initializing the data layer
+func CheckConfig(cfg *Config) []error {
+	var errs []error
+	var err error
+
+	cfg.DB.timeout, err = time.ParseDuration(cfg.DB.Timeout)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Error parsing DB Timeout: %s", err))
+	}
+
+	if cfg.DB.timeout <= 0 || cfg.DB.timeout > checkTimeout {
+		cfg.DB.timeout = checkTimeout
+	}
+
+	s, err := rt.Connect(cfg.DB.connectOpts())
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Error connecting to database %s: %s", cfg.DB.Address, err))
+	} else {
+		_ = s.Close()
+	}
+
+	if len(cfg.Cache.Addresses) == 0 {
+		errs = append(errs, fmt.Errorf("No cache server addresses are configured"))
+	}
+
+	for _, address := range cfg.Cache.Addresses {
+		_, err = newConsistentSelector(address)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Invalid cache server address %s: %s", address, err))
+			continue
+		}
+		client := memcache.New(address)
+		client.Timeout = checkTimeout
+		_, err = client.Get("townsourced-config-check")
+		if err != nil && err != memcache.ErrCacheMiss {
+			errs = append(errs, fmt.Errorf("Error connecting to cache server %s: %s", address, err))
+		}
+	}
+
+	if len(cfg.Search.Addresses) == 0 {
+		errs = append(errs, fmt.Errorf("No search server addresses are configured"))
+	}
+
+	for _, address := range cfg.Search.Addresses {
+		client, err := elastic.NewClient(
+			elastic.SetURL(address),
+			elastic.SetSniff(false),
+			elastic.SetMaxRetries(0),
+			elastic.SetHealthcheckTimeoutStartup(checkTimeout),
+		)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Error connecting to search server %s: %s", address, err))
+			continue
+		}
+		client.Stop()
+	}
+
+	if cfg.Search.Index.Name == "" {
+		errs = append(errs, fmt.Errorf("No search index name is configured"))
+	}
+
+	return errs
+}
@@ -39,12 +39,13 @@ var tblComment = &table{
 				return []interface{}{row.Field("Username"), row.Field("Updated")}
 			},
 		},
+		index{name: "Queued"},
 	},
 }
 
 // CommentGet retrieves a single comment
 func CommentGet(result interface{}, key UUID) error {
-	c, err := tblComment.Get(key).Run(session)
+	c, err := run("CommentGet", tblComment.Get(key))
 	if err != nil {
 		return err
 	}
@@ -58,7 +59,7 @@ func CommentGet(result interface{}, key UUID) error {
 
 // CommentGetTree retrieves a single comment and it's children
 func CommentGetTree(result interface{}, key UUID, limit int, sort string) error {
-	c, err := commentChildrenTerm(tblComment.Get(key), limit, 0, sort).Run(session)
+	c, err := run("CommentGetTree", commentChildrenTerm(tblComment.Get(key), limit, 0, sort))
 	if err == rt.ErrEmptyResult {
 		return ErrNotFound
 	}
@@ -87,7 +88,7 @@ func CommentsGet(result interface{}, postKey, parent UUID, from, limit int, sort
 		Skip(from).Limit(limit).OrderBy(commentOrderByTerm(sort))
 
 	trm = commentChildrenTerm(trm, limit, 0, sort)
-	c, err := trm.Run(session)
+	c, err := run("CommentsGet", trm)
 
 	if err != nil {
 		return err
@@ -150,7 +151,7 @@ func commentOrderByTerm(sortType string) rt.Term {
 
 // CommentInsert inserts a new user notification into the database
 func CommentInsert(comment interface{}) (UUID, error) {
-	w, err := tblComment.Insert(comment).RunWrite(session)
+	w, err := runWrite("CommentInsert", tblComment.Insert(comment))
 	err = wErr(w, err)
 	if err != nil {
 		return EmptyUUID, err
@@ -165,7 +166,7 @@ func CommentInsert(comment interface{}) (UUID, error) {
 
 // CommentUpdate updates an existing comment
 func CommentUpdate(comment interface{}, key UUID) error {
-	return tryUpdateVersion(tblComment.Get(key), comment)
+	return tryUpdateVersion("CommentUpdate", tblComment.Get(key), comment)
 }
 
 // CommentsGetByUser retrieves a set of comments posted by a given user
@@ -184,6 +185,8 @@ func CommentsGetByUser(result interface{}, username Key, public bool, since time
 		Index: rt.Desc("Username"),
 	})
 
+	trm = trm.Filter(rt.Row.Field("Deleted").Default(false).Not())
+
 	if public {
 		trm = trm.Filter(func(comment rt.Term) rt.Term {
 			var post = tblPost.Get(comment.Field("PostKey"))
@@ -191,7 +194,7 @@ func CommentsGetByUser(result interface{}, username Key, public bool, since time
 		})
 	}
 
-	c, err := trm.Limit(limit).Run(session)
+	c, err := run("CommentsGetByUser", trm.Limit(limit))
 
 	if err != nil {
 		return err
@@ -209,3 +212,91 @@ func CommentsGetByUser(result interface{}, username Key, public bool, since time
 
 	return c.All(result)
 }
+
+// CommentCountByUserOnPost counts the comments a user has posted on a post
+func CommentCountByUserOnPost(username Key, post UUID) (count int, err error) {
+	c, err := run("CommentCountByUserOnPost", tblComment.Between([]interface{}{username, rt.MinVal},
+		[]interface{}{username, rt.MaxVal}, rt.BetweenOpts{
+			Index: "Username",
+		}).Filter(rt.Row.Field("PostKey").Eq(post)).Count())
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	err = c.One(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// CommentCountRepliesOnPost counts a user's replies on a post to the comments of another user
+func CommentCountRepliesOnPost(username, to Key, post UUID) (count int, err error) {
+	c, err := run("CommentCountRepliesOnPost", tblComment.Between([]interface{}{username, rt.MinVal},
+		[]interface{}{username, rt.MaxVal}, rt.BetweenOpts{
+			Index: "Username",
+		}).Filter(func(row rt.Term) rt.Term {
+		return row.Field("PostKey").Eq(post).And(row.HasFields("Parent")).
+			And(tblComment.Get(row.Field("Parent")).Field("Username").Default("").Eq(to))
+	}).Count())
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	err = c.One(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// CommentsSetHidden hides or shows every comment posted by a given user
+func CommentsSetHidden(username Key, hidden bool) error {
+	return wErr(runWrite("CommentsSetHidden", tblComment.Between([]interface{}{username, rt.MinVal},
+		[]interface{}{username, rt.MaxVal}, rt.BetweenOpts{
+			Index: "Username",
+		}).Update(map[string]interface{}{"Hidden": hidden})))
+}
+
+// CommentDelete removes a comment's text and marks it as deleted, the comment itself is kept so its replies stay
+// in place
+func CommentDelete(key UUID) error {
+	return wErr(runWrite("CommentDelete", tblComment.Get(key).Update(map[string]interface{}{
+		"Deleted": true,
+		"Comment": "",
+		"Queued":  false,
+	})))
+}
+
+// CommentGetModQueue retrieves the comments waiting for review on posts in any of the passed in towns, newest first
+func CommentGetModQueue(result interface{}, towns []Key, from, limit int) (err error) {
+	trm := tblComment.GetAllByIndex("Queued", true).Filter(func(comment rt.Term) rt.Term {
+		return tblPost.Get(comment.Field("PostKey")).Field("TownKeys").Contains(func(townKey rt.Term) rt.Term {
+			return rt.Expr(towns).Contains(townKey)
+		})
+	}).OrderBy(rt.Desc("Updated"))
+
+	c, err := run("CommentGetModQueue", trm.Skip(from).Limit(limit))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+	if c.IsNil() {
+		return ErrNotFound
+	}
+	return c.All(result)
+}
@@ -16,6 +16,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"reflect"
 	"strings"
 	"time"
 
@@ -239,11 +240,17 @@ func DefaultConfig() *Config {
 	}
 }
 
+// running is the config the data layer was initialized with, updated with any reloaded values
+var running *Config
+
 // Init initialized the data layer based on the passed in
 // configuration
 func Init(cfg *Config) error {
 	var err error
 
+	current := *cfg
+	running = &current
+
 	rt.SetVerbose(cfg.DevMode)
 	cfg.DB.timeout, err = time.ParseDuration(cfg.DB.Timeout)
 	if err != nil {
@@ -275,20 +282,51 @@ func Init(cfg *Config) error {
 	return err
 }
 
+// Reload applies the settings that can be safely changed while running, which is currently only the list of cache
+// servers.  The names of any other changed settings are returned, and are left as they are until restart
+func Reload(cfg *Config) ([]string, error) {
+	if running == nil {
+		return nil, errors.New("The data layer has not been initialized")
+	}
+
+	var restart []string
+
+	if !reflect.DeepEqual(cfg.Cache.Addresses, running.Cache.Addresses) {
+		err := cacheSelector.setServers(cfg.Cache.Addresses...)
+		if err != nil {
+			return nil, fmt.Errorf("Error updating cache servers: %s", err)
+		}
+		log.WithField("addresses", cfg.Cache.Addresses).Infof("Cache servers updated")
+		running.Cache = cfg.Cache
+	}
+
+	db := cfg.DB
+	db.timeout = running.DB.timeout
+	if !reflect.DeepEqual(db, running.DB) {
+		restart = append(restart, "db")
+	}
+
+	if !reflect.DeepEqual(cfg.Search, running.Search) {
+		restart = append(restart, "search")
+	}
+
+	return restart, nil
+}
+
+// Close closes the connections to the database and search servers
+func Close() error {
+	if searchClient != nil {
+		searchClient.Stop()
+	}
+	if session != nil {
+		return session.Close()
+	}
+	return nil
+}
+
 func rtConnect(cfg *Config) {
 	var err error
-	session, err = rt.Connect(rt.ConnectOpts{
-		Address:             cfg.DB.Address,
-		Addresses:           cfg.DB.Addresses,
-		Database:            cfg.DB.Database,
-		AuthKey:             cfg.DB.AuthKey,
-		Timeout:             cfg.DB.timeout,
-		TLSConfig:           cfg.DB.TLSConfig,
-		MaxIdle:             cfg.DB.MaxIdle,
-		MaxOpen:             cfg.DB.MaxOpen,
-		DiscoverHosts:       cfg.DB.DiscoverHosts,
-		NodeRefreshInterval: cfg.DB.NodeRefreshInterval,
-	})
+	session, err = rt.Connect(cfg.DB.connectOpts())
 
 	if err != nil {
 		log.Warnf("Error connecting to database: %s  RETRYING...", err)
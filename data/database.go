@@ -10,6 +10,7 @@ import (
 
 	rt "git.townsourced.com/townsourced/gorethink"
 	log "git.townsourced.com/townsourced/logrus"
+	"github.com/timshannon/townsourced/metrics"
 )
 
 var tables []*table
@@ -39,6 +40,21 @@ type DBConfig struct {
 	NodeRefreshInterval time.Duration `json:"nodeRefreshInterval,omitempty"`
 }
 
+func (c *DBConfig) connectOpts() rt.ConnectOpts {
+	return rt.ConnectOpts{
+		Address:             c.Address,
+		Addresses:           c.Addresses,
+		Database:            c.Database,
+		AuthKey:             c.AuthKey,
+		Timeout:             c.timeout,
+		TLSConfig:           c.TLSConfig,
+		MaxIdle:             c.MaxIdle,
+		MaxOpen:             c.MaxOpen,
+		DiscoverHosts:       c.DiscoverHosts,
+		NodeRefreshInterval: c.NodeRefreshInterval,
+	}
+}
+
 // TODO: Handle shard and replication at this level?
 var session *rt.Session
 
@@ -55,7 +71,7 @@ func prepDB() error {
 
 func ensureDB(dbname string) error {
 	var dbNames []string
-	c, err := rt.DBList().Run(session)
+	c, err := run("EnsureDB", rt.DBList())
 	if err != nil {
 		return err
 	}
@@ -67,7 +83,7 @@ func ensureDB(dbname string) error {
 	if !inStr(dbNames, dbname) {
 
 		log.Debugf("Creating Database %s", dbname)
-		_, err := rt.DBCreate(dbname).RunWrite(session)
+		_, err := runWrite("EnsureDB", rt.DBCreate(dbname))
 		if err != nil {
 			return err
 		}
@@ -109,7 +125,7 @@ func (t *table) ensure() error {
 	}
 	var tables []string
 	db := rt.DB(t.database)
-	c, err := db.TableList().Run(session)
+	c, err := run("EnsureTable", db.TableList())
 	if err != nil {
 		return err
 	}
@@ -124,7 +140,7 @@ func (t *table) ensure() error {
 			t.TableCreateOpts.PrimaryKey = "Key"
 		}
 		log.Debugf("Creating Table %s", t.name)
-		_, err = db.TableCreate(t.name, t.TableCreateOpts).RunWrite(session)
+		_, err = runWrite("EnsureTable", db.TableCreate(t.name, t.TableCreateOpts))
 		if err != nil {
 			return err
 		}
@@ -141,7 +157,7 @@ func (t *table) ensure() error {
 	}
 
 	// wait for all indexes to finish building
-	_, err = t.IndexWait().Run(session)
+	_, err = run("EnsureTable", t.IndexWait())
 	if err != nil {
 		return err
 	}
@@ -153,7 +169,7 @@ func (i *index) ensure() error {
 
 	t := rt.DB(i.table.database).Table(i.table.name)
 
-	c, err := t.IndexList().Run(session)
+	c, err := run("EnsureIndex", t.IndexList())
 	if err != nil {
 		return err
 	}
@@ -165,9 +181,9 @@ func (i *index) ensure() error {
 	if !inStr(indexes, i.name) {
 		log.Debugf("Creating Index %s on Table %s", i.name, i.table.name)
 		if i.indexFunc != nil {
-			_, err = t.IndexCreateFunc(i.name, i.indexFunc, i.IndexCreateOpts).RunWrite(session)
+			_, err = runWrite("EnsureIndex", t.IndexCreateFunc(i.name, i.indexFunc, i.IndexCreateOpts))
 		} else {
-			_, err = t.IndexCreate(i.name, i.IndexCreateOpts).RunWrite(session)
+			_, err = runWrite("EnsureIndex", t.IndexCreate(i.name, i.IndexCreateOpts))
 		}
 		if err != nil {
 			return err
@@ -190,6 +206,37 @@ func wErr(res rt.WriteResponse, err error) error {
 	return nil
 }
 
+var (
+	dbQueryDuration = metrics.NewHistogram("townsourced_db_query_duration_seconds",
+		"Latency of RethinkDB queries in seconds, by query.", nil, "query")
+	dbQueryErrors = metrics.NewCounter("townsourced_db_query_errors_total",
+		"Number of RethinkDB queries that failed, by query.", "query")
+)
+
+// run runs the query against the current session, and records its latency and any errors under the passed in name
+func run(name string, t rt.Term) (*rt.Cursor, error) {
+	start := time.Now()
+	c, err := t.Run(session)
+	observeQuery(name, start, err)
+	return c, err
+}
+
+// runWrite runs the write query against the current session, and records its latency and any errors under the
+// passed in name
+func runWrite(name string, t rt.Term) (rt.WriteResponse, error) {
+	start := time.Now()
+	w, err := t.RunWrite(session)
+	observeQuery(name, start, err)
+	return w, err
+}
+
+func observeQuery(name string, start time.Time, err error) {
+	dbQueryDuration.Since(start, name)
+	if err != nil && err != rt.ErrEmptyResult {
+		dbQueryErrors.Inc(name)
+	}
+}
+
 // DatabaseSession returns the underlying rethinkdb database session
 // should usually only be used in tools and tests
 func DatabaseSession() *rt.Session {
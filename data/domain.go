@@ -0,0 +1,66 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package data
+
+import rt "git.townsourced.com/townsourced/gorethink"
+
+func init() {
+	tables = append(tables, tblBlockedDomain)
+}
+
+var tblBlockedDomain = &table{
+	name: "blockeddomain",
+	TableCreateOpts: rt.TableCreateOpts{
+		PrimaryKey: "Domain",
+	},
+}
+
+// BlockedDomainInsert adds a domain to the site wide block list, replacing it if it's already blocked
+func BlockedDomainInsert(blocked interface{}) error {
+	return wErr(runWrite("BlockedDomainInsert", tblBlockedDomain.Insert(blocked, rt.InsertOpts{Conflict: "replace"})))
+}
+
+// BlockedDomainDelete removes a domain from the site wide block list
+func BlockedDomainDelete(domain string) error {
+	return wErr(runWrite("BlockedDomainDelete", tblBlockedDomain.Get(domain).Delete()))
+}
+
+// BlockedDomainGetAll retrieves the site wide block list
+func BlockedDomainGetAll(result interface{}) (err error) {
+	c, err := run("BlockedDomainGetAll", tblBlockedDomain.OrderBy("Domain"))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+	if c.IsNil() {
+		return ErrNotFound
+	}
+	return c.All(result)
+}
+
+// BlockedDomainGet retrieves which of the passed in domains are on the site wide block list
+func BlockedDomainGet(result interface{}, domains []string) (err error) {
+	keys := make([]interface{}, len(domains))
+	for i := range domains {
+		keys[i] = domains[i]
+	}
+
+	c, err := run("BlockedDomainGet", tblBlockedDomain.GetAll(keys...))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+	if c.IsNil() {
+		return ErrNotFound
+	}
+	return c.All(result)
+}
@@ -0,0 +1,169 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package data
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	rt "git.townsourced.com/townsourced/gorethink"
+)
+
+// ErrHealthTimeout is returned when a server doesn't respond to a health check in time
+var ErrHealthTimeout = errors.New("Timed out waiting for a response")
+
+// cacheHealthKeyTries is how many keys are hashed looking for one that lands on each cache server
+const cacheHealthKeyTries = 1000
+
+var dbProbing int32     // set while a database health check query is running
+var searchProbing int32 // set while a search health check request is running
+
+// HealthCheck is the result of checking a single component, a nil Err means the component is healthy
+type HealthCheck struct {
+	Err     error
+	Latency time.Duration
+}
+
+// Health checks that the database, cache and search servers are reachable, and returns the result of each check
+// by component name.  All components are checked at the same time, and any check that takes longer than the
+// timeout fails
+func Health(timeout time.Duration) map[string]HealthCheck {
+	checks := map[string]func(time.Duration) error{
+		"rethinkdb":     dbHealth,
+		"memcached":     cacheHealth,
+		"elasticsearch": searchHealth,
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	result := make(map[string]HealthCheck, len(checks))
+
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(time.Duration) error) {
+			defer wg.Done()
+
+			start := time.Now()
+			errc := make(chan error, 1)
+			go func() {
+				errc <- check(timeout)
+			}()
+
+			var err error
+			select {
+			case err = <-errc:
+			case <-time.After(timeout):
+				err = ErrHealthTimeout
+			}
+
+			mu.Lock()
+			result[name] = HealthCheck{Err: err, Latency: time.Since(start)}
+			mu.Unlock()
+		}(name, check)
+	}
+
+	wg.Wait()
+	return result
+}
+
+func dbHealth(timeout time.Duration) error {
+	if session == nil || !session.IsConnected() {
+		return errors.New("Not connected to the database")
+	}
+
+	// only one query is ever left waiting on a hung database, instead of one more for every check
+	if !atomic.CompareAndSwapInt32(&dbProbing, 0, 1) {
+		return errors.New("The last database health check is still waiting for a response")
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		defer atomic.StoreInt32(&dbProbing, 0)
+		c, err := run("Health", rt.Expr(true))
+		if err != nil {
+			errc <- err
+			return
+		}
+		errc <- c.Close()
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-time.After(timeout):
+		return ErrHealthTimeout
+	}
+}
+
+// cacheHealth checks every cache server with the shared cache client, since each one holds a different portion of
+// the keys.  The client's own socket timeout keeps a hung server from holding up the check
+func cacheHealth(timeout time.Duration) error {
+	if cacheSelector == nil || cacheClient == nil {
+		return errors.New("The cache has not been initialized")
+	}
+
+	keys, err := cacheHealthKeys()
+	if err != nil {
+		return err
+	}
+
+	_, err = cacheClient.GetMulti(keys)
+	if err != nil {
+		return fmt.Errorf("Cache: %s", err)
+	}
+	return nil
+}
+
+// cacheHealthKeys returns a key that lands on each of the cache servers
+func cacheHealthKeys() ([]string, error) {
+	servers := make(map[string]bool)
+	err := cacheSelector.Each(func(addr net.Addr) error {
+		servers[addr.String()] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for i := 0; len(servers) > 0 && i < cacheHealthKeyTries; i++ {
+		key := fmt.Sprintf("townsourced-health-check-%d", i)
+		addr, err := cacheSelector.PickServer(key)
+		if err != nil {
+			return nil, err
+		}
+		if servers[addr.String()] {
+			delete(servers, addr.String())
+			keys = append(keys, key)
+		}
+	}
+
+	return keys, nil
+}
+
+func searchHealth(timeout time.Duration) error {
+	if searchClient == nil || searchHealthClient == nil || !searchClient.IsRunning() {
+		return errors.New("Not connected to the search cluster")
+	}
+
+	if !atomic.CompareAndSwapInt32(&searchProbing, 0, 1) {
+		return errors.New("The last search health check is still waiting for a response")
+	}
+	defer atomic.StoreInt32(&searchProbing, 0)
+
+	// only one check is ever running, so its deadline can be set on the shared health check http client
+	searchHealthHTTP.Timeout = timeout
+	health, err := searchHealthClient.ClusterHealth().Timeout(fmt.Sprintf("%dms", timeout/time.Millisecond)).Do()
+	if err != nil {
+		return err
+	}
+	if health.Status == "red" {
+		return fmt.Errorf("Search cluster %s status is red", health.ClusterName)
+	}
+	return nil
+}
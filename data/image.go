@@ -36,7 +36,7 @@ func ImageGet(result interface{}, key UUID, thumb, placeholder bool) error {
 		trm = trm.Without("ThumbData", "PlaceholderData")
 	}
 
-	c, err := trm.Default(nil).Run(session)
+	c, err := run("ImageGet", trm.Default(nil))
 	if err != nil {
 		return err
 	}
@@ -48,9 +48,31 @@ func ImageGet(result interface{}, key UUID, thumb, placeholder bool) error {
 	return c.One(result)
 }
 
+// ImageGetHashes retrieves the hashes of the passed in images, images without a hash are skipped
+func ImageGetHashes(result interface{}, keys []UUID) (err error) {
+	ids := make([]interface{}, len(keys))
+	for i := range keys {
+		ids[i] = keys[i]
+	}
+
+	c, err := run("ImageGetHashes", tblImage.GetAll(ids...).HasFields("Hash").Field("Hash"))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+	if c.IsNil() {
+		return ErrNotFound
+	}
+	return c.All(result)
+}
+
 // ImageInsert inserts a new image into the database
 func ImageInsert(image interface{}) (UUID, error) {
-	w, err := tblImage.Insert(image).RunWrite(session)
+	w, err := runWrite("ImageInsert", tblImage.Insert(image))
 	err = wErr(w, err)
 	if err != nil {
 		return EmptyUUID, err
@@ -65,17 +87,17 @@ func ImageInsert(image interface{}) (UUID, error) {
 
 // ImageUpdate updates an existing image
 func ImageUpdate(image interface{}, key UUID) error {
-	return tryUpdateVersion(tblImage.Get(key), image)
+	return tryUpdateVersion("ImageUpdate", tblImage.Get(key), image)
 }
 
 // ImageDelete deletes an image
 func ImageDelete(key UUID) error {
-	return wErr(tblImage.Get(key).Delete().RunWrite(session))
+	return wErr(runWrite("ImageDelete", tblImage.Get(key).Delete()))
 }
 
 // ImageDeleteOrphans deletes all images that aren't currently in use
 // and haven't been updated since the passed in time
 func ImageDeleteOrphans(updatedSince time.Time) error {
-	return wErr(tblImage.GetAllByIndex("InUse", false).Filter(rt.Row.Field("Updated").Le(updatedSince)).
-		Delete(rt.DeleteOpts{Durability: "soft"}).RunWrite(session))
+	return wErr(runWrite("ImageDeleteOrphans", tblImage.GetAllByIndex("InUse", false).
+		Filter(rt.Row.Field("Updated").Le(updatedSince)).Delete(rt.DeleteOpts{Durability: "soft"})))
 }
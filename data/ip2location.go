@@ -28,12 +28,12 @@ var tblIP2Location = &table{
 func IP2LocationGet(result interface{}, ipAddress string) error {
 	iNum := IPNumber(ipAddress)
 
-	c, err := tblIP2Location.Between(rt.MinVal, iNum,
+	c, err := run("IP2LocationGet", tblIP2Location.Between(rt.MinVal, iNum,
 		rt.BetweenOpts{
 			RightBound: "closed",
 		}).OrderBy("IPFrom", rt.OrderByOpts{
 		Index: rt.Desc("IPFrom"),
-	}).Limit(1).Filter(rt.Row.Field("IPTo").Ge(iNum)).Run(session)
+	}).Limit(1).Filter(rt.Row.Field("IPTo").Ge(iNum)))
 
 	if err != nil {
 		return err
@@ -51,7 +51,7 @@ func IP2LocationGet(result interface{}, ipAddress string) error {
 // IP2LocationTruncate truncates the IP2Location table by dropping it and recreating it.  Much faster than
 // deleting all the records individually
 func IP2LocationTruncate() error {
-	err := wErr(rt.DB(tblIP2Location.database).TableDrop(tblIP2Location.name).RunWrite(session))
+	err := wErr(runWrite("IP2LocationTruncate", rt.DB(tblIP2Location.database).TableDrop(tblIP2Location.name)))
 	if err != nil {
 		return err
 	}
@@ -61,7 +61,7 @@ func IP2LocationTruncate() error {
 
 // IP2LocationImport imports an array for IP2Location entries
 func IP2LocationImport(entries interface{}) error {
-	return wErr(tblIP2Location.Insert(entries).RunWrite(session))
+	return wErr(runWrite("IP2LocationImport", tblIP2Location.Insert(entries)))
 }
 
 // IPNumber returns a sortable int version of a string IP Address
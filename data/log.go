@@ -4,6 +4,9 @@
 package data
 
 import (
+	"regexp"
+	"time"
+
 	rt "git.townsourced.com/townsourced/gorethink"
 )
 
@@ -18,13 +21,165 @@ var tblLog = &table{
 	database: logDatabase,
 	indexes: []index{
 		index{name: "Time"},
+		index{
+			name: "Fingerprint_Time",
+			indexFunc: func(row rt.Term) interface{} {
+				return []interface{}{logFingerprint(row), row.Field("Time")}
+			},
+		},
 	},
 }
 
-// Log writes a new log entry
-func Log(entry interface{}) error {
-	return wErr(tblLog.Insert(entry, rt.InsertOpts{
+// Log writes a new log entry, or a slice of entries in one batch
+func Log(entries interface{}) error {
+	return wErr(runWrite("Log", tblLog.Insert(entries, rt.InsertOpts{
 		Durability:    "soft",
 		ReturnChanges: false,
-	}).RunWrite(session))
+	})))
+}
+
+// LogFilter filters which log entries are returned, empty values aren't filtered on
+type LogFilter struct {
+	Levels      []string
+	Since       time.Time
+	Until       time.Time
+	Message     string            // case insensitive text the message must contain
+	Fields      map[string]string // case insensitive text each field's value must contain
+	Fingerprint string
+}
+
+// logFingerprint is the fingerprint of a log entry, entries logged before fingerprints were added are grouped by
+// their message
+func logFingerprint(row rt.Term) rt.Term {
+	return row.Field("Fingerprint").Default(row.Field("Message"))
+}
+
+func (f *LogFilter) timeRange() (since, until interface{}) {
+	since = rt.MinVal
+	until = rt.MaxVal
+	if !f.Since.IsZero() {
+		since = f.Since
+	}
+	if !f.Until.IsZero() {
+		until = f.Until
+	}
+	return since, until
+}
+
+func (f *LogFilter) term() rt.Term {
+	since, until := f.timeRange()
+
+	return f.filter(tblLog.Between(since, until, rt.BetweenOpts{
+		Index:      "Time",
+		RightBound: "closed",
+	}).OrderBy(rt.OrderByOpts{
+		Index: rt.Desc("Time"),
+	}))
+}
+
+// filter applies everything but the time range of the filter
+func (f *LogFilter) filter(trm rt.Term) rt.Term {
+	if len(f.Levels) != 0 {
+		trm = trm.Filter(func(row rt.Term) interface{} {
+			return rt.Expr(f.Levels).Contains(row.Field("Level"))
+		})
+	}
+
+	if f.Message != "" {
+		trm = trm.Filter(rt.Row.Field("Message").Match("(?i)" + regexp.QuoteMeta(f.Message)))
+	}
+
+	for name, value := range f.Fields {
+		// field values can be any type, so they are compared as their json string values
+		// entries without the field are filtered out
+		trm = trm.Filter(rt.Row.Field("Fields").Field(name).CoerceTo("string").
+			Match("(?i)" + regexp.QuoteMeta(value)))
+	}
+
+	if f.Fingerprint != "" {
+		trm = trm.Filter(func(row rt.Term) interface{} {
+			return logFingerprint(row).Eq(f.Fingerprint)
+		})
+	}
+
+	return trm
+}
+
+// fingerprintTerm returns the entries with the given fingerprint that match the filter, in the passed in order
+func (f *LogFilter) fingerprintTerm(fingerprint rt.Term, order func(...interface{}) rt.Term) rt.Term {
+	since, until := f.timeRange()
+
+	return f.filter(tblLog.Between([]interface{}{fingerprint, since}, []interface{}{fingerprint, until},
+		rt.BetweenOpts{
+			Index:      "Fingerprint_Time",
+			RightBound: "closed",
+		}).OrderBy(rt.OrderByOpts{
+		Index: order("Fingerprint_Time"),
+	}))
+}
+
+// LogGet retrieves the log entries that match the filter, newest first
+func LogGet(result interface{}, filter *LogFilter, from, limit int) (err error) {
+	c, err := run("LogGet", filter.term().Skip(from).Limit(limit))
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	if c.IsNil() {
+		return ErrNotFound
+	}
+
+	return c.All(result)
+}
+
+// LogGroups groups the log entries that match the filter by their fingerprint, with the most frequent groups first.
+// Groups are only counted, and the first and latest entries of the top groups are looked up on the fingerprint index,
+// so the matching entries are never all loaded at once
+func LogGroups(result interface{}, filter *LogFilter, limit int) (err error) {
+	since, until := filter.timeRange()
+
+	c, err := run("LogGroups", filter.filter(tblLog.Between(since, until, rt.BetweenOpts{
+		Index:      "Time",
+		RightBound: "closed",
+	})).Group(logFingerprint).Count().Ungroup().OrderBy(rt.Desc("reduction")).Limit(limit).
+		Map(func(group rt.Term) interface{} {
+			fingerprint := group.Field("group")
+			latest := filter.fingerprintTerm(fingerprint, rt.Desc).Nth(0)
+			return map[string]interface{}{
+				"Fingerprint": fingerprint,
+				"Level":       latest.Field("Level"),
+				"Message":     latest.Field("Message"),
+				"Count":       group.Field("reduction"),
+				"First":       filter.fingerprintTerm(fingerprint, rt.Asc).Nth(0).Field("Time"),
+				"Last":        latest.Field("Time"),
+			}
+		}))
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	if c.IsNil() {
+		return ErrNotFound
+	}
+
+	return c.All(result)
+}
+
+// LogDeleteBefore deletes all log entries older than the passed in time
+func LogDeleteBefore(before time.Time) error {
+	return wErr(runWrite("LogDeleteBefore", tblLog.Between(rt.MinVal, before, rt.BetweenOpts{
+		Index: "Time",
+	}).Delete(rt.DeleteOpts{Durability: "soft"})))
 }
@@ -33,7 +33,7 @@ var tblNotification = &table{
 
 // NotificationInsert inserts a new user notification into the database
 func NotificationInsert(notification interface{}) error {
-	return wErr(tblNotification.Insert(notification).RunWrite(session))
+	return wErr(runWrite("NotificationInsert", tblNotification.Insert(notification)))
 }
 
 // NotificationGetUnread retrieves all unread notifications for a user
@@ -61,7 +61,7 @@ func notificationsGet(result interface{}, username Key, since time.Time, limit i
 		})
 
 	}
-	c, err := trm.Limit(limit).Run(session)
+	c, err := run("NotificationsGet", trm.Limit(limit))
 
 	if err != nil {
 		return err
@@ -88,13 +88,13 @@ func NotificationGetAll(result interface{}, username Key, since time.Time, limit
 func NotificationUnreadCount(username Key) (int, error) {
 	var count int
 
-	c, err := tblNotification.Between([]interface{}{username, rt.MinVal},
+	c, err := run("NotificationUnreadCount", tblNotification.Between([]interface{}{username, rt.MinVal},
 		[]interface{}{username, rt.MaxVal},
 		rt.BetweenOpts{
 			Index: "Username_When",
 		}).Filter(map[string]interface{}{
 		"Read": false,
-	}).Count().Run(session)
+	}).Count())
 
 	if err != nil {
 		return -1, err
@@ -110,7 +110,7 @@ func NotificationUnreadCount(username Key) (int, error) {
 
 // NotificationGet retrieves a specific notification for a user
 func NotificationGet(result interface{}, notificationKey UUID) error {
-	c, err := tblNotification.Get(notificationKey).Run(session)
+	c, err := run("NotificationGet", tblNotification.Get(notificationKey))
 
 	if err != nil {
 		return err
@@ -125,18 +125,18 @@ func NotificationGet(result interface{}, notificationKey UUID) error {
 
 // NotificationUpdate updates a single notification
 func NotificationUpdate(notification interface{}, key UUID) error {
-	return wErr(tblNotification.Get(key).Update(notification).RunWrite(session))
+	return wErr(runWrite("NotificationUpdate", tblNotification.Get(key).Update(notification)))
 }
 
 // NotificationUpdateUnread updates all unread notifications
 func NotificationUpdateUnread(notification interface{}, username Key) error {
-	return wErr(tblNotification.Between([]interface{}{username, rt.MinVal},
+	return wErr(runWrite("NotificationUpdateUnread", tblNotification.Between([]interface{}{username, rt.MinVal},
 		[]interface{}{username, rt.MaxVal},
 		rt.BetweenOpts{
 			Index: "Username_When",
 		}).Filter(map[string]interface{}{
 		"Read": false,
-	}).Update(notification).RunWrite(session))
+	}).Update(notification)))
 }
 
 // NotificationsGetSent gets all sent notifications for a user
@@ -155,7 +155,7 @@ func NotificationsGetSent(result interface{}, username Key, since time.Time, lim
 		Index: rt.Desc("From_When"),
 	})
 
-	c, err := trm.Limit(limit).Run(session)
+	c, err := run("NotificationsGetSent", trm.Limit(limit))
 
 	if err != nil {
 		return err
@@ -172,3 +172,25 @@ func NotificationsGetSent(result interface{}, username Key, since time.Time, lim
 	}
 	return c.All(result)
 }
+
+// NotificationCountSent counts the notifications one user has sent to another since the passed in time
+func NotificationCountSent(from, to Key, since time.Time) (count int, err error) {
+	c, err := run("NotificationCountSent", tblNotification.Between([]interface{}{from, since},
+		[]interface{}{from, rt.MaxVal}, rt.BetweenOpts{
+			Index: "From_When",
+		}).Filter(rt.Row.Field("Username").Eq(to)).Count())
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	err = c.One(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
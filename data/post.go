@@ -19,6 +19,8 @@ const (
 	PostStatusPublished = "published"
 	//PostStatusClosed not searchable but link is still valid
 	PostStatusClosed = "closed"
+	// PostStatusPending waiting for a town's moderators to approve it before it's published
+	PostStatusPending = "pending"
 )
 
 /* PostSort sets the sort order on post search results */
@@ -45,6 +47,23 @@ var tblPost = &table{
 			},
 		},
 		index{name: "Published"},
+		index{
+			name: "ModQueue",
+			IndexCreateOpts: rt.IndexCreateOpts{
+				Multi: true,
+			},
+			indexFunc: func(post rt.Term) interface{} {
+				// one entry for each town the post is waiting for review in
+				return rt.Branch(post.Field("Status").Eq(PostStatusPublished).
+					Or(post.Field("Status").Eq(PostStatusPending)),
+					post.Field("TownKeys").Filter(func(townKey rt.Term) rt.Term {
+						return postQueued(post, townKey)
+					}).Map(func(townKey rt.Term) interface{} {
+						return []interface{}{townKey, post.Field("Published")}
+					}),
+					[]interface{}{})
+			},
+		},
 	},
 }
 
@@ -101,7 +120,7 @@ var postListFields = []string{"key", "title", "category", "townKeys", "status",
 
 // PostInsert inserts a new post into the database
 func PostInsert(post interface{}) (UUID, error) {
-	w, err := tblPost.Insert(post).RunWrite(session)
+	w, err := runWrite("PostInsert", tblPost.Insert(post))
 	err = wErr(w, err)
 	if err != nil {
 		return EmptyUUID, err
@@ -126,12 +145,12 @@ func PostRemoveIndex(key UUID) error {
 
 // PostUpdate updates an existing post
 func PostUpdate(post interface{}, key UUID) error {
-	return tryUpdateVersion(tblPost.Get(key), post)
+	return tryUpdateVersion("PostUpdate", tblPost.Get(key), post)
 }
 
 // PostGet retrieves a post by a specific post key
 func PostGet(result interface{}, key UUID) error {
-	c, err := tblPost.Get(key).Run(session)
+	c, err := run("PostGet", tblPost.Get(key))
 	if err != nil {
 		return err
 	}
@@ -173,8 +192,150 @@ func PostGetByUser(result interface{}, username Key, status string, public bool,
 		trm = trm.Filter(rt.Row.Field("Status").Eq(status))
 	}
 
-	c, err := trm.Limit(limit).Run(session)
+	c, err := run("PostGetByUser", trm.Limit(limit))
+
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+	if c.IsNil() {
+		return ErrNotFound
+	}
+	return c.All(result)
+}
+
+// PostKeysByUser retrieves the keys of every post created by a specific user with the given status
+func PostKeysByUser(result interface{}, username Key, status string) (err error) {
+	c, err := run("PostKeysByUser", tblPost.Between([]interface{}{username, rt.MinVal},
+		[]interface{}{username, rt.MaxVal}, rt.BetweenOpts{
+			Index: "Creator",
+		}).Filter(rt.Row.Field("Status").Eq(status)).Field("Key"))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+	if c.IsNil() {
+		return ErrNotFound
+	}
+	return c.All(result)
+}
+
+// PostCountByUserInTown counts the posts a user published to a town between since and until, optionally only in a
+// single category, excluding the passed in post
+func PostCountByUserInTown(username, town Key, category string, since, until time.Time, exclude UUID) (count int,
+	err error) {
+	trm := tblPost.Between([]interface{}{username, rt.MinVal}, []interface{}{username, rt.MaxVal},
+		rt.BetweenOpts{
+			Index: "Creator",
+		}).Filter(rt.Row.Field("Status").Ne(PostStatusDraft).
+		And(rt.Row.Field("Key").Ne(exclude)).
+		And(rt.Row.Field("TownKeys").Contains(town)).
+		And(rt.Row.Field("Published").During(since, until)))
+
+	if category != "" {
+		trm = trm.Filter(rt.Row.Field("Category").Eq(category))
+	}
+
+	c, err := run("PostCountByUserInTown", trm.Count())
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	err = c.One(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// PostGetByCreators retrieves the posts any of the users published between since and until, with only the fields
+// needed to count them against auto moderator quotas and duplicate rules
+func PostGetByCreators(result interface{}, usernames []Key, since, until time.Time) (err error) {
+	c, err := run("PostGetByCreators", rt.Expr(usernames).ConcatMap(func(username rt.Term) interface{} {
+		return tblPost.Between([]interface{}{username, rt.MinVal}, []interface{}{username, rt.MaxVal},
+			rt.BetweenOpts{
+				Index: "Creator",
+			})
+	}).Filter(rt.Row.Field("Status").Ne(PostStatusDraft).
+		And(rt.Row.Field("Published").During(since, until))).
+		Pluck("Key", "Creator", "Category", "TownKeys", "Status", "Published", "Fingerprint", "ImageHashes"))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+	if c.IsNil() {
+		return ErrNotFound
+	}
+	return c.All(result)
+}
+
+// PostCountDuplicates counts the posts a user published between since and until which have the same fingerprint or
+// share any of the image hashes, excluding the passed in post
+func PostCountDuplicates(username Key, fingerprint string, imageHashes []string, since, until time.Time,
+	exclude UUID) (count int, err error) {
+	hashes := make([]interface{}, len(imageHashes))
+	for i := range imageHashes {
+		hashes[i] = imageHashes[i]
+	}
+
+	trm := tblPost.Between([]interface{}{username, rt.MinVal}, []interface{}{username, rt.MaxVal},
+		rt.BetweenOpts{
+			Index: "Creator",
+		}).Filter(rt.Row.Field("Status").Ne(PostStatusDraft).
+		And(rt.Row.Field("Key").Ne(exclude)).
+		And(rt.Row.Field("Published").During(since, until))).
+		Filter(func(post rt.Term) rt.Term {
+			duplicate := post.Field("Fingerprint").Default("").Eq(fingerprint)
+			if len(hashes) != 0 {
+				duplicate = duplicate.Or(post.Field("ImageHashes").Default([]interface{}{}).
+					Contains(func(hash rt.Term) rt.Term {
+						return rt.Expr(hashes).Contains(hash)
+					}))
+			}
+			return duplicate
+		})
+
+	c, err := run("PostCountDuplicates", trm.Count())
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	err = c.One(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// PostGetRecentByTown retrieves the full posts most recently published to a town
+func PostGetRecentByTown(result interface{}, town Key, limit int) (err error) {
+	trm := tblPost.OrderBy(rt.OrderByOpts{
+		Index: rt.Desc("Published"),
+	}).Filter(rt.Row.Field("Status").Ne(PostStatusDraft).And(rt.Row.Field("TownKeys").Contains(town)))
 
+	c, err := run("PostGetRecentByTown", trm.Limit(limit))
 	if err != nil {
 		return err
 	}
@@ -199,7 +360,7 @@ func PostGetUserSaved(result interface{}, username Key, status string, from, lim
 		trm = trm.Filter(rt.Row.Field("Status").Eq(status))
 	}
 
-	c, err := trm.Skip(from).Limit(limit).OrderBy(rt.Desc("When")).Pluck(postListPluck...).Run(session)
+	c, err := run("PostGetUserSaved", trm.Skip(from).Limit(limit).OrderBy(rt.Desc("When")).Pluck(postListPluck...))
 
 	if err != nil {
 		return err
@@ -237,8 +398,8 @@ func PostGetByTowns(result interface{}, towns []Key, category string, since time
 		trm = trm.Filter(rt.Row.Field("Category").Eq(category))
 	}
 
-	c, err := trm.Filter(rt.Row.Field("Status").Eq(PostStatusPublished)).Pluck(postListPluck...).
-		Limit(limit).Run(session)
+	c, err := run("PostGetByTowns", trm.Filter(rt.Row.Field("Status").Eq(PostStatusPublished)).
+		Pluck(postListPluck...).Limit(limit))
 
 	if err != nil {
 		return err
@@ -352,7 +513,7 @@ func postFilterByTowns(trm rt.Term, towns []Key, showModerated bool) rt.Term {
 
 // PostAllCount returns the count of the total number of posts, usually used by maintenance and not the frontend
 func PostAllCount() (int, error) {
-	c, err := tblPost.Count().Run(session)
+	c, err := run("PostAllCount", tblPost.Count())
 	if err != nil {
 		return -1, err
 	}
@@ -373,7 +534,7 @@ func PostAllCount() (int, error) {
 // PostGetAll retrieves all posts
 // This likely shouldn't be used for the actual website, and should only be used for maintenance / tasks
 func PostGetAll(result interface{}, from, limit int) error {
-	c, err := tblPost.Skip(from).Limit(limit).Run(session)
+	c, err := run("PostGetAll", tblPost.Skip(from).Limit(limit))
 	if err != nil {
 		return err
 	}
@@ -411,3 +572,61 @@ func postIsPublic(post rt.Term) rt.Term {
 		return tblTown.Get(key).Field("Private").Eq(false)
 	})
 }
+
+// PostGetModQueue retrieves the published and pending posts waiting for review in any of the passed in towns, newest
+// first.  A post is waiting for review in a town if it was held or auto moderated there, or if it has been reported
+// and hasn't been moderated or approved there yet
+func PostGetModQueue(result interface{}, towns []Key, from, limit int) (err error) {
+	townQueue := func(town interface{}) rt.Term {
+		return tblPost.Between([]interface{}{town, rt.MinVal}, []interface{}{town, rt.MaxVal}, rt.BetweenOpts{
+			Index: "ModQueue",
+		})
+	}
+
+	var trm rt.Term
+	if len(towns) == 1 {
+		trm = townQueue(towns[0]).OrderBy(rt.OrderByOpts{
+			Index: rt.Desc("ModQueue"),
+		})
+	} else {
+		// a post can be waiting in more than one of the towns
+		trm = rt.Expr(towns).ConcatMap(func(town rt.Term) interface{} {
+			return townQueue(town)
+		}).Distinct().OrderBy(rt.Desc("Published"))
+	}
+
+	c, err := run("PostGetModQueue", trm.Skip(from).Limit(limit))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+	if c.IsNil() {
+		return ErrNotFound
+	}
+	return c.All(result)
+}
+
+func postQueued(post, townKey rt.Term) rt.Term {
+	moderation := post.Field("Moderation").Default([]interface{}{})
+
+	autoModerated := moderation.Contains(func(mod rt.Term) rt.Term {
+		return mod.Field("Town").Eq(townKey).And(mod.Field("Who").Default("").Eq(""))
+	})
+	moderated := moderation.Contains(func(mod rt.Term) rt.Term {
+		return mod.Field("Town").Eq(townKey)
+	})
+	reported := post.Field("Reported").Default(map[string]interface{}{}).Keys().IsEmpty().Not()
+	flagged := post.Field("Flagged").Default([]interface{}{}).Contains(func(flag rt.Term) rt.Term {
+		return flag.Field("Town").Eq(townKey)
+	})
+	approved := post.Field("Approved").Default([]interface{}{}).Contains(townKey)
+	held := post.Field("Held").Default([]interface{}{}).Contains(func(hold rt.Term) rt.Term {
+		return hold.Field("Town").Eq(townKey)
+	})
+
+	return held.Or(autoModerated).Or(reported.Or(flagged).And(moderated.Not()).And(approved.Not()))
+}
@@ -0,0 +1,144 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package data
+
+import (
+	"time"
+
+	rt "git.townsourced.com/townsourced/gorethink"
+)
+
+func init() {
+	tables = append(tables, tblRating)
+}
+
+var tblRating = &table{
+	name: "rating",
+	indexes: []index{
+		index{
+			name: "Username_When",
+			indexFunc: func(row rt.Term) interface{} {
+				return []interface{}{row.Field("Username"), row.Field("When")}
+			},
+		},
+		index{
+			name: "From_When",
+			indexFunc: func(row rt.Term) interface{} {
+				return []interface{}{row.Field("From"), row.Field("When")}
+			},
+		},
+	},
+}
+
+// RatingGet retrieves a single rating
+func RatingGet(result interface{}, key string) (err error) {
+	c, err := run("RatingGet", tblRating.Get(key))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+	if c.IsNil() {
+		return ErrNotFound
+	}
+	return c.One(result)
+}
+
+// RatingUpsert inserts a rating, replacing any previous rating with the same key
+func RatingUpsert(rating interface{}) error {
+	return wErr(runWrite("RatingUpsert", tblRating.Insert(rating, rt.InsertOpts{Conflict: "replace"})))
+}
+
+// RatingDelete removes a rating
+func RatingDelete(key string) error {
+	return wErr(runWrite("RatingDelete", tblRating.Get(key).Delete()))
+}
+
+// RatingsGetByUser retrieves the ratings a user has received before the since time, newest first
+func RatingsGetByUser(result interface{}, username Key, since time.Time, limit int) (err error) {
+	var sinceOp interface{} = rt.MaxVal
+
+	if !since.IsZero() {
+		sinceOp = since
+	}
+
+	trm := tblRating.Between([]interface{}{username, rt.MinVal}, []interface{}{username, sinceOp},
+		rt.BetweenOpts{
+			Index:     "Username_When",
+			LeftBound: "open",
+		}).OrderBy(rt.OrderByOpts{
+		Index: rt.Desc("Username_When"),
+	})
+
+	c, err := run("RatingsGetByUser", trm.Limit(limit))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+	if c.IsNil() {
+		return ErrNotFound
+	}
+	return c.All(result)
+}
+
+// RatingSummary retrieves the number of ratings a user has received, and the total of their scores
+func RatingSummary(username Key) (count, total int, err error) {
+	ratings := tblRating.Between([]interface{}{username, rt.MinVal}, []interface{}{username, rt.MaxVal},
+		rt.BetweenOpts{
+			Index: "Username_When",
+		})
+
+	c, err := run("RatingSummary", rt.Expr(map[string]interface{}{
+		"Count": ratings.Count(),
+		"Total": ratings.Sum("Score"),
+	}))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	result := struct {
+		Count int
+		Total int
+	}{}
+
+	err = c.One(&result)
+	if err != nil {
+		return 0, 0, err
+	}
+	return result.Count, result.Total, nil
+}
+
+// RatingCountFrom counts the ratings a user has given since the passed in time
+func RatingCountFrom(from Key, since time.Time) (count int, err error) {
+	c, err := run("RatingCountFrom", tblRating.Between([]interface{}{from, since},
+		[]interface{}{from, rt.MaxVal}, rt.BetweenOpts{
+			Index: "From_When",
+		}).Count())
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	err = c.One(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
@@ -12,11 +12,38 @@ import (
 
 	"git.townsourced.com/townsourced/elastic"
 	log "git.townsourced.com/townsourced/logrus"
+	"github.com/timshannon/townsourced/metrics"
 )
 
 var searchClient *elastic.Client
 var searchTypes []*searchType
 
+// health checks use their own client, so their requests can have a deadline without limiting every other request
+var searchHealthClient *elastic.Client
+var searchHealthHTTP = &http.Client{Transport: searchTransport{http.DefaultTransport}}
+
+var (
+	searchDuration = metrics.NewHistogram("townsourced_search_request_duration_seconds",
+		"Latency of Elasticsearch requests in seconds, by HTTP method.", nil, "method")
+	searchErrors = metrics.NewCounter("townsourced_search_errors_total",
+		"Number of Elasticsearch requests that failed, or returned a server error, by HTTP method.", "method")
+)
+
+// searchTransport records the latency and errors of every request the elasticsearch client makes
+type searchTransport struct {
+	http.RoundTripper
+}
+
+func (s searchTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	start := time.Now()
+	res, err := s.RoundTripper.RoundTrip(r)
+	searchDuration.Since(start, r.Method)
+	if err != nil || res.StatusCode >= http.StatusInternalServerError {
+		searchErrors.Inc(r.Method)
+	}
+	return res, err
+}
+
 // SearchConfig is search server connection configuration
 type SearchConfig struct {
 	Addresses  []string          `json:"addresses,omitempty"`
@@ -35,6 +62,7 @@ func initSearch(cfg *SearchConfig) error {
 	var err error
 	searchClient, err = elastic.NewClient(
 		elastic.SetURL(cfg.Addresses...),
+		elastic.SetHttpClient(&http.Client{Transport: searchTransport{http.DefaultTransport}}),
 		elastic.SetMaxRetries(cfg.MaxRetries),
 		elastic.SetHealthcheckTimeoutStartup(30*time.Second),
 	)
@@ -42,6 +70,17 @@ func initSearch(cfg *SearchConfig) error {
 		return err
 	}
 
+	searchHealthClient, err = elastic.NewClient(
+		elastic.SetURL(cfg.Addresses...),
+		elastic.SetHttpClient(searchHealthHTTP),
+		elastic.SetMaxRetries(0),
+		elastic.SetSniff(false),
+		elastic.SetHealthcheck(false),
+	)
+	if err != nil {
+		return err
+	}
+
 	log.Debugf("Connected to Search Instance")
 
 	//prep indexes
@@ -6,6 +6,8 @@ package data
 import (
 	"time"
 
+	"git.townsourced.com/townsourced/gomemcache/memcache"
+	rt "git.townsourced.com/townsourced/gorethink"
 	log "git.townsourced.com/townsourced/logrus"
 )
 
@@ -15,6 +17,9 @@ func init() {
 
 var tblSession = &table{
 	name: "session",
+	indexes: []index{
+		index{name: "UserKey"},
+	},
 }
 
 type cacheSession struct {
@@ -27,7 +32,7 @@ func (s *cacheSession) key() string {
 }
 
 func (s *cacheSession) source(result interface{}) error {
-	c, err := tblSession.Get(s.key()).Run(session)
+	c, err := run("SessionGet", tblSession.Get(s.key()))
 	if err != nil {
 		return err
 	}
@@ -65,7 +70,7 @@ func SessionGet(result interface{}, sessionKey string) error {
 
 // SessionInsert inserts a session
 func SessionInsert(s interface{}, sessionKey string, expires time.Time) error {
-	r, err := tblSession.Insert(s).RunWrite(session)
+	r, err := runWrite("SessionInsert", tblSession.Insert(s))
 
 	err = wErr(r, err)
 	if err != nil {
@@ -86,7 +91,7 @@ func SessionInsert(s interface{}, sessionKey string, expires time.Time) error {
 
 // SessionUpdate updates a session
 func SessionUpdate(s interface{}, sessionKey string, expires time.Time) error {
-	r, err := tblSession.Get(sessionKey).Update(s).RunWrite(session)
+	r, err := runWrite("SessionUpdate", tblSession.Get(sessionKey).Update(s))
 
 	err = wErr(r, err)
 	if err != nil {
@@ -104,3 +109,43 @@ func SessionUpdate(s interface{}, sessionKey string, expires time.Time) error {
 	}(s, sessionKey, expires)
 	return nil
 }
+
+// SessionInvalidateUser invalidates every valid session for the given user, so they have to log in again
+func SessionInvalidateUser(userKey Key) (err error) {
+	trm := tblSession.GetAllByIndex("UserKey", userKey).Filter(rt.Row.Field("Valid").Eq(true))
+
+	c, err := run("SessionInvalidateUser", trm.Field("Key"))
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	var keys []string
+	err = c.All(&keys)
+	if err != nil {
+		return err
+	}
+
+	if len(keys) == 0 {
+		return nil
+	}
+
+	err = wErr(runWrite("SessionInvalidateUser", trm.Update(map[string]interface{}{"Valid": false})))
+	if err != nil {
+		return err
+	}
+
+	for i := range keys {
+		cerr := cacheSet(&cacheSession{sessionKey: keys[i]}, nil)
+		if cerr != nil && cerr != memcache.ErrCacheMiss {
+			return cerr
+		}
+	}
+
+	return nil
+}
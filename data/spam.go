@@ -0,0 +1,74 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package data
+
+import (
+	"time"
+
+	rt "git.townsourced.com/townsourced/gorethink"
+)
+
+func init() {
+	tables = append(tables, tblSpamModel)
+}
+
+var tblSpamModel = &table{
+	name: "spammodel",
+	TableCreateOpts: rt.TableCreateOpts{
+		PrimaryKey: "Town",
+	},
+}
+
+// SpamModelUpsert inserts a trained spam model, replacing the town's previous model
+func SpamModelUpsert(model interface{}) error {
+	return wErr(runWrite("SpamModelUpsert", tblSpamModel.Insert(model, rt.InsertOpts{Conflict: "replace"})))
+}
+
+// SpamModelGet retrieves a town's trained spam model
+func SpamModelGet(result interface{}, town Key) (err error) {
+	c, err := run("SpamModelGet", tblSpamModel.Get(town))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+	if c.IsNil() {
+		return ErrNotFound
+	}
+	return c.One(result)
+}
+
+// SpamModelDeleteTrainedBefore removes the spam models which weren't retrained since the passed in time
+func SpamModelDeleteTrainedBefore(before time.Time) error {
+	return wErr(runWrite("SpamModelDeleteTrainedBefore",
+		tblSpamModel.Filter(rt.Row.Field("Trained").Lt(before)).Delete()))
+}
+
+// PostGetTraining retrieves the text and moderation history of posts published since the given time, newest first,
+// for training the spam models
+func PostGetTraining(result interface{}, since time.Time, from, limit int) (err error) {
+	trm := tblPost.Between(since, rt.MaxVal, rt.BetweenOpts{
+		Index: "Published",
+	}).OrderBy(rt.OrderByOpts{
+		Index: rt.Desc("Published"),
+	}).Filter(rt.Row.Field("Status").Ne(PostStatusDraft).And(rt.Row.Field("Status").Ne(PostStatusPending))).
+		Pluck("Key", "Title", "Content", "TownKeys", "Moderation", "Reported", "Approved")
+
+	c, err := run("PostGetTraining", trm.Skip(from).Limit(limit))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+	if c.IsNil() {
+		return ErrNotFound
+	}
+	return c.All(result)
+}
@@ -31,29 +31,90 @@ var tblTask = &table{
 
 // TaskInsert inserts a new task to be run
 func TaskInsert(task interface{}) error {
-	return wErr(tblTask.Insert(task).RunWrite(session))
+	return wErr(runWrite("TaskInsert", tblTask.Insert(task)))
 }
 
 // TaskUpdate updates a single task
 func TaskUpdate(task interface{}, key UUID) error {
-	return wErr(tblTask.Get(key).Update(task).RunWrite(session))
+	return wErr(runWrite("TaskUpdate", tblTask.Get(key).Update(task)))
 }
 
-// TaskClaim marks the next set of unclaimed, non-closed tasks as owned by the given user
+// TaskClaim marks up to limit of the next unclaimed, non-closed tasks as owned by the given user
 // this is to immediately prevent any other task runners from sharing these tasks
-// A task should only belong to one runner at a time
-func TaskClaim(owner Key, limit uint) error {
-	return wErr(tblTask.GetAllByIndex("Owner", []interface{}{EmptyKey, false}).
-		Filter(rt.Row.Field("NextRun").Le(time.Now())).OrderBy("Priority", "Created").
-		Limit(limit).Update(map[string]interface{}{
-		"Owner": owner,
-	}).RunWrite(session))
+// A task should only belong to one runner at a time.  No more than typeLimits[type] tasks are claimed of any type in
+// typeLimits, the rest are left for other runners.
+func TaskClaim(owner Key, limit uint, typeLimits map[string]int) (err error) {
+	full := []string{}
+	for tType, typeLimit := range typeLimits {
+		if typeLimit <= 0 {
+			full = append(full, tType)
+		}
+	}
+
+	c, err := run("TaskClaim", tblTask.GetAllByIndex("Owner", []interface{}{EmptyKey, false}).
+		Filter(rt.Row.Field("NextRun").Le(time.Now()).
+			And(rt.Expr(full).Contains(rt.Row.Field("Type")).Not())).
+		OrderBy("Priority", "Created").Pluck("Key", "Type"))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	var keys []interface{}
+	claimed := make(map[string]int)
+	task := struct {
+		Key  UUID
+		Type string
+	}{}
+
+	for uint(len(keys)) < limit && c.Next(&task) {
+		if typeLimit, ok := typeLimits[task.Type]; ok && claimed[task.Type] >= typeLimit {
+			continue
+		}
+		claimed[task.Type]++
+		keys = append(keys, task.Key)
+	}
+	if c.Err() != nil {
+		return c.Err()
+	}
+
+	if len(keys) == 0 {
+		return nil
+	}
+
+	// skip any tasks another runner claimed in the meantime
+	return wErr(runWrite("TaskClaim", tblTask.GetAll(keys...).Update(func(row rt.Term) interface{} {
+		return rt.Branch(row.Field("Owner").Eq(EmptyKey), map[string]interface{}{"Owner": owner},
+			map[string]interface{}{})
+	})))
+}
+
+// TaskCountReady returns the number of unclaimed, non-closed tasks who's nextRun time has passed
+func TaskCountReady() (count int, err error) {
+	c, err := run("TaskCountReady", tblTask.GetAllByIndex("Owner", []interface{}{EmptyKey, false}).
+		Filter(rt.Row.Field("NextRun").Le(time.Now())).Count())
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	err = c.One(&count)
+	return count, err
 }
 
 // TaskGetMine retrieves all unprocessed tasks that have been marked as the owenres, who's nextRun time has passed
 // in order by priority, then oldest tasks
 func TaskGetMine(result interface{}, owner Key) (err error) {
-	c, err := tblTask.GetAllByIndex("Owner", []interface{}{owner, false}).OrderBy("Priority", "Created").Run(session)
+	c, err := run("TaskGetMine", tblTask.GetAllByIndex("Owner", []interface{}{owner, false}).
+		OrderBy("Priority", "Created"))
 	if err != nil {
 		return err
 	}
@@ -68,9 +129,55 @@ func TaskGetMine(result interface{}, owner Key) (err error) {
 	return c.All(result)
 }
 
+// TaskRelease throws all of the owner's open tasks back into the queue for other task runners to process
+// except for the passed in task keys
+func TaskRelease(owner Key, except []UUID) error {
+	if except == nil {
+		except = []UUID{}
+	}
+	return wErr(runWrite("TaskRelease", tblTask.GetAllByIndex("Owner", []interface{}{owner, false}).
+		Filter(rt.Expr(except).Contains(rt.Row.Field("Key")).Not()).
+		Update(map[string]interface{}{
+			"Owner": EmptyKey,
+		})))
+}
+
+// TaskWatchNew calls the passed in func every time a new task is inserted by any server, until the stop channel
+// is closed
+func TaskWatchNew(stop <-chan struct{}, newTask func()) error {
+	c, err := run("TaskWatchNew", tblTask.Changes().Filter(rt.Row.Field("old_val").Eq(nil)))
+	if err != nil {
+		return err
+	}
+
+	// closing the cursor on stop ends the watch, the goroutine exits along with the watch either way
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-stop:
+		case <-done:
+		}
+		_ = c.Close()
+	}()
+
+	var change interface{}
+	for c.Next(&change) {
+		newTask()
+	}
+
+	select {
+	case <-stop:
+		return nil
+	default:
+		return c.Err()
+	}
+}
+
 // TaskGetOpenType gets all open tasks of a given type
 func TaskGetOpenType(result interface{}, taskType string, limit uint) (err error) {
-	c, err := tblTask.GetAllByIndex("Type", taskType).Limit(limit).Run(session)
+	c, err := run("TaskGetOpenType", tblTask.GetAllByIndex("Type", taskType).Filter(rt.Row.Field("Closed").Eq(false)).
+		Limit(limit))
 	if err != nil {
 		return err
 	}
@@ -87,6 +194,6 @@ func TaskGetOpenType(result interface{}, taskType string, limit uint) (err error
 
 // TaskDeleteClosed deletes all closed tasks
 func TaskDeleteClosed() error {
-	return wErr(tblTask.GetAllByIndex("Owner", []interface{}{EmptyKey, true}).
-		Delete(rt.DeleteOpts{Durability: "soft"}).RunWrite(session))
+	return wErr(runWrite("TaskDeleteClosed", tblTask.GetAllByIndex("Owner", []interface{}{EmptyKey, true}).
+		Delete(rt.DeleteOpts{Durability: "soft"})))
 }
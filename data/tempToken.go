@@ -6,6 +6,7 @@ package data
 import (
 	"time"
 
+	"git.townsourced.com/townsourced/gomemcache/memcache"
 	rt "git.townsourced.com/townsourced/gorethink"
 )
 
@@ -31,7 +32,7 @@ func (t *cacheTempToken) key() string {
 }
 
 func (t *cacheTempToken) source(result interface{}) error {
-	c, err := tblTempToken.Get(t.token).Default([]interface{}{}).Field("Data").Run(session)
+	c, err := run("TempTokenGet", tblTempToken.Get(t.token).Default([]interface{}{}).Field("Data"))
 	if err != nil {
 		return err
 	}
@@ -72,7 +73,7 @@ func TempTokenGet(tokenData interface{}, token string) error {
 // TempTokenSet sets a session in the memcache if expires is less than 30 minutes, otherwise it goes in rethink
 func TempTokenSet(tokenData interface{}, token string, expires time.Duration) error {
 	if expires > 30*time.Minute {
-		return wErr(tblTempToken.Insert(struct {
+		return wErr(runWrite("TempTokenSet", tblTempToken.Insert(struct {
 			Key     string
 			Expires time.Duration
 			Data    interface{}
@@ -80,7 +81,7 @@ func TempTokenSet(tokenData interface{}, token string, expires time.Duration) er
 			Key:     token,
 			Expires: expires,
 			Data:    tokenData,
-		}).RunWrite(session))
+		})))
 	}
 	return cacheSet(&cacheTempToken{
 		token:   token,
@@ -95,9 +96,35 @@ func TempTokenExpire(token string) error {
 		return err
 	}
 
-	err = wErr(tblTempToken.Get(token).Delete(rt.DeleteOpts{Durability: "soft"}).RunWrite(session))
+	err = wErr(runWrite("TempTokenExpire", tblTempToken.Get(token).Delete(rt.DeleteOpts{Durability: "soft"})))
 	if err != nil && err != ErrNotFound {
 		return err
 	}
 	return nil
 }
+
+// TempTokenAttempt atomically counts an attempt at using a temp token, and returns the number of attempts so far,
+// including this one.  The count expires with the token, so concurrent attempts can't be missed
+func TempTokenAttempt(token string, expires time.Duration) (uint64, error) {
+	key := "attempts:" + token
+	for {
+		start := time.Now()
+		attempts, err := cacheClient.Increment(key, 1)
+		observeCache("increment", start, err)
+		if err != memcache.ErrCacheMiss {
+			return attempts, err
+		}
+
+		start = time.Now()
+		err = cacheClient.Add(&memcache.Item{
+			Key:        key,
+			Value:      []byte("1"),
+			Expiration: int32(expires.Seconds()),
+		})
+		observeCache("add", start, err)
+		if err != memcache.ErrNotStored {
+			return 1, err
+		}
+		// another attempt added the count first
+	}
+}
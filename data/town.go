@@ -56,7 +56,7 @@ func Towns(result interface{}, keys ...Key) (err error) {
 	for i := range keys {
 		ikeys[i] = keys[i]
 	}
-	c, err := tblTown.GetAll(ikeys...).Run(session)
+	c, err := run("Towns", tblTown.GetAll(ikeys...))
 
 	if err != nil {
 		return err
@@ -81,9 +81,9 @@ func TownGet(result interface{}, key Key) error {
 
 // TownGetByLocation retrieves all towns within the distance passed from the location passed in
 func TownGetByLocation(result interface{}, locationQry LocationSearcher, from, limit int) error {
-	c, err := locationQry.query(tblTown, "Location", limit).Filter(map[string]interface{}{
+	c, err := run("TownGetByLocation", locationQry.query(tblTown, "Location", limit).Filter(map[string]interface{}{
 		"Private": false,
-	}).Filter(rt.Row.Field("Key").Eq(AnnouncementTown).Not()).Skip(from).Run(session)
+	}).Filter(rt.Row.Field("Key").Eq(AnnouncementTown).Not()).Skip(from))
 
 	if err != nil {
 		return err
@@ -129,7 +129,7 @@ func TownGetBySearch(search string, from, limit int) (*SearchResult, error) {
 
 // TownInsert inserts a new town
 func TownInsert(data interface{}, key Key) error {
-	err := wErr(tblTown.Insert(data).RunWrite(session))
+	err := wErr(runWrite("TownInsert", tblTown.Insert(data)))
 	if err != nil {
 		return err
 	}
@@ -142,7 +142,7 @@ func TownInsert(data interface{}, key Key) error {
 
 // TownUpdate updates a town
 func TownUpdate(data interface{}, key Key) error {
-	err := tryUpdateVersion(tblTown.Get(key), data)
+	err := tryUpdateVersion("TownUpdate", tblTown.Get(key), data)
 	if err != nil {
 		return err
 	}
@@ -163,7 +163,7 @@ func (t *cacheTown) key() string {
 }
 
 func (t *cacheTown) source(result interface{}) (err error) {
-	c, err := tblTown.Get(t.townKey).Run(session)
+	c, err := run("TownGet", tblTown.Get(t.townKey))
 	if err != nil {
 		return err
 	}
@@ -216,7 +216,7 @@ func TownRemoveIndex(key Key) error {
 
 // TownAllCount returns the count of the total number of towns, usually used by maintenance and not the frontend
 func TownAllCount() (int, error) {
-	c, err := tblTown.Count().Run(session)
+	c, err := run("TownAllCount", tblTown.Count())
 	if err != nil {
 		return -1, err
 	}
@@ -237,7 +237,32 @@ func TownAllCount() (int, error) {
 // TownGetAll retrieves all towns
 // This likely shouldn't be used for the actual website, and should only be used for maintenance / tasks
 func TownGetAll(result interface{}, from, limit int) error {
-	c, err := tblTown.Skip(from).Limit(limit).Run(session)
+	c, err := run("TownGetAll", tblTown.Skip(from).Limit(limit))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+	if c.IsNil() {
+		return ErrNotFound
+	}
+	return c.All(result)
+}
+
+// TownGetUnmoderated retrieves the keys of the towns which have no active moderators
+func TownGetUnmoderated(result interface{}) (err error) {
+	c, err := run("TownGetUnmoderated", tblTown.Filter(func(town rt.Term) rt.Term {
+		return town.Field("Moderators").Default([]interface{}{}).Contains(func(mod rt.Term) rt.Term {
+			// unset times are stored as the zero time, which is before the epoch
+			start := mod.Field("Start").Default(rt.EpochTime(0))
+			end := mod.Field("End").Default(rt.EpochTime(0))
+			return start.Gt(rt.EpochTime(0)).And(start.Lt(rt.Now())).
+				And(end.Le(rt.EpochTime(0)).Or(end.Gt(rt.Now())))
+		}).Not()
+	}).Pluck("Key"))
 	if err != nil {
 		return err
 	}
@@ -262,11 +287,11 @@ func (t *cacheTownPopulation) key() string {
 }
 
 func (t *cacheTownPopulation) source(result interface{}) error {
-	c, err := tblUser.Filter(func(user rt.Term) rt.Term {
+	c, err := run("TownPopulation", tblUser.Filter(func(user rt.Term) rt.Term {
 		return user.Field("TownKeys").Contains(func(tk rt.Term) rt.Term {
 			return tk.Field("Key").Eq(t.townKey)
 		})
-	}).Count().Run(session)
+	}).Count())
 
 	if err != nil {
 		return err
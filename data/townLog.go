@@ -0,0 +1,58 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package data
+
+import rt "git.townsourced.com/townsourced/gorethink"
+
+func init() {
+	tables = append(tables, tblTownLog)
+}
+
+var tblTownLog = &table{
+	name: "townlog",
+	indexes: []index{
+		index{
+			name: "Town_When",
+			indexFunc: func(row rt.Term) interface{} {
+				return []interface{}{row.Field("Town"), row.Field("When")}
+			},
+		},
+	},
+}
+
+// TownLogInsert appends an entry, or a slice of entries, to the towns' moderation logs
+func TownLogInsert(entries interface{}) error {
+	return wErr(runWrite("TownLogInsert", tblTownLog.Insert(entries)))
+}
+
+// TownLogGet retrieves a town's moderation log newest first, optionally only entries for a specific action
+func TownLogGet(result interface{}, town Key, action string, from, limit int) (err error) {
+	trm := tblTownLog.Between([]interface{}{town, rt.MinVal}, []interface{}{town, rt.MaxVal},
+		rt.BetweenOpts{
+			Index: "Town_When",
+		}).OrderBy(rt.OrderByOpts{
+		Index: rt.Desc("Town_When"),
+	})
+
+	if action != "" {
+		trm = trm.Filter(rt.Row.Field("Action").Eq(action))
+	}
+
+	c, err := run("TownLogGet", trm.Skip(from).Limit(limit))
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	if c.IsNil() {
+		return ErrNotFound
+	}
+
+	return c.All(result)
+}
@@ -35,7 +35,7 @@ var tblUser = &table{
 
 // UserGet gets a user
 func UserGet(result interface{}, username Key) error {
-	c, err := tblUser.Get(username).Run(session)
+	c, err := run("UserGet", tblUser.Get(username))
 	if err != nil {
 		return err
 	}
@@ -47,13 +47,36 @@ func UserGet(result interface{}, username Key) error {
 	return c.One(result)
 }
 
+// Users returns a set of users in the database
+func Users(result interface{}, usernames ...Key) (err error) {
+	ikeys := make([]interface{}, len(usernames))
+	for i := range usernames {
+		ikeys[i] = usernames[i]
+	}
+	c, err := run("Users", tblUser.GetAll(ikeys...))
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	if c.IsNil() {
+		return ErrNotFound
+	}
+	return c.All(result)
+}
+
 // UserGetEmail gets a user with an email address
 func UserGetEmail(result interface{}, email string) error {
 	return userGetBy(result, "EmailSearch", strings.ToLower(email))
 }
 
 func userGetBy(result interface{}, index, key string) error {
-	c, err := tblUser.GetAllByIndex(index, key).Run(session)
+	c, err := run("UserGetBy", tblUser.GetAllByIndex(index, key))
 	if err != nil {
 		return err
 	}
@@ -66,14 +89,14 @@ func userGetBy(result interface{}, index, key string) error {
 // UserGetMatching retrieves all users who's username starts with the passed in string
 func UserGetMatching(result interface{}, match string, limit int) error {
 	match = strings.ToLower(match)
-	c, err := tblUser.Between(match, rt.MaxVal).
+	c, err := run("UserGetMatching", tblUser.Between(match, rt.MaxVal).
 		Filter(func(row rt.Term) rt.Term {
 			return row.Field("Username").Match("(?i)^" + match)
 		}).
 		Union(tblUser.Between(match, rt.MaxVal, rt.BetweenOpts{Index: "Name"}).
 			Filter(func(row rt.Term) rt.Term {
 				return row.Field("Name").Match("(?i)^" + match)
-			})).OrderBy("Username").Limit(limit).Pluck("Username", "Name", "ProfileIcon").Run(session)
+			})).OrderBy("Username").Limit(limit).Pluck("Username", "Name", "ProfileIcon"))
 
 	if err != nil {
 		return err
@@ -106,17 +129,17 @@ func UserGetFacebook(result interface{}, facebookID string) error {
 
 // UserInsert inserts a new user into the database
 func UserInsert(user interface{}) error {
-	return wErr(tblUser.Insert(user).RunWrite(session))
+	return wErr(runWrite("UserInsert", tblUser.Insert(user)))
 }
 
 // UserUpdate updates an existing user
 func UserUpdate(user interface{}, username Key) error {
-	return tryUpdateVersion(tblUser.Get(username), user)
+	return tryUpdateVersion("UserUpdate", tblUser.Get(username), user)
 }
 
 // UserAllCount returns the count of the total number of users
 func UserAllCount() (int, error) {
-	c, err := tblUser.Count().Run(session)
+	c, err := run("UserAllCount", tblUser.Count())
 	if err != nil {
 		return -1, err
 	}
@@ -133,3 +156,26 @@ func UserAllCount() (int, error) {
 	}
 	return result, nil
 }
+
+// UserGetStaff retrieves every user with a site role, or the admin flag set
+func UserGetStaff(result interface{}) (err error) {
+	c, err := run("UserGetStaff", tblUser.Filter(func(user rt.Term) rt.Term {
+		return user.Field("Roles").Default([]interface{}{}).IsEmpty().Not().
+			Or(user.Field("Admin").Default(false))
+	}).OrderBy("Username").Pluck("Username", "Name", "Admin", "Roles"))
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	if c.IsNil() {
+		return ErrNotFound
+	}
+
+	return c.All(result)
+}
@@ -77,12 +77,11 @@ func (v *Version) VerField() string {
 	return "VerTag"
 }
 
-func tryUpdateVersion(selection rt.Term, data interface{}) error {
+func tryUpdateVersion(name string, selection rt.Term, data interface{}) error {
 	if v, ok := data.(versioner); ok {
 		current := v.Ver()
 		v.Rev()
-		w, err := selection.Update(rt.Branch(rt.Row.Field(v.VerField()).Eq(current), data, nil)).
-			RunWrite(session)
+		w, err := runWrite(name, selection.Update(rt.Branch(rt.Row.Field(v.VerField()).Eq(current), data, nil)))
 		err = wErr(w, err)
 		if err != nil {
 			return err
@@ -93,7 +92,7 @@ func tryUpdateVersion(selection rt.Term, data interface{}) error {
 			// if selection matches records without version
 			// If nothing is found, then errnotfound
 			// if something is found, then version mismatch
-			c, err := selection.Run(session)
+			c, err := run(name, selection)
 			if err != nil {
 				return err
 			}
@@ -104,5 +103,5 @@ func tryUpdateVersion(selection rt.Term, data interface{}) error {
 		}
 		return nil
 	}
-	return wErr(selection.Update(data).RunWrite(session))
+	return wErr(runWrite(name, selection.Update(data)))
 }
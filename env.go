@@ -0,0 +1,160 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// envPrefix is the prefix for all townsourced environment variables
+// Every config value can be set with an environment variable named after its section and json field names
+// in upper snake case.  Environment variables take priority over values in the settings file
+//
+//	web.readTimeout 	-> TOWNSOURCED_WEB_READ_TIMEOUT
+//	data.db.address 	-> TOWNSOURCED_DATA_DB_ADDRESS
+//	data.cache.addresses	-> TOWNSOURCED_DATA_CACHE_ADDRESSES (comma separated)
+const envPrefix = "TOWNSOURCED_"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// applyEnv overrides the values in the passed in config struct pointer with any set environment variables
+func applyEnv(section string, cfg interface{}) error {
+	set := func(name string, value reflect.Value) error {
+		env, ok := os.LookupEnv(name)
+		if !ok {
+			return nil
+		}
+		err := setEnvValue(value, env)
+		if err != nil {
+			return fmt.Errorf("Invalid value for environment variable %s: %s", name, err)
+		}
+		return nil
+	}
+
+	return envStruct(reflect.ValueOf(cfg).Elem(), envPrefix+envName(section), set)
+}
+
+// envStruct calls fn for every field in the config struct that can be set from an environment variable
+func envStruct(v reflect.Value, prefix string, fn func(name string, value reflect.Value) error) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			// unexported
+			continue
+		}
+
+		jsonName := strings.Split(field.Tag.Get("json"), ",")[0]
+		if jsonName == "-" {
+			continue
+		}
+		if jsonName == "" {
+			jsonName = field.Name
+		}
+
+		name := prefix + "_" + envName(jsonName)
+
+		if field.Type.Kind() == reflect.Struct {
+			err := envStruct(v.Field(i), name, fn)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !envSupported(field.Type) {
+			// complex values such as the DB TLSConfig can only be set in the settings file
+			continue
+		}
+
+		err := fn(name, v.Field(i))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// envName converts a camel case json name to upper snake case
+//
+//	maxUploadMemoryMB -> MAX_UPLOAD_MEMORY_MB
+func envName(name string) string {
+	runes := []rune(name)
+	result := make([]rune, 0, len(runes)+5)
+
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) ||
+				(unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				result = append(result, '_')
+			}
+		}
+		result = append(result, unicode.ToUpper(r))
+	}
+
+	return string(result)
+}
+
+func envSupported(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.String
+	default:
+		return false
+	}
+}
+
+func setEnvValue(v reflect.Value, env string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(env)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(env)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(env)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(env, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(env, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Slice:
+		var values []string
+		for _, s := range strings.Split(env, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				values = append(values, s)
+			}
+		}
+		v.Set(reflect.ValueOf(values))
+	}
+	return nil
+}
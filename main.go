@@ -4,11 +4,17 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"git.townsourced.com/townsourced/config"
 	"git.townsourced.com/townsourced/logrus"
@@ -18,12 +24,19 @@ import (
 )
 
 var (
-	hostname      = ""
-	flagDevMode   = false
-	flagDemoMode  = false
-	flagDir       = "."
-	flagZopfli    = false
-	flagSubdomain = ""
+	hostname       = ""
+	flagDevMode    = false
+	flagDemoMode   = false
+	flagDir        = "."
+	flagZopfli     = false
+	flagSubdomain  = ""
+	flagCheck      = false
+	flagNoWrite    = false
+	flagGrantRole  = ""
+	flagRevokeRole = ""
+
+	shutdownTimeout = 30 * time.Second
+	settingsFile    = ""
 )
 
 func init() {
@@ -35,19 +48,57 @@ func init() {
 		"server startup slower, but creates smaller file sizes for static assets.")
 	flag.StringVar(&flagDir, "dir", ".", "Dir sets the directory where server files will be served from.")
 	flag.StringVar(&flagSubdomain, "subdomain", "", "Only works in dev mode, forces townsourced to a specific subdomain.")
+	flag.BoolVar(&flagCheck, "check-config", false, "Check config validates the settings and environment variables, "+
+		"tests the connection to each of the database, cache and search servers, then exits.  Exits with a non-zero "+
+		"status if any problems are found.")
+	flag.BoolVar(&flagNoWrite, "no-write-config", envBool("TOWNSOURCED_NO_WRITE_CONFIG"), "Never create or write to "+
+		"the settings file, for running on read-only file systems.  Can also be set with the "+
+		"TOWNSOURCED_NO_WRITE_CONFIG environment variable.")
+	flag.StringVar(&flagGrantRole, "grant-role", "", "Grant role grants a site role to a user, then exits.  "+
+		"Formatted as username:role, where role is one of admin, support or reviewer.  Use this to set up the first "+
+		"admin, after which roles can be managed from the admin page.")
+	flag.StringVar(&flagRevokeRole, "revoke-role", "", "Revoke role revokes a site role from a user, then exits.  "+
+		"Formatted as username:role.")
 
 	go func() {
 		//Capture program shutdown, to make sure everything shuts down nicely
+		// and reload the settings on SIGHUP
 		c := make(chan os.Signal, 1)
-		signal.Notify(c, os.Interrupt)
+		signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
 		for sig := range c {
-			if sig == os.Interrupt {
-				app.Halt("Townsourced Web Server %s shutting down", hostname)
+			if sig == syscall.SIGHUP {
+				reload()
+				continue
 			}
+			shutdown(sig)
 		}
 	}()
 }
 
+// shutdown stops accepting new requests, and waits for in-flight requests, tasks, emails and log entries to finish
+// before closing the data connections and exiting
+func shutdown(sig os.Signal) {
+	fmt.Printf("Townsourced Web Server %s shutting down on %s...\n", hostname, sig)
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	err := web.Shutdown(ctx)
+	if err != nil {
+		logrus.Errorf("Error shutting down townsourced web server: %s", err)
+	}
+
+	app.Shutdown(ctx)
+
+	err = data.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error closing townsourced data connections: %s\n", err)
+	}
+
+	fmt.Printf("Townsourced Web Server %s shut down\n", hostname)
+	os.Exit(0)
+}
+
 func main() {
 	flag.Parse()
 	var err error
@@ -62,37 +113,112 @@ func main() {
 	for i := range settingPaths {
 		fmt.Println("\t", settingPaths[i])
 	}
-	cfg, err := config.LoadOrCreate(settingPaths...)
+
+	cfg, err := loadConfig(settingPaths)
 	if err != nil {
 		app.Halt(err.Error())
 	}
 
-	fmt.Printf("This webserver is currently using the file %s for settings.\n", cfg.FileName())
+	settingsFile = cfg.FileName()
+	if settingsFile != "" {
+		// the settings file is re-read on SIGHUP, after the working directory has changed
+		settingsFile, err = filepath.Abs(settingsFile)
+		if err != nil {
+			app.Halt("Error getting the absolute path of the settings file %s: %s", cfg.FileName(), err)
+		}
+		fmt.Printf("This webserver is currently using the file %s for settings.\n", settingsFile)
+	} else {
+		fmt.Println("No settings file was found, using default settings and environment variables.")
+	}
 
 	err = os.Chdir(flagDir)
 	if err != nil {
 		log.Fatalf("Error changing dir to  %s: %s", flagDir, err)
 	}
 
-	webCfg := web.DefaultConfig()
+	webCfg, dataCfg, appCfg, err := readConfig(cfg)
+	if err != nil {
+		app.Halt(err.Error())
+	}
+
+	if flagCheck {
+		os.Exit(checkConfig(webCfg, dataCfg, appCfg))
+	}
 
-	err = cfg.ValueToType("web", webCfg)
+	shutdownTimeout, err = time.ParseDuration(webCfg.ShutdownTimeout)
 	if err != nil {
-		app.Halt("Error reading web config values: %s", err.Error())
+		app.Halt("Error parsing web shutdownTimeout: %s", err)
 	}
 
-	webCfg.DevMode = flagDevMode
-	webCfg.DemoMode = flagDemoMode
-	webCfg.Zopfli = flagZopfli
-	webCfg.SubDomain = flagSubdomain
+	if !flagNoWrite {
+		err = cfg.Write()
+		if err != nil {
+			app.Halt("Error writting config file to %s. Error: %s", cfg.FileName(), err)
+		}
+	}
+
+	fmt.Printf("Townsourced Web Server %s starting up...\n", hostname)
+
+	logrus.AddHook(&app.LogHook{})
+	lvl, err := appCfg.Level()
+	if err != nil {
+		app.Halt(err.Error())
+	}
+	logrus.SetLevel(lvl)
+
+	err = data.Init(dataCfg)
+	if err != nil {
+		log.Fatalf("Error initializing townsourced data layer: %s", err.Error())
+	}
+
+	if flagGrantRole != "" || flagRevokeRole != "" {
+		os.Exit(changeRole(flagGrantRole, flagRevokeRole))
+	}
+
+	err = app.Init(appCfg, hostname, webCfg.Address, ".")
+	if err != nil {
+		log.Fatalf("Error initializing townsourced application layer: %s", err.Error())
+	}
+
+	err = web.StartServer(webCfg)
+	if err != nil {
+		app.Halt("Error Starting townsourced web server: %s", err.Error())
+	}
+
+	// server has been shutdown, wait for the rest of the shutdown to finish and exit
+	select {}
+}
+
+// readConfig reads the config for each layer from the settings, overridden by any environment variables and flags
+func readConfig(cfg *config.Cfg) (*web.Config, *data.Config, *app.Config, error) {
+	webCfg := web.DefaultConfig()
+
+	err := cfg.ValueToType("web", webCfg)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Error reading web config values: %s", err)
+	}
 
 	dataCfg := data.DefaultConfig()
 	err = cfg.ValueToType("data", dataCfg)
 	if err != nil {
-		app.Halt("Error reading data config values: %s", err.Error())
+		return nil, nil, nil, fmt.Errorf("Error reading data config values: %s", err)
 	}
 
+	appCfg := app.DefaultConfig()
+
+	err = cfg.ValueToType("app", appCfg)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Error reading app config values: %s", err)
+	}
+
+	// the settings file may keep the structs it read into, to write them out as its defaults, so environment
+	// variables and flags are applied to copies and are never saved to the settings file
+	webEnv, dataEnv, appEnv := *webCfg, *dataCfg, *appCfg
+	webCfg, dataCfg, appCfg = &webEnv, &dataEnv, &appEnv
+
 	// override with any environment variables
+	// DB_ADDRESS, SEARCH_ADDRESS and CACHE_ADDRESS are kept for existing deployments, the prefixed variables
+	// take priority over them
 	if os.Getenv("DB_ADDRESS") != "" {
 		dataCfg.DB.Address = os.Getenv("DB_ADDRESS")
 	}
@@ -103,42 +229,153 @@ func main() {
 		dataCfg.Cache.Addresses = []string{os.Getenv("CACHE_ADDRESS")}
 	}
 
+	for section, sectionCfg := range map[string]interface{}{
+		"web":  webCfg,
+		"data": dataCfg,
+		"app":  appCfg,
+	} {
+		err = applyEnv(section, sectionCfg)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	webCfg.DevMode = flagDevMode
+	webCfg.DemoMode = flagDemoMode
+	webCfg.Zopfli = flagZopfli
+	webCfg.SubDomain = flagSubdomain
 	dataCfg.DevMode = flagDevMode
+	appCfg.DevMode = flagDevMode
 
-	err = cfg.Write()
-	if err != nil {
-		app.Halt("Error writting config file to %s. Error: %s", cfg.FileName(), err)
+	return webCfg, dataCfg, appCfg, nil
+}
+
+// reload re-reads the settings file and environment variables, and applies the settings that can be safely changed
+// while the server is running.  Any changed settings that require a restart are logged, and left as they are
+func reload() {
+	cfg := config.LoadEnv(envPrefix)
+	if settingsFile != "" {
+		var err error
+		cfg, err = config.Load(settingsFile)
+		if err != nil {
+			logrus.Errorf("Error reloading settings file %s: %s", settingsFile, err)
+			return
+		}
 	}
 
-	fmt.Printf("Townsourced Web Server %s starting up...\n", hostname)
+	webCfg, dataCfg, appCfg, err := readConfig(cfg)
+	if err != nil {
+		logrus.Errorf("Error reloading settings: %s", err)
+		return
+	}
 
-	logrus.AddHook(&app.LogHook{})
-	if flagDevMode {
-		logrus.SetLevel(logrus.DebugLevel)
+	timeout, err := time.ParseDuration(webCfg.ShutdownTimeout)
+	if err != nil {
+		logrus.Errorf("Error parsing web shutdownTimeout: %s", err)
 	} else {
-		logrus.SetLevel(logrus.InfoLevel)
+		shutdownTimeout = timeout
 	}
 
-	appCfg := app.DefaultConfig()
+	var restart []string
+	for _, section := range []struct {
+		name   string
+		reload func() ([]string, error)
+	}{
+		{"web", func() ([]string, error) { return web.Reload(webCfg) }},
+		{"data", func() ([]string, error) { return data.Reload(dataCfg) }},
+		{"app", func() ([]string, error) { return app.Reload(appCfg) }},
+	} {
+		changed, err := section.reload()
+		if err != nil {
+			logrus.Errorf("Error reloading %s settings: %s", section.name, err)
+			continue
+		}
+		for i := range changed {
+			restart = append(restart, section.name+"."+changed[i])
+		}
+	}
 
-	err = cfg.ValueToType("app", appCfg)
-	if err != nil {
-		app.Halt("Error reading app config values: %s", err.Error())
+	if len(restart) != 0 {
+		logrus.WithField("settings", restart).
+			Warnf("Some changed settings can't be applied while running and require a restart to take effect")
 	}
-	appCfg.DevMode = flagDevMode
 
-	err = data.Init(dataCfg)
-	if err != nil {
-		log.Fatalf("Error initializing townsourced data layer: %s", err.Error())
+	logrus.Infof("Townsourced Web Server %s settings reloaded", hostname)
+}
+
+// loadConfig loads the first settings file found, if writing is allowed and no settings file exists, then one is
+// created with the default settings.  Checking the config never creates a settings file
+func loadConfig(settingPaths []string) (*config.Cfg, error) {
+	if !flagNoWrite && !flagCheck {
+		return config.LoadOrCreate(settingPaths...)
 	}
 
-	err = app.Init(appCfg, hostname, webCfg.Address, ".")
-	if err != nil {
-		log.Fatalf("Error initializing townsourced application layer: %s", err.Error())
+	cfg, err := config.Load(settingPaths...)
+	if os.IsNotExist(err) {
+		// no settings file, and one can't be created, so values only come from defaults and environment variables
+		return config.LoadEnv(envPrefix), nil
 	}
+	return cfg, err
+}
 
-	err = web.StartServer(webCfg)
+// checkConfig prints any problems with the config and the connections to the data servers, and returns the
+// exit status
+func checkConfig(webCfg *web.Config, dataCfg *data.Config, appCfg *app.Config) int {
+	status := 0
+
+	for _, section := range []struct {
+		name string
+		errs []error
+	}{
+		{"web", web.CheckConfig(webCfg)},
+		{"data", data.CheckConfig(dataCfg)},
+		{"app", app.CheckConfig(appCfg)},
+	} {
+		if len(section.errs) == 0 {
+			fmt.Printf("%s config: OK\n", section.name)
+			continue
+		}
+
+		status = 1
+		fmt.Fprintf(os.Stderr, "%s config: %d problem(s) found\n", section.name, len(section.errs))
+		for i := range section.errs {
+			fmt.Fprintf(os.Stderr, "\t%s\n", section.errs[i])
+		}
+	}
+
+	return status
+}
+
+// envBool returns the boolean value of the environment variable, false if it isn't set or isn't a valid boolean
+func envBool(name string) bool {
+	b, _ := strconv.ParseBool(os.Getenv(name))
+	return b
+}
+
+// changeRole grants or revokes a user's site role from the command line, and returns the exit status
+func changeRole(grant, revoke string) int {
+	if grant != "" && revoke != "" {
+		fmt.Fprintln(os.Stderr, "Only one of grant-role or revoke-role can be set at a time")
+		return 1
+	}
+
+	change, changed, value, changeFunc := "grant", "granted", grant, app.ConsoleGrantRole
+	if revoke != "" {
+		change, changed, value, changeFunc = "revoke", "revoked", revoke, app.ConsoleRevokeRole
+	}
+
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		fmt.Fprintf(os.Stderr, "Invalid %s-role value %q, must be formatted as username:role\n", change, value)
+		return 1
+	}
+
+	err := changeFunc(data.NewKey(parts[0]), parts[1])
 	if err != nil {
-		app.Halt("Error Starting townsourced web server: %s", err.Error())
+		fmt.Fprintf(os.Stderr, "Error trying to %s the role %s for user %s: %s\n", change, parts[1], parts[0], err)
+		return 1
 	}
+
+	fmt.Printf("Role %s %s for user %s\n", parts[1], changed, parts[0])
+	return 0
 }
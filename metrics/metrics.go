@@ -0,0 +1,294 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+// Package metrics collects operational counters, latencies and gauges from every layer of townsourced, and serves
+// them in the Prometheus text exposition format
+//
+// Metrics are defined as package level variables where they are recorded, and register themselves when created:
+//
+//	var requests = metrics.NewCounter("townsourced_http_requests_total", "Number of HTTP requests.", "route", "status")
+//	requests.Inc("/api/v1/town/:town/", "200")
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are the default latency buckets in seconds, from 1ms to 10s
+var DefaultBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type metric interface {
+	name() string
+	write(w *bufio.Writer)
+}
+
+var registry struct {
+	sync.Mutex
+	metrics []metric
+}
+
+func register(m metric) {
+	registry.Lock()
+	defer registry.Unlock()
+	for i := range registry.metrics {
+		if registry.metrics[i].name() == m.name() {
+			panic(fmt.Sprintf("Metric %s is already registered", m.name()))
+		}
+	}
+	registry.metrics = append(registry.metrics, m)
+}
+
+// Handler serves all registered metrics in the Prometheus text format
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+		registry.Lock()
+		metrics := make([]metric, len(registry.metrics))
+		copy(metrics, registry.metrics)
+		registry.Unlock()
+
+		sort.Slice(metrics, func(i, j int) bool { return metrics[i].name() < metrics[j].name() })
+
+		buf := bufio.NewWriter(w)
+		for i := range metrics {
+			metrics[i].write(buf)
+		}
+		_ = buf.Flush()
+	})
+}
+
+// labels holds the label names of a metric, and the values of each series by their joined label values
+type labels struct {
+	names []string
+}
+
+// key joins the label values into a single series key
+func (l labels) key(values []string) string {
+	if len(values) != len(l.names) {
+		panic(fmt.Sprintf("Expected %d label values, got %d", len(l.names), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// format returns the labels of the series in the text format, with any extra label appended
+func (l labels) format(key string, extra ...string) string {
+	if len(l.names) == 0 && len(extra) == 0 {
+		return ""
+	}
+
+	var pairs []string
+	if len(l.names) != 0 {
+		values := strings.Split(key, "\xff")
+		for i := range l.names {
+			pairs = append(pairs, l.names[i]+"="+strconv.Quote(values[i]))
+		}
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+"="+strconv.Quote(extra[i+1]))
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func sortedKeys(m map[string]*float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func writeHeader(w *bufio.Writer, name, help, metricType string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+// Counter is a value that only ever goes up, such as the number of requests served
+type Counter struct {
+	metricName string
+	help       string
+	labels     labels
+
+	mu     sync.Mutex
+	values map[string]*float64
+}
+
+// NewCounter creates and registers a new counter with the given label names
+func NewCounter(name, help string, labelNames ...string) *Counter {
+	c := &Counter{
+		metricName: name,
+		help:       help,
+		labels:     labels{names: labelNames},
+		values:     make(map[string]*float64),
+	}
+	register(c)
+	return c
+}
+
+// Inc increments the counter for the given label values by 1
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v to the counter for the given label values
+func (c *Counter) Add(v float64, labelValues ...string) {
+	key := c.labels.key(labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	value, ok := c.values[key]
+	if !ok {
+		value = new(float64)
+		c.values[key] = value
+	}
+	*value += v
+}
+
+func (c *Counter) name() string { return c.metricName }
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	writeHeader(w, c.metricName, c.help, "counter")
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.metricName, c.labels.format(key), formatFloat(*c.values[key]))
+	}
+}
+
+// Histogram counts observations, such as request latencies, into buckets
+type Histogram struct {
+	metricName string
+	help       string
+	labels     labels
+	buckets    []float64
+
+	mu     sync.Mutex
+	series map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	counts []uint64 // cumulative counts for each bucket
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram creates and registers a new histogram with the given upper bucket bounds and label names.  If no
+// buckets are passed in, DefaultBuckets are used
+func NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	if !sort.Float64sAreSorted(buckets) {
+		panic(fmt.Sprintf("Histogram %s buckets must be sorted", name))
+	}
+
+	h := &Histogram{
+		metricName: name,
+		help:       help,
+		labels:     labels{names: labelNames},
+		buckets:    buckets,
+		series:     make(map[string]*histogramSeries),
+	}
+	register(h)
+	return h
+}
+
+// Observe records a single value for the given label values
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	key := h.labels.key(labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+
+	for i := range h.buckets {
+		if v <= h.buckets[i] {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += v
+}
+
+// Since records the number of seconds elapsed since start
+func (h *Histogram) Since(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+func (h *Histogram) name() string { return h.metricName }
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	writeHeader(w, h.metricName, h.help, "histogram")
+
+	keys := make([]string, 0, len(h.series))
+	for k := range h.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := h.series[key]
+		for i := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName,
+				h.labels.format(key, "le", formatFloat(h.buckets[i])), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labels.format(key, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, h.labels.format(key), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, h.labels.format(key), s.count)
+	}
+}
+
+// Gauge is a value that can go up and down, such as the number of running tasks, and is read from a function
+// every time the metrics are served
+type Gauge struct {
+	metricName string
+	help       string
+	value      func() float64
+}
+
+// NewGauge creates and registers a new gauge which reads its value from the passed in function
+func NewGauge(name, help string, value func() float64) *Gauge {
+	g := &Gauge{
+		metricName: name,
+		help:       help,
+		value:      value,
+	}
+	register(g)
+	return g
+}
+
+func (g *Gauge) name() string { return g.metricName }
+
+func (g *Gauge) write(w *bufio.Writer) {
+	writeHeader(w, g.metricName, g.help, "gauge")
+	fmt.Fprintf(w, "%s %s\n", g.metricName, formatFloat(g.value()))
+}
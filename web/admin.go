@@ -4,13 +4,41 @@
 package web
 
 import (
+	"encoding/csv"
+	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
-	log "git.townsourced.com/townsourced/logrus"
 	"github.com/timshannon/townsourced/app"
+	"github.com/timshannon/townsourced/data"
+	"github.com/timshannon/townsourced/fail"
 )
 
+const (
+	adminLogLimitDefault   = 100
+	adminAuditLimitDefault = 50
+	adminQueueLimitDefault = 20
+)
+
+type adminSuspensionInput struct {
+	Until       *time.Time `json:"until,omitempty"` // required unless banning
+	Ban         bool       `json:"ban,omitempty"`
+	HideContent bool       `json:"hideContent,omitempty"` // only when banning
+	Reason      string     `json:"reason,omitempty"`
+}
+
+type adminRoleInput struct {
+	Role   string `json:"role,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type adminDomainInput struct {
+	Domain string `json:"domain,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
 func adminTemplate(w http.ResponseWriter, r *http.Request, c context) {
 	// ?since=<since>
 
@@ -25,7 +53,7 @@ func adminTemplate(w http.ResponseWriter, r *http.Request, c context) {
 		return
 	}
 
-	if !u.Admin {
+	if !u.Staff() {
 		four04(w, r)
 		return
 	}
@@ -44,13 +72,512 @@ func adminTemplate(w http.ResponseWriter, r *http.Request, c context) {
 	stats, err := app.AdminStatsGet(u, since)
 
 	err = w.(*templateWriter).execute("ADMIN", struct {
-		Stats *app.AdminStats
-		Error error
+		Stats       *app.AdminStats
+		Permissions []string
+		Error       error
 	}{
-		Stats: stats,
-		Error: err,
+		Stats:       stats,
+		Permissions: u.Permissions(),
+		Error:       err,
 	})
 	if err != nil {
-		log.Errorf("Error executing admin template: %s", err)
+		requestLog(w).Errorf("Error executing admin template: %s", err)
+	}
+}
+
+// adminUser returns the current session's user if they have a site role, otherwise it handles the response and
+// returns nil
+func adminUser(w http.ResponseWriter, r *http.Request, c context) *app.User {
+	if c.session == nil {
+		unauthorized(w, r)
+		return nil
+	}
+
+	u, err := c.session.User()
+	if errHandled(err, w, r, c) {
+		return nil
+	}
+
+	if !u.Staff() {
+		four04(w, r)
+		return nil
+	}
+
+	return u
+}
+
+// logFilter builds a log filter from the request's query parameters
+//
+//	?level=error&level=warning
+//	?since=<RFC3339>&until=<RFC3339>
+//	?message=<text>
+//	?field.<name>=<text>
+//	?fingerprint=<fingerprint>
+func logFilter(r *http.Request) (*app.LogFilter, error) {
+	values := r.URL.Query()
+
+	filter := &app.LogFilter{
+		Levels:      values["level"],
+		Message:     values.Get("message"),
+		Fingerprint: values.Get("fingerprint"),
+		Fields:      make(map[string]string),
+	}
+
+	var err error
+	if values.Get("since") != "" {
+		filter.Since, err = time.Parse(time.RFC3339, values.Get("since"))
+		if err != nil {
+			return nil, fail.New("Invalid time format for since parameter", values.Get("since"))
+		}
+	}
+
+	if values.Get("until") != "" {
+		filter.Until, err = time.Parse(time.RFC3339, values.Get("until"))
+		if err != nil {
+			return nil, fail.New("Invalid time format for until parameter", values.Get("until"))
+		}
 	}
+
+	for name := range values {
+		if strings.HasPrefix(name, "field.") && len(name) > len("field.") && values.Get(name) != "" {
+			filter.Fields[strings.TrimPrefix(name, "field.")] = values.Get(name)
+		}
+	}
+
+	return filter, nil
+}
+
+func adminLogGet(w http.ResponseWriter, r *http.Request, c context) {
+	// ?from=<from>&limit=100
+	// see logFilter for the remaining parameters
+	u := adminUser(w, r, c)
+	if u == nil {
+		return
+	}
+
+	filter, err := logFilter(r)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	values := r.URL.Query()
+
+	limit, err := strconv.Atoi(values.Get("limit"))
+	if err != nil {
+		limit = adminLogLimitDefault
+	}
+
+	from, err := strconv.Atoi(values.Get("from"))
+	if err != nil {
+		from = 0
+	}
+
+	entries, err := app.AdminLogGet(u, filter, from, limit)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+		Data:   entries,
+	})
+}
+
+func adminLogGetGroups(w http.ResponseWriter, r *http.Request, c context) {
+	// see logFilter for parameters
+	u := adminUser(w, r, c)
+	if u == nil {
+		return
+	}
+
+	filter, err := logFilter(r)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	groups, err := app.AdminLogGroups(u, filter)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+		Data:   groups,
+	})
+}
+
+func adminLogExport(w http.ResponseWriter, r *http.Request, c context) {
+	// ?format=<json|csv>
+	// see logFilter for the remaining parameters
+	u := adminUser(w, r, c)
+	if u == nil {
+		return
+	}
+
+	filter, err := logFilter(r)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		errHandled(fail.New("Invalid export format, must be json or csv", format), w, r, c)
+		return
+	}
+
+	entries, err := app.AdminLogExport(u, filter)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	filename := "townsourced-log-" + time.Now().UTC().Format("20060102T150405Z") + "." + format
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(entries)
+		if err != nil {
+			requestLog(w).Errorf("Error exporting log as json: %s", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	cw := csv.NewWriter(w)
+	err = cw.Write([]string{"time", "level", "message", "fingerprint", "fields"})
+	if err != nil {
+		requestLog(w).Errorf("Error exporting log as csv: %s", err)
+		return
+	}
+
+	for i := range entries {
+		fields, err := json.Marshal(entries[i].Fields)
+		if err != nil {
+			fields = []byte(err.Error())
+		}
+		err = cw.Write([]string{
+			entries[i].Time.Format(time.RFC3339Nano),
+			entries[i].Level,
+			csvCell(entries[i].Message),
+			csvCell(entries[i].Fingerprint),
+			csvCell(string(fields)),
+		})
+		if err != nil {
+			requestLog(w).Errorf("Error exporting log as csv: %s", err)
+			return
+		}
+	}
+
+	cw.Flush()
+	if cw.Error() != nil {
+		requestLog(w).Errorf("Error exporting log as csv: %s", cw.Error())
+	}
+}
+
+// adminTargetUser returns the user in the route's user parameter, otherwise it handles the response and returns nil
+func adminTargetUser(w http.ResponseWriter, r *http.Request, c context) *app.User {
+	u, err := app.UserGet(data.NewKey(c.params.ByName("user")))
+	if err == app.ErrUserNotFound {
+		four04(w, r)
+		return nil
+	}
+	if errHandled(err, w, r, c) {
+		return nil
+	}
+	return u
+}
+
+func adminPostUserSuspension(w http.ResponseWriter, r *http.Request, c context) {
+	who := adminUser(w, r, c)
+	if who == nil {
+		return
+	}
+
+	input := &adminSuspensionInput{}
+	err := parseInput(r, input)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	u := adminTargetUser(w, r, c)
+	if u == nil {
+		return
+	}
+
+	if input.Ban {
+		err = u.Ban(who, input.Reason, input.HideContent)
+	} else {
+		if input.Until == nil {
+			errHandled(fail.New("The field until is required when suspending a user", input), w, r, c)
+			return
+		}
+		err = u.Suspend(who, *input.Until, input.Reason)
+	}
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+		Data:   u.Suspension,
+	})
+}
+
+func adminDeleteUserSuspension(w http.ResponseWriter, r *http.Request, c context) {
+	who := adminUser(w, r, c)
+	if who == nil {
+		return
+	}
+
+	input := &adminSuspensionInput{}
+	err := parseInput(r, input)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	u := adminTargetUser(w, r, c)
+	if u == nil {
+		return
+	}
+
+	if errHandled(u.Reinstate(who, input.Reason), w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+	})
+}
+
+func adminAuditGet(w http.ResponseWriter, r *http.Request, c context) {
+	// ?user=<username>&from=<from>&limit=50
+	who := adminUser(w, r, c)
+	if who == nil {
+		return
+	}
+
+	values := r.URL.Query()
+
+	limit, err := strconv.Atoi(values.Get("limit"))
+	if err != nil {
+		limit = adminAuditLimitDefault
+	}
+
+	from, err := strconv.Atoi(values.Get("from"))
+	if err != nil {
+		from = 0
+	}
+
+	entries, err := app.AdminAuditGet(who, data.NewKey(values.Get("user")), from, limit)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+		Data:   entries,
+	})
+}
+
+func adminStaffGet(w http.ResponseWriter, r *http.Request, c context) {
+	who := adminUser(w, r, c)
+	if who == nil {
+		return
+	}
+
+	users, err := app.AdminStaffGet(who)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	staff := make([]map[string]interface{}, len(users))
+	for i := range users {
+		staff[i] = map[string]interface{}{
+			"username": users[i].Username,
+			"name":     users[i].Name,
+			"roles":    users[i].Roles,
+			"admin":    users[i].Admin,
+		}
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+		Data:   staff,
+	})
+}
+
+func adminPostUserRole(w http.ResponseWriter, r *http.Request, c context) {
+	who := adminUser(w, r, c)
+	if who == nil {
+		return
+	}
+
+	input := &adminRoleInput{}
+	err := parseInput(r, input)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	u := adminTargetUser(w, r, c)
+	if u == nil {
+		return
+	}
+
+	if errHandled(u.GrantRole(who, input.Role, input.Reason), w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+		Data:   u.Roles,
+	})
+}
+
+func adminDeleteUserRole(w http.ResponseWriter, r *http.Request, c context) {
+	who := adminUser(w, r, c)
+	if who == nil {
+		return
+	}
+
+	input := &adminRoleInput{}
+	err := parseInput(r, input)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	u := adminTargetUser(w, r, c)
+	if u == nil {
+		return
+	}
+
+	if errHandled(u.RevokeRole(who, input.Role, input.Reason), w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+		Data:   u.Roles,
+	})
+}
+
+func adminModQueueGet(w http.ResponseWriter, r *http.Request, c context) {
+	// ?from=<from>&limit=20
+	who := adminUser(w, r, c)
+	if who == nil {
+		return
+	}
+
+	values := r.URL.Query()
+
+	limit, err := strconv.Atoi(values.Get("limit"))
+	if err != nil {
+		limit = adminQueueLimitDefault
+	}
+
+	from, err := strconv.Atoi(values.Get("from"))
+	if err != nil {
+		from = 0
+	}
+
+	entries, err := app.AdminModQueueGet(who, from, limit)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+		Data:   entries,
+	})
+}
+
+func adminCommentQueueGet(w http.ResponseWriter, r *http.Request, c context) {
+	// ?from=<from>&limit=20
+	who := adminUser(w, r, c)
+	if who == nil {
+		return
+	}
+
+	values := r.URL.Query()
+
+	limit, err := strconv.Atoi(values.Get("limit"))
+	if err != nil {
+		limit = adminQueueLimitDefault
+	}
+
+	from, err := strconv.Atoi(values.Get("from"))
+	if err != nil {
+		from = 0
+	}
+
+	entries, err := app.AdminCommentQueueGet(who, from, limit)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+		Data:   entries,
+	})
+}
+
+func adminBlockedDomainsGet(w http.ResponseWriter, r *http.Request, c context) {
+	who := adminUser(w, r, c)
+	if who == nil {
+		return
+	}
+
+	blocked, err := app.AdminBlockedDomainsGet(who)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+		Data:   blocked,
+	})
+}
+
+func adminPostBlockedDomain(w http.ResponseWriter, r *http.Request, c context) {
+	who := adminUser(w, r, c)
+	if who == nil {
+		return
+	}
+
+	input := &adminDomainInput{}
+	err := parseInput(r, input)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	if errHandled(app.AdminBlockDomain(who, input.Domain, input.Reason), w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+	})
+}
+
+func adminDeleteBlockedDomain(w http.ResponseWriter, r *http.Request, c context) {
+	who := adminUser(w, r, c)
+	if who == nil {
+		return
+	}
+
+	input := &adminDomainInput{}
+	err := parseInput(r, input)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	if errHandled(app.AdminUnblockDomain(who, input.Domain, input.Reason), w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+	})
 }
@@ -0,0 +1,46 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package web
+
+import (
+	"log"
+	"net/http"
+
+	"git.townsourced.com/townsourced/logrus"
+	"github.com/timshannon/townsourced/metrics"
+)
+
+// The admin server runs on a separate listener from the public site, so that operational endpoints like /metrics,
+// the health checks, and draining can be firewalled off, and are never routed through the public subdomains
+
+func startAdminServer(c *Config) {
+	if c.AdminAddress == "" {
+		logrus.Debugf("No admin address set, admin server is disabled")
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	mux.HandleFunc("/healthz", healthz)
+	mux.HandleFunc("/readyz", readyz)
+	mux.HandleFunc("/drain", drainHandler)
+
+	go func(cfg *Config) {
+		server := &http.Server{
+			Addr:           cfg.AdminAddress,
+			Handler:        mux,
+			ReadTimeout:    cfg.readTimeout,
+			WriteTimeout:   cfg.writeTimeout,
+			MaxHeaderBytes: cfg.MaxHeaderBytes,
+			ErrorLog:       log.New(logrus.StandardLogger().Writer(), "", log.LstdFlags),
+		}
+
+		servers.add(server)
+		logrus.Debugf("Admin server running at %s", cfg.AdminAddress)
+		err := server.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			logrus.WithField("error", err).Error("Error starting admin server")
+		}
+	}(c)
+}
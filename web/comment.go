@@ -14,7 +14,11 @@ import (
 )
 
 type commentInput struct {
-	Comment *string `json:"comment,omitempty"`
+	Comment          *string `json:"comment,omitempty"`
+	Report           *string `json:"report,omitempty"`          // reason for reporting the comment
+	ModeratorReason  *string `json:"moderatorReason,omitempty"` // moderates the comment
+	RemoveModeration bool    `json:"removeModeration,omitempty"`
+	Approve          bool    `json:"approve,omitempty"` // takes the comment out of the moderation queue
 }
 
 // rate limit the number of new comments that can be created by the same user
@@ -62,7 +66,15 @@ func commentGet(w http.ResponseWriter, r *http.Request, c context) {
 		}
 	}
 
-	comments, more, err := app.CommentsGet(post, parent, from, limit, values.Get("sort"))
+	var who *app.User
+	if c.session != nil {
+		who, err = c.session.User()
+		if errHandled(err, w, r, c) {
+			return
+		}
+	}
+
+	comments, more, err := app.CommentsGet(who, post, parent, from, limit, values.Get("sort"))
 	if errHandled(err, w, r, c) {
 		return
 	}
@@ -111,7 +123,7 @@ func commentPost(w http.ResponseWriter, r *http.Request, c context) {
 	}
 
 	//Rate limit new comments
-	if errHandled(app.AttemptRequest(string(c.session.UserKey), commentNewRequestType), w, r, c) {
+	if errHandled(app.AttemptRequest(r.Context(), string(c.session.UserKey), commentNewRequestType), w, r, c) {
 		return
 	}
 
@@ -121,7 +133,7 @@ func commentPost(w http.ResponseWriter, r *http.Request, c context) {
 		if errHandled(err, w, r, c) {
 			return
 		}
-		newComment, err := comment.Reply(u, *input.Comment)
+		newComment, err := comment.Reply(r.Context(), u, *input.Comment)
 		if errHandled(err, w, r, c) {
 			return
 		}
@@ -133,7 +145,7 @@ func commentPost(w http.ResponseWriter, r *http.Request, c context) {
 		return
 	}
 
-	comment, err := app.CommentNew(u, post, *input.Comment)
+	comment, err := app.CommentNew(r.Context(), u, post, *input.Comment)
 	if errHandled(err, w, r, c) {
 		return
 
@@ -144,3 +156,80 @@ func commentPost(w http.ResponseWriter, r *http.Request, c context) {
 		Data:   comment,
 	}, http.StatusCreated)
 }
+
+func commentPut(w http.ResponseWriter, r *http.Request, c context) {
+	if c.session == nil {
+		unauthorized(w, r)
+		return
+	}
+
+	u, err := c.session.User()
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	input := &commentInput{}
+	err = parseInput(r, input)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	comment, err := app.CommentGet(data.ToUUID(c.params.ByName("comment")))
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	if input.Comment != nil {
+		errHandled(fail.New("Comments can't be edited"), w, r, c)
+		return
+	}
+
+	switch {
+	case input.Report != nil:
+		err = comment.Report(u, *input.Report)
+	case input.ModeratorReason != nil:
+		err = comment.Moderate(u, *input.ModeratorReason)
+	case input.RemoveModeration:
+		err = comment.RemoveModeration(u)
+	case input.Approve:
+		err = comment.Approve(u)
+	default:
+		err = fail.New("No action specified", input)
+	}
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	if errHandled(comment.Update(), w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+	})
+}
+
+func commentDelete(w http.ResponseWriter, r *http.Request, c context) {
+	if c.session == nil {
+		unauthorized(w, r)
+		return
+	}
+
+	u, err := c.session.User()
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	comment, err := app.CommentGet(data.ToUUID(c.params.ByName("comment")))
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	if errHandled(comment.Delete(u), w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+	})
+}
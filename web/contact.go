@@ -40,7 +40,7 @@ func contactMessage(w http.ResponseWriter, r *http.Request, c context) {
 		id = string(c.session.UserKey)
 	}
 
-	if errHandled(app.AttemptRequest(id, contactMessageRate), w, r, c) {
+	if errHandled(app.AttemptRequest(r.Context(), id, contactMessageRate), w, r, c) {
 		return
 	}
 
@@ -0,0 +1,14 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package web
+
+import "strings"
+
+// csvCell escapes values which a spreadsheet would otherwise run as a formula when the csv is opened
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -8,7 +8,6 @@ import (
 	"time"
 
 	"git.townsourced.com/townsourced/httprouter"
-	log "git.townsourced.com/townsourced/logrus"
 	"github.com/timshannon/townsourced/app"
 	"github.com/timshannon/townsourced/data"
 	"github.com/timshannon/townsourced/fail"
@@ -28,7 +27,7 @@ func forgotPasswordTemplate(w http.ResponseWriter, r *http.Request, c context) {
 	var user *app.User
 
 	if token != "" {
-		if errHandledPage(app.AttemptRequest(ipAddress(r), userPassworkTokenRate), w, r, c) {
+		if errHandledPage(app.AttemptRequest(r.Context(), ipAddress(r), userPassworkTokenRate), w, r, c) {
 			return
 		}
 
@@ -54,14 +53,15 @@ func forgotPasswordTemplate(w http.ResponseWriter, r *http.Request, c context) {
 		Token: token,
 	})
 	if err != nil {
-		log.Errorf("Error executing FORGOTPASSWORD template: %s", err)
+		requestLog(w).Errorf("Error executing FORGOTPASSWORD template: %s", err)
 	}
 }
 
 func resetPassword(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 	//faked context to prevent csrf issues
 	c := context{
-		params: p,
+		params:    p,
+		requestID: requestID(w, r),
 	}
 
 	input := &userInput{}
@@ -77,7 +77,7 @@ func resetPassword(w http.ResponseWriter, r *http.Request, p httprouter.Params)
 		return
 	}
 
-	if errHandled(app.AttemptRequest(ipAddress(r), userPassworkTokenRate), w, r, c) {
+	if errHandled(app.AttemptRequest(r.Context(), ipAddress(r), userPassworkTokenRate), w, r, c) {
 		return
 	}
 
@@ -87,8 +87,11 @@ func resetPassword(w http.ResponseWriter, r *http.Request, p httprouter.Params)
 		return
 	}
 
-	if errHandled(setSessionCookie(w, r, u, false), w, r, c) {
-		return
+	// users with two-factor authentication log in with their new password and a code
+	if !u.TwoFactor {
+		if errHandled(setSessionCookie(w, r, u, false), w, r, c) {
+			return
+		}
 	}
 
 	respondJsend(w, &JSend{
@@ -142,7 +145,7 @@ func confirmEmailTemplate(w http.ResponseWriter, r *http.Request, c context) {
 
 	err = w.(*templateWriter).execute("CONFIRMEMAIL", success)
 	if err != nil {
-		log.Errorf("Error executing CONFIRMEMAIL template: %s", err)
+		requestLog(w).Errorf("Error executing CONFIRMEMAIL template: %s", err)
 	}
 }
 
@@ -48,12 +48,13 @@ func errHandled(err error, w http.ResponseWriter, r *http.Request, c context) bo
 		}
 
 		log.WithFields(log.Fields{
-			"url":     r.URL,
-			"method":  r.Method,
-			"header":  r.Header,
-			"params":  c.params,
-			"session": c.session,
-			"data":    err.(*fail.Fail).Data,
+			"url":              r.URL,
+			"method":           r.Method,
+			"header":           r.Header,
+			"params":           c.params,
+			"session":          c.session,
+			app.RequestIDField: c.requestID,
+			"data":             err.(*fail.Fail).Data,
 		}).Warning(err)
 	case *http.ProtocolError, *json.SyntaxError, *json.UnmarshalTypeError:
 		//Hardcoded external errors which can bubble up to the end users
@@ -62,11 +63,12 @@ func errHandled(err error, w http.ResponseWriter, r *http.Request, c context) bo
 		status = statusFail
 
 		log.WithFields(log.Fields{
-			"url":     r.URL,
-			"method":  r.Method,
-			"header":  r.Header,
-			"params":  c.params,
-			"session": c.session,
+			"url":              r.URL,
+			"method":           r.Method,
+			"header":           r.Header,
+			"params":           c.params,
+			"session":          c.session,
+			app.RequestIDField: c.requestID,
 		}).Warning(err)
 		errMsg = fmt.Sprintf("We had trouble parsing your input, please check your input and try again: %s", err)
 	default:
@@ -79,11 +81,12 @@ func errHandled(err error, w http.ResponseWriter, r *http.Request, c context) bo
 
 		status = statusError
 		log.WithFields(log.Fields{
-			"url":     r.URL,
-			"method":  r.Method,
-			"header":  r.Header,
-			"params":  c.params,
-			"session": c.session,
+			"url":              r.URL,
+			"method":           r.Method,
+			"header":           r.Header,
+			"params":           c.params,
+			"session":          c.session,
+			app.RequestIDField: c.requestID,
 		}).Error(err)
 
 		if !devMode {
@@ -120,8 +123,9 @@ func errHandledPage(err error, w http.ResponseWriter, r *http.Request, c context
 	}
 
 	log.WithFields(log.Fields{
-		"params":  c.params,
-		"session": c.session,
+		"params":           c.params,
+		"session":          c.session,
+		app.RequestIDField: c.requestID,
 	}).Error(err)
 
 	errorHandler.ServeHTTP(w, r)
@@ -153,21 +157,22 @@ func unauthorized(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Content-Type", "application/json")
 	response := &JSend{
-		Status:  statusFail,
-		Message: "Unauthorized.  Please re-authenticate and try again.",
-		Data:    r.URL.String(),
+		Status:    statusFail,
+		Message:   "Unauthorized.  Please re-authenticate and try again.",
+		Data:      r.URL.String(),
+		RequestID: w.Header().Get(requestIDHeader),
 	}
 
 	w.WriteHeader(http.StatusUnauthorized)
 	result, err := json.Marshal(response)
 	if err != nil {
-		log.Errorf("Error marshalling unauthorized response: %s", err)
+		requestLog(w).Errorf("Error marshalling unauthorized response: %s", err)
 		return
 	}
 
 	_, err = w.Write(result)
 	if err != nil {
-		log.Errorf("Error in unauthorized json response: %s", err)
+		requestLog(w).Errorf("Error in unauthorized json response: %s", err)
 	}
 }
 
@@ -175,22 +180,23 @@ func respond404JSON(w http.ResponseWriter, url string) {
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Content-Type", "application/json")
 	response := &JSend{
-		Status:  statusFail,
-		Message: "Resource not found",
-		Data:    url,
+		Status:    statusFail,
+		Message:   "Resource not found",
+		Data:      url,
+		RequestID: w.Header().Get(requestIDHeader),
 	}
 
 	w.WriteHeader(http.StatusNotFound)
 
 	result, err := json.Marshal(response)
 	if err != nil {
-		log.Errorf("Error marshalling 404 response: %s", err)
+		requestLog(w).Errorf("Error marshalling 404 response: %s", err)
 		return
 	}
 
 	_, err = w.Write(result)
 	if err != nil {
-		log.Errorf("Error in respond404JSON: %s", err)
+		requestLog(w).Errorf("Error in respond404JSON: %s", err)
 	}
 }
 
@@ -208,7 +214,10 @@ func panicHandler(w http.ResponseWriter, r *http.Request, rec interface{}) {
 			stack := buf[:runtime.Stack(buf, true)]
 			app.Halt("PANIC: %s \n STACK: %s", rec, stack)
 		}
-		errHandled(fmt.Errorf("townsourced webserver panicked on %v and has recovered", rec), w, r, context{})
+		// the request has already been untracked by the time the router recovers, so the ID is read back from the
+		// response header
+		errHandled(fmt.Errorf("townsourced webserver panicked on %v and has recovered", rec), w, r,
+			context{requestID: w.Header().Get(requestIDHeader)})
 		return
 	}
 }
@@ -35,13 +35,7 @@ func googleGet(w http.ResponseWriter, r *http.Request, c context) {
 			return
 		}
 
-		if errHandled(setSessionCookie(w, r, u, true), w, r, c) {
-			return
-		}
-
-		respondJsend(w, &JSend{
-			Status: statusSuccess,
-		})
+		respondLogin(w, r, c, u, true)
 		return
 	}
 	u, err := c.session.User()
@@ -0,0 +1,120 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package web
+
+import (
+	stdcontext "context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"git.townsourced.com/townsourced/logrus"
+	"github.com/timshannon/townsourced/data"
+)
+
+// draining is set to 1 when the server is shutting down, or has been manually drained, and fails readiness checks
+// so load balancers stop sending it new requests
+var draining int32
+
+type componentHealth struct {
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+	Latency string `json:"latency"`
+}
+
+type readiness struct {
+	Ready      bool                        `json:"ready"`
+	Draining   bool                        `json:"draining"`
+	Components map[string]*componentHealth `json:"components"`
+}
+
+// healthz is the liveness check, and only reports that the web server is up and handling requests
+func healthz(w http.ResponseWriter, r *http.Request) {
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+	})
+}
+
+// readyz is the readiness check, it checks every data layer dependency, and fails if any are unreachable, or if
+// the server is draining
+func readyz(w http.ResponseWriter, r *http.Request) {
+	result := &readiness{
+		Ready:      true,
+		Draining:   atomic.LoadInt32(&draining) == 1,
+		Components: make(map[string]*componentHealth),
+	}
+
+	for name, check := range data.Health(healthTimeout()) {
+		component := &componentHealth{
+			Status:  "ok",
+			Latency: check.Latency.String(),
+		}
+		if check.Err != nil {
+			component.Status = "error"
+			component.Error = check.Err.Error()
+			result.Ready = false
+		}
+		result.Components[name] = component
+	}
+
+	if result.Draining {
+		result.Ready = false
+	}
+
+	if !result.Ready {
+		respondJsendCode(w, &JSend{
+			Status:  statusError,
+			Message: "Server is not ready to handle requests",
+			Data:    result,
+		}, http.StatusServiceUnavailable)
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+		Data:   result,
+	})
+}
+
+// drainHandler lets an operator manually take the server out of the load balancer with a POST, and put it back
+// in with a DELETE
+func drainHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "POST":
+		atomic.StoreInt32(&draining, 1)
+		logrus.Infof("Server is draining, readiness checks will fail")
+	case "DELETE":
+		atomic.StoreInt32(&draining, 0)
+		logrus.Infof("Server is no longer draining")
+	default:
+		w.Header().Set("Allow", "POST, DELETE")
+		respondJsendCode(w, &JSend{
+			Status:  statusFail,
+			Message: "Method not allowed",
+		}, http.StatusMethodNotAllowed)
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+		Data:   atomic.LoadInt32(&draining) == 1,
+	})
+}
+
+// drain fails readiness checks, and waits for the configured drain delay so load balancers have time to notice
+// before the listeners are closed
+func drain(ctx stdcontext.Context) {
+	atomic.StoreInt32(&draining, 1)
+
+	delay := drainDelay()
+	if delay <= 0 || devMode {
+		return
+	}
+
+	logrus.Infof("Draining for %s before shutting down the web server", delay)
+	select {
+	case <-time.After(delay):
+	case <-ctx.Done():
+	}
+}
@@ -6,7 +6,6 @@ package web
 import (
 	"net/http"
 
-	log "git.townsourced.com/townsourced/logrus"
 	"github.com/timshannon/townsourced/app"
 	"github.com/timshannon/townsourced/data"
 )
@@ -22,6 +21,6 @@ func helpTemplate(w http.ResponseWriter, r *http.Request, c context) {
 	err = w.(*templateWriter).execute("HELP", help)
 
 	if err != nil {
-		log.Errorf("Error executing help template: %s", err)
+		requestLog(w).Errorf("Error executing help template: %s", err)
 	}
 }
@@ -5,6 +5,7 @@ package web
 
 import (
 	"net/http"
+	"sync/atomic"
 
 	"github.com/timshannon/townsourced/app"
 	"github.com/timshannon/townsourced/data"
@@ -78,7 +79,7 @@ func imagesFromForm(u *app.User, r *http.Request) ([]*app.Image, error) {
 
 	var images []*app.Image
 
-	err := r.ParseMultipartForm(maxUploadMemory)
+	err := r.ParseMultipartForm(atomic.LoadInt64(&maxUploadMemory))
 	if err != nil {
 		return nil, err
 	}
@@ -9,7 +9,6 @@ import (
 	"io/ioutil"
 	"net/http"
 
-	log "git.townsourced.com/townsourced/logrus"
 	"github.com/timshannon/townsourced/fail"
 )
 
@@ -33,6 +32,8 @@ type JSend struct {
 	Message  string      `json:"message,omitempty"`
 	Failures []error     `json:"failures,omitempty"`
 	More     bool        `json:"more,omitempty"` // more data exists for this request
+	// RequestID is included in errors and failures, so they can be matched to the server's logs
+	RequestID string `json:"requestID,omitempty"`
 }
 
 type etagger interface {
@@ -50,13 +51,17 @@ func respondJsendCode(w http.ResponseWriter, response *JSend, statusCode int) {
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Content-Type", "application/json")
 
+	if response.Status != statusSuccess {
+		response.RequestID = w.Header().Get(requestIDHeader)
+	}
+
 	if len(response.Failures) > 0 && response.Message == "" {
 		response.Message = "One or more item has failed. Check the individual failures for details."
 	}
 
 	result, err := json.MarshalIndent(response, "", "    ")
 	if err != nil {
-		log.Errorf("Error marshalling response: %s", err)
+		requestLog(w).Errorf("Error marshalling response: %s", err)
 
 		result, _ = json.Marshal(&JSend{
 			Status:  statusError,
@@ -84,7 +89,7 @@ func respondJsendCode(w http.ResponseWriter, response *JSend, statusCode int) {
 
 	_, err = w.Write(result)
 	if err != nil {
-		log.WithField("JSEND", result).Errorf("Error writing jsend response: %s", err)
+		requestLog(w).WithField("JSEND", result).Errorf("Error writing jsend response: %s", err)
 	}
 }
 
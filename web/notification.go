@@ -204,7 +204,7 @@ func userPostNotifications(w http.ResponseWriter, r *http.Request, c context) {
 		return
 	}
 
-	if errHandled(u.SendMessage(to, *input.Subject, *input.Message), w, r, c) {
+	if errHandled(u.SendDirectMessage(to, *input.Subject, *input.Message), w, r, c) {
 		return
 	}
 
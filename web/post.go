@@ -8,7 +8,6 @@ import (
 	"path"
 	"time"
 
-	log "git.townsourced.com/townsourced/logrus"
 	"github.com/timshannon/townsourced/app"
 	"github.com/timshannon/townsourced/data"
 	"github.com/timshannon/townsourced/data/private"
@@ -88,12 +87,12 @@ func postTemplate(w http.ResponseWriter, r *http.Request, c context) {
 	commentContext := c.params.ByName("comment")
 
 	if commentContext == "" {
-		comments, more, err = app.CommentsGet(post, nil, 0, commentListSizeDefault, values.Get("sort"))
+		comments, more, err = app.CommentsGet(user, post, nil, 0, commentListSizeDefault, values.Get("sort"))
 		if errHandledPage(err, w, r, c) {
 			return
 		}
 	} else {
-		commentTree, err = app.CommentGetTree(data.ToUUID(commentContext), commentListSizeDefault,
+		commentTree, err = app.CommentGetTree(user, data.ToUUID(commentContext), commentListSizeDefault,
 			values.Get("sort"))
 		if err == data.ErrNotFound {
 			four04(w, r)
@@ -152,7 +151,7 @@ func postTemplate(w http.ResponseWriter, r *http.Request, c context) {
 			URL:            canonicalURL,
 		})
 	if err != nil {
-		log.Errorf("Error executing post template: %s", err)
+		requestLog(w).Errorf("Error executing post template: %s", err)
 	}
 }
 
@@ -186,7 +185,7 @@ func editPostTemplate(w http.ResponseWriter, r *http.Request, c context) {
 			User: user,
 		})
 		if err != nil {
-			log.Errorf("Error executing editpost template: %s", err)
+			requestLog(w).Errorf("Error executing editpost template: %s", err)
 		}
 		return
 	}
@@ -230,7 +229,7 @@ func editPostTemplate(w http.ResponseWriter, r *http.Request, c context) {
 	})
 
 	if err != nil {
-		log.Errorf("Error executing editpost template: %s", err)
+		requestLog(w).Errorf("Error executing editpost template: %s", err)
 	}
 }
 
@@ -327,12 +326,12 @@ func postPost(w http.ResponseWriter, r *http.Request, c context) {
 	}
 
 	//Rate limit new posts
-	if errHandled(app.AttemptRequest(string(c.session.UserKey), postNewRequestType), w, r, c) {
+	if errHandled(app.AttemptRequest(r.Context(), string(c.session.UserKey), postNewRequestType), w, r, c) {
 		return
 	}
 
-	post, err := app.PostNew(*input.Title, *input.Content, category, format, u, input.TownKeys, input.Images,
-		featuredImage, allowComments, notifyOnComment, input.Draft)
+	post, err := app.PostNew(r.Context(), *input.Title, *input.Content, category, format, u, input.TownKeys,
+		input.Images, featuredImage, allowComments, notifyOnComment, input.Draft)
 	if errHandled(err, w, r, c) {
 		return
 	}
@@ -389,7 +388,7 @@ func postPut(w http.ResponseWriter, r *http.Request, c context) {
 	}
 
 	if input.Content != nil {
-		if errHandled(post.SetContent(u, *input.Content), w, r, c) {
+		if errHandled(post.SetContent(r.Context(), u, *input.Content), w, r, c) {
 			return
 		}
 	}
@@ -407,7 +406,7 @@ func postPut(w http.ResponseWriter, r *http.Request, c context) {
 	}
 
 	if input.TownKeys != nil {
-		if errHandled(post.SetTowns(u, input.TownKeys), w, r, c) {
+		if errHandled(post.SetTowns(r.Context(), u, input.TownKeys), w, r, c) {
 			return
 		}
 	}
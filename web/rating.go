@@ -0,0 +1,122 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package web
+
+import (
+	"net/http"
+
+	"github.com/timshannon/townsourced/app"
+	"github.com/timshannon/townsourced/data"
+)
+
+const ratingListSizeDefault = 20
+
+type ratingInput struct {
+	Post    data.UUID `json:"post,omitempty"`
+	Score   int       `json:"score,omitempty"`
+	Comment string    `json:"comment,omitempty"`
+	From    data.Key  `json:"from,omitempty"`   // who gave the rating being removed, defaults to the current user
+	Reason  string    `json:"reason,omitempty"` // required when staff remove another user's rating
+}
+
+func userGetRatings(w http.ResponseWriter, r *http.Request, c context) {
+	// ?since=<since>
+	// ?limit=<limit>
+
+	u, err := app.UserGet(data.NewKey(c.params.ByName("user")))
+	if err == app.ErrUserNotFound {
+		four04(w, r)
+		return
+	}
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	since, limit, err := sinceLimitValues(r.URL.Query(), ratingListSizeDefault)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	ratings, err := u.Ratings(since, limit)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+		Data:   ratings,
+	})
+}
+
+func userPostRating(w http.ResponseWriter, r *http.Request, c context) {
+	if c.session == nil {
+		unauthorized(w, r)
+		return
+	}
+
+	who, err := c.session.User()
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	input := &ratingInput{}
+	err = parseInput(r, input)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	post, err := app.PostGet(input.Post)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	rating, err := who.Rate(data.NewKey(c.params.ByName("user")), post, input.Score, input.Comment)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+		Data:   rating,
+	})
+}
+
+func userDeleteRating(w http.ResponseWriter, r *http.Request, c context) {
+	if c.session == nil {
+		unauthorized(w, r)
+		return
+	}
+
+	who, err := c.session.User()
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	input := &ratingInput{}
+	err = parseInput(r, input)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	if input.From == data.EmptyKey {
+		input.From = who.Username
+	}
+
+	rating, err := app.RatingGet(input.Post, input.From, data.NewKey(c.params.ByName("user")))
+	if err == app.ErrRatingNotFound {
+		four04(w, r)
+		return
+	}
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	if errHandled(rating.Remove(who, input.Reason), w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+	})
+}
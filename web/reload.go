@@ -0,0 +1,139 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package web
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var running struct {
+	sync.Mutex
+	cfg *Config
+}
+
+var serverCert certificate
+
+// certificate is the server's TLS certificate, which can be reloaded from its files while the server is running
+type certificate struct {
+	sync.RWMutex
+	cert *tls.Certificate
+}
+
+func (c *certificate) load(certFile, keyFile string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return fmt.Errorf("Error loading TLS certificate: %s", err)
+	}
+
+	c.Lock()
+	c.cert = &cert
+	c.Unlock()
+	return nil
+}
+
+func (c *certificate) get(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.RLock()
+	defer c.RUnlock()
+	return c.cert, nil
+}
+
+func setRunning(cfg *Config) {
+	running.Lock()
+	defer running.Unlock()
+
+	current := *cfg
+	running.cfg = &current
+}
+
+// healthTimeout is how long readiness checks wait on each dependency
+func healthTimeout() time.Duration {
+	running.Lock()
+	defer running.Unlock()
+	if running.cfg == nil {
+		return 2 * time.Second
+	}
+	return running.cfg.healthTimeout
+}
+
+// drainDelay is how long the server fails readiness checks before shutting down
+func drainDelay() time.Duration {
+	running.Lock()
+	defer running.Unlock()
+	if running.cfg == nil {
+		return 0
+	}
+	return running.cfg.drainDelay
+}
+
+// Reload applies the settings that can be safely changed while the server is running: the max upload memory, the
+// health timeout, the drain delay, the access log, and the TLS certificate, which is reloaded from its files even if their names
+// haven't changed.
+// The names of any other changed settings are returned, and are left as they are until the server is restarted
+func Reload(cfg *Config) ([]string, error) {
+	running.Lock()
+	defer running.Unlock()
+
+	if running.cfg == nil {
+		return nil, errors.New("The web server is not running")
+	}
+
+	current := running.cfg
+	var restart []string
+
+	if cfg.MaxUploadMemoryMB <= 0 {
+		return nil, fmt.Errorf("Web MaxUploadMemoryMB must be greater than 0")
+	}
+
+	err := cfg.parseTimeouts()
+	if err != nil {
+		return nil, err
+	}
+
+	if (current.CertFile == "" || current.KeyFile == "") != (cfg.CertFile == "" || cfg.KeyFile == "") {
+		// switching between http and https
+		restart = append(restart, "certFile", "keyFile")
+	} else if cfg.CertFile != "" && cfg.KeyFile != "" {
+		err := serverCert.load(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		current.CertFile = cfg.CertFile
+		current.KeyFile = cfg.KeyFile
+	}
+
+	atomic.StoreInt64(&maxUploadMemory, int64(cfg.MaxUploadMemoryMB)<<20)
+	current.MaxUploadMemoryMB = cfg.MaxUploadMemoryMB
+
+	current.HealthTimeout, current.healthTimeout = cfg.HealthTimeout, cfg.healthTimeout
+	current.DrainDelay, current.drainDelay = cfg.DrainDelay, cfg.drainDelay
+
+	setAccessLog(cfg.AccessLog)
+	current.AccessLog = cfg.AccessLog
+
+	if cfg.Address != current.Address {
+		restart = append(restart, "address")
+	}
+	if cfg.ReadTimeout != current.ReadTimeout {
+		restart = append(restart, "readTimeout")
+	}
+	if cfg.WriteTimeout != current.WriteTimeout {
+		restart = append(restart, "writeTimeout")
+	}
+	if cfg.MaxHeaderBytes != current.MaxHeaderBytes {
+		restart = append(restart, "maxHeaderBytes")
+	}
+	if cfg.MinTLSVersion != current.MinTLSVersion {
+		restart = append(restart, "minTLSVersion")
+	}
+	if cfg.AdminAddress != current.AdminAddress {
+		restart = append(restart, "adminAddress")
+	}
+
+	return restart, nil
+}
@@ -0,0 +1,162 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package web
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"git.townsourced.com/townsourced/httprouter"
+	"git.townsourced.com/townsourced/logrus"
+	"github.com/timshannon/townsourced/app"
+	"github.com/timshannon/townsourced/metrics"
+)
+
+// requestIDHeader is accepted from clients and load balancers, and echoed back on every response
+const requestIDHeader = "X-Request-ID"
+
+var (
+	httpRequests = metrics.NewCounter("townsourced_http_requests_total",
+		"Number of HTTP requests handled, by route, method and status code.", "route", "method", "status")
+	httpDuration = metrics.NewHistogram("townsourced_http_request_duration_seconds",
+		"Latency of HTTP requests in seconds, by route and method.", nil, "route", "method")
+)
+
+// accessLog writes one JSON line per request to stdout.  It's kept separate from the standard logger so that
+// access lines aren't written to the database log by the LogHook
+var accessLog = &logrus.Logger{
+	Out:       os.Stdout,
+	Formatter: &logrus.JSONFormatter{},
+	Hooks:     make(logrus.LevelHooks),
+	Level:     logrus.InfoLevel,
+}
+
+// accessLogEnabled is accessed atomically, as it can be reloaded while the server is running
+var accessLogEnabled = int32(1)
+
+func setAccessLog(enabled bool) {
+	if enabled {
+		atomic.StoreInt32(&accessLogEnabled, 1)
+	} else {
+		atomic.StoreInt32(&accessLogEnabled, 0)
+	}
+}
+
+// requestID accepts the request ID passed in by the client or load balancer, or generates a new one, and echoes
+// it in the response
+func requestID(w http.ResponseWriter, r *http.Request) string {
+	id := app.NewRequestID(r.Header.Get(requestIDHeader))
+	w.Header().Set(requestIDHeader, id)
+	return id
+}
+
+// requestLog returns a log entry with the ID of the request the response is for
+func requestLog(w http.ResponseWriter) *logrus.Entry {
+	return logrus.WithField(app.RequestIDField, w.Header().Get(requestIDHeader))
+}
+
+// statusWriter records the status code and number of bytes written to the response
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (s *statusWriter) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusWriter) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.bytes += n
+	return n, err
+}
+
+// recordStatus wraps the response writer to record its status code.  Template writers are wrapped underneath, so
+// handlers can still get at their template
+func recordStatus(w http.ResponseWriter) (http.ResponseWriter, *statusWriter) {
+	if t, ok := w.(*templateWriter); ok {
+		s := &statusWriter{ResponseWriter: t.ResponseWriter}
+		t.ResponseWriter = s
+		return t, s
+	}
+	s := &statusWriter{ResponseWriter: w}
+	return s, s
+}
+
+// observeRequest records the count and latency of a request by its route, rather than its path, so that the
+// number of series stays bounded, and writes the request to the access log.  If the handler panicked before
+// writing a response, the request is recorded as a 500
+func observeRequest(r *http.Request, c *context, s *statusWriter, start time.Time, completed bool) {
+	status := s.status
+	if status == 0 {
+		status = http.StatusOK
+		if !completed {
+			status = http.StatusInternalServerError
+		}
+	}
+	route := routePattern(r.URL.Path, c.params)
+	duration := time.Since(start)
+
+	httpDuration.Observe(duration.Seconds(), route, r.Method)
+	httpRequests.Inc(route, r.Method, strconv.Itoa(status))
+
+	if atomic.LoadInt32(&accessLogEnabled) == 0 {
+		return
+	}
+
+	user := ""
+	if c.session != nil {
+		user = string(c.session.UserKey)
+	}
+
+	accessLog.WithFields(logrus.Fields{
+		app.RequestIDField: c.requestID,
+		"method":           r.Method,
+		"path":             r.URL.Path,
+		"route":            route,
+		"status":           status,
+		"bytes":            s.bytes,
+		"duration":         duration.Seconds(),
+		"user":             user,
+		"ip":               ipAddress(r),
+	}).Info("request")
+}
+
+// routePattern rebuilds the router pattern from the request path by replacing param values with their names
+//
+//	/api/v1/town/denver/ -> /api/v1/town/:town/
+func routePattern(path string, p httprouter.Params) string {
+	if len(p) == 0 {
+		return path
+	}
+
+	segments := strings.Split(path, "/")
+	next := 0
+	for i := range p {
+		if strings.HasPrefix(p[i].Value, "/") {
+			// catch all param
+			return strings.Join(segments[:len(segments)-strings.Count(p[i].Value, "/")], "/") + "/*" + p[i].Key
+		}
+		for j := next; j < len(segments); j++ {
+			if segments[j] == p[i].Value {
+				segments[j] = ":" + p[i].Key
+				next = j + 1
+				break
+			}
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
@@ -7,7 +7,6 @@ import (
 	"net/http"
 	"time"
 
-	log "git.townsourced.com/townsourced/logrus"
 	"github.com/timshannon/townsourced/app"
 	"github.com/timshannon/townsourced/data"
 )
@@ -19,7 +18,7 @@ func rootTemplate(w http.ResponseWriter, r *http.Request, c context) {
 	if demoMode {
 		err := w.(*templateWriter).execute("DEMO", nil)
 		if err != nil {
-			log.Errorf("Error executing DEMO template: %s", err)
+			requestLog(w).Errorf("Error executing DEMO template: %s", err)
 		}
 		return
 	}
@@ -50,7 +49,7 @@ func rootTemplate(w http.ResponseWriter, r *http.Request, c context) {
 			Towns: towns,
 		})
 		if err != nil {
-			log.Errorf("Error executing public template: %s", err)
+			requestLog(w).Errorf("Error executing public template: %s", err)
 		}
 		return
 	}
@@ -103,6 +102,6 @@ func rootTemplate(w http.ResponseWriter, r *http.Request, c context) {
 	})
 
 	if err != nil {
-		log.Errorf("Error executing ROOT template: %s", err)
+		requestLog(w).Errorf("Error executing ROOT template: %s", err)
 	}
 }
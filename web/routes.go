@@ -199,6 +199,25 @@ func wwwRoutes() http.Handler {
 
 	//API
 
+	//admin
+	rootHandler.GET("/api/v1/admin/log/", makeHandle(adminLogGet))
+	rootHandler.GET("/api/v1/admin/log/groups/", makeHandle(adminLogGetGroups))
+	rootHandler.GET("/api/v1/admin/log/export/", makeHandle(adminLogExport))
+	rootHandler.GET("/api/v1/admin/audit/", makeHandle(adminAuditGet))
+	rootHandler.GET("/api/v1/admin/queue/", makeHandle(adminModQueueGet))
+	rootHandler.GET("/api/v1/admin/commentqueue/", makeHandle(adminCommentQueueGet))
+	//	user suspensions and bans
+	rootHandler.POST("/api/v1/admin/user/:user/suspension/", makeHandle(adminPostUserSuspension))
+	rootHandler.DELETE("/api/v1/admin/user/:user/suspension/", makeHandle(adminDeleteUserSuspension))
+	//	site roles
+	rootHandler.GET("/api/v1/admin/role/", makeHandle(adminStaffGet))
+	rootHandler.POST("/api/v1/admin/user/:user/role/", makeHandle(adminPostUserRole))
+	rootHandler.DELETE("/api/v1/admin/user/:user/role/", makeHandle(adminDeleteUserRole))
+	//	site wide blocked link domains
+	rootHandler.GET("/api/v1/admin/domain/", makeHandle(adminBlockedDomainsGet))
+	rootHandler.POST("/api/v1/admin/domain/", makeHandle(adminPostBlockedDomain))
+	rootHandler.DELETE("/api/v1/admin/domain/", makeHandle(adminDeleteBlockedDomain))
+
 	//town
 	rootHandler.GET("/api/v1/town/", makeHandle(townSearch))
 	rootHandler.POST("/api/v1/town/", makeHandle(townPostNew))
@@ -225,7 +244,7 @@ func wwwRoutes() http.Handler {
 	//	automod category
 	rootHandler.POST("/api/v1/town/:town/automod/category", makeHandle(townPostAutoModCategory))
 	rootHandler.DELETE("/api/v1/town/:town/automod/category", makeHandle(townDeleteAutoModCategory))
-	//	automod minUserDays, maxNumLinks
+	//	automod minUserDays, maxNumLinks, duplicates, rule actions
 	rootHandler.PUT("/api/v1/town/:town/automod", makeHandle(townPutAutoMod))
 	//	automod users
 	rootHandler.POST("/api/v1/town/:town/automod/user", makeHandle(townPostAutoModUser))
@@ -233,6 +252,32 @@ func wwwRoutes() http.Handler {
 	//	automod regexp
 	rootHandler.POST("/api/v1/town/:town/automod/regexp", makeHandle(townPostAutoModRegexp))
 	rootHandler.DELETE("/api/v1/town/:town/automod/regexp", makeHandle(townDeleteAutoModRegexp))
+	//	automod trusted users
+	rootHandler.POST("/api/v1/town/:town/automod/trusted", makeHandle(townPostAutoModTrusted))
+	rootHandler.DELETE("/api/v1/town/:town/automod/trusted", makeHandle(townDeleteAutoModTrusted))
+	//	automod quotas
+	rootHandler.POST("/api/v1/town/:town/automod/quota", makeHandle(townPostAutoModQuota))
+	rootHandler.DELETE("/api/v1/town/:town/automod/quota", makeHandle(townDeleteAutoModQuota))
+	//	automod link domains
+	rootHandler.POST("/api/v1/town/:town/automod/domain", makeHandle(townPostAutoModDomain))
+	rootHandler.DELETE("/api/v1/town/:town/automod/domain", makeHandle(townDeleteAutoModDomain))
+	//	automod dry run
+	rootHandler.POST("/api/v1/town/:town/automod/dryrun", makeHandle(townPostAutoModDryRun))
+	//	comment moderator minUserDays, maxNumLinks
+	rootHandler.PUT("/api/v1/town/:town/commentmod", makeHandle(townPutCommentMod))
+	//	comment moderator users
+	rootHandler.POST("/api/v1/town/:town/commentmod/user", makeHandle(townPostCommentModUser))
+	rootHandler.DELETE("/api/v1/town/:town/commentmod/user", makeHandle(townDeleteCommentModUser))
+	//	comment moderator regexp
+	rootHandler.POST("/api/v1/town/:town/commentmod/regexp", makeHandle(townPostCommentModRegexp))
+	rootHandler.DELETE("/api/v1/town/:town/commentmod/regexp", makeHandle(townDeleteCommentModRegexp))
+	//	moderation queue
+	rootHandler.GET("/api/v1/town/:town/queue/", makeHandle(townGetQueue))
+	rootHandler.PUT("/api/v1/town/:town/queue/:post", makeHandle(townPutQueue))
+	rootHandler.GET("/api/v1/town/:town/commentqueue/", makeHandle(townGetCommentQueue))
+	//	moderation log
+	rootHandler.GET("/api/v1/town/:town/log/", makeHandle(townGetLog))
+	rootHandler.GET("/api/v1/town/:town/log/export/", makeHandle(townGetLogExport))
 
 	//	Posts
 	rootHandler.GET("/api/v1/posts/", makeHandle(postsGet))
@@ -259,7 +304,20 @@ func wwwRoutes() http.Handler {
 	rootHandler.DELETE("/api/v1/user/:user/post/saved/:post", makeHandle(userDeleteSavedPost))
 	//	user comments
 	rootHandler.GET("/api/v1/user/:user/comment/", makeHandle(userGetComments))
+	//	user block list
+	rootHandler.GET("/api/v1/user/:user/blocked/", makeHandle(userGetBlocked))
+	rootHandler.POST("/api/v1/user/:user/blocked/:blocked", makeHandle(userPostBlocked))
+	rootHandler.DELETE("/api/v1/user/:user/blocked/:blocked", makeHandle(userDeleteBlocked))
+	//	user ratings
+	rootHandler.GET("/api/v1/user/:user/rating/", makeHandle(userGetRatings))
+	rootHandler.POST("/api/v1/user/:user/rating/", makeHandle(userPostRating))
+	rootHandler.DELETE("/api/v1/user/:user/rating/", makeHandle(userDeleteRating))
 	//	user email confirmation
+	rootHandler.POST("/api/v1/user/:user/twofactor/", makeHandle(userPostTwoFactor))
+	rootHandler.PUT("/api/v1/user/:user/twofactor/", makeHandle(userPutTwoFactor))
+	rootHandler.DELETE("/api/v1/user/:user/twofactor/", makeHandle(userDeleteTwoFactor))
+	rootHandler.POST("/api/v1/user/:user/twofactor/recovery/", makeHandle(userPostTwoFactorRecovery))
+
 	rootHandler.PUT("/api/v1/user/:user/confirmemail", makeHandle(userConfirmEmail))
 	//	user match search
 	rootHandler.GET("/api/v1/user/", makeHandle(userGetMatch))
@@ -270,6 +328,7 @@ func wwwRoutes() http.Handler {
 	//sessions and 3rd party
 	rootHandler.POST("/api/v1/session/", makeHandle(sessionPost))
 	rootHandler.DELETE("/api/v1/session/", makeHandle(sessionDelete))
+	rootHandler.POST("/api/v1/session/twofactor/", makeHandle(sessionTwoFactorPost))
 
 	rootHandler.GET("/api/v1/session/3rdparty/", makeHandle(thirdPartyGet))
 	rootHandler.POST("/api/v1/session/3rdparty/", makeHandle(thirdPartyPost))
@@ -300,7 +359,8 @@ func wwwRoutes() http.Handler {
 	rootHandler.GET("/api/v1/post/:post/comment/:comment", makeHandle(commentGet))
 	rootHandler.POST("/api/v1/post/:post/comment/", makeHandle(commentPost))
 	rootHandler.POST("/api/v1/post/:post/comment/:comment", makeHandle(commentPost))
-	//rootHandler.PUT("/api/v1/post/:post/comments/:comment", makeHandle(commentPut)) // updating comments?
+	rootHandler.PUT("/api/v1/post/:post/comment/:comment", makeHandle(commentPut))
+	rootHandler.DELETE("/api/v1/post/:post/comment/:comment", makeHandle(commentDelete))
 
 	//search
 	rootHandler.GET("/api/v1/search", makeHandle(postSearchGet))
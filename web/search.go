@@ -7,7 +7,6 @@ import (
 	"net/http"
 	"strconv"
 
-	log "git.townsourced.com/townsourced/logrus"
 	"github.com/timshannon/townsourced/app"
 	"github.com/timshannon/townsourced/data"
 	"github.com/timshannon/townsourced/data/private"
@@ -55,7 +54,7 @@ func searchTemplate(w http.ResponseWriter, r *http.Request, c context) {
 
 	err = w.(*templateWriter).execute("SEARCH", result)
 	if err != nil {
-		log.Errorf("Error executing SEARCH template: %s", err)
+		requestLog(w).Errorf("Error executing SEARCH template: %s", err)
 	}
 }
 
@@ -83,7 +82,7 @@ func searchLocationTemplate(w http.ResponseWriter, r *http.Request, c context) {
 
 	err = w.(*templateWriter).execute("SEARCHLOCATION", result)
 	if err != nil {
-		log.Errorf("Error executing SEARCHLOCATION template: %s", err)
+		requestLog(w).Errorf("Error executing SEARCHLOCATION template: %s", err)
 	}
 }
 
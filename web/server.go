@@ -6,12 +6,14 @@ package web
 import (
 	"bytes"
 	"compress/gzip"
+	stdcontext "context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -19,6 +21,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"text/template"
 	"time"
 
@@ -48,8 +51,9 @@ var (
 	isSSL           = false
 	subDomainForce  = ""
 	canonicalHost   = ""
-	maxUploadMemory = int64(10 << 20)
+	maxUploadMemory = int64(10 << 20) // accessed atomically, as it can be reloaded while the server is running
 	zipPool         sync.Pool
+	servers         serverList
 )
 
 // Config are the config values for
@@ -65,6 +69,13 @@ type Config struct {
 	KeyFile           string `json:"keyFile"`
 	Address           string `json:"address"`
 	MaxUploadMemoryMB int    `json:"maxUploadMemoryMB"`
+	ShutdownTimeout   string `json:"shutdownTimeout"`
+	AdminAddress      string `json:"adminAddress"` // listen address for /metrics and health checks, empty disables it
+	HealthTimeout     string `json:"healthTimeout"`
+	healthTimeout     time.Duration
+	DrainDelay        string `json:"drainDelay"`
+	drainDelay        time.Duration
+	AccessLog         bool `json:"accessLog"` // write a JSON line to stdout for every request
 
 	DevMode   bool   `json:"-"`
 	DemoMode  bool   `json:"-"`
@@ -80,10 +91,15 @@ func DefaultConfig() *Config {
 		ReadTimeout:       "60s",
 		WriteTimeout:      "60s",
 		MaxUploadMemoryMB: 10, //10MB default
+		ShutdownTimeout:   "30s",
+		AdminAddress:      "127.0.0.1:8081",
+		HealthTimeout:     "2s",
+		DrainDelay:        "5s",
+		AccessLog:         true,
 	}
 }
 
-// StartServer Starts the townsourced webserver
+// StartServer Starts the townsourced webserver, and blocks until the server fails or is shutdown
 func StartServer(cfg *Config) error {
 	devMode = cfg.DevMode
 	demoMode = cfg.DemoMode
@@ -92,7 +108,8 @@ func StartServer(cfg *Config) error {
 		subDomainForce = cfg.SubDomain
 	}
 
-	maxUploadMemory = int64(cfg.MaxUploadMemoryMB) << 20
+	atomic.StoreInt64(&maxUploadMemory, int64(cfg.MaxUploadMemoryMB)<<20)
+	setAccessLog(cfg.AccessLog)
 
 	urlAddress, err := url.Parse(cfg.Address)
 	serverAddr := urlAddress.Host
@@ -120,15 +137,13 @@ func StartServer(cfg *Config) error {
 	handler := setRoutes()
 	logrus.Debugf("Web Routes initialized")
 
-	cfg.readTimeout, err = time.ParseDuration(cfg.ReadTimeout)
+	err = cfg.parseTimeouts()
 	if err != nil {
-		return fmt.Errorf("Error parsing web ReadTimeout: %s", err)
-	}
-	cfg.writeTimeout, err = time.ParseDuration(cfg.WriteTimeout)
-	if err != nil {
-		return fmt.Errorf("Error parsing web WriteTimeout: %s", err)
+		return err
 	}
 
+	startAdminServer(cfg)
+
 	tlsCFG := &tls.Config{MinVersion: cfg.MinTLSVersion}
 	server := &http.Server{
 		Handler:        handler,
@@ -138,6 +153,9 @@ func StartServer(cfg *Config) error {
 		ErrorLog:       log.New(logrus.StandardLogger().Writer(), "", log.LstdFlags),
 	}
 
+	servers.add(server)
+	setRunning(cfg)
+
 	fmt.Printf("Townsourced Web Server running at %s\n", serverAddr)
 
 	if cfg.CertFile == "" || cfg.KeyFile == "" {
@@ -150,18 +168,139 @@ func StartServer(cfg *Config) error {
 
 		isSSL = true
 
+		// certificates are loaded through GetCertificate so they can be reloaded without restarting
+		err = serverCert.load(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return err
+		}
+		tlsCFG.GetCertificate = serverCert.get
 		server.TLSConfig = tlsCFG
 
 		server.Addr = serverAddr
-		err = server.ListenAndServeTLS(cfg.CertFile, cfg.KeyFile)
+		err = server.ListenAndServeTLS("", "")
 	}
-	if err != nil {
+	if err != nil && err != http.ErrServerClosed {
 		return err
 	}
 
 	return nil
 }
 
+func (c *Config) parseTimeouts() error {
+	var err error
+	c.readTimeout, err = time.ParseDuration(c.ReadTimeout)
+	if err != nil {
+		return fmt.Errorf("Error parsing web ReadTimeout: %s", err)
+	}
+	c.writeTimeout, err = time.ParseDuration(c.WriteTimeout)
+	if err != nil {
+		return fmt.Errorf("Error parsing web WriteTimeout: %s", err)
+	}
+	c.healthTimeout, err = time.ParseDuration(c.HealthTimeout)
+	if err != nil {
+		return fmt.Errorf("Error parsing web HealthTimeout: %s", err)
+	}
+	if c.healthTimeout <= 0 {
+		return fmt.Errorf("Web HealthTimeout must be greater than 0")
+	}
+	c.drainDelay, err = time.ParseDuration(c.DrainDelay)
+	if err != nil {
+		return fmt.Errorf("Error parsing web DrainDelay: %s", err)
+	}
+	return nil
+}
+
+// CheckConfig validates the web config without starting the server
+func CheckConfig(cfg *Config) []error {
+	var errs []error
+
+	urlAddress, err := url.Parse(cfg.Address)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Error parsing web Address: %s", err))
+	} else if urlAddress.Scheme != "http" && urlAddress.Scheme != "https" {
+		errs = append(errs, fmt.Errorf("Invalid Address scheme in Web Config: %s", urlAddress.Scheme))
+	}
+
+	err = cfg.parseTimeouts()
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	_, err = time.ParseDuration(cfg.ShutdownTimeout)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Error parsing web ShutdownTimeout: %s", err))
+	}
+
+	if cfg.MaxUploadMemoryMB <= 0 {
+		errs = append(errs, fmt.Errorf("Web MaxUploadMemoryMB must be greater than 0"))
+	}
+
+	if cfg.AdminAddress != "" {
+		_, _, err = net.SplitHostPort(cfg.AdminAddress)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Invalid web AdminAddress: %s", err))
+		}
+	}
+
+	if cfg.MinTLSVersion < tls.VersionTLS10 {
+		errs = append(errs, fmt.Errorf("Invalid web MinTLSVersion %d, must be at least TLS 1.0 (%d)",
+			cfg.MinTLSVersion, tls.VersionTLS10))
+	}
+
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		errs = append(errs, fmt.Errorf("Both web CertFile and KeyFile must be set to use TLS"))
+	} else if cfg.CertFile != "" {
+		_, err = tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Error loading web TLS certificate: %s", err))
+		}
+	}
+
+	return errs
+}
+
+// Shutdown fails readiness checks for the drain delay, then stops the webserver from accepting new connections,
+// and waits for in-flight requests to finish, or until the context is done
+func Shutdown(ctx stdcontext.Context) error {
+	drain(ctx)
+	return servers.shutdown(ctx)
+}
+
+type serverList struct {
+	sync.Mutex
+	servers []*http.Server
+}
+
+func (s *serverList) add(server *http.Server) {
+	s.Lock()
+	defer s.Unlock()
+	s.servers = append(s.servers, server)
+}
+
+func (s *serverList) shutdown(ctx stdcontext.Context) error {
+	s.Lock()
+	defer s.Unlock()
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(s.servers))
+
+	for i := range s.servers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = s.servers[i].Shutdown(ctx)
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range errs {
+		if errs[i] != nil {
+			return errs[i]
+		}
+	}
+	return nil
+}
+
 type gzipResponse struct {
 	zip *gzip.Writer
 	http.ResponseWriter
@@ -207,8 +346,9 @@ func standardHeaders(w http.ResponseWriter) {
 }
 
 type context struct {
-	params  httprouter.Params
-	session *app.Session
+	params    httprouter.Params
+	session   *app.Session
+	requestID string
 }
 
 type tsHandlerFunc func(http.ResponseWriter, *http.Request, context)
@@ -228,11 +368,21 @@ func makeNoZipHandle(tsFunc tsHandlerFunc) httprouter.Handle {
 }
 
 func tsPreHandle(w http.ResponseWriter, r *http.Request, p httprouter.Params, tsFunc tsHandlerFunc) {
-	s, err := session(r)
+	start := time.Now()
+	w, status := recordStatus(w)
 	c := context{
-		params:  p,
-		session: s,
+		params:    p,
+		requestID: requestID(w, r),
 	}
+
+	completed := false
+	defer func() {
+		observeRequest(r, &c, status, start, completed)
+	}()
+	r = r.WithContext(app.WithRequestID(r.Context(), c.requestID))
+
+	s, err := session(r)
+	c.session = s
 	if errHandled(err, w, r, c) {
 		return
 	}
@@ -242,12 +392,12 @@ func tsPreHandle(w http.ResponseWriter, r *http.Request, p httprouter.Params, ts
 			return
 		}
 		// if user is logged in rate-limit based on userkey not ip address
-		if errHandled(app.AttemptRequest(string(s.UserKey), app.RequestType{}), w, r, c) {
+		if errHandled(app.AttemptRequest(r.Context(), string(s.UserKey), app.RequestType{}), w, r, c) {
 			return
 		}
 	} else {
 		//if not logged in access, rate limit based on IP
-		if errHandled(app.AttemptRequest(ipAddress(r), app.RequestType{}), w, r, c) {
+		if errHandled(app.AttemptRequest(r.Context(), ipAddress(r), app.RequestType{}), w, r, c) {
 			return
 		}
 	}
@@ -255,6 +405,7 @@ func tsPreHandle(w http.ResponseWriter, r *http.Request, p httprouter.Params, ts
 	standardHeaders(w)
 
 	tsFunc(w, r, c)
+	completed = true
 }
 
 // Server side templates should only be used
@@ -7,7 +7,6 @@ import (
 	"net/http"
 	"time"
 
-	log "git.townsourced.com/townsourced/logrus"
 	"github.com/timshannon/townsourced/app"
 	"github.com/timshannon/townsourced/fail"
 )
@@ -25,6 +24,15 @@ var userLogonRequest = app.RequestType{
 	MaxWait:      1 * time.Minute,
 }
 
+// rate limit two-factor authentication codes
+var userTwoFactorRequest = app.RequestType{
+	Type:         "twoFactor",
+	FreeAttempts: 5,
+	Scale:        5 * time.Second,
+	Range:        5 * time.Minute,
+	MaxWait:      1 * time.Minute,
+}
+
 // get a session from the request
 func session(r *http.Request) (*app.Session, error) {
 	// must iter through all cookies because you can have
@@ -97,6 +105,33 @@ func setSessionCookie(w http.ResponseWriter, r *http.Request, u *app.User, remem
 	return nil
 }
 
+// respondLogin starts a session for the user who just logged in, unless they have two-factor authentication
+// enabled, in which case the token for the second step of their login, sessionTwoFactorPost, is returned instead
+func respondLogin(w http.ResponseWriter, r *http.Request, c context, u *app.User, rememberMe bool) {
+	if u.TwoFactor {
+		token, err := u.TwoFactorLoginNew(rememberMe)
+		if errHandled(err, w, r, c) {
+			return
+		}
+
+		respondJsend(w, &JSend{
+			Status: statusSuccess,
+			Data: map[string]string{
+				"twoFactorToken": token,
+			},
+		})
+		return
+	}
+
+	if errHandled(setSessionCookie(w, r, u, rememberMe), w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+	})
+}
+
 func expireSessionCookie(w http.ResponseWriter, r *http.Request, s *app.Session) {
 	cookie, err := r.Cookie(cookieName)
 
@@ -125,10 +160,12 @@ type sessionInput struct {
 func sessionPost(w http.ResponseWriter, r *http.Request, c context) {
 	if c.session != nil {
 		//If previous session still exists, log out so it can't be used again
+		logger := requestLog(w)
 		go func(session *app.Session) {
 			err := session.Logout()
 			if err != nil {
-				log.WithField("session", session).Error("Error logging out session when trying to log into a new session")
+				logger.WithField("session", session).
+					Error("Error logging out session when trying to log into a new session")
 			}
 		}(c.session)
 	}
@@ -159,7 +196,7 @@ func sessionPost(w http.ResponseWriter, r *http.Request, c context) {
 	}
 
 	// rate limit login requests
-	if errHandled(app.AttemptRequest(ipAddress(r), userLogonRequest), w, r, c) {
+	if errHandled(app.AttemptRequest(r.Context(), ipAddress(r), userLogonRequest), w, r, c) {
 		return
 	}
 
@@ -168,7 +205,41 @@ func sessionPost(w http.ResponseWriter, r *http.Request, c context) {
 		return
 	}
 
-	if errHandled(setSessionCookie(w, r, u, input.RememberMe), w, r, c) {
+	respondLogin(w, r, c, u, input.RememberMe)
+}
+
+type sessionTwoFactorInput struct {
+	Token string `json:"token,omitempty"`
+	Code  string `json:"code,omitempty"`
+}
+
+// second step of logging in users with two-factor authentication
+func sessionTwoFactorPost(w http.ResponseWriter, r *http.Request, c context) {
+	input := &sessionTwoFactorInput{}
+	err := parseInput(r, input)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	if input.Token == "" || input.Code == "" {
+		errHandled(fail.New("A login token and a two-factor authentication code are required"), w, r, c)
+		return
+	}
+
+	// rate limit by the login token as well, so changing ip addresses doesn't allow more attempts
+	if errHandled(app.AttemptRequest(r.Context(), ipAddress(r), userTwoFactorRequest), w, r, c) {
+		return
+	}
+	if errHandled(app.AttemptRequest(r.Context(), input.Token, userTwoFactorRequest), w, r, c) {
+		return
+	}
+
+	u, rememberMe, err := app.UserLoginTwoFactor(input.Token, input.Code)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	if errHandled(setSessionCookie(w, r, u, rememberMe), w, r, c) {
 		return
 	}
 
@@ -7,7 +7,6 @@ import (
 	"net/http"
 	"time"
 
-	log "git.townsourced.com/townsourced/logrus"
 	"github.com/timshannon/townsourced/app"
 	"github.com/timshannon/townsourced/data"
 	"github.com/timshannon/townsourced/fail"
@@ -46,7 +45,7 @@ func shareTemplate(w http.ResponseWriter, r *http.Request, c context) {
 	}
 
 	//Rate limit shares
-	if errHandledPage(app.AttemptRequest(string(c.session.UserKey), shareRequestType), w, r, c) {
+	if errHandledPage(app.AttemptRequest(r.Context(), string(c.session.UserKey), shareRequestType), w, r, c) {
 		return
 	}
 
@@ -58,7 +57,7 @@ func shareTemplate(w http.ResponseWriter, r *http.Request, c context) {
 		imageKeys = append(imageKeys, data.ToUUID(k))
 	}
 
-	post, err := app.Share(u, values.Get("url"), values.Get("title"), values.Get("content"), r.UserAgent(),
+	post, err := app.Share(r.Context(), u, values.Get("url"), values.Get("title"), values.Get("content"), r.UserAgent(),
 		data.Key(values.Get("town")), values["image"], imageKeys, values.Get("selector"))
 
 	shareError := false
@@ -81,7 +80,7 @@ func shareTemplate(w http.ResponseWriter, r *http.Request, c context) {
 		ShareError: shareError,
 	})
 	if err != nil {
-		log.Errorf("Error executing editpost template: %s", err)
+		requestLog(w).Errorf("Error executing editpost template: %s", err)
 	}
 
 }
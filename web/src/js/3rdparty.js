@@ -19,7 +19,7 @@ import {
 from "./ts/error";
 
 import {
-    get3rdPartyState, validateEmail as userValidateEmail, validateNew
+    get3rdPartyState, validateEmail as userValidateEmail, validateNew, loginTwoFactor
 }
 from "./ts/user";
 
@@ -122,9 +122,32 @@ $(document).ready(function() {
                     }
 
                 });
-        }
+        },
+        "twoFactor": function(event) {
+            event.original.preventDefault();
+            r.set("twoFactorErr", null);
+
+            loginTwoFactor(r.get("twoFactorToken"), r.get("twoFactorCode"))
+                .done(function() {
+                    window.location = r.get("returnURL");
+                })
+                .fail(function(result) {
+                    r.set("twoFactorErr", err(result).message);
+                });
+        },
     });
 
+    // loggedIn returns the user to where they started logging in, unless they need to enter a two-factor
+    // authentication code first
+    function loggedIn(result) {
+        if (result.data && result.data.twoFactorToken) {
+            r.set("twoFactorToken", result.data.twoFactorToken);
+            $("#twoFactorCode").focus();
+            return;
+        }
+        window.location = r.get("returnURL");
+    }
+
     function validateUsername() {
         r.set("usernameErr", null);
         validateNew(r.get("username"))
@@ -148,8 +171,7 @@ $(document).ready(function() {
     function facebook(code) {
         Facebook.getSession(code)
             .done(function(result) {
-                window.location = r.get("returnURL");
-                return;
+                loggedIn(result);
             })
             .fail(function(result) {
                 if (result.responseJSON && result.responseJSON.message == "Username needed") {
@@ -172,8 +194,7 @@ $(document).ready(function() {
     function google(code) {
         Google.getSession(code)
             .done(function(result) {
-                window.location = r.get("returnURL");
-                return;
+                loggedIn(result);
             })
             .fail(function(result) {
                 if (result.responseJSON && result.responseJSON.message == "Username needed") {
@@ -196,8 +217,7 @@ $(document).ready(function() {
     function twitter(code, token) {
         Twitter.getSession(token, code)
             .done(function(result) {
-                window.location = r.get("returnURL");
-                return;
+                loggedIn(result);
             })
             .fail(function(result) {
                 if (result.responseJSON && result.responseJSON.message == "Username needed") {
@@ -6,12 +6,25 @@ import Ractive from "./ts/ractiveInit";
 import Page from "./components/page";
 import ExpandPanel from "./components/expandPanel";
 import Chart from "./components/chart";
+import Alert from "./components/alert";
 
 //ts libs
 import {
     htmlPayload,
+    since,
+    formatDate,
 }
 from "./ts/util";
+import {
+    err
+}
+from "./ts/error";
+import * as Admin from "./ts/admin";
+import {
+    queueApprove,
+    queueModerate
+}
+from "./ts/town";
 
 // 3rd party
 $(document).ready(function() {
@@ -25,26 +38,270 @@ $(document).ready(function() {
             page: Page,
             expandPanel: ExpandPanel,
             chart: Chart,
+            alert: Alert,
         },
         data: function() {
             var stats = htmlPayload();
+            var permissions = htmlPayload("permissions") || [];
             return {
                 stats: stats,
+                can: function(permission) {
+                    return permissions.indexOf(permission) !== -1;
+                },
                 userCountTrendChart: makeDateCountChart("User Count By Day", stats.userCountTrend),
                 townCountTrendChart: makeDateCountChart("Town Count By Day", stats.townCountTrend),
                 postCountTrendChart: makeDateCountChart("Post Count By Day", stats.postCountTrend),
+                logLevels: ["panic", "fatal", "error", "warning", "info", "debug"],
+                logFilter: {
+                    levels: ["panic", "fatal", "error"],
+                },
+                logQueryFilter: {},
+                logEntries: [],
+                logGroups: [],
+                since: since,
+                formatDate: formatDate,
+                logExportURL: Admin.logExportURL,
+                modUser: {
+                    action: "suspend",
+                },
+                auditEntries: [],
+                roles: ["admin", "support", "reviewer"],
+                roleUser: {
+                    role: "support",
+                },
+                staff: [],
+                queue: [],
+                blockedDomains: [],
+                blockDomain: {},
             };
         },
     });
 
+    if (r.get("can")("viewLog")) {
+        loadLog();
+    }
+    if (r.get("can")("viewAudit")) {
+        loadAudit();
+    }
+    if (r.get("can")("manageRoles")) {
+        loadStaff();
+    }
+    if (r.get("can")("reviewContent")) {
+        loadQueue();
+    }
+    if (r.get("can")("blockDomains")) {
+        loadBlockedDomains();
+    }
 
     //ractive events
     r.on({
+        "logSearch": function(event) {
+            event.original.preventDefault();
+            loadLog();
+        },
+        "logFingerprint": function(event, fingerprint) {
+            event.original.preventDefault();
+            r.set("logFilter.fingerprint", fingerprint);
+            loadLog();
+        },
+        "logClearFingerprint": function(event) {
+            event.original.preventDefault();
+            r.set("logFilter.fingerprint", null);
+            loadLog();
+        },
+        "logNext": function() {
+            loadLogEntries(r.get("logEntries").length);
+        },
+        "userModerate": function(event) {
+            event.original.preventDefault();
+            var mod = r.get("modUser");
+            var req;
+
+            r.set("userError", null);
+            r.set("userSuccess", null);
+
+            if (mod.action == "ban") {
+                req = Admin.banUser(mod.username, mod.reason, mod.hideContent);
+            } else if (mod.action == "reinstate") {
+                req = Admin.reinstateUser(mod.username, mod.reason);
+            } else {
+                if (!mod.until) {
+                    r.set("userError", "Choose when the suspension ends");
+                    return;
+                }
+                req = Admin.suspendUser(mod.username, new Date(mod.until).toISOString(), mod.reason);
+            }
+
+            req.done(function() {
+                    r.set("userSuccess", "User " + mod.username + " updated");
+                    r.set("modUser.reason", null);
+                    loadAudit();
+                })
+                .fail(function(result) {
+                    r.set("userError", err(result).message);
+                });
+        },
+        "queueApprove": function(event, i, town) {
+            event.original.preventDefault();
+            r.set("queueError", null);
+            queueApprove(town, r.get("queue." + i + ".post.key"))
+                .done(function() {
+                    loadQueue();
+                })
+                .fail(function(result) {
+                    r.set("queueError", err(result).message);
+                });
+        },
+        "queueModerate": function(event, i, town) {
+            event.original.preventDefault();
+            r.set("queueError", null);
+            var reason = r.get("queue." + i + ".reason");
+            if (!reason) {
+                r.set("queueError", "A reason is required when moderating a post");
+                return;
+            }
+            queueModerate(town, r.get("queue." + i + ".post.key"), reason)
+                .done(function() {
+                    loadQueue();
+                })
+                .fail(function(result) {
+                    r.set("queueError", err(result).message);
+                });
+        },
+        "domainBlock": function(event) {
+            event.original.preventDefault();
+            var block = r.get("blockDomain");
 
+            r.set("domainError", null);
+            Admin.blockDomain(block.domain, block.reason)
+                .done(function() {
+                    r.set("blockDomain", {});
+                    loadBlockedDomains();
+                })
+                .fail(function(result) {
+                    r.set("domainError", err(result).message);
+                });
+        },
+        "domainUnblock": function(event, domain) {
+            event.original.preventDefault();
+
+            r.set("domainError", null);
+            Admin.unblockDomain(domain, r.get("blockDomain.reason"))
+                .done(function() {
+                    loadBlockedDomains();
+                })
+                .fail(function(result) {
+                    r.set("domainError", err(result).message);
+                });
+        },
+        "roleChange": function(event, grant) {
+            event.original.preventDefault();
+            var change = r.get("roleUser");
+            var req;
+
+            r.set("roleError", null);
+            r.set("roleSuccess", null);
+
+            if (grant) {
+                req = Admin.grantRole(change.username, change.role, change.reason);
+            } else {
+                req = Admin.revokeRole(change.username, change.role, change.reason);
+            }
+
+            req.done(function() {
+                    r.set("roleSuccess", "Roles for " + change.username + " updated");
+                    r.set("roleUser.reason", null);
+                    loadStaff();
+                    if (r.get("can")("viewAudit")) {
+                        loadAudit();
+                    }
+                })
+                .fail(function(result) {
+                    r.set("roleError", err(result).message);
+                });
+        },
     });
 
     //functions
 
+    function loadQueue() {
+        Admin.modQueueGet()
+            .done(function(result) {
+                r.set("queue", result.data);
+            })
+            .fail(function(result) {
+                r.set("queueError", err(result).message);
+            });
+    }
+
+    function loadBlockedDomains() {
+        Admin.blockedDomainsGet()
+            .done(function(result) {
+                r.set("blockedDomains", result.data);
+            })
+            .fail(function(result) {
+                r.set("domainError", err(result).message);
+            });
+    }
+
+    function loadStaff() {
+        Admin.staffGet()
+            .done(function(result) {
+                r.set("staff", result.data);
+            })
+            .fail(function(result) {
+                r.set("roleError", err(result).message);
+            });
+    }
+
+    function loadAudit() {
+        Admin.auditGet()
+            .done(function(result) {
+                r.set("auditEntries", result.data);
+            })
+            .fail(function(result) {
+                r.set("userError", err(result).message);
+            });
+    }
+
+    function loadLog() {
+        var filter = $.extend({}, r.get("logFilter"));
+        if (r.get("logFieldName")) {
+            filter.fields = {};
+            filter.fields[r.get("logFieldName")] = r.get("logFieldValue");
+        }
+
+        r.set("logQueryFilter", filter);
+        r.set("logError", null);
+
+        Admin.logGroups(filter)
+            .done(function(result) {
+                r.set("logGroups", result.data);
+            })
+            .fail(function(result) {
+                r.set("logError", err(result).message);
+            });
+
+        loadLogEntries(0);
+    }
+
+    function loadLogEntries(from) {
+        Admin.logGet(r.get("logQueryFilter"), {
+                from: from,
+            })
+            .done(function(result) {
+                if (from === 0) {
+                    r.set("logEntries", result.data);
+                } else {
+                    r.push.apply(r, ["logEntries"].concat(result.data));
+                }
+                r.set("logMore", result.data.length === Admin.logLimit);
+            })
+            .fail(function(result) {
+                r.set("logError", err(result).message);
+            });
+    }
+
     function arrKey(data, key, eachFunc) {
         var result = [];
         for (var i = 0; i < data.length; i++) {
@@ -29,6 +29,8 @@ default {
         forgotPass: false,
         resetSent: false,
         redirect: null,
+        twoFactorToken: null,
+        twoFactorCode: "",
     },
     oncomplete: function() {
             "use strict";
@@ -88,6 +90,9 @@ default {
                         "signup": false,
                         "forgotPass": false,
                         "resetSent": false,
+                        "twoFactorToken": null,
+                        "twoFactorCode": "",
+                        "twoFactorErr": null,
                     });
                 },
                 signup: function(event) {
@@ -166,11 +171,13 @@ default {
 
                     User.login(r.get("username"), r.get("password"), r.get("rememberMe"))
                         .done(function(result) {
-                            if (r.get("redirect")) {
-                                window.location = r.get("redirect");
-                            } else {
-                                window.location.reload();
+                            if (result.data && result.data.twoFactorToken) {
+                                r.set("twoFactorToken", result.data.twoFactorToken);
+                                r.set("waiting", false);
+                                $("#twoFactorCode").focus();
+                                return;
                             }
+                            loggedIn();
                         })
                         .fail(function(result) {
                             result = result.responseJSON;
@@ -178,6 +185,33 @@ default {
                             r.set("waiting", false);
                         });
                 },
+                doTwoFactor: function(event) {
+                    event.original.preventDefault();
+                    r.set("waiting", true);
+                    r.set("twoFactorErr", null);
+
+                    if (!r.get("twoFactorCode")) {
+                        r.set("twoFactorErr", "You must provide a code from your authenticator app or a recovery code");
+                        r.set("waiting", false);
+                        return;
+                    }
+
+                    User.loginTwoFactor(r.get("twoFactorToken"), r.get("twoFactorCode"))
+                        .done(function() {
+                            loggedIn();
+                        })
+                        .fail(function(result) {
+                            r.set("twoFactorErr", err(result).message);
+                            r.set("waiting", false);
+                        });
+                },
+                cancelTwoFactor: function(event) {
+                    event.original.preventDefault();
+                    r.set("twoFactorToken", null);
+                    r.set("twoFactorCode", "");
+                    r.set("twoFactorErr", null);
+                    r.set("password", "");
+                },
                 loginFacebook: function() {
                     Facebook.startLogin(r.get("redirect"));
                 },
@@ -224,6 +258,14 @@ default {
                 },
             });
 
+            function loggedIn() {
+                if (r.get("redirect")) {
+                    window.location = r.get("redirect");
+                } else {
+                    window.location.reload();
+                }
+            }
+
             function validatePassword() {
                 r.set("password2Err", null);
                 if (!r.get("signup")) {
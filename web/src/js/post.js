@@ -43,6 +43,7 @@ import {
     isSavedPost,
     sendMessage,
 	listName,
+    rate,
 }
 from "./ts/user";
 
@@ -189,6 +190,41 @@ $(document).ready(function() {
         "reportHide": function() {
             r.set("reportErr", null);
         },
+        "rateModal": function(event) {
+            event.original.preventDefault();
+            var nav = r.findComponent("navbar");
+            if (!nav.get("user")) {
+                nav.fire("login", "Login to rate users");
+                return;
+            }
+
+            r.set("rateWho", null);
+            r.set("rateScore", "5");
+            r.set("rateComment", "");
+            $("#rateModal").modal();
+        },
+        "rate": function(event) {
+            event.original.preventDefault();
+            var username = r.get("post.creator");
+            if (r.get("isCreator")) {
+                username = r.get("rateWho.username");
+                if (!username) {
+                    r.set("rateErr", "You must specify who you are rating");
+                    return;
+                }
+            }
+
+            rate(username, r.get("post.key"), r.get("rateScore"), r.get("rateComment"))
+                .done(function() {
+                    $("#rateModal").modal("hide");
+                })
+                .fail(function(result) {
+                    r.set("rateErr", err(result).message);
+                });
+        },
+        "rateHide": function() {
+            r.set("rateErr", null);
+        },
         "close": function(event) {
             event.original.preventDefault();
             Post.close(r.get("post.key"), r.get("post.vertag"))
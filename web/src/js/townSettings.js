@@ -29,6 +29,7 @@ import {
     isEmail,
     escapeRegExp,
     since,
+    formatDate,
 }
 from "./ts/util";
 import {
@@ -65,6 +66,53 @@ $(document).ready(function() {
                 error: null,
                 inviteUser: null,
                 userBan: null,
+                userTrust: null,
+                quotaCategory: "",
+                quotaPeriod: "day",
+                quotaMax: 1,
+                linkDomainAllow: false,
+                autoModRules: [
+                    {
+                        rule: "categories",
+                        name: "Category",
+                    },
+                    {
+                        rule: "users",
+                        name: "Blocked users",
+                    },
+                    {
+                        rule: "minUserDays",
+                        name: "Account age",
+                    },
+                    {
+                        rule: "minUserRating",
+                        name: "User rating",
+                    },
+                    {
+                        rule: "maxNumLinks",
+                        name: "Number of links",
+                    },
+                    {
+                        rule: "domains",
+                        name: "Link domains",
+                    },
+                    {
+                        rule: "regexpReject",
+                        name: "Words and expressions",
+                    },
+                    {
+                        rule: "quotas",
+                        name: "Post quotas",
+                    },
+                    {
+                        rule: "spam",
+                        name: "Spam classifier",
+                    },
+                ],
+                holds: function(rule) {
+                    var hold = this.get("town.autoModerator.hold");
+                    return !!hold && hold.indexOf(rule) !== -1;
+                },
                 town: htmlPayload(),
                 users: {},
                 categories: categories,
@@ -80,12 +128,19 @@ $(document).ready(function() {
                 memberSortType: "date",
                 since: since,
                 invitesOriginal: [],
+                modLog: [],
+                modLogExportURL: Town.logExportURL,
+                formatDate: formatDate,
             };
         },
     });
 
     setTown(r.get("town"));
 
+    $("#logTab").on("shown.bs.tab", function() {
+        loadLog(0);
+    });
+
     //ractive events
     r.on({
         "navbar.userLoaded": function(currentUser) {
@@ -101,6 +156,9 @@ $(document).ready(function() {
         "back": function() {
             window.location = "/town/" + r.get("town.key");
         },
+        "logNext": function() {
+            loadLog(r.get("modLog").length);
+        },
         "header.imageModal": function(event) {
             $("#imageModal").modal();
             r.findComponent("imageUpload").fire("reset");
@@ -333,15 +391,22 @@ $(document).ready(function() {
         },
         "autoModUserModal": function() {
             r.set("minUserDaysValue", r.get("town.autoModerator.minUserDays"));
+            r.set("minUserRatingValue", r.get("town.autoModerator.minUserRating") || 0);
             $("#autoModUserModal").modal();
         },
         "autoModUserModalHide": function() {
             r.set("autoModUserErr", null);
             r.set("userDaysErr", null);
+            r.set("userRatingErr", null);
             r.set("banUserErr", null);
+            r.set("trustUserErr", null);
+            r.set("quotaErr", null);
         },
         "autoModContentModal": function() {
             r.set("maxNumLinksValue", r.get("town.autoModerator.maxNumLinks"));
+            r.set("duplicateThreshold", r.get("town.autoModerator.duplicates.threshold") || 0);
+            r.set("duplicateAction", r.get("town.autoModerator.duplicates.action") || "flag");
+            r.set("spamThreshold", r.get("town.autoModerator.spamThreshold") || 0);
             $("#autoModContentModal").modal();
         },
         "autoModContentModalHide": function() {
@@ -352,6 +417,11 @@ $(document).ready(function() {
             r.set("regexpReason", null);
             r.set("regexpErr", null);
             r.set("reasonErr", null);
+            r.set("duplicatesErr", null);
+            r.set("spamThresholdErr", null);
+            r.set("dryRun", null);
+            r.set("domainErr", null);
+            r.set("linkDomain", null);
         },
         "addCategory": function(event, category) {
             Town.addAutoModCategory(r.get("town.key"), category)
@@ -385,6 +455,17 @@ $(document).ready(function() {
                     r.set("userDaysErr", err(result).message);
                 });
         },
+        "setMinUserRating": function(event) {
+            event.original.preventDefault();
+            Town.setAutoModMinUserRating(r.get("town.key"), r.get("minUserRatingValue"))
+                .done(function() {
+                    loadTown();
+                    r.set("userRatingErr", null);
+                })
+                .fail(function(result) {
+                    r.set("userRatingErr", err(result).message);
+                });
+        },
         "banUser": function(user) {
             Town.addAutoModUser(r.get("town.key"), user.username)
                 .done(function() {
@@ -407,6 +488,175 @@ $(document).ready(function() {
                     r.set("autoModUserErr", err(result).message);
                 });
         },
+        "trustUser": function(user) {
+            Town.addAutoModTrusted(r.get("town.key"), user.username)
+                .done(function() {
+                    r.set("trustUserErr", null);
+                    r.set("userTrust", null);
+                    loadTown();
+                })
+                .fail(function(result) {
+                    r.set("trustUserErr", err(result).message);
+                });
+        },
+        "removeTrust": function(event) {
+            Town.removeAutoModTrusted(r.get("town.key"), event.context)
+                .done(function() {
+                    r.set("autoModUserErr", null);
+                    loadTown();
+                })
+                .fail(function(result) {
+                    r.set("autoModUserErr", err(result).message);
+                });
+        },
+        "setQuota": function(event) {
+            event.original.preventDefault();
+            Town.setAutoModQuota(r.get("town.key"), r.get("quotaCategory"), r.get("quotaPeriod"),
+                    Number(r.get("quotaMax")))
+                .done(function() {
+                    r.set("quotaErr", null);
+                    loadTown();
+                })
+                .fail(function(result) {
+                    r.set("quotaErr", err(result).message);
+                });
+        },
+        "removeQuota": function(event) {
+            Town.removeAutoModQuota(r.get("town.key"), event.context.category, event.context.period)
+                .done(function() {
+                    r.set("autoModUserErr", null);
+                    loadTown();
+                })
+                .fail(function(result) {
+                    r.set("autoModUserErr", err(result).message);
+                });
+        },
+        "addDomain": function(event) {
+            event.original.preventDefault();
+            Town.addAutoModDomain(r.get("town.key"), r.get("linkDomain"), r.get("linkDomainAllow"))
+                .done(function() {
+                    r.set("domainErr", null);
+                    r.set("linkDomain", null);
+                    loadTown();
+                })
+                .fail(function(result) {
+                    r.set("domainErr", err(result).message);
+                });
+        },
+        "removeDomain": function(event, domain, allow) {
+            Town.removeAutoModDomain(r.get("town.key"), domain, allow)
+                .done(function() {
+                    r.set("domainErr", null);
+                    loadTown();
+                })
+                .fail(function(result) {
+                    r.set("domainErr", err(result).message);
+                });
+        },
+        "autoModCommentModal": function() {
+            r.set("commentMinUserDays", r.get("town.commentModerator.minUserDays") || 0);
+            r.set("commentMaxNumLinks", r.get("town.commentModerator.maxNumLinks") || 0);
+            $("#autoModCommentModal").modal();
+        },
+        "autoModCommentModalHide": function() {
+            r.set("commentModErr", null);
+            r.set("commentBanUserErr", null);
+            r.set("commentRegexp", null);
+            r.set("commentRegexpReason", null);
+        },
+        "setCommentMod": function(event) {
+            event.original.preventDefault();
+            Town.setCommentMod(r.get("town.key"), Number(r.get("commentMinUserDays")),
+                    Number(r.get("commentMaxNumLinks")))
+                .done(function() {
+                    r.set("commentModErr", null);
+                    loadTown();
+                })
+                .fail(function(result) {
+                    r.set("commentModErr", err(result).message);
+                });
+        },
+        "commentBanUser": function(user) {
+            Town.addCommentModUser(r.get("town.key"), user.username)
+                .done(function() {
+                    r.set("commentBanUserErr", null);
+                    r.set("commentUserBan", null);
+                    loadTown();
+                })
+                .fail(function(result) {
+                    r.set("commentBanUserErr", err(result).message);
+                });
+        },
+        "removeCommentBan": function(event, username) {
+            Town.removeCommentModUser(r.get("town.key"), username)
+                .done(function() {
+                    r.set("commentModErr", null);
+                    loadTown();
+                })
+                .fail(function(result) {
+                    r.set("commentModErr", err(result).message);
+                });
+        },
+        "addCommentRegexp": function(event) {
+            event.original.preventDefault();
+            if (!r.get("commentRegexp") || !r.get("commentRegexpReason")) {
+                r.set("commentModErr", "An expression and a reason are required");
+                return;
+            }
+
+            Town.addCommentModRegexp(r.get("town.key"), r.get("commentRegexp"), r.get("commentRegexpReason"))
+                .done(function() {
+                    r.set("commentModErr", null);
+                    r.set("commentRegexp", null);
+                    r.set("commentRegexpReason", null);
+                    loadTown();
+                })
+                .fail(function(result) {
+                    r.set("commentModErr", err(result).message);
+                });
+        },
+        "removeCommentRegexp": function(event, regexp) {
+            Town.removeCommentModRegexp(r.get("town.key"), regexp)
+                .done(function() {
+                    r.set("commentModErr", null);
+                    loadTown();
+                })
+                .fail(function(result) {
+                    r.set("commentModErr", err(result).message);
+                });
+        },
+        "setAutoModAction": function(event, rule) {
+            Town.setAutoModAction(r.get("town.key"), rule, event.node.value)
+                .done(function() {
+                    r.set("autoModActionErr", null);
+                    loadTown();
+                })
+                .fail(function(result) {
+                    r.set("autoModActionErr", err(result).message);
+                });
+        },
+        "setDuplicates": function(event) {
+            event.original.preventDefault();
+            Town.setAutoModDuplicates(r.get("town.key"), Number(r.get("duplicateThreshold")), r.get("duplicateAction"))
+                .done(function() {
+                    r.set("duplicatesErr", null);
+                    loadTown();
+                })
+                .fail(function(result) {
+                    r.set("duplicatesErr", err(result).message);
+                });
+        },
+        "setSpamThreshold": function(event) {
+            event.original.preventDefault();
+            Town.setAutoModSpamThreshold(r.get("town.key"), r.get("spamThreshold"))
+                .done(function() {
+                    r.set("spamThresholdErr", null);
+                    loadTown();
+                })
+                .fail(function(result) {
+                    r.set("spamThresholdErr", err(result).message);
+                });
+        },
         "setMaxNumLinks": function(event) {
             event.original.preventDefault();
             Town.setAutoModMaxNumLinks(r.get("town.key"), r.get("maxNumLinksValue"))
@@ -473,6 +723,30 @@ $(document).ready(function() {
                     r.set("reasonErr", null);
                 });
         },
+        "testRegexpBan": function(event) {
+            event.original.preventDefault();
+            r.set("regexpErr", null);
+            r.set("dryRun", null);
+
+            if (!r.get("regexpBan") || !r.get("regexpBan").trim()) {
+                r.set("regexpErr", "An expression is required");
+                return;
+            }
+
+            var rules = $.extend({}, r.get("town.autoModerator"));
+            rules.regexpReject = (rules.regexpReject || []).concat([{
+                regexp: r.get("regexpBan"),
+                reason: r.get("regexpReason") || r.get("regexpBan"),
+            }]);
+
+            Town.autoModDryRun(r.get("town.key"), rules)
+                .done(function(result) {
+                    r.set("dryRun", result.data);
+                })
+                .fail(function(result) {
+                    r.set("regexpErr", err(result).message);
+                });
+        },
         "removeExpression": function(event) {
             Town.removeAutoModRegexp(r.get("town.key"), event.context.regexp)
                 .done(function() {
@@ -525,6 +799,22 @@ $(document).ready(function() {
     });
 
     //functions
+    function loadLog(from) {
+        r.set("modLogError", null);
+        Town.logGet(r.get("town.key"), null, from)
+            .done(function(result) {
+                if (from === 0) {
+                    r.set("modLog", result.data);
+                } else {
+                    r.push.apply(r, ["modLog"].concat(result.data));
+                }
+                r.set("modLogMore", result.data.length === 50);
+            })
+            .fail(function(result) {
+                r.set("modLogError", err(result).message);
+            });
+    }
+
     function loadTown() {
         Town.get(r.get("town.key"))
             .done(function(result) {
@@ -0,0 +1,258 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+/* jshint  esnext: true, strict: true */
+import csrf from "./csrf";
+
+export
+var logLimit = 100;
+
+// logQuery builds the query string for filtering the log
+//  filter: {levels: [], since: "", until: "", message: "", fields: {name: value}, fingerprint: ""}
+export
+
+function logQuery(filter, options) {
+    "use strict";
+    var query = {};
+    filter = filter || {};
+    options = options || {};
+
+    if (filter.levels && filter.levels.length) {
+        query.level = filter.levels;
+    }
+    if (filter.since) {
+        query.since = new Date(filter.since).toISOString();
+    }
+    if (filter.until) {
+        query.until = new Date(filter.until).toISOString();
+    }
+    if (filter.message) {
+        query.message = filter.message;
+    }
+    if (filter.fingerprint) {
+        query.fingerprint = filter.fingerprint;
+    }
+    if (filter.fields) {
+        for (var name in filter.fields) {
+            if (filter.fields.hasOwnProperty(name) && filter.fields[name]) {
+                query["field." + name] = filter.fields[name];
+            }
+        }
+    }
+
+    for (var opt in options) {
+        if (options.hasOwnProperty(opt) && options[opt] !== undefined) {
+            query[opt] = options[opt];
+        }
+    }
+
+    return $.param(query, true);
+}
+
+export
+
+function logGet(filter, options) {
+    "use strict";
+    options = options || {};
+
+    return csrf.ajax({
+        type: "GET",
+        dataType: "json",
+        url: "/api/v1/admin/log/?" + logQuery(filter, {
+            limit: options.limit || logLimit,
+            from: options.from || 0,
+        }),
+    });
+}
+
+export
+
+function logGroups(filter) {
+    "use strict";
+    return csrf.ajax({
+        type: "GET",
+        dataType: "json",
+        url: "/api/v1/admin/log/groups/?" + logQuery(filter),
+    });
+}
+
+export
+
+function logExportURL(filter, format) {
+    "use strict";
+    return "/api/v1/admin/log/export/?" + logQuery(filter, {
+        format: format,
+    });
+}
+
+export
+
+function suspendUser(username, until, reason) {
+    "use strict";
+    return csrf.ajax({
+        type: "POST",
+        url: "/api/v1/admin/user/" + username + "/suspension/",
+        data: JSON.stringify({
+            until: until,
+            reason: reason,
+        }),
+    });
+}
+
+export
+
+function banUser(username, reason, hideContent) {
+    "use strict";
+    return csrf.ajax({
+        type: "POST",
+        url: "/api/v1/admin/user/" + username + "/suspension/",
+        data: JSON.stringify({
+            ban: true,
+            hideContent: hideContent,
+            reason: reason,
+        }),
+    });
+}
+
+export
+
+function reinstateUser(username, reason) {
+    "use strict";
+    return csrf.ajax({
+        type: "DELETE",
+        url: "/api/v1/admin/user/" + username + "/suspension/",
+        data: JSON.stringify({
+            reason: reason,
+        }),
+    });
+}
+
+export
+
+function auditGet(username, options) {
+    "use strict";
+    var query = {};
+    options = options || {};
+
+    if (username) {
+        query.user = username;
+    }
+    query.limit = options.limit || 50;
+    query.from = options.from || 0;
+
+    return csrf.ajax({
+        type: "GET",
+        dataType: "json",
+        url: "/api/v1/admin/audit/?" + $.param(query, true),
+    });
+}
+
+export
+
+function staffGet() {
+    "use strict";
+    return csrf.ajax({
+        type: "GET",
+        dataType: "json",
+        url: "/api/v1/admin/role/",
+    });
+}
+
+export
+
+function grantRole(username, role, reason) {
+    "use strict";
+    return csrf.ajax({
+        type: "POST",
+        url: "/api/v1/admin/user/" + username + "/role/",
+        data: JSON.stringify({
+            role: role,
+            reason: reason,
+        }),
+    });
+}
+
+export
+
+function revokeRole(username, role, reason) {
+    "use strict";
+    return csrf.ajax({
+        type: "DELETE",
+        url: "/api/v1/admin/user/" + username + "/role/",
+        data: JSON.stringify({
+            role: role,
+            reason: reason,
+        }),
+    });
+}
+
+export
+
+function modQueueGet(options) {
+    "use strict";
+    options = options || {};
+
+    return csrf.ajax({
+        type: "GET",
+        dataType: "json",
+        url: "/api/v1/admin/queue/?" + $.param({
+            from: options.from || 0,
+            limit: options.limit || 20,
+        }),
+    });
+}
+
+export
+
+function commentQueueGet(options) {
+    "use strict";
+    options = options || {};
+
+    return csrf.ajax({
+        type: "GET",
+        dataType: "json",
+        url: "/api/v1/admin/commentqueue/?" + $.param({
+            from: options.from || 0,
+            limit: options.limit || 20,
+        }),
+    });
+}
+
+export
+
+function blockedDomainsGet() {
+    "use strict";
+    return csrf.ajax({
+        type: "GET",
+        dataType: "json",
+        url: "/api/v1/admin/domain/",
+    });
+}
+
+export
+
+function blockDomain(domain, reason) {
+    "use strict";
+    return csrf.ajax({
+        type: "POST",
+        url: "/api/v1/admin/domain/",
+        data: JSON.stringify({
+            domain: domain,
+            reason: reason,
+        }),
+    });
+}
+
+export
+
+function unblockDomain(domain, reason) {
+    "use strict";
+    return csrf.ajax({
+        type: "DELETE",
+        url: "/api/v1/admin/domain/",
+        data: JSON.stringify({
+            domain: domain,
+            reason: reason,
+        }),
+    });
+}
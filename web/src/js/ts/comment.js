@@ -77,3 +77,70 @@ function reply(postKey, parentKey, comment) {
         }),
     });
 }
+
+export
+
+function remove(postKey, commentKey) {
+    "use strict";
+    return csrf.ajax({
+        type: "DELETE",
+        dataType: "json",
+        url: "/api/v1/post/" + postKey + "/comment/" + commentKey,
+    });
+}
+
+export
+
+function report(postKey, commentKey, reason) {
+    "use strict";
+    return csrf.ajax({
+        type: "PUT",
+        dataType: "json",
+        url: "/api/v1/post/" + postKey + "/comment/" + commentKey,
+        data: JSON.stringify({
+            report: reason,
+        }),
+    });
+}
+
+export
+
+function moderate(postKey, commentKey, reason) {
+    "use strict";
+    return csrf.ajax({
+        type: "PUT",
+        dataType: "json",
+        url: "/api/v1/post/" + postKey + "/comment/" + commentKey,
+        data: JSON.stringify({
+            moderatorReason: reason,
+        }),
+    });
+}
+
+export
+
+function removeModeration(postKey, commentKey) {
+    "use strict";
+    return csrf.ajax({
+        type: "PUT",
+        dataType: "json",
+        url: "/api/v1/post/" + postKey + "/comment/" + commentKey,
+        data: JSON.stringify({
+            removeModeration: true,
+        }),
+    });
+}
+
+export
+
+function approve(postKey, commentKey) {
+    "use strict";
+    return csrf.ajax({
+        type: "PUT",
+        dataType: "json",
+        url: "/api/v1/post/" + postKey + "/comment/" + commentKey,
+        data: JSON.stringify({
+            approve: true,
+        }),
+    });
+}
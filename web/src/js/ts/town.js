@@ -591,6 +591,248 @@ function removeAutoModRegexp(townKey, regexp) {
 
 export
 
+function setCommentMod(townKey, minUserDays, maxNumLinks) {
+    "use strict";
+    return csrf.ajax({
+        type: "PUT",
+        url: "/api/v1/town/" + townKey + "/commentmod",
+        data: JSON.stringify({
+            minUserDays: minUserDays,
+            maxNumLinks: maxNumLinks,
+        }),
+    });
+}
+
+export
+
+function addCommentModUser(townKey, username) {
+    "use strict";
+    return csrf.ajax({
+        type: "POST",
+        url: "/api/v1/town/" + townKey + "/commentmod/user",
+        data: JSON.stringify({
+            user: username,
+        }),
+    });
+}
+
+export
+
+function removeCommentModUser(townKey, username) {
+    "use strict";
+    return csrf.ajax({
+        type: "DELETE",
+        url: "/api/v1/town/" + townKey + "/commentmod/user",
+        data: JSON.stringify({
+            user: username,
+        }),
+    });
+}
+
+export
+
+function addCommentModRegexp(townKey, regexp, reason) {
+    "use strict";
+    return csrf.ajax({
+        type: "POST",
+        url: "/api/v1/town/" + townKey + "/commentmod/regexp",
+        data: JSON.stringify({
+            regexp: regexp,
+            reason: reason,
+        }),
+    });
+}
+
+export
+
+function removeCommentModRegexp(townKey, regexp) {
+    "use strict";
+    return csrf.ajax({
+        type: "DELETE",
+        url: "/api/v1/town/" + townKey + "/commentmod/regexp",
+        data: JSON.stringify({
+            regexp: regexp,
+        }),
+    });
+}
+
+export
+
+function setAutoModDuplicates(townKey, threshold, action) {
+    "use strict";
+    return csrf.ajax({
+        type: "PUT",
+        url: "/api/v1/town/" + townKey + "/automod",
+        data: JSON.stringify({
+            duplicates: {
+                threshold: threshold,
+                action: action,
+            },
+        }),
+    });
+}
+
+export
+
+function setAutoModAction(townKey, rule, action) {
+    "use strict";
+    var actions = {};
+    actions[rule] = action;
+    return csrf.ajax({
+        type: "PUT",
+        url: "/api/v1/town/" + townKey + "/automod",
+        data: JSON.stringify({
+            actions: actions,
+        }),
+    });
+}
+
+export
+
+function setAutoModMinUserRating(townKey, minUserRating) {
+    "use strict";
+
+    var dfr = $.Deferred();
+    if (!minUserRating) {
+        minUserRating = 0;
+    }
+    if (!$.isNumeric(minUserRating)) {
+        dfr.reject("Invalid number");
+        return dfr;
+    }
+
+    return csrf.ajax({
+        type: "PUT",
+        url: "/api/v1/town/" + townKey + "/automod",
+        data: JSON.stringify({
+            minUserRating: Number(minUserRating),
+        }),
+    });
+}
+
+export
+
+function setAutoModSpamThreshold(townKey, threshold) {
+    "use strict";
+
+    var dfr = $.Deferred();
+    if (!threshold) {
+        threshold = 0;
+    }
+    if (!$.isNumeric(threshold)) {
+        dfr.reject("Invalid number");
+        return dfr;
+    }
+
+    return csrf.ajax({
+        type: "PUT",
+        url: "/api/v1/town/" + townKey + "/automod",
+        data: JSON.stringify({
+            spamThreshold: Number(threshold),
+        }),
+    });
+}
+
+export
+
+function addAutoModDomain(townKey, domain, allow) {
+    "use strict";
+    return csrf.ajax({
+        type: "POST",
+        url: "/api/v1/town/" + townKey + "/automod/domain",
+        data: JSON.stringify({
+            domain: domain,
+            allow: allow,
+        }),
+    });
+}
+
+export
+
+function removeAutoModDomain(townKey, domain, allow) {
+    "use strict";
+    return csrf.ajax({
+        type: "DELETE",
+        url: "/api/v1/town/" + townKey + "/automod/domain",
+        data: JSON.stringify({
+            domain: domain,
+            allow: allow,
+        }),
+    });
+}
+
+export
+
+function autoModDryRun(townKey, autoModerator, limit) {
+    "use strict";
+    return csrf.ajax({
+        type: "POST",
+        dataType: "json",
+        url: "/api/v1/town/" + townKey + "/automod/dryrun",
+        data: JSON.stringify({
+            autoModerator: autoModerator,
+            limit: limit,
+        }),
+    });
+}
+
+export
+
+function addAutoModTrusted(townKey, username) {
+    "use strict";
+    return csrf.ajax({
+        type: "POST",
+        url: "/api/v1/town/" + townKey + "/automod/trusted",
+        data: JSON.stringify({
+            user: username,
+        }),
+    });
+}
+
+export
+
+function removeAutoModTrusted(townKey, username) {
+    "use strict";
+    return csrf.ajax({
+        type: "DELETE",
+        url: "/api/v1/town/" + townKey + "/automod/trusted",
+        data: JSON.stringify({
+            user: username,
+        }),
+    });
+}
+
+export
+
+function setAutoModQuota(townKey, category, period, max) {
+    "use strict";
+    return csrf.ajax({
+        type: "POST",
+        url: "/api/v1/town/" + townKey + "/automod/quota",
+        data: JSON.stringify({
+            category: category,
+            period: period,
+            max: max,
+        }),
+    });
+}
+
+export
+
+function removeAutoModQuota(townKey, category, period) {
+    "use strict";
+    return csrf.ajax({
+        type: "DELETE",
+        url: "/api/v1/town/" + townKey + "/automod/quota",
+        data: JSON.stringify({
+            category: category,
+            period: period,
+        }),
+    });
+}
+
+export
+
 function canPost(town, optUser) {
     "use strict";
     if (!town) {
@@ -694,6 +936,93 @@ function search(options) {
     });
 }
 
+//moderation queue
+export
+
+function queueGet(townKey, from, limit) {
+    "use strict";
+    return csrf.ajax({
+        type: "GET",
+        dataType: "json",
+        url: "/api/v1/town/" + townKey + "/queue/?" + $.param({
+            from: from || 0,
+            limit: limit || 20,
+        }),
+    });
+}
+
+export
+
+function commentQueueGet(townKey, from, limit) {
+    "use strict";
+    return csrf.ajax({
+        type: "GET",
+        dataType: "json",
+        url: "/api/v1/town/" + townKey + "/commentqueue/?" + $.param({
+            from: from || 0,
+            limit: limit || 20,
+        }),
+    });
+}
+
+export
+
+function queueApprove(townKey, postKey) {
+    "use strict";
+    return csrf.ajax({
+        type: "PUT",
+        url: "/api/v1/town/" + townKey + "/queue/" + postKey,
+        data: JSON.stringify({
+            approve: true,
+        }),
+    });
+}
+
+export
+
+function queueModerate(townKey, postKey, reason) {
+    "use strict";
+    return csrf.ajax({
+        type: "PUT",
+        url: "/api/v1/town/" + townKey + "/queue/" + postKey,
+        data: JSON.stringify({
+            reason: reason,
+        }),
+    });
+}
+
+//moderation log
+export
+
+function logGet(townKey, action, from, limit) {
+    "use strict";
+    var query = {
+        from: from || 0,
+        limit: limit || 50,
+    };
+    if (action) {
+        query.action = action;
+    }
+
+    return csrf.ajax({
+        type: "GET",
+        dataType: "json",
+        url: "/api/v1/town/" + townKey + "/log/?" + $.param(query),
+    });
+}
+
+export
+
+function logExportURL(townKey, action, format) {
+    "use strict";
+    var query = {
+        format: format,
+    };
+    if (action) {
+        query.action = action;
+    }
+    return "/api/v1/town/" + townKey + "/log/export/?" + $.param(query);
+}
 
 //theme
 var townTextColors = ["#fff", "#333"];
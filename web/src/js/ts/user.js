@@ -88,6 +88,19 @@ function login(username, password, rememberMe) {
 }
 export
 
+function loginTwoFactor(token, code) {
+    "use strict";
+    return csrf.ajax({
+        type: "POST",
+        url: "/api/v1/session/twofactor/",
+        data: JSON.stringify({
+            token: token,
+            code: code,
+        }),
+    });
+}
+export
+
 function logout() {
     "use strict";
     return csrf.ajax({
@@ -449,6 +462,73 @@ function comments(user, options) {
     });
 }
 
+export
+
+function block(username) {
+    "use strict";
+    return csrf.ajax({
+        type: "POST",
+        url: "/api/v1/user/me/blocked/" + username,
+    });
+}
+
+export
+
+function unblock(username) {
+    "use strict";
+    return csrf.ajax({
+        type: "DELETE",
+        url: "/api/v1/user/me/blocked/" + username,
+    });
+}
+
+export
+
+function ratings(user, options) {
+    "use strict";
+    var query = {};
+    options = options || {};
+
+    query.limit = options.limit || 20;
+    query.since = options.since;
+
+    return csrf.ajax({
+        type: "GET",
+        url: "/api/v1/user/" + user + "/rating/?" +
+            $.param(query),
+    });
+}
+
+export
+
+function rate(user, postKey, score, comment) {
+    "use strict";
+    return csrf.ajax({
+        type: "POST",
+        url: "/api/v1/user/" + user + "/rating/",
+        data: JSON.stringify({
+            post: postKey,
+            score: Number(score),
+            comment: comment,
+        }),
+    });
+}
+
+export
+
+function removeRating(user, postKey, optFrom, optReason) {
+    "use strict";
+    return csrf.ajax({
+        type: "DELETE",
+        url: "/api/v1/user/" + user + "/rating/",
+        data: JSON.stringify({
+            post: postKey,
+            from: optFrom,
+            reason: optReason,
+        }),
+    });
+}
+
 
 
 
@@ -522,6 +602,56 @@ function resetPassword(token, newPassword) {
 
 export
 
+function twoFactorBegin() {
+    "use strict";
+    return csrf.ajax({
+        type: "POST",
+        url: "/api/v1/user/me/twofactor/",
+    });
+}
+
+export
+
+function twoFactorEnable(code) {
+    "use strict";
+    return csrf.ajax({
+        type: "PUT",
+        url: "/api/v1/user/me/twofactor/",
+        data: JSON.stringify({
+            code: code,
+        }),
+    });
+}
+
+export
+
+function twoFactorDisable(password, code) {
+    "use strict";
+    return csrf.ajax({
+        type: "DELETE",
+        url: "/api/v1/user/me/twofactor/",
+        data: JSON.stringify({
+            password: password,
+            code: code,
+        }),
+    });
+}
+
+export
+
+function twoFactorRecoveryCodes(code) {
+    "use strict";
+    return csrf.ajax({
+        type: "POST",
+        url: "/api/v1/user/me/twofactor/recovery/",
+        data: JSON.stringify({
+            code: code,
+        }),
+    });
+}
+
+export
+
 function confirmEmail() {
     "use strict";
 
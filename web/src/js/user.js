@@ -113,6 +113,21 @@ $(document).ready(function() {
                         getComments(last.updated);
                     },
                 },
+                ratings: {
+                    pageSize: 15, //ratings per page
+                    page: 1,
+                    data: [],
+                    fetch: function(last) {
+                        getRatings(last.when);
+                    },
+                },
+                isBlocked: function(username) {
+                    var blocked = this.get("currentUser.blocked");
+                    return !!blocked && blocked.indexOf(username) !== -1;
+                },
+                stars: function(score) {
+                    return new Array(score || 0);
+                },
                 processComment: function(comment) {
                     if (!comment) {
                         return;
@@ -239,6 +254,65 @@ $(document).ready(function() {
                     r.set("passwordErr", err(result).message);
                 });
         },
+        "twoFactorModal": function(event) {
+            event.original.preventDefault();
+            $("#twoFactorModal").on("shown.bs.modal", function() {
+                $("#twoFactorCode").focus();
+            });
+
+            r.set("twoFactorSetup", null);
+            r.set("twoFactorCodes", null);
+            r.set("twoFactorCode", "");
+            r.set("twoFactorPassword", "");
+            r.set("twoFactorErr", false);
+
+            if (!r.get("user.twoFactor")) {
+                User.twoFactorBegin()
+                    .done(function(result) {
+                        r.set("twoFactorSetup", result.data);
+                    })
+                    .fail(function(result) {
+                        r.set("twoFactorErr", err(result).message);
+                    });
+            }
+
+            $("#twoFactorModal").modal();
+        },
+        "twoFactorEnable": function(event) {
+            event.original.preventDefault();
+            r.set("twoFactorErr", false);
+            User.twoFactorEnable(r.get("twoFactorCode"))
+                .done(function(result) {
+                    r.set("twoFactorCodes", result.data);
+                    getUser(r.get("user.username"));
+                })
+                .fail(function(result) {
+                    r.set("twoFactorErr", err(result).message);
+                });
+        },
+        "twoFactorDisable": function(event) {
+            event.original.preventDefault();
+            r.set("twoFactorErr", false);
+            User.twoFactorDisable(r.get("twoFactorPassword"), r.get("twoFactorCode"))
+                .done(function() {
+                    getUser(r.get("user.username"));
+                    $("#twoFactorModal").modal("hide");
+                })
+                .fail(function(result) {
+                    r.set("twoFactorErr", err(result).message);
+                });
+        },
+        "twoFactorRecovery": function(event) {
+            event.original.preventDefault();
+            r.set("twoFactorErr", false);
+            User.twoFactorRecoveryCodes(r.get("twoFactorCode"))
+                .done(function(result) {
+                    r.set("twoFactorCodes", result.data);
+                })
+                .fail(function(result) {
+                    r.set("twoFactorErr", err(result).message);
+                });
+        },
         "toggleFacebook": function(event) {
             if (r.get("user.facebookID")) {
                 Facebook.disconnect()
@@ -324,6 +398,30 @@ $(document).ready(function() {
             r.set("tab", "Comments");
             getComments();
         },
+        "block": function(event) {
+            event.original.preventDefault();
+            setBlocked(User.block(r.get("user.username")));
+        },
+        "unblock": function(event) {
+            event.original.preventDefault();
+            setBlocked(User.unblock(r.get("user.username")));
+        },
+        "ratings": function(event) {
+            r.set("tab", "Ratings");
+            getRatings();
+        },
+        "removeRating": function(event, i) {
+            event.original.preventDefault();
+            var rating = r.get("ratings.data")[i];
+            User.removeRating(rating.username, rating.post)
+                .done(function() {
+                    getUser(r.get("user.username"));
+                    getRatings();
+                })
+                .fail(function(result) {
+                    r.set("ratingsErr", err(result).message);
+                });
+        },
         "towns": function(event) {
             r.set("tab", "Towns");
             r.set("tLoading", true);
@@ -529,6 +627,9 @@ $(document).ready(function() {
     } else if (hash == "comments") {
         $('.nav-tabs a[href="#comments"]').tab('show');
         r.fire("comments");
+    } else if (hash == "ratings") {
+        $('.nav-tabs a[href="#ratings"]').tab('show');
+        r.fire("ratings");
     }
 
 
@@ -701,6 +802,45 @@ $(document).ready(function() {
             });
     }
 
+    function setBlocked(call) {
+        r.set("blockErr", null);
+        call.done(function() {
+                User.get()
+                    .done(function(result) {
+                        r.set("currentUser", result.data);
+                    });
+            })
+            .fail(function(result) {
+                r.set("blockErr", err(result).message);
+            });
+    }
+
+    function getRatings(since) {
+        r.set("rLoading", true);
+        r.set("ratingsErr", null);
+        if (!since) {
+            r.set("ratings.data", []);
+            r.set("ratings.page", 1);
+        }
+
+        User.ratings(r.get("user.username"), {
+                since: since,
+                limit: 50,
+            })
+            .done(function(result) {
+                if (result.data) {
+                    r.set("ratings.data", r.get("ratings.data").concat(result.data));
+                }
+            })
+            .fail(function(result) {
+                r.set("ratings.data", []);
+                r.set("ratingsErr", err(result).message);
+            })
+            .always(function() {
+                r.set("rLoading", false);
+            });
+    }
+
     function toggleSetting(setting) {
         var data = {
             vertag: r.get("user.vertag"),
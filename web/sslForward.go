@@ -31,8 +31,9 @@ func startSSLForwarder(c *Config) {
 		}
 
 		server.Addr = ":http"
+		servers.add(server)
 		err := server.ListenAndServe()
-		if err != nil {
+		if err != nil && err != http.ErrServerClosed {
 			logrus.WithField("error", err).Error("Error starting ssl forwarding server")
 		}
 	}(c)
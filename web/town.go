@@ -10,7 +10,6 @@ import (
 	"strings"
 	"time"
 
-	log "git.townsourced.com/townsourced/logrus"
 	"github.com/timshannon/townsourced/app"
 	"github.com/timshannon/townsourced/data"
 	"github.com/timshannon/townsourced/data/private"
@@ -107,7 +106,7 @@ func townTemplate(w http.ResponseWriter, r *http.Request, c context) {
 		FacebookAppID: private.FacebookAppID,
 	})
 	if err != nil {
-		log.Errorf("Error executing town template: %s", err)
+		requestLog(w).Errorf("Error executing town template: %s", err)
 	}
 }
 
@@ -140,7 +139,7 @@ func townSettingsTemplate(w http.ResponseWriter, r *http.Request, c context) {
 
 	err = w.(*templateWriter).execute("TOWN", town)
 	if err != nil {
-		log.Errorf("Error executing townSettings template: %s", err)
+		requestLog(w).Errorf("Error executing townSettings template: %s", err)
 	}
 
 }
@@ -215,7 +214,7 @@ func townPostNew(w http.ResponseWriter, r *http.Request, c context) {
 	}
 
 	//Rate limit new towns
-	if errHandled(app.AttemptRequest(string(c.session.UserKey), townNewRequestType), w, r, c) {
+	if errHandled(app.AttemptRequest(r.Context(), string(c.session.UserKey), townNewRequestType), w, r, c) {
 		return
 	}
 
@@ -833,7 +832,7 @@ func newtownTemplate(w http.ResponseWriter, r *http.Request, c context) {
 		User:             u,
 	})
 	if err != nil {
-		log.Errorf("Error executing newtown template: %s", err)
+		requestLog(w).Errorf("Error executing newtown template: %s", err)
 	}
 }
 
@@ -863,7 +862,7 @@ func townsearchTemplate(w http.ResponseWriter, r *http.Request, c context) {
 		Location:         location,
 	})
 	if err != nil {
-		log.Errorf("Error executing townSearch template: %s", err)
+		requestLog(w).Errorf("Error executing townSearch template: %s", err)
 	}
 
 }
@@ -15,6 +15,20 @@ type townAutoModInput struct {
 	User        *data.Key `json:"user,omitempty"`
 	Regexp      *string   `json:"regexp,omitempty"`
 	Reason      *string   `json:"reason,omitempty"`
+	Period      *string   `json:"period,omitempty"`
+	Max         *uint     `json:"max,omitempty"`
+	Domain      *string   `json:"domain,omitempty"`
+	Allow       bool      `json:"allow,omitempty"` // the domain is for the allowed list instead of the blocked list
+
+	Duplicates    *app.DuplicateRule `json:"duplicates,omitempty"`
+	Actions       map[string]string  `json:"actions,omitempty"`       // reject or hold, by auto moderator rule type
+	SpamThreshold *float64           `json:"spamThreshold,omitempty"` // 0 turns off the spam classifier
+	MinUserRating *float64           `json:"minUserRating,omitempty"` // 0 turns off the minimum user rating
+}
+
+type townAutoModDryRunInput struct {
+	AutoModerator *app.AutoModerator `json:"autoModerator,omitempty"` // proposed rules, defaults to the current ones
+	Limit         int                `json:"limit,omitempty"`         // number of recent posts to run against
 }
 
 func townPostAutoModCategory(w http.ResponseWriter, r *http.Request, c context) {
@@ -140,12 +154,37 @@ func townPutAutoMod(w http.ResponseWriter, r *http.Request, c context) {
 		}
 	}
 
+	if input.MinUserRating != nil {
+		if errHandled(town.SetAutoModMinUserRating(who, *input.MinUserRating), w, r, c) {
+			return
+		}
+	}
+
 	if input.MaxNumLinks != nil {
 		if errHandled(town.SetAutoModMaxNumLinks(who, *input.MaxNumLinks), w, r, c) {
 			return
 		}
 	}
 
+	if input.Duplicates != nil {
+		err = town.SetAutoModDuplicates(who, input.Duplicates.Threshold, input.Duplicates.Action)
+		if errHandled(err, w, r, c) {
+			return
+		}
+	}
+
+	if input.SpamThreshold != nil {
+		if errHandled(town.SetAutoModSpamThreshold(who, *input.SpamThreshold), w, r, c) {
+			return
+		}
+	}
+
+	for rule, action := range input.Actions {
+		if errHandled(town.SetAutoModAction(who, rule, action), w, r, c) {
+			return
+		}
+	}
+
 	if errHandled(town.Update(), w, r, c) {
 		return
 	}
@@ -334,3 +373,326 @@ func townDeleteAutoModRegexp(w http.ResponseWriter, r *http.Request, c context)
 		Status: statusSuccess,
 	})
 }
+
+func townPostAutoModTrusted(w http.ResponseWriter, r *http.Request, c context) {
+	if c.session == nil {
+		unauthorized(w, r)
+		return
+	}
+
+	key := data.NewKey(c.params.ByName("town"))
+	town, err := app.TownGet(key)
+	if err == app.ErrTownNotFound {
+		four04(w, r)
+		return
+	}
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	input := &townAutoModInput{}
+	err = parseInput(r, input)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	if input.User == nil {
+		errHandled(fail.New("The field user is required", input), w, r, c)
+		return
+	}
+
+	who, err := c.session.User()
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	if errHandled(town.AddAutoModTrusted(who, *input.User), w, r, c) {
+		return
+	}
+
+	if errHandled(town.Update(), w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+	})
+}
+
+func townDeleteAutoModTrusted(w http.ResponseWriter, r *http.Request, c context) {
+	if c.session == nil {
+		unauthorized(w, r)
+		return
+	}
+
+	key := data.NewKey(c.params.ByName("town"))
+	town, err := app.TownGet(key)
+	if err == app.ErrTownNotFound {
+		four04(w, r)
+		return
+	}
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	input := &townAutoModInput{}
+	err = parseInput(r, input)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	if input.User == nil {
+		errHandled(fail.New("The field user is required", input), w, r, c)
+		return
+	}
+
+	who, err := c.session.User()
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	if errHandled(town.RemoveAutoModTrusted(who, *input.User), w, r, c) {
+		return
+	}
+
+	if errHandled(town.Update(), w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+	})
+}
+
+func townPostAutoModQuota(w http.ResponseWriter, r *http.Request, c context) {
+	if c.session == nil {
+		unauthorized(w, r)
+		return
+	}
+
+	key := data.NewKey(c.params.ByName("town"))
+	town, err := app.TownGet(key)
+	if err == app.ErrTownNotFound {
+		four04(w, r)
+		return
+	}
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	input := &townAutoModInput{}
+	err = parseInput(r, input)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	if input.Period == nil {
+		errHandled(fail.New("The field period is required", input), w, r, c)
+		return
+	}
+
+	if input.Max == nil {
+		errHandled(fail.New("The field max is required", input), w, r, c)
+		return
+	}
+
+	category := ""
+	if input.Category != nil {
+		category = *input.Category
+	}
+
+	who, err := c.session.User()
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	if errHandled(town.SetAutoModQuota(who, category, *input.Period, *input.Max), w, r, c) {
+		return
+	}
+
+	if errHandled(town.Update(), w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+	})
+}
+
+func townDeleteAutoModQuota(w http.ResponseWriter, r *http.Request, c context) {
+	if c.session == nil {
+		unauthorized(w, r)
+		return
+	}
+
+	key := data.NewKey(c.params.ByName("town"))
+	town, err := app.TownGet(key)
+	if err == app.ErrTownNotFound {
+		four04(w, r)
+		return
+	}
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	input := &townAutoModInput{}
+	err = parseInput(r, input)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	if input.Period == nil {
+		errHandled(fail.New("The field period is required", input), w, r, c)
+		return
+	}
+
+	category := ""
+	if input.Category != nil {
+		category = *input.Category
+	}
+
+	who, err := c.session.User()
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	if errHandled(town.RemoveAutoModQuota(who, category, *input.Period), w, r, c) {
+		return
+	}
+
+	if errHandled(town.Update(), w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+	})
+}
+
+func townPostAutoModDryRun(w http.ResponseWriter, r *http.Request, c context) {
+	if c.session == nil {
+		unauthorized(w, r)
+		return
+	}
+
+	key := data.NewKey(c.params.ByName("town"))
+	town, err := app.TownGet(key)
+	if err == app.ErrTownNotFound {
+		four04(w, r)
+		return
+	}
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	input := &townAutoModDryRunInput{}
+	err = parseInput(r, input)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	who, err := c.session.User()
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	run, err := town.AutoModDryRun(r.Context(), who, input.AutoModerator, input.Limit)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+		Data:   run,
+	})
+}
+
+func townPostAutoModDomain(w http.ResponseWriter, r *http.Request, c context) {
+	if c.session == nil {
+		unauthorized(w, r)
+		return
+	}
+
+	key := data.NewKey(c.params.ByName("town"))
+	town, err := app.TownGet(key)
+	if err == app.ErrTownNotFound {
+		four04(w, r)
+		return
+	}
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	input := &townAutoModInput{}
+	err = parseInput(r, input)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	if input.Domain == nil {
+		errHandled(fail.New("The field domain is required", input), w, r, c)
+		return
+	}
+
+	who, err := c.session.User()
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	if errHandled(town.AddAutoModDomain(who, *input.Domain, input.Allow), w, r, c) {
+		return
+	}
+
+	if errHandled(town.Update(), w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+	})
+}
+
+func townDeleteAutoModDomain(w http.ResponseWriter, r *http.Request, c context) {
+	if c.session == nil {
+		unauthorized(w, r)
+		return
+	}
+
+	key := data.NewKey(c.params.ByName("town"))
+	town, err := app.TownGet(key)
+	if err == app.ErrTownNotFound {
+		four04(w, r)
+		return
+	}
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	input := &townAutoModInput{}
+	err = parseInput(r, input)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	if input.Domain == nil {
+		errHandled(fail.New("The field domain is required", input), w, r, c)
+		return
+	}
+
+	who, err := c.session.User()
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	if errHandled(town.RemoveAutoModDomain(who, *input.Domain, input.Allow), w, r, c) {
+		return
+	}
+
+	if errHandled(town.Update(), w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+	})
+}
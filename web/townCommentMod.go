@@ -0,0 +1,240 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package web
+
+import (
+	"net/http"
+
+	"github.com/timshannon/townsourced/app"
+	"github.com/timshannon/townsourced/data"
+	"github.com/timshannon/townsourced/fail"
+)
+
+func townPutCommentMod(w http.ResponseWriter, r *http.Request, c context) {
+	if c.session == nil {
+		unauthorized(w, r)
+		return
+	}
+
+	key := data.NewKey(c.params.ByName("town"))
+	town, err := app.TownGet(key)
+	if err == app.ErrTownNotFound {
+		four04(w, r)
+		return
+	}
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	input := &townAutoModInput{}
+	err = parseInput(r, input)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	who, err := c.session.User()
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	if input.MinUserDays != nil {
+		if errHandled(town.SetCommentModMinUserDays(who, *input.MinUserDays), w, r, c) {
+			return
+		}
+	}
+
+	if input.MaxNumLinks != nil {
+		if errHandled(town.SetCommentModMaxNumLinks(who, *input.MaxNumLinks), w, r, c) {
+			return
+		}
+	}
+
+	if errHandled(town.Update(), w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+	})
+}
+
+func townPostCommentModUser(w http.ResponseWriter, r *http.Request, c context) {
+	if c.session == nil {
+		unauthorized(w, r)
+		return
+	}
+
+	key := data.NewKey(c.params.ByName("town"))
+	town, err := app.TownGet(key)
+	if err == app.ErrTownNotFound {
+		four04(w, r)
+		return
+	}
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	input := &townAutoModInput{}
+	err = parseInput(r, input)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	if input.User == nil {
+		errHandled(fail.New("The field user is required", input), w, r, c)
+		return
+	}
+
+	who, err := c.session.User()
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	if errHandled(town.AddCommentModUser(who, *input.User), w, r, c) {
+		return
+	}
+
+	if errHandled(town.Update(), w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+	})
+}
+
+func townDeleteCommentModUser(w http.ResponseWriter, r *http.Request, c context) {
+	if c.session == nil {
+		unauthorized(w, r)
+		return
+	}
+
+	key := data.NewKey(c.params.ByName("town"))
+	town, err := app.TownGet(key)
+	if err == app.ErrTownNotFound {
+		four04(w, r)
+		return
+	}
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	input := &townAutoModInput{}
+	err = parseInput(r, input)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	if input.User == nil {
+		errHandled(fail.New("The field user is required", input), w, r, c)
+		return
+	}
+
+	who, err := c.session.User()
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	if errHandled(town.RemoveCommentModUser(who, *input.User), w, r, c) {
+		return
+	}
+
+	if errHandled(town.Update(), w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+	})
+}
+
+func townPostCommentModRegexp(w http.ResponseWriter, r *http.Request, c context) {
+	if c.session == nil {
+		unauthorized(w, r)
+		return
+	}
+
+	key := data.NewKey(c.params.ByName("town"))
+	town, err := app.TownGet(key)
+	if err == app.ErrTownNotFound {
+		four04(w, r)
+		return
+	}
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	input := &townAutoModInput{}
+	err = parseInput(r, input)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	if input.Regexp == nil || input.Reason == nil {
+		errHandled(fail.New("The fields regexp and reason are required", input), w, r, c)
+		return
+	}
+
+	who, err := c.session.User()
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	if errHandled(town.AddCommentModRegexp(who, *input.Regexp, *input.Reason), w, r, c) {
+		return
+	}
+
+	if errHandled(town.Update(), w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+	})
+}
+
+func townDeleteCommentModRegexp(w http.ResponseWriter, r *http.Request, c context) {
+	if c.session == nil {
+		unauthorized(w, r)
+		return
+	}
+
+	key := data.NewKey(c.params.ByName("town"))
+	town, err := app.TownGet(key)
+	if err == app.ErrTownNotFound {
+		four04(w, r)
+		return
+	}
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	input := &townAutoModInput{}
+	err = parseInput(r, input)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	if input.Regexp == nil {
+		errHandled(fail.New("The field regexp is required", input), w, r, c)
+		return
+	}
+
+	who, err := c.session.User()
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	if errHandled(town.RemoveCommentModRegexp(who, *input.Regexp), w, r, c) {
+		return
+	}
+
+	if errHandled(town.Update(), w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+	})
+}
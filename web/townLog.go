@@ -0,0 +1,152 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package web
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/timshannon/townsourced/app"
+	"github.com/timshannon/townsourced/data"
+	"github.com/timshannon/townsourced/fail"
+)
+
+const townLogLimitDefault = 50
+
+// townLogTown returns the town in the route's town parameter along with the current session's user, otherwise it
+// handles the response and returns nil
+func townLogTown(w http.ResponseWriter, r *http.Request, c context) (*app.Town, *app.User) {
+	if c.session == nil {
+		unauthorized(w, r)
+		return nil, nil
+	}
+
+	town, err := app.TownGet(data.NewKey(c.params.ByName("town")))
+	if err == app.ErrTownNotFound {
+		four04(w, r)
+		return nil, nil
+	}
+	if errHandled(err, w, r, c) {
+		return nil, nil
+	}
+
+	who, err := c.session.User()
+	if errHandled(err, w, r, c) {
+		return nil, nil
+	}
+
+	return town, who
+}
+
+func townGetLog(w http.ResponseWriter, r *http.Request, c context) {
+	// ?action=<action>&from=<from>&limit=50
+	town, who := townLogTown(w, r, c)
+	if town == nil {
+		return
+	}
+
+	values := r.URL.Query()
+
+	limit, err := strconv.Atoi(values.Get("limit"))
+	if err != nil {
+		limit = townLogLimitDefault
+	}
+
+	from, err := strconv.Atoi(values.Get("from"))
+	if err != nil {
+		from = 0
+	}
+
+	entries, err := town.Log(who, values.Get("action"), from, limit)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+		Data:   entries,
+	})
+}
+
+func townGetLogExport(w http.ResponseWriter, r *http.Request, c context) {
+	// ?action=<action>&format=<json|csv>
+	town, who := townLogTown(w, r, c)
+	if town == nil {
+		return
+	}
+
+	values := r.URL.Query()
+
+	format := values.Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		errHandled(fail.New("Invalid export format, must be json or csv", format), w, r, c)
+		return
+	}
+
+	entries, err := town.LogExport(who, values.Get("action"))
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	filename := fmt.Sprintf("townsourced-%s-moderation-log-%s.%s", town.Key,
+		time.Now().UTC().Format("20060102T150405Z"), format)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(entries)
+		if err != nil {
+			requestLog(w).Errorf("Error exporting town moderation log as json: %s", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	cw := csv.NewWriter(w)
+	err = cw.Write([]string{"when", "action", "who", "target", "before", "after", "reason"})
+	if err != nil {
+		requestLog(w).Errorf("Error exporting town moderation log as csv: %s", err)
+		return
+	}
+
+	for i := range entries {
+		err = cw.Write([]string{
+			entries[i].When.Format(time.RFC3339Nano),
+			csvCell(entries[i].Action),
+			csvCell(string(entries[i].Who)),
+			csvCell(entries[i].Target),
+			csvCell(csvJSON(entries[i].Before)),
+			csvCell(csvJSON(entries[i].After)),
+			csvCell(entries[i].Reason),
+		})
+		if err != nil {
+			requestLog(w).Errorf("Error exporting town moderation log as csv: %s", err)
+			return
+		}
+	}
+
+	cw.Flush()
+	if cw.Error() != nil {
+		requestLog(w).Errorf("Error exporting town moderation log as csv: %s", cw.Error())
+	}
+}
+
+// csvJSON returns the value as json for writing into a csv column, or an empty string if it's not set
+func csvJSON(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	buf, err := json.Marshal(value)
+	if err != nil {
+		return err.Error()
+	}
+	return string(buf)
+}
@@ -0,0 +1,165 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package web
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/timshannon/townsourced/app"
+	"github.com/timshannon/townsourced/data"
+	"github.com/timshannon/townsourced/fail"
+)
+
+const townQueueLimitDefault = 20
+
+type townQueueInput struct {
+	Approve bool    `json:"approve,omitempty"`
+	Reason  *string `json:"reason,omitempty"` // moderates the post
+}
+
+func townGetQueue(w http.ResponseWriter, r *http.Request, c context) {
+	// ?from=<from>&limit=20
+	if c.session == nil {
+		unauthorized(w, r)
+		return
+	}
+
+	town, err := app.TownGet(data.NewKey(c.params.ByName("town")))
+	if err == app.ErrTownNotFound {
+		four04(w, r)
+		return
+	}
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	who, err := c.session.User()
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	values := r.URL.Query()
+
+	limit, err := strconv.Atoi(values.Get("limit"))
+	if err != nil {
+		limit = townQueueLimitDefault
+	}
+
+	from, err := strconv.Atoi(values.Get("from"))
+	if err != nil {
+		from = 0
+	}
+
+	entries, err := town.ModQueue(who, from, limit)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+		Data:   entries,
+	})
+}
+
+func townGetCommentQueue(w http.ResponseWriter, r *http.Request, c context) {
+	// ?from=<from>&limit=20
+	if c.session == nil {
+		unauthorized(w, r)
+		return
+	}
+
+	town, err := app.TownGet(data.NewKey(c.params.ByName("town")))
+	if err == app.ErrTownNotFound {
+		four04(w, r)
+		return
+	}
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	who, err := c.session.User()
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	values := r.URL.Query()
+
+	limit, err := strconv.Atoi(values.Get("limit"))
+	if err != nil {
+		limit = townQueueLimitDefault
+	}
+
+	from, err := strconv.Atoi(values.Get("from"))
+	if err != nil {
+		from = 0
+	}
+
+	entries, err := town.CommentQueue(who, from, limit)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+		Data:   entries,
+	})
+}
+
+func townPutQueue(w http.ResponseWriter, r *http.Request, c context) {
+	if c.session == nil {
+		unauthorized(w, r)
+		return
+	}
+
+	town, err := app.TownGet(data.NewKey(c.params.ByName("town")))
+	if err == app.ErrTownNotFound {
+		four04(w, r)
+		return
+	}
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	post, err := app.PostGet(data.ToUUID(c.params.ByName("post")))
+	if err == app.ErrPostNotFound {
+		four04(w, r)
+		return
+	}
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	input := &townQueueInput{}
+	err = parseInput(r, input)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	who, err := c.session.User()
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	if input.Approve {
+		err = post.Approve(town, who)
+	} else {
+		if input.Reason == nil {
+			errHandled(fail.New("Either approve or reason is required", input), w, r, c)
+			return
+		}
+		err = post.Moderate(town, who, *input.Reason)
+	}
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	if errHandled(post.Update(), w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+	})
+}
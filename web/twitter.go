@@ -34,13 +34,7 @@ func twitterGet(w http.ResponseWriter, r *http.Request, c context) {
 			return
 		}
 
-		if errHandled(setSessionCookie(w, r, u, true), w, r, c) {
-			return
-		}
-
-		respondJsend(w, &JSend{
-			Status: statusSuccess,
-		})
+		respondLogin(w, r, c, u, true)
 		return
 	}
 
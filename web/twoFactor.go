@@ -0,0 +1,150 @@
+// Townsourced
+// Copyright 2016 Tim Shannon. All rights reserved.
+
+package web
+
+import (
+	"net/http"
+
+	"github.com/timshannon/townsourced/app"
+)
+
+type twoFactorInput struct {
+	Code     string `json:"code,omitempty"`
+	Password string `json:"password,omitempty"` // required to disable two-factor authentication
+}
+
+// twoFactorUser gets the current user for managing their own two-factor authentication settings
+// if user is nil, then an error has already been properly written to w
+func twoFactorUser(w http.ResponseWriter, r *http.Request, c context) *app.User {
+	if c.params.ByName("user") != app.UsernameSelf {
+		four04(w, r)
+		return nil
+	}
+
+	if c.session == nil {
+		unauthorized(w, r)
+		return nil
+	}
+
+	u, err := c.session.User()
+	if errHandled(err, w, r, c) {
+		return nil
+	}
+
+	u.SetVer(u.Ver())
+	return u
+}
+
+// start two-factor authentication setup
+func userPostTwoFactor(w http.ResponseWriter, r *http.Request, c context) {
+	u := twoFactorUser(w, r, c)
+	if u == nil {
+		return
+	}
+
+	setup, err := u.TwoFactorBegin()
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	if errHandled(u.Update(), w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+		Data:   setup,
+	})
+}
+
+// verify a code and enable two-factor authentication
+func userPutTwoFactor(w http.ResponseWriter, r *http.Request, c context) {
+	u := twoFactorUser(w, r, c)
+	if u == nil {
+		return
+	}
+
+	input := &twoFactorInput{}
+	if errHandled(parseInput(r, input), w, r, c) {
+		return
+	}
+
+	if errHandled(app.AttemptRequest(r.Context(), string(c.session.UserKey), userTwoFactorRequest), w, r, c) {
+		return
+	}
+
+	codes, err := u.TwoFactorEnable(input.Code)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	if errHandled(u.Update(), w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+		Data:   codes,
+	})
+}
+
+// disable two-factor authentication
+func userDeleteTwoFactor(w http.ResponseWriter, r *http.Request, c context) {
+	u := twoFactorUser(w, r, c)
+	if u == nil {
+		return
+	}
+
+	input := &twoFactorInput{}
+	if errHandled(parseInput(r, input), w, r, c) {
+		return
+	}
+
+	if errHandled(app.AttemptRequest(r.Context(), string(c.session.UserKey), userTwoFactorRequest), w, r, c) {
+		return
+	}
+
+	if errHandled(u.TwoFactorDisable(input.Password, input.Code), w, r, c) {
+		return
+	}
+
+	if errHandled(u.Update(), w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+	})
+}
+
+// replace two-factor authentication recovery codes
+func userPostTwoFactorRecovery(w http.ResponseWriter, r *http.Request, c context) {
+	u := twoFactorUser(w, r, c)
+	if u == nil {
+		return
+	}
+
+	input := &twoFactorInput{}
+	if errHandled(parseInput(r, input), w, r, c) {
+		return
+	}
+
+	if errHandled(app.AttemptRequest(r.Context(), string(c.session.UserKey), userTwoFactorRequest), w, r, c) {
+		return
+	}
+
+	codes, err := u.TwoFactorRecoveryCodes(input.Code)
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	if errHandled(u.Update(), w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+		Data:   codes,
+	})
+}
@@ -8,7 +8,6 @@ import (
 	"strconv"
 	"time"
 
-	log "git.townsourced.com/townsourced/logrus"
 	"github.com/timshannon/townsourced/app"
 	"github.com/timshannon/townsourced/data"
 	"github.com/timshannon/townsourced/fail"
@@ -51,7 +50,7 @@ func userTemplate(w http.ResponseWriter, r *http.Request, c context) {
 		Self bool `json:"self"`
 	}{User: u, Self: self})
 	if err != nil {
-		log.Errorf("Error executing user template: %s", err)
+		requestLog(w).Errorf("Error executing user template: %s", err)
 	}
 }
 
@@ -109,7 +108,7 @@ func userGet(w http.ResponseWriter, r *http.Request, c context) {
 }
 
 func userPost(w http.ResponseWriter, r *http.Request, c context) {
-	if errHandled(app.AttemptRequest(ipAddress(r), app.RequestType{}), w, r, c) {
+	if errHandled(app.AttemptRequest(r.Context(), ipAddress(r), app.RequestType{}), w, r, c) {
 		return
 	}
 
@@ -119,7 +118,7 @@ func userPost(w http.ResponseWriter, r *http.Request, c context) {
 			if errHandled(err, w, r, c) {
 				return
 			}
-			if !u.Admin {
+			if !u.HasRole(app.RoleAdmin) {
 				// in demo mode, only admin accounts can create new users
 				errHandled(fail.New("Signups are currently disabled.  Contact info@townsourced.com "+
 					"for more information"), w, r, c)
@@ -158,7 +157,7 @@ func userPost(w http.ResponseWriter, r *http.Request, c context) {
 		return
 	}
 
-	if errHandled(app.AttemptRequest(ipAddress(r), userNewRequestType), w, r, c) {
+	if errHandled(app.AttemptRequest(r.Context(), ipAddress(r), userNewRequestType), w, r, c) {
 		return
 	}
 
@@ -647,6 +646,96 @@ func userDeleteSavedPost(w http.ResponseWriter, r *http.Request, c context) {
 
 }
 
+func userGetBlocked(w http.ResponseWriter, r *http.Request, c context) {
+	if c.params.ByName("user") != app.UsernameSelf {
+		four04(w, r)
+		return
+	}
+
+	if c.session == nil {
+		unauthorized(w, r)
+		return
+	}
+
+	u, err := c.session.User()
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	blocked := u.Blocked
+	if blocked == nil {
+		blocked = []data.Key{}
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+		Data:   blocked,
+	})
+}
+
+func userPostBlocked(w http.ResponseWriter, r *http.Request, c context) {
+	if c.params.ByName("user") != app.UsernameSelf {
+		four04(w, r)
+		return
+	}
+
+	if c.session == nil {
+		unauthorized(w, r)
+		return
+	}
+
+	u, err := c.session.User()
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	u.SetVer(u.Ver())
+
+	err = u.Block(data.NewKey(c.params.ByName("blocked")))
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	err = u.Update()
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+	})
+}
+
+func userDeleteBlocked(w http.ResponseWriter, r *http.Request, c context) {
+	if c.params.ByName("user") != app.UsernameSelf {
+		four04(w, r)
+		return
+	}
+
+	if c.session == nil {
+		unauthorized(w, r)
+		return
+	}
+
+	u, err := c.session.User()
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	u.SetVer(u.Ver())
+
+	u.Unblock(data.NewKey(c.params.ByName("blocked")))
+
+	err = u.Update()
+	if errHandled(err, w, r, c) {
+		return
+	}
+
+	respondJsend(w, &JSend{
+		Status: statusSuccess,
+	})
+}
+
 func userGetComments(w http.ResponseWriter, r *http.Request, c context) {
 	// ?since=<since>
 	// ?limit=<limit>
@@ -774,6 +863,6 @@ func welcomeTemplate(w http.ResponseWriter, r *http.Request, c context) {
 	})
 
 	if err != nil {
-		log.Errorf("Error executing WELCOME template: %s", err)
+		requestLog(w).Errorf("Error executing WELCOME template: %s", err)
 	}
 }